@@ -0,0 +1,32 @@
+// Package simpus embeds the web assets so the server can run from a single
+// binary, independent of the working directory.
+package simpus
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+//go:embed static
+var staticFiles embed.FS
+
+// Templates returns the embedded templates/ directory.
+func Templates() fs.FS {
+	return mustSub(templateFiles, "templates")
+}
+
+// Static returns the embedded static/ directory.
+func Static() fs.FS {
+	return mustSub(staticFiles, "static")
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
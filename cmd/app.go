@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+
+	"simpus/config"
+	"simpus/database"
+	"simpus/internal/app/auth"
+	"simpus/internal/app/backups"
+	"simpus/internal/app/books"
+	"simpus/internal/app/borrowings"
+	"simpus/internal/app/branches"
+	"simpus/internal/app/holds"
+	"simpus/internal/app/members"
+	"simpus/internal/app/notifications"
+	"simpus/internal/app/transfers"
+	"simpus/internal/credentials"
+	"simpus/internal/mailer"
+	"simpus/internal/search"
+	"simpus/internal/storage"
+)
+
+// app holds the database and the services shared by the web server and the
+// administrative commands, so both go through the same business rules.
+type app struct {
+	cfg     *config.Config
+	db      *sql.DB
+	dialect database.Dialect
+	files   storage.Storage // uploaded covers, served under /media/
+
+	authService     *auth.Service
+	bookService     *books.Service
+	branchService   *branches.Service
+	memberService   *members.Service
+	borrowService   *borrowings.Service
+	holdService     *holds.Service
+	transferService *transfers.Service
+	notifService    *notifications.Service
+	backupService   *backups.Service
+}
+
+func newApp(cfg *config.Config) (*app, error) {
+	// Connect to database
+	db, dialect, err := database.Connect(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+
+	// Initialize repositories
+	// Auth
+	userRepo := auth.NewRepository(db)
+	resetRepo := auth.NewResetRepository(db)
+	identityRepo := auth.NewIdentityRepository(db)
+
+	// Books
+	categoryRepo := books.NewCategoryRepository(db)
+	authorRepo := books.NewAuthorRepository(db)
+	bookRepo := books.NewBookRepository(db)
+	workRepo := books.NewWorkRepository(db)
+
+	// Branches
+	branchRepo := branches.NewRepository(db)
+
+	// Members
+	memberRepo := members.NewRepository(db)
+
+	// Borrowings
+	borrowRepo := borrowings.NewRepository(db, dialect)
+
+	// Holds and transfers
+	holdRepo := holds.NewRepository(db)
+	transferRepo := transfers.NewRepository(db)
+
+	// Notifications
+	notifRepo := notifications.NewRepository(db)
+
+	// Mail
+	smtpMailer := mailer.NewSMTPMailer(cfg.SMTP)
+
+	// Credentials
+	passwordHistoryRepo := credentials.NewHistoryRepository(db)
+	credentialManager, err := credentials.NewManager(cfg.Password, passwordHistoryRepo)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialize password policy: %w", err)
+	}
+
+	// Uploaded files
+	var files storage.Storage = storage.NewLocal(cfg.Storage.UploadDir)
+	if cfg.Storage.Backend == "s3" {
+		s3 := cfg.Storage.S3
+		files, err = storage.NewS3(storage.S3Options{
+			Endpoint:  s3.Endpoint,
+			Region:    s3.Region,
+			Bucket:    s3.Bucket,
+			AccessKey: s3.AccessKey,
+			SecretKey: s3.SecretKey,
+			PathStyle: s3.PathStyle,
+		})
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("initialize file storage: %w", err)
+		}
+	}
+
+	// Initialize services
+	authService := auth.NewService(userRepo, memberRepo, resetRepo, identityRepo, credentialManager, smtpMailer, cfg)
+	if cfg.OIDC.Enabled {
+		authService.UseOIDC(auth.NewOIDCClient(cfg.OIDC))
+	}
+	if cfg.LDAP.Enabled {
+		authService.UseDirectory(auth.NewLDAPAuthenticator(cfg.LDAP))
+	}
+
+	bookService := books.NewService(bookRepo, categoryRepo, authorRepo, workRepo, search.NewMemory())
+	bookService.UseCovers(files)
+	switch cfg.Lookup.Provider {
+	case "openlibrary":
+		bookService.UseLookup(books.NewOpenLibrary(cfg.Lookup.URL, cfg.Lookup.Timeout), books.NewLookupCache(db), cfg.Lookup.CacheTTL)
+	case "fake":
+		bookService.UseLookup(books.NewFakeProvider(), books.NewLookupCache(db), cfg.Lookup.CacheTTL)
+	}
+
+	notifService := notifications.NewService(notifRepo)
+	holdService := holds.NewService(holdRepo, bookRepo, branchRepo, notifService)
+
+	return &app{
+		cfg:             cfg,
+		db:              db,
+		dialect:         dialect,
+		files:           files,
+		authService:     authService,
+		bookService:     bookService,
+		branchService:   branches.NewService(branchRepo),
+		memberService:   members.NewService(memberRepo, credentialManager, smtpMailer, cfg),
+		borrowService:   borrowings.NewService(borrowRepo, bookRepo, branchRepo, memberRepo, holdRepo, notifRepo, cfg.Loan),
+		holdService:     holdService,
+		transferService: transfers.NewService(transferRepo, bookRepo, branchRepo, holdService),
+		notifService:    notifService,
+		backupService:   backups.NewService(db, dialect.Name(), database.NewMigrator(db, dialect, io.Discard), cfg.Backup, files),
+	}, nil
+}
+
+func (a *app) Close() error {
+	return a.db.Close()
+}
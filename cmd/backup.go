@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const backupUsage = `Usage:
+  simpus backup [-o FILE]      write all library data and uploads to a .tar.gz archive
+                               (default: a new archive in the backup directory)
+  simpus restore -yes FILE     replace all library data with the archive`
+
+func runBackup(a *app, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := flags.String("o", "", "archive file instead of the backup directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q\n%s", flags.Arg(0), backupUsage)
+	}
+	ctx := context.Background()
+
+	if *output == "" {
+		info, err := a.backupService.Create(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup written to %s/%s\n", a.cfg.Backup.Dir, info.Name)
+		return nil
+	}
+
+	f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	manifest, err := a.backupService.Write(ctx, f)
+	if err != nil {
+		f.Close()
+		os.Remove(*output)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Backup written to %s (schema version %d, %d upload(s))\n", *output, manifest.SchemaVersion, manifest.Uploads)
+	return nil
+}
+
+func runRestore(a *app, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "confirm that all current data will be replaced")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("restore needs an archive\n%s", backupUsage)
+	}
+	if !*yes {
+		return errors.New("restore replaces all current data, run again with -yes to confirm")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := a.backupService.Restore(context.Background(), f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s, created %s\n", flags.Arg(0), manifest.CreatedAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+)
+
+const bookUsage = `Usage:
+  simpus book import [options] FILE   add books from a CSV, XLSX, MARC (.mrc)
+                                      or MARCXML (.xml) file
+  simpus book export [-o FILE] [-format csv|xlsx|marc|marcxml]
+                                      write the catalog, with its availability
+                                      in CSV and XLSX
+
+Import options:
+  -branch CODE       branch receiving the stock column
+  -create-missing    create unknown categories and authors instead of
+                     rejecting their rows
+  -map FIELD=COLUMN  read a field from the column with this header; may be
+                     repeated. Columns named like the fields (isbn, title or
+                     judul, author or penulis, ...) are found without it.
+                     MARC records are read into columns named like the
+                     fields
+  -dry-run           check every row and save nothing
+  -errors FILE       write the rejected rows as CSV, ready to be fixed and
+                     imported again
+
+Valid rows are saved in one transaction; rejected rows are reported. The
+running server picks up imported books at its next search reindex.`
+
+func runBook(a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing book command\n%s", bookUsage)
+	}
+
+	flags := flag.NewFlagSet("book "+args[0], flag.ContinueOnError)
+
+	switch args[0] {
+	case "import":
+		branch := flags.String("branch", "", "branch `CODE` receiving the stock")
+		createMissing := flags.Bool("create-missing", false, "create unknown categories and authors")
+		dryRun := flags.Bool("dry-run", false, "check the file without saving")
+		errorsFile := flags.String("errors", "", "write rejected rows to `FILE`")
+		var mappings []string
+		flags.Func("map", "read `FIELD=COLUMN` from the column with that header", func(s string) error {
+			mappings = append(mappings, s)
+			return nil
+		})
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("import needs a file\n%s", bookUsage)
+		}
+		path := flags.Arg(0)
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		sheet, err := books.ReadSheet(path, f)
+		if err != nil {
+			return err
+		}
+
+		opts := books.ImportOptions{Mapping: books.GuessMapping(sheet.Header), CreateMissing: *createMissing}
+		if err := mapColumns(opts.Mapping, sheet.Header, mappings); err != nil {
+			return err
+		}
+		if *branch != "" {
+			b, err := a.branchService.GetBranchByCode(*branch)
+			if err != nil {
+				return fmt.Errorf("branch %q: %w", *branch, err)
+			}
+			opts.BranchID = b.ID
+		}
+
+		var result *books.ImportResult
+		if *dryRun {
+			result, err = a.bookService.PreviewImport(sheet, opts)
+		} else {
+			result, err = a.bookService.ImportBooks(sheet, opts)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, row := range result.InvalidRows() {
+			fmt.Fprintf(os.Stderr, "baris %d: %s\n", row.Row, strings.Join(row.Errors, "; "))
+		}
+		if *errorsFile != "" && result.Invalid > 0 {
+			out, err := os.Create(*errorsFile)
+			if err != nil {
+				return err
+			}
+			defer out.Close()
+			if err := books.WriteImportErrors(out, sheet, result); err != nil {
+				return err
+			}
+		}
+		if len(result.NewCategories) > 0 {
+			fmt.Printf("new categories: %s\n", strings.Join(result.NewCategories, ", "))
+		}
+		if len(result.NewAuthors) > 0 {
+			fmt.Printf("new authors: %s\n", strings.Join(result.NewAuthors, ", "))
+		}
+		if *dryRun {
+			fmt.Printf("dry run: %d book(s) would be imported, %d row(s) rejected\n", result.Valid, result.Invalid)
+		} else {
+			fmt.Printf("%d book(s) imported, %d row(s) rejected\n", result.Created, result.Invalid)
+		}
+		if result.Invalid > 0 {
+			return fmt.Errorf("%d row(s) could not be imported", result.Invalid)
+		}
+
+	case "export":
+		output := flags.String("o", "", "output file (default stdout)")
+		format := flags.String("format", "", "csv, xlsx, marc or marcxml (default from the extension of -o, else csv)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *format == "" {
+			switch strings.ToLower(filepath.Ext(*output)) {
+			case ".xlsx":
+				*format = books.ExportXLSX
+			case ".mrc", ".marc":
+				*format = books.ExportMARC
+			case ".xml":
+				*format = books.ExportMARCXML
+			default:
+				*format = books.ExportCSV
+			}
+		}
+
+		var out io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		return a.bookService.ExportCatalog(out, *format, models.BookFilter{})
+
+	default:
+		return fmt.Errorf("unknown book command %q\n%s", args[0], bookUsage)
+	}
+	return nil
+}
+
+// mapColumns applies -map FIELD=COLUMN flags, COLUMN being a header of the
+// file, on top of the guessed mapping.
+func mapColumns(mapping books.ImportMapping, header []string, flags []string) error {
+	for _, m := range flags {
+		field, column, ok := strings.Cut(m, "=")
+		if !ok {
+			return fmt.Errorf("-map %q: want FIELD=COLUMN", m)
+		}
+		known := false
+		for _, f := range books.ImportFields {
+			known = known || f.Name == field
+		}
+		if !known {
+			return fmt.Errorf("-map %q: unknown field %q", m, field)
+		}
+		col := -1
+		for i, h := range header {
+			if strings.EqualFold(h, strings.TrimSpace(column)) {
+				col = i
+				break
+			}
+		}
+		if col < 0 {
+			return fmt.Errorf("-map %q: the file has no column %q", m, column)
+		}
+		mapping[field] = col
+	}
+	return nil
+}
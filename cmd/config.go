@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"simpus/config"
+)
+
+const configUsage = `Usage:
+  simpus config check    validate the configuration and print it with secrets redacted`
+
+// runConfig handles the config subcommands. They run before the database
+// connection so a broken configuration can be diagnosed on its own.
+func runConfig(path string, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return fmt.Errorf("unknown config command\n%s", configUsage)
+	}
+
+	cfg, err := config.Read(path)
+	if err != nil {
+		return err
+	}
+
+	source := path
+	if source == "" {
+		source = "(none, defaults and environment only)"
+	}
+	fmt.Printf("# config file: %s\n", source)
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			fmt.Fprintln(os.Stderr, err)
+			return errors.New("configuration is invalid")
+		}
+		return err
+	}
+	fmt.Println("# configuration is valid")
+	return nil
+}
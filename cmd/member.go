@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const memberUsage = `Usage:
+  simpus member import FILE.csv     create members from a CSV file ("-" for stdin)
+  simpus member export [-o FILE]    write all members as CSV (default stdout)
+
+Import columns: name, email, member_type (mahasiswa, guru or karyawan) and
+optionally identity_number, phone, address and password. Members imported
+without a password sign in after using "Lupa password".`
+
+func runMember(a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing member command\n%s", memberUsage)
+	}
+
+	flags := flag.NewFlagSet("member "+args[0], flag.ContinueOnError)
+
+	switch args[0] {
+	case "import":
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return fmt.Errorf("import needs a file\n%s", memberUsage)
+		}
+
+		var in io.Reader = os.Stdin
+		if path := flags.Arg(0); path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+
+		result, err := a.memberService.ImportCSV(in)
+		if err != nil {
+			return err
+		}
+		for _, rowErr := range result.Errors {
+			fmt.Fprintln(os.Stderr, rowErr)
+		}
+		fmt.Printf("%d member(s) imported, %d row(s) skipped\n", result.Created, len(result.Errors))
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("%d row(s) could not be imported", len(result.Errors))
+		}
+
+	case "export":
+		output := flags.String("o", "", "output file (default stdout)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		return a.memberService.ExportCSV(out)
+
+	default:
+		return fmt.Errorf("unknown member command %q\n%s", args[0], memberUsage)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"simpus/database"
+)
+
+const migrateUsage = `Usage:
+  simpus migrate up [-dry-run] [-steps N]    apply pending migrations
+  simpus migrate down [-dry-run] [-steps N]  revert the last N migrations (default 1)
+  simpus migrate status                      list migrations and when they were applied
+  simpus migrate baseline VERSION            mark migrations up to VERSION as applied
+  simpus seed                                load sample data into a fresh database`
+
+func runSeed(a *app, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("seed takes no arguments\n%s", migrateUsage)
+	}
+	if err := database.Seed(context.Background(), a.db, os.Stdout); err != nil {
+		return fmt.Errorf("seed failed: %w", err)
+	}
+	fmt.Println("Seed data loaded")
+	return nil
+}
+
+func runMigrate(a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", migrateUsage)
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL without executing it")
+	steps := flags.Int("steps", 0, "number of migrations to apply or revert")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	migrator := database.NewMigrator(a.db, a.dialect, os.Stdout)
+	migrator.DryRun = *dryRun
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) %s\n", n, verb(*dryRun, "applied"))
+
+	case "down":
+		n, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) %s\n", n, verb(*dryRun, "reverted"))
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+
+	case "baseline":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("baseline needs a version\n%s", migrateUsage)
+		}
+		version, err := strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid version %q", flags.Arg(0))
+		}
+		return migrator.Baseline(ctx, version)
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+	return nil
+}
+
+func verb(dryRun bool, done string) string {
+	if dryRun {
+		return "would be " + done
+	}
+	return done
+}
@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+const notificationsUsage = `Usage:
+  simpus notifications run-overdue    create overdue notifications without waiting for the scheduler`
+
+func runNotifications(a *app, args []string) error {
+	if len(args) != 1 || args[0] != "run-overdue" {
+		return fmt.Errorf("unknown notifications command\n%s", notificationsUsage)
+	}
+
+	count, err := a.borrowService.CheckAndCreateOverdueNotifications()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d overdue notification(s) created\n", count)
+	return nil
+}
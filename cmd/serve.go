@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"simpus"
+	"simpus/database"
+	"simpus/internal/app/auth"
+	"simpus/internal/app/backups"
+	"simpus/internal/app/books"
+	"simpus/internal/app/borrowings"
+	"simpus/internal/app/branches"
+	"simpus/internal/app/dashboard"
+	"simpus/internal/app/health"
+	"simpus/internal/app/holds"
+	"simpus/internal/app/members"
+	"simpus/internal/app/notifications"
+	"simpus/internal/app/reports"
+	"simpus/internal/app/transfers"
+	"simpus/internal/metrics"
+	authMiddleware "simpus/internal/middleware"
+	"simpus/internal/renderer"
+	"simpus/internal/scheduler"
+	"simpus/internal/storage"
+)
+
+// runServe runs the web server and the background jobs until SIGINT or
+// SIGTERM.
+func runServe(a *app, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("serve takes no arguments\n%s", usage)
+	}
+	slog.Info("connected to database", "driver", a.dialect.Name())
+
+	migrator := database.NewMigrator(a.db, a.dialect, os.Stdout)
+	if a.cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(context.Background(), 0)
+		if err != nil {
+			return fmt.Errorf("migrate database: %w", err)
+		}
+		slog.Info("migrations applied", "count", applied)
+	}
+
+	// The search index lives in memory and starts empty
+	indexed, err := a.bookService.RebuildIndex()
+	if err != nil {
+		return fmt.Errorf("build search index: %w", err)
+	}
+	slog.Info("search index built", "books", indexed)
+
+	// Background jobs
+	jobs := scheduler.New()
+	jobs.Add(scheduler.Job{
+		Name:     "overdue-notifications",
+		Interval: a.cfg.Scheduler.OverdueCheckInterval,
+		Run: func(ctx context.Context) error {
+			count, err := a.borrowService.CheckAndCreateOverdueNotifications()
+			if count > 0 {
+				slog.InfoContext(ctx, "overdue notifications created", "count", count)
+			}
+			return err
+		},
+	})
+	// Picks up books changed outside this process, such as by the command
+	// line or another instance
+	jobs.Add(scheduler.Job{
+		Name:     "search-index",
+		Interval: a.cfg.Scheduler.SearchReindexInterval,
+		Run: func(ctx context.Context) error {
+			_, err := a.bookService.RebuildIndex()
+			return err
+		},
+	})
+	if a.cfg.Backup.Interval > 0 {
+		jobs.Add(scheduler.Job{
+			Name:     "backup",
+			Interval: a.cfg.Backup.Interval,
+			Run: func(ctx context.Context) error {
+				info, err := a.backupService.Create(ctx)
+				if err != nil {
+					return err
+				}
+				slog.InfoContext(ctx, "backup created", "name", info.Name, "size", info.Size)
+				return nil
+			},
+		})
+	}
+
+	// Templates and static files are embedded; hot reload reads them from disk
+	templateFiles, staticFiles := simpus.Templates(), simpus.Static()
+	if a.cfg.App.HotReload {
+		templateFiles, staticFiles = os.DirFS("templates"), os.DirFS("static")
+	}
+	views, err := renderer.New(templateFiles, a.cfg.App.HotReload)
+	if err != nil {
+		return fmt.Errorf("parse templates: %w", err)
+	}
+
+	// Initialize handlers
+	authHandler := auth.NewHandler(a.authService, views, a.cfg.App.BaseURL)
+	bookHandler := books.NewBookHandler(a.bookService, a.branchService, views)
+	importHandler := books.NewImportHandler(a.bookService, a.branchService, views, a.cfg.Storage.ImportDir)
+	categoryHandler := books.NewCategoryHandler(a.bookService, views)
+	authorHandler := books.NewAuthorHandler(a.bookService, views)
+	workHandler := books.NewWorkHandler(a.bookService, views)
+	memberHandler := members.NewHandler(a.memberService, views)
+	borrowHandler := borrowings.NewHandler(a.borrowService, a.bookService, a.memberService, a.branchService, views)
+	dashboardHandler := dashboard.NewHandler(a.bookService, a.memberService, a.borrowService, a.branchService, views)
+	reportHandler := reports.NewHandler(a.borrowService, a.branchService, views)
+	branchHandler := branches.NewHandler(a.branchService, views)
+	holdHandler := holds.NewHandler(a.holdService, views)
+	transferHandler := transfers.NewHandler(a.transferService, a.holdService, a.bookService, a.branchService, views)
+	notifHandler := notifications.NewHandler(a.notifService, views)
+	backupHandler := backups.NewHandler(a.backupService, views)
+	healthHandler := health.NewHandler(
+		health.Check{Name: "database", Func: a.db.PingContext},
+		health.Check{Name: "migrations", Func: func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err == nil && pending > 0 {
+				err = fmt.Errorf("%d pending migration(s)", pending)
+			}
+			return err
+		}},
+		health.Check{Name: "scheduler", Func: func(ctx context.Context) error {
+			return jobs.Alive()
+		}},
+	)
+
+	// Initialize middleware
+	authMw := authMiddleware.NewAuthMiddleware(a.authService)
+
+	// Create router
+	r := chi.NewRouter()
+
+	// Middleware
+	r.Use(middleware.RequestID)
+	r.Use(authMiddleware.RequestLogger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Compress(5))
+
+	// Static files; uploads live on disk, outside the embedded assets
+	fileServer := http.FileServerFS(staticFiles)
+	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
+	uploads := http.FileServer(http.Dir(a.cfg.Storage.UploadDir))
+	r.Handle("/static/uploads/*", http.StripPrefix("/static/uploads/", uploads))
+	// Covers, from whichever storage backend keeps them
+	r.Method(http.MethodGet, "/media/*", http.StripPrefix("/media/", storage.Handler(a.files)))
+
+	// Probes
+	r.Get("/healthz", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
+	r.Handle("/metrics", metrics.Handler(a.db, func() (int, error) {
+		return a.borrowService.GetOverdueCount(0)
+	}))
+
+	// Public routes
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		views.Render(w, r, "home.html", nil)
+	})
+
+	// Auth routes
+	r.Get("/login", authHandler.LoginPage)
+	r.Post("/login", authHandler.Login)
+	r.Get("/login/member", authHandler.MemberLoginPage)
+	r.Post("/login/member", authHandler.MemberLogin)
+	r.Get("/logout", authHandler.Logout)
+	r.Get("/register", authHandler.RegisterMemberPage)
+	r.Post("/register", authHandler.RegisterMember)
+	r.Get("/forgot-password", authHandler.ForgotPasswordPage)
+	r.Post("/forgot-password", authHandler.ForgotPassword)
+	r.Get("/reset-password", authHandler.ResetPasswordPage)
+	r.Post("/reset-password", authHandler.ResetPassword)
+	r.Get("/verify-email", authHandler.VerifyEmail)
+	r.Get("/verify-email/resend", authHandler.ResendVerificationPage)
+	r.Post("/verify-email/resend", authHandler.ResendVerification)
+	r.Get("/auth/oidc/login", authHandler.OIDCLogin)
+	r.Get("/auth/oidc/callback", authHandler.OIDCCallback)
+
+	// Admin routes (protected)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(authMw.RequireAuth)
+		r.Use(authMw.RequireAdmin)
+
+		// Dashboard
+		r.Get("/dashboard", dashboardHandler.AdminDashboard)
+
+		// Books
+		r.Get("/books", bookHandler.Index)
+		r.Get("/books/suggest", bookHandler.Suggest)
+		r.Get("/books/create", bookHandler.Create)
+		r.Get("/books/lookup", bookHandler.Lookup)
+		r.Post("/books", bookHandler.Store)
+		r.Get("/books/{id}/edit", bookHandler.Edit)
+		r.Post("/books/{id}", bookHandler.Update)
+		r.Delete("/books/{id}", bookHandler.Delete)
+		r.Get("/books/labels", bookHandler.Labels)
+		r.Get("/books/export", importHandler.Export)
+		r.Get("/books/import", importHandler.Upload)
+		r.Post("/books/import", importHandler.Store)
+		r.Get("/books/import/{token}", importHandler.Mapping)
+		r.Post("/books/import/{token}/preview", importHandler.Preview)
+		r.Post("/books/import/{token}/commit", importHandler.Commit)
+		r.Get("/books/import/{token}/errors", importHandler.Errors)
+
+		// Categories
+		r.Get("/categories", categoryHandler.Index)
+		r.Post("/categories", categoryHandler.Store)
+		r.Post("/categories/{id}", categoryHandler.Update)
+		r.Post("/categories/{id}/merge", categoryHandler.Merge)
+		r.Delete("/categories/{id}", categoryHandler.Delete)
+
+		// Authors
+		r.Get("/authors", authorHandler.Index)
+		r.Post("/authors", authorHandler.Store)
+		r.Post("/authors/{id}", authorHandler.Update)
+		r.Delete("/authors/{id}", authorHandler.Delete)
+
+		// Works and series
+		r.Get("/works", workHandler.Index)
+		r.Post("/works", workHandler.Store)
+		r.Post("/works/{id}", workHandler.Update)
+		r.Delete("/works/{id}", workHandler.Delete)
+		r.Post("/series", workHandler.StoreSeries)
+		r.Post("/series/{id}", workHandler.UpdateSeries)
+		r.Delete("/series/{id}", workHandler.DeleteSeries)
+
+		// Members
+		r.Get("/members", memberHandler.Index)
+		r.Get("/members/create", memberHandler.Create)
+		r.Post("/members", memberHandler.Store)
+		r.Get("/members/{id}/edit", memberHandler.Edit)
+		r.Post("/members/{id}", memberHandler.Update)
+		r.Post("/members/{id}/approve", memberHandler.Approve)
+		r.Post("/members/{id}/reject", memberHandler.Reject)
+		r.Delete("/members/{id}", memberHandler.Delete)
+
+		// Borrowings
+		r.Get("/borrowings", borrowHandler.Index)
+		r.Get("/borrowings/create", borrowHandler.Create)
+		r.Post("/borrowings", borrowHandler.Store)
+		r.Post("/borrowings/{id}/return", borrowHandler.Return)
+
+		// Holds and transfers between branches
+		r.Get("/transfers", transferHandler.Index)
+		r.Post("/transfers", transferHandler.Store)
+		r.Post("/transfers/{id}/dispatch", transferHandler.Dispatch)
+		r.Post("/transfers/{id}/send", transferHandler.Send)
+		r.Post("/transfers/{id}/receive", transferHandler.Receive)
+		r.Post("/transfers/{id}/cancel", transferHandler.Cancel)
+		r.Post("/holds/{id}/ready", holdHandler.Ready)
+		r.Post("/holds/{id}/cancel", holdHandler.Cancel)
+
+		// Reports
+		r.Get("/reports", reportHandler.Index)
+
+		// Branch setup and backups (which contain password hashes) are
+		// limited to administrators
+		r.Group(func(r chi.Router) {
+			r.Use(authMw.RequireRole("admin"))
+			r.Get("/branches", branchHandler.Index)
+			r.Post("/branches", branchHandler.Store)
+			r.Post("/branches/{id}", branchHandler.Update)
+			r.Get("/backups", backupHandler.Index)
+			r.Post("/backups", backupHandler.Create)
+			r.Get("/backups/{name}", backupHandler.Download)
+		})
+	})
+
+	// Member routes (protected)
+	r.Route("/member", func(r chi.Router) {
+		r.Use(authMw.RequireAuth)
+		r.Use(authMw.RequireMember)
+
+		r.Get("/dashboard", dashboardHandler.MemberDashboard)
+
+		// Books
+		r.Get("/books", bookHandler.MemberIndex)
+		r.Get("/books/suggest", bookHandler.MemberSuggest)
+		r.Get("/books/{id}", bookHandler.MemberShow)
+
+		// Borrowings
+		r.Post("/borrowings", borrowHandler.MemberRequest)
+		r.Get("/history", borrowHandler.MemberHistory)
+
+		// Holds
+		r.Get("/holds", holdHandler.MemberIndex)
+		r.Post("/holds", holdHandler.MemberStore)
+		r.Post("/holds/{id}/cancel", holdHandler.MemberCancel)
+
+		// Profile
+		r.Get("/profile", memberHandler.Profile)
+		r.Post("/profile", memberHandler.UpdateProfile)
+
+		// Notifications
+		r.Get("/notifications", notifHandler.MemberIndex)
+	})
+
+	// Start server
+	addr := fmt.Sprintf("%s:%s", a.cfg.Server.Host, a.cfg.Server.Port)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		ReadHeaderTimeout: a.cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       a.cfg.Server.ReadTimeout,
+		WriteTimeout:      a.cfg.Server.WriteTimeout,
+		IdleTimeout:       a.cfg.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	jobs.Start(ctx)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server started", "addr", "http://"+addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		slog.Info("shutting down, draining requests")
+	}
+	stop()
+
+	// Finish in-flight requests and background jobs before closing the pool
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown", "error", err)
+	}
+	if err := jobs.Stop(shutdownCtx); err != nil {
+		slog.Error("scheduler shutdown", "error", err)
+	}
+
+	slog.Info("server stopped")
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"simpus/internal/models"
+)
+
+const userUsage = `Usage:
+  simpus user create -username NAME -email EMAIL -name "FULL NAME" [-role admin|staff] [-branch CODE] [-password-stdin]
+  simpus user reset-password -username NAME [-password-stdin]
+  simpus user set-branch -username NAME [-branch CODE]
+
+Without -password-stdin a random password is generated and printed once.
+Without -branch the account works across all branches.`
+
+func runUser(a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing user command\n%s", userUsage)
+	}
+
+	flags := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	username := flags.String("username", "", "login name")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from the first line of stdin")
+	branchCode := flags.String("branch", "", "code of the branch the account works at")
+
+	switch args[0] {
+	case "create":
+		email := flags.String("email", "", "email address, used for password resets")
+		name := flags.String("name", "", "full name")
+		role := flags.String("role", "admin", "admin or staff")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		branchID, err := a.branchID(*branchCode)
+		if err != nil {
+			return err
+		}
+
+		password, generated, err := readPassword(*passwordStdin)
+		if err != nil {
+			return err
+		}
+		id, err := a.authService.CreateUser(&models.UserCreate{
+			Username: *username,
+			Email:    *email,
+			Name:     *name,
+			Role:     *role,
+			Password: password,
+			BranchID: branchID,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("User %s created (id %d, role %s)\n", *username, id, *role)
+		printGenerated(generated, password)
+
+	case "reset-password":
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *username == "" {
+			return fmt.Errorf("-username is required\n%s", userUsage)
+		}
+
+		password, generated, err := readPassword(*passwordStdin)
+		if err != nil {
+			return err
+		}
+		if err := a.authService.SetUserPassword(*username, password); err != nil {
+			return err
+		}
+		fmt.Printf("Password of %s changed, existing sessions signed out\n", *username)
+		printGenerated(generated, password)
+
+	case "set-branch":
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *username == "" {
+			return fmt.Errorf("-username is required\n%s", userUsage)
+		}
+		branchID, err := a.branchID(*branchCode)
+		if err != nil {
+			return err
+		}
+
+		if err := a.authService.SetUserBranch(*username, branchID); err != nil {
+			return err
+		}
+		where := "all branches"
+		if branchID != nil {
+			where = "branch " + strings.ToUpper(*branchCode)
+		}
+		fmt.Printf("%s now works at %s, existing sessions signed out\n", *username, where)
+
+	default:
+		return fmt.Errorf("unknown user command %q\n%s", args[0], userUsage)
+	}
+	return nil
+}
+
+// branchID resolves a branch code from the command line. An empty code
+// means no branch.
+func (a *app) branchID(code string) (*int, error) {
+	if code == "" {
+		return nil, nil
+	}
+	b, err := a.branchService.GetBranchByCode(code)
+	if err != nil {
+		return nil, fmt.Errorf("branch %q: %w", code, err)
+	}
+	return &b.ID, nil
+}
+
+// readPassword returns the password from stdin, or a random one that the
+// caller must show to the operator.
+func readPassword(fromStdin bool) (password string, generated bool, err error) {
+	if !fromStdin {
+		b := make([]byte, 15)
+		if _, err := rand.Read(b); err != nil {
+			return "", false, err
+		}
+		return strings.ToLower(base32.StdEncoding.EncodeToString(b)), true, nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false, fmt.Errorf("read password from stdin: %w", err)
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", false, errors.New("empty password on stdin")
+	}
+	return password, false, nil
+}
+
+// printGenerated writes a generated password to stdout only, never to the
+// log.
+func printGenerated(generated bool, password string) {
+	if generated {
+		fmt.Printf("Generated password: %s\n", password)
+	}
+}
@@ -1,75 +1,313 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultPath is the config file read when SIMPUS_CONFIG is not set.
+const DefaultPath = "simpus.yaml"
+
 type Config struct {
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Server   ServerConfig
-	App      AppConfig
+	Database  DatabaseConfig  `yaml:"database"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Server    ServerConfig    `yaml:"server"`
+	App       AppConfig       `yaml:"app"`
+	SMTP      SMTPConfig      `yaml:"smtp"`
+	Auth      AuthConfig      `yaml:"auth"`
+	OIDC      OIDCConfig      `yaml:"oidc"`
+	LDAP      LDAPConfig      `yaml:"ldap"`
+	Password  PasswordConfig  `yaml:"password"`
+	Loan      LoanConfig      `yaml:"loan"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Lookup    LookupConfig    `yaml:"isbn_lookup"`
+	Backup    BackupConfig    `yaml:"backup"`
+	Log       LogConfig       `yaml:"log"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
+	Driver      string `yaml:"driver"` // "mysql" or "sqlite"
+	Path        string `yaml:"path"`   // SQLite database file
+	Host        string `yaml:"host"`
+	Port        string `yaml:"port"`
+	User        string `yaml:"user"`
+	Password    string `yaml:"password"`
+	Name        string `yaml:"name"`
+	AutoMigrate bool   `yaml:"auto_migrate"`
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret string        `yaml:"secret"`
+	Expiry time.Duration `yaml:"expiry"`
 }
 
 type ServerConfig struct {
-	Host string
-	Port string
+	Host              string        `yaml:"host"`
+	Port              string        `yaml:"port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // time allowed to drain requests on SIGTERM
 }
 
 type AppConfig struct {
-	Name string
-	Env  string
+	Name      string `yaml:"name"`
+	Env       string `yaml:"env"` // "development" or "production"
+	BaseURL   string `yaml:"base_url"`
+	HotReload bool   `yaml:"hot_reload"` // read templates and static files from disk on every request
 }
 
-func Load() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-		// .env file is optional in production
-	}
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type AuthConfig struct {
+	ResetTokenExpiry        time.Duration `yaml:"reset_token_expiry"`
+	VerificationTokenExpiry time.Duration `yaml:"verification_token_expiry"`
+	RequireMemberApproval   bool          `yaml:"require_member_approval"`
+}
+
+type OIDCConfig struct {
+	Enabled           bool     `yaml:"enabled"`
+	IssuerURL         string   `yaml:"issuer_url"`
+	ClientID          string   `yaml:"client_id"`
+	ClientSecret      string   `yaml:"client_secret"`
+	Scopes            []string `yaml:"scopes"`
+	NIMClaim          string   `yaml:"nim_claim"`     // claim holding the student NIM, used to link members
+	JITProvision      bool     `yaml:"jit_provision"` // create members on first SSO login
+	DefaultMemberType string   `yaml:"default_member_type"`
+}
+
+type LDAPConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	URL            string `yaml:"url"`
+	StartTLS       bool   `yaml:"start_tls"`
+	BindDN         string `yaml:"bind_dn"`
+	BindPassword   string `yaml:"bind_password"`
+	BaseDN         string `yaml:"base_dn"`
+	UserFilter     string `yaml:"user_filter"` // %s is replaced with the escaped username
+	EmailAttribute string `yaml:"email_attribute"`
+	NameAttribute  string `yaml:"name_attribute"`
+}
+
+type PasswordConfig struct {
+	MinLength        int    `yaml:"min_length"`
+	BreachedListFile string `yaml:"breached_list_file"` // one password or SHA-1 hash (HIBP format) per line, or a directory of HIBP range files
+	HistorySize      int    `yaml:"history_size"`       // number of previous passwords that may not be reused
+	Algorithm        string `yaml:"algorithm"`          // "bcrypt" or "argon2id"
+	BcryptCost       int    `yaml:"bcrypt_cost"`
+	Argon2Memory     uint32 `yaml:"argon2_memory"` // KiB
+	Argon2Iterations uint32 `yaml:"argon2_iterations"`
+	Argon2Threads    uint8  `yaml:"argon2_threads"`
+}
+
+type LoanConfig struct {
+	DefaultDays int     `yaml:"default_days"` // loan period when none is requested
+	MaxDays     int     `yaml:"max_days"`
+	FinePerDay  float64 `yaml:"fine_per_day"` // rupiah per day late
+}
 
-	expiry, _ := time.ParseDuration(getEnv("JWT_EXPIRY", "24h"))
+type SchedulerConfig struct {
+	OverdueCheckInterval  time.Duration `yaml:"overdue_check_interval"`
+	SearchReindexInterval time.Duration `yaml:"search_reindex_interval"` // full rebuild of the search index
+}
+
+type StorageConfig struct {
+	UploadDir string   `yaml:"upload_dir"` // uploaded files, served under /static/uploads/
+	ImportDir string   `yaml:"import_dir"` // catalog import files and their error reports, kept for a day
+	Backend   string   `yaml:"backend"`    // where book covers are kept: "local" (in upload_dir) or "s3"
+	S3        S3Config `yaml:"s3"`
+}
+
+// S3Config points at an S3 compatible object store, such as AWS S3 or MinIO.
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"` // e.g. https://s3.ap-southeast-3.amazonaws.com
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	PathStyle bool   `yaml:"path_style"` // bucket in the path instead of the host name, as MinIO needs
+}
+
+type LookupConfig struct {
+	Provider string        `yaml:"provider"` // "openlibrary", "fake" (offline sample data) or "none"
+	URL      string        `yaml:"url"`      // base URL of an Open Library compatible API
+	Timeout  time.Duration `yaml:"timeout"`
+	CacheTTL time.Duration `yaml:"cache_ttl"` // how long a looked up ISBN is kept before asking again
+}
 
+type BackupConfig struct {
+	Dir      string        `yaml:"dir"`      // where backup archives are kept
+	Interval time.Duration `yaml:"interval"` // scheduled backups, 0 disables them
+	Keep     int           `yaml:"keep"`     // archives kept after a backup, 0 keeps all
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // "json" or "text"
+}
+
+// Default returns the configuration used when neither the config file nor
+// the environment set a value.
+func Default() *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "3306"),
-			User:     getEnv("DB_USER", "root"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Name:     getEnv("DB_NAME", "simpus"),
+			Driver: "mysql",
+			Path:   "simpus.db",
+			Host:   "localhost",
+			Port:   "3306",
+			User:   "root",
+			Name:   "simpus",
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "simpus-secret-key"),
-			Expiry: expiry,
+			Secret: DefaultJWTSecret,
+			Expiry: 24 * time.Hour,
 		},
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "localhost"),
-			Port: getEnv("SERVER_PORT", "8081"),
+			Host:              "localhost",
+			Port:              "8081",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   20 * time.Second,
 		},
 		App: AppConfig{
-			Name: getEnv("APP_NAME", "SIMPUS"),
-			Env:  getEnv("APP_ENV", "development"),
+			Name:    "SIMPUS",
+			Env:     "development",
+			BaseURL: "http://localhost:8081",
+		},
+		SMTP: SMTPConfig{
+			Host: "localhost",
+			Port: "1025",
+			From: "SIMPUS <no-reply@simpus.local>",
+		},
+		Auth: AuthConfig{
+			ResetTokenExpiry:        time.Hour,
+			VerificationTokenExpiry: 48 * time.Hour,
 		},
-	}, nil
+		OIDC: OIDCConfig{
+			Scopes:            []string{"openid", "email", "profile"},
+			NIMClaim:          "nim",
+			DefaultMemberType: "mahasiswa",
+		},
+		LDAP: LDAPConfig{
+			URL:            "ldap://localhost:389",
+			UserFilter:     "(uid=%s)",
+			EmailAttribute: "mail",
+			NameAttribute:  "cn",
+		},
+		Password: PasswordConfig{
+			MinLength:        8,
+			HistorySize:      5,
+			Algorithm:        "bcrypt",
+			BcryptCost:       10,
+			Argon2Memory:     64 * 1024,
+			Argon2Iterations: 3,
+			Argon2Threads:    2,
+		},
+		Loan: LoanConfig{
+			DefaultDays: 7,
+			MaxDays:     30,
+			FinePerDay:  1000,
+		},
+		Scheduler: SchedulerConfig{
+			OverdueCheckInterval:  24 * time.Hour,
+			SearchReindexInterval: time.Hour,
+		},
+		Storage: StorageConfig{
+			UploadDir: "data/uploads",
+			ImportDir: "data/imports",
+			Backend:   "local",
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+		},
+		Lookup: LookupConfig{
+			Provider: "openlibrary",
+			URL:      "https://openlibrary.org",
+			Timeout:  10 * time.Second,
+			CacheTTL: 30 * 24 * time.Hour,
+		},
+		Backup: BackupConfig{
+			Dir:  "data/backups",
+			Keep: 7,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Load reads the configuration and validates it.
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
 	}
-	return defaultValue
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read builds the configuration from the defaults, the YAML file at path (if
+// path is not empty), .env and the environment, in increasing priority,
+// without validating it.
+func Read(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	// .env is optional, but one that exists must parse
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Path returns the config file named by SIMPUS_CONFIG, or simpus.yaml when
+// it exists in the working directory, or "" for none.
+func Path() string {
+	if path := os.Getenv("SIMPUS_CONFIG"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	return ""
+}
+
+// IsProduction reports whether APP_ENV is production.
+func (c *Config) IsProduction() bool {
+	return c.App.Env == "production"
 }
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chdir moves the test into an empty directory so a developer's .env is not
+// picked up.
+func chdir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileWithEnvOverride(t *testing.T) {
+	dir := chdir(t)
+	path := writeFile(t, dir, "simpus.yaml", `
+database:
+  driver: sqlite
+  path: perpus.db
+loan:
+  default_days: 10
+  max_days: 20
+  fine_per_day: 500
+scheduler:
+  overdue_check_interval: 6h
+`)
+	t.Setenv("LOAN_FINE_PER_DAY", "2000")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Driver != "sqlite" || cfg.Database.Path != "perpus.db" {
+		t.Errorf("database = %+v", cfg.Database)
+	}
+	if cfg.Loan.DefaultDays != 10 || cfg.Loan.MaxDays != 20 {
+		t.Errorf("loan = %+v", cfg.Loan)
+	}
+	if cfg.Loan.FinePerDay != 2000 {
+		t.Errorf("fine_per_day = %v, want env override 2000", cfg.Loan.FinePerDay)
+	}
+	if cfg.Scheduler.OverdueCheckInterval != 6*time.Hour {
+		t.Errorf("overdue_check_interval = %v", cfg.Scheduler.OverdueCheckInterval)
+	}
+	// Untouched settings keep their defaults
+	if cfg.Server.Port != "8081" {
+		t.Errorf("server.port = %q, want default", cfg.Server.Port)
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	dir := chdir(t)
+	path := writeFile(t, dir, "simpus.yaml", "loan:\n  fine_per_hari: 500\n")
+
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "fine_per_hari") {
+		t.Errorf("err = %v, want unknown field reported", err)
+	}
+}
+
+func TestLoadReportsUnparsableEnv(t *testing.T) {
+	chdir(t)
+	t.Setenv("JWT_EXPIRY", "sehari")
+	t.Setenv("LOAN_MAX_DAYS", "tiga puluh")
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("invalid environment accepted")
+	}
+	for _, name := range []string{"JWT_EXPIRY", "LOAN_MAX_DAYS"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error does not mention %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadReportsBrokenDotEnv(t *testing.T) {
+	dir := chdir(t)
+	writeFile(t, dir, ".env", "JWT_SECRET='tidak ditutup\n")
+
+	if _, err := Load(""); err == nil {
+		t.Error("broken .env ignored")
+	}
+}
+
+func TestValidateCollectsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Driver = "postgres"
+	cfg.Loan.MaxDays = 3
+	cfg.Log.Format = "xml"
+
+	var invalid *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &invalid) {
+		t.Fatalf("err = %v, want ValidationError", err)
+	}
+	if len(invalid.Problems) != 3 {
+		t.Errorf("problems = %q, want 3", invalid.Problems)
+	}
+}
+
+func TestValidateS3Storage(t *testing.T) {
+	cfg := Default()
+	cfg.Storage.Backend = "s3"
+	cfg.Storage.S3.Endpoint = "localhost:9000"
+
+	var invalid *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &invalid) {
+		t.Fatalf("err = %v, want ValidationError", err)
+	}
+	// endpoint, bucket, access_key and secret_key
+	if len(invalid.Problems) != 4 {
+		t.Errorf("problems = %q, want 4", invalid.Problems)
+	}
+
+	cfg.Storage.S3 = S3Config{Endpoint: "http://localhost:9000", Region: "us-east-1", Bucket: "simpus", AccessKey: "key", SecretKey: "secret"}
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+	if r := cfg.Redacted(); r.Storage.S3.SecretKey != "[REDACTED]" {
+		t.Errorf("S3 secret shown as %q", r.Storage.S3.SecretKey)
+	}
+}
+
+func TestValidateProductionSecrets(t *testing.T) {
+	strong := strings.Repeat("k", minSecretLength)
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"default jwt secret", func(c *Config) {}, "jwt.secret"},
+		{"short jwt secret", func(c *Config) { c.JWT.Secret = "pendek" }, "jwt.secret"},
+		{"placeholder oidc secret", func(c *Config) {
+			c.JWT.Secret = strong
+			c.OIDC = OIDCConfig{Enabled: true, IssuerURL: "https://sso.kampus.ac.id", ClientID: "simpus", ClientSecret: "secret", DefaultMemberType: "mahasiswa"}
+		}, "oidc.client_secret"},
+		{"ldap bind without password", func(c *Config) {
+			c.JWT.Secret = strong
+			c.LDAP.Enabled, c.LDAP.BaseDN, c.LDAP.BindDN = true, "ou=staff,dc=kampus", "cn=admin,dc=kampus"
+		}, "ldap.bind_password"},
+		{"strong secrets", func(c *Config) { c.JWT.Secret = strong }, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.App.Env = "production"
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The same defaults are fine for development
+	if err := Default().Validate(); err != nil {
+		t.Errorf("defaults invalid in development: %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "db-pass"
+	cfg.OIDC.ClientSecret = "oidc-pass"
+
+	r := cfg.Redacted()
+	if r.JWT.Secret != "[REDACTED]" || r.Database.Password != "[REDACTED]" || r.OIDC.ClientSecret != "[REDACTED]" {
+		t.Errorf("secrets not redacted: %+v", r)
+	}
+	if r.SMTP.Password != "" {
+		t.Errorf("empty secret shown as %q, want empty", r.SMTP.Password)
+	}
+	if cfg.Database.Password != "db-pass" {
+		t.Error("Redacted modified the original")
+	}
+}
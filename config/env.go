@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides cfg with the environment variables that are set.
+// Values that do not parse are reported instead of being ignored.
+func applyEnv(cfg *Config) error {
+	e := &envReader{}
+
+	e.str("DB_DRIVER", &cfg.Database.Driver)
+	e.str("DB_PATH", &cfg.Database.Path)
+	e.str("DB_HOST", &cfg.Database.Host)
+	e.str("DB_PORT", &cfg.Database.Port)
+	e.str("DB_USER", &cfg.Database.User)
+	e.str("DB_PASSWORD", &cfg.Database.Password)
+	e.str("DB_NAME", &cfg.Database.Name)
+	e.bool("DB_AUTO_MIGRATE", &cfg.Database.AutoMigrate)
+
+	e.str("JWT_SECRET", &cfg.JWT.Secret)
+	e.duration("JWT_EXPIRY", &cfg.JWT.Expiry)
+
+	e.str("SERVER_HOST", &cfg.Server.Host)
+	e.str("SERVER_PORT", &cfg.Server.Port)
+	e.duration("SERVER_READ_HEADER_TIMEOUT", &cfg.Server.ReadHeaderTimeout)
+	e.duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	e.duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	e.duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	e.duration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	e.str("APP_NAME", &cfg.App.Name)
+	e.str("APP_ENV", &cfg.App.Env)
+	e.str("APP_BASE_URL", &cfg.App.BaseURL)
+	e.bool("APP_HOT_RELOAD", &cfg.App.HotReload)
+
+	e.str("SMTP_HOST", &cfg.SMTP.Host)
+	e.str("SMTP_PORT", &cfg.SMTP.Port)
+	e.str("SMTP_USERNAME", &cfg.SMTP.Username)
+	e.str("SMTP_PASSWORD", &cfg.SMTP.Password)
+	e.str("SMTP_FROM", &cfg.SMTP.From)
+
+	e.duration("RESET_TOKEN_EXPIRY", &cfg.Auth.ResetTokenExpiry)
+	e.duration("VERIFICATION_TOKEN_EXPIRY", &cfg.Auth.VerificationTokenExpiry)
+	e.bool("REGISTRATION_REQUIRE_APPROVAL", &cfg.Auth.RequireMemberApproval)
+
+	e.bool("OIDC_ENABLED", &cfg.OIDC.Enabled)
+	e.str("OIDC_ISSUER_URL", &cfg.OIDC.IssuerURL)
+	e.str("OIDC_CLIENT_ID", &cfg.OIDC.ClientID)
+	e.str("OIDC_CLIENT_SECRET", &cfg.OIDC.ClientSecret)
+	e.fields("OIDC_SCOPES", &cfg.OIDC.Scopes)
+	e.str("OIDC_NIM_CLAIM", &cfg.OIDC.NIMClaim)
+	e.bool("OIDC_JIT_PROVISION", &cfg.OIDC.JITProvision)
+	e.str("OIDC_DEFAULT_MEMBER_TYPE", &cfg.OIDC.DefaultMemberType)
+
+	e.bool("LDAP_ENABLED", &cfg.LDAP.Enabled)
+	e.str("LDAP_URL", &cfg.LDAP.URL)
+	e.bool("LDAP_START_TLS", &cfg.LDAP.StartTLS)
+	e.str("LDAP_BIND_DN", &cfg.LDAP.BindDN)
+	e.str("LDAP_BIND_PASSWORD", &cfg.LDAP.BindPassword)
+	e.str("LDAP_BASE_DN", &cfg.LDAP.BaseDN)
+	e.str("LDAP_USER_FILTER", &cfg.LDAP.UserFilter)
+	e.str("LDAP_EMAIL_ATTRIBUTE", &cfg.LDAP.EmailAttribute)
+	e.str("LDAP_NAME_ATTRIBUTE", &cfg.LDAP.NameAttribute)
+
+	e.int("PASSWORD_MIN_LENGTH", &cfg.Password.MinLength)
+	e.str("PASSWORD_BREACHED_LIST_FILE", &cfg.Password.BreachedListFile)
+	e.int("PASSWORD_HISTORY_SIZE", &cfg.Password.HistorySize)
+	e.str("PASSWORD_HASH_ALGORITHM", &cfg.Password.Algorithm)
+	e.int("PASSWORD_BCRYPT_COST", &cfg.Password.BcryptCost)
+	e.uint32("PASSWORD_ARGON2_MEMORY", &cfg.Password.Argon2Memory)
+	e.uint32("PASSWORD_ARGON2_ITERATIONS", &cfg.Password.Argon2Iterations)
+	e.uint8("PASSWORD_ARGON2_THREADS", &cfg.Password.Argon2Threads)
+
+	e.int("LOAN_DEFAULT_DAYS", &cfg.Loan.DefaultDays)
+	e.int("LOAN_MAX_DAYS", &cfg.Loan.MaxDays)
+	e.float("LOAN_FINE_PER_DAY", &cfg.Loan.FinePerDay)
+
+	e.duration("OVERDUE_CHECK_INTERVAL", &cfg.Scheduler.OverdueCheckInterval)
+	e.duration("SEARCH_REINDEX_INTERVAL", &cfg.Scheduler.SearchReindexInterval)
+
+	e.str("STORAGE_UPLOAD_DIR", &cfg.Storage.UploadDir)
+	e.str("STORAGE_IMPORT_DIR", &cfg.Storage.ImportDir)
+	e.str("STORAGE_BACKEND", &cfg.Storage.Backend)
+	e.str("S3_ENDPOINT", &cfg.Storage.S3.Endpoint)
+	e.str("S3_REGION", &cfg.Storage.S3.Region)
+	e.str("S3_BUCKET", &cfg.Storage.S3.Bucket)
+	e.str("S3_ACCESS_KEY", &cfg.Storage.S3.AccessKey)
+	e.str("S3_SECRET_KEY", &cfg.Storage.S3.SecretKey)
+	e.bool("S3_PATH_STYLE", &cfg.Storage.S3.PathStyle)
+
+	e.str("ISBN_LOOKUP_PROVIDER", &cfg.Lookup.Provider)
+	e.str("ISBN_LOOKUP_URL", &cfg.Lookup.URL)
+	e.duration("ISBN_LOOKUP_TIMEOUT", &cfg.Lookup.Timeout)
+	e.duration("ISBN_LOOKUP_CACHE_TTL", &cfg.Lookup.CacheTTL)
+
+	e.str("BACKUP_DIR", &cfg.Backup.Dir)
+	e.duration("BACKUP_INTERVAL", &cfg.Backup.Interval)
+	e.int("BACKUP_KEEP", &cfg.Backup.Keep)
+
+	e.str("LOG_LEVEL", &cfg.Log.Level)
+	e.str("LOG_FORMAT", &cfg.Log.Format)
+
+	return errors.Join(e.errs...)
+}
+
+// envReader copies set environment variables into config fields and
+// collects parse errors. Empty variables count as unset.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) lookup(key string) (string, bool) {
+	value := os.Getenv(key)
+	return value, value != ""
+}
+
+func (e *envReader) fail(key, value string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, value, err))
+}
+
+func (e *envReader) str(key string, dst *string) {
+	if value, ok := e.lookup(key); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) fields(key string, dst *[]string) {
+	if value, ok := e.lookup(key); ok {
+		*dst = strings.Fields(value)
+	}
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	if value, ok := e.lookup(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			e.fail(key, value, errors.New("must be true or false"))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(key, value, errors.New("must be a whole number"))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) uint32(key string, dst *uint32) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			e.fail(key, value, errors.New("must be a positive whole number"))
+			return
+		}
+		*dst = uint32(n)
+	}
+}
+
+func (e *envReader) uint8(key string, dst *uint8) {
+	if value, ok := e.lookup(key); ok {
+		n, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			e.fail(key, value, errors.New("must be a whole number from 0 to 255"))
+			return
+		}
+		*dst = uint8(n)
+	}
+}
+
+func (e *envReader) float(key string, dst *float64) {
+	if value, ok := e.lookup(key); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			e.fail(key, value, errors.New("must be a number"))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (e *envReader) duration(key string, dst *time.Duration) {
+	if value, ok := e.lookup(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(key, value, errors.New("must be a duration such as 30s, 15m or 24h"))
+			return
+		}
+		*dst = d
+	}
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultJWTSecret is only accepted outside production.
+const DefaultJWTSecret = "simpus-secret-key"
+
+// minSecretLength is the shortest JWT secret accepted in production.
+const minSecretLength = 32
+
+// weakSecrets are placeholder values from examples and documentation.
+var weakSecrets = []string{DefaultJWTSecret, "secret", "changeme", "password", "admin123"}
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the configuration as a whole. In production it also
+// rejects default or weak secrets.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("app.env", c.App.Env, "development", "production")
+	v.url("app.base_url", c.App.BaseURL)
+	v.notEmpty("app.name", c.App.Name)
+
+	v.oneOf("database.driver", c.Database.Driver, "mysql", "sqlite")
+	if c.Database.Driver == "sqlite" {
+		v.notEmpty("database.path", c.Database.Path)
+	} else {
+		v.notEmpty("database.host", c.Database.Host)
+		v.port("database.port", c.Database.Port)
+		v.notEmpty("database.name", c.Database.Name)
+	}
+
+	v.notEmpty("jwt.secret", c.JWT.Secret)
+	v.positive("jwt.expiry", c.JWT.Expiry)
+
+	v.port("server.port", c.Server.Port)
+	v.positive("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	v.positive("server.read_timeout", c.Server.ReadTimeout)
+	v.positive("server.write_timeout", c.Server.WriteTimeout)
+	v.positive("server.idle_timeout", c.Server.IdleTimeout)
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	v.notEmpty("smtp.host", c.SMTP.Host)
+	v.port("smtp.port", c.SMTP.Port)
+	if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+		v.add("smtp.from: %q is not an email address", c.SMTP.From)
+	}
+
+	v.positive("auth.reset_token_expiry", c.Auth.ResetTokenExpiry)
+	v.positive("auth.verification_token_expiry", c.Auth.VerificationTokenExpiry)
+
+	if c.OIDC.Enabled {
+		v.url("oidc.issuer_url", c.OIDC.IssuerURL)
+		v.notEmpty("oidc.client_id", c.OIDC.ClientID)
+		v.notEmpty("oidc.client_secret", c.OIDC.ClientSecret)
+		v.oneOf("oidc.default_member_type", c.OIDC.DefaultMemberType, "mahasiswa", "guru", "karyawan")
+	}
+	if c.LDAP.Enabled {
+		v.url("ldap.url", c.LDAP.URL)
+		v.notEmpty("ldap.base_dn", c.LDAP.BaseDN)
+		if !strings.Contains(c.LDAP.UserFilter, "%s") {
+			v.add("ldap.user_filter: must contain %%s for the username")
+		}
+	}
+
+	if c.Password.MinLength < 8 {
+		v.add("password.min_length: must be at least 8")
+	}
+	if c.Password.HistorySize < 0 {
+		v.add("password.history_size: must not be negative")
+	}
+	v.oneOf("password.algorithm", c.Password.Algorithm, "bcrypt", "argon2id")
+	if c.Password.BcryptCost < 4 || c.Password.BcryptCost > 31 {
+		v.add("password.bcrypt_cost: must be between 4 and 31")
+	}
+	if c.Password.Argon2Memory == 0 || c.Password.Argon2Iterations == 0 || c.Password.Argon2Threads == 0 {
+		v.add("password.argon2_*: memory, iterations and threads must be positive")
+	}
+
+	if c.Loan.DefaultDays < 1 {
+		v.add("loan.default_days: must be at least 1")
+	}
+	if c.Loan.MaxDays < c.Loan.DefaultDays {
+		v.add("loan.max_days: must not be less than loan.default_days")
+	}
+	if c.Loan.FinePerDay < 0 {
+		v.add("loan.fine_per_day: must not be negative")
+	}
+
+	v.positive("scheduler.overdue_check_interval", c.Scheduler.OverdueCheckInterval)
+	v.positive("scheduler.search_reindex_interval", c.Scheduler.SearchReindexInterval)
+	v.notEmpty("storage.upload_dir", c.Storage.UploadDir)
+	v.notEmpty("storage.import_dir", c.Storage.ImportDir)
+	v.oneOf("storage.backend", c.Storage.Backend, "local", "s3")
+	if c.Storage.Backend == "s3" {
+		v.url("storage.s3.endpoint", c.Storage.S3.Endpoint)
+		v.notEmpty("storage.s3.region", c.Storage.S3.Region)
+		v.notEmpty("storage.s3.bucket", c.Storage.S3.Bucket)
+		v.notEmpty("storage.s3.access_key", c.Storage.S3.AccessKey)
+		v.notEmpty("storage.s3.secret_key", c.Storage.S3.SecretKey)
+	}
+
+	v.oneOf("isbn_lookup.provider", c.Lookup.Provider, "openlibrary", "fake", "none")
+	if c.Lookup.Provider == "openlibrary" {
+		v.url("isbn_lookup.url", c.Lookup.URL)
+	}
+	v.positive("isbn_lookup.timeout", c.Lookup.Timeout)
+	v.positive("isbn_lookup.cache_ttl", c.Lookup.CacheTTL)
+
+	v.notEmpty("backup.dir", c.Backup.Dir)
+	if c.Backup.Interval < 0 {
+		v.add("backup.interval: must not be negative")
+	}
+	if c.Backup.Keep < 0 {
+		v.add("backup.keep: must not be negative")
+	}
+
+	v.oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+	v.oneOf("log.format", c.Log.Format, "json", "text")
+
+	if c.IsProduction() {
+		if isWeakSecret(c.JWT.Secret) || len(c.JWT.Secret) < minSecretLength {
+			v.add("jwt.secret: default or weak secret refused in production, use at least %d random characters", minSecretLength)
+		}
+		if c.OIDC.Enabled && isWeakSecret(c.OIDC.ClientSecret) {
+			v.add("oidc.client_secret: placeholder secret refused in production")
+		}
+		if c.LDAP.Enabled && c.LDAP.BindDN != "" && isWeakSecret(c.LDAP.BindPassword) {
+			v.add("ldap.bind_password: empty or placeholder password refused in production")
+		}
+		if c.App.HotReload {
+			v.add("app.hot_reload: must be off in production")
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func isWeakSecret(secret string) bool {
+	if secret == "" {
+		return true
+	}
+	for _, weak := range weakSecrets {
+		if strings.EqualFold(secret, weak) {
+			return true
+		}
+	}
+	return false
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) notEmpty(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add("%s: must not be empty", field)
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add("%s: %q is not one of %s", field, value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) positive(field string, d time.Duration) {
+	if d <= 0 {
+		v.add("%s: must be a positive duration", field)
+	}
+}
+
+func (v *validator) port(field, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.add("%s: %q is not a valid port", field, value)
+	}
+}
+
+func (v *validator) url(field, value string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		v.add("%s: %q is not an absolute URL", field, value)
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets masked, for
+// printing.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.OIDC.Scopes = append([]string(nil), c.OIDC.Scopes...)
+	for _, secret := range []*string{
+		&r.Database.Password,
+		&r.JWT.Secret,
+		&r.SMTP.Password,
+		&r.OIDC.ClientSecret,
+		&r.LDAP.BindPassword,
+		&r.Storage.S3.SecretKey,
+	} {
+		if *secret != "" {
+			*secret = "[REDACTED]"
+		}
+	}
+	return &r
+}
@@ -3,45 +3,76 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 	"simpus/config"
 )
 
-var DB *sql.DB
-
-func Connect(cfg *config.Config) error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.Name,
-	)
-
-	var err error
-	DB, err = sql.Open("mysql", dsn)
+// Connect opens the database selected by DB_DRIVER and returns it with its
+// dialect.
+func Connect(cfg *config.Config) (*sql.DB, Dialect, error) {
+	dialect, err := DialectFor(cfg.Database.Driver)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, nil, err
 	}
 
-	// Configure connection pool
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(5)
-	DB.SetConnMaxLifetime(5 * time.Minute)
+	var db *sql.DB
+	switch dialect {
+	case SQLite:
+		db, err = OpenSQLite(cfg.Database.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=Local",
+			cfg.Database.User,
+			cfg.Database.Password,
+			cfg.Database.Host,
+			cfg.Database.Port,
+			cfg.Database.Name,
+		)
+
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		// Configure connection pool
+		db.SetMaxOpenConns(25)
+		db.SetMaxIdleConns(5)
+		db.SetConnMaxLifetime(5 * time.Minute)
+	}
 
 	// Verify connection
-	if err := DB.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return nil
+	return db, dialect, nil
 }
 
-func Close() error {
-	if DB != nil {
-		return DB.Close()
+// OpenSQLite opens the SQLite database at path with foreign keys enforced.
+// path may also be a "file:" URI, e.g. a shared in-memory database.
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := path
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	// Times are stored in a sortable text format so date comparisons in SQL
+	// behave like MySQL's DATE columns.
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
-	return nil
+	return db, nil
 }
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect hides the few SQL differences between the supported databases.
+// Repositories keep to portable SQL and ask the dialect for the rest.
+type Dialect interface {
+	// Name is the driver name and the migrations sub-directory.
+	Name() string
+	// CurrentDate is an expression for today's date in local time.
+	CurrentDate() string
+	// Concat joins string expressions, treating NULL as an empty string.
+	Concat(exprs ...string) string
+
+	tableExists(ctx context.Context, q queryer, table string) (bool, error)
+	// lock serialises migration runs on conn. release receives whether the
+	// run succeeded.
+	lock(ctx context.Context, conn *sql.Conn) (release func(ok bool) error, err error)
+}
+
+var (
+	MySQL  Dialect = mysqlDialect{}
+	SQLite Dialect = sqliteDialect{}
+)
+
+// DialectFor returns the dialect for a DB_DRIVER value.
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "mysql":
+		return MySQL, nil
+	case "sqlite":
+		return SQLite, nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", driver)
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) CurrentDate() string { return "CURDATE()" }
+
+func (mysqlDialect) Concat(exprs ...string) string {
+	args := make([]string, len(exprs))
+	for i, e := range exprs {
+		args[i] = "IFNULL(" + e + ", '')"
+	}
+	return "CONCAT(" + strings.Join(args, ", ") + ")"
+}
+
+func (mysqlDialect) tableExists(ctx context.Context, q queryer, table string) (bool, error) {
+	var n int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = ?`, table).Scan(&n)
+	return n > 0, err
+}
+
+// MySQL commits DDL implicitly, so a named lock is the only protection
+// against two instances migrating at the same time.
+func (mysqlDialect) lock(ctx context.Context, conn *sql.Conn) (func(bool) error, error) {
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrationLockName, migrationLockWait).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		return nil, ErrMigrationLocked
+	}
+	return func(bool) error {
+		_, err := conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLockName)
+		return err
+	}, nil
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) CurrentDate() string { return "DATE('now', 'localtime')" }
+
+func (sqliteDialect) Concat(exprs ...string) string {
+	args := make([]string, len(exprs))
+	for i, e := range exprs {
+		args[i] = "IFNULL(" + e + ", '')"
+	}
+	return "(" + strings.Join(args, " || ") + ")"
+}
+
+func (sqliteDialect) tableExists(ctx context.Context, q queryer, table string) (bool, error) {
+	var n int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n)
+	return n > 0, err
+}
+
+// SQLite DDL is transactional: an immediate transaction takes the write lock
+// and makes the whole run atomic.
+//
+// Foreign keys are switched off for the run, as SQLite requires for
+// migrations that rebuild a table, since dropping the old copy of a parent
+// table would otherwise cascade into its children. The constraints are
+// checked again before commit.
+func (sqliteDialect) lock(ctx context.Context, conn *sql.Conn) (func(bool) error, error) {
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		conn.ExecContext(context.Background(), `PRAGMA foreign_keys = ON`)
+		return nil, err
+	}
+	return func(ok bool) error {
+		ctx := context.Background()
+		defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+		if ok {
+			if err := foreignKeyCheck(ctx, conn); err != nil {
+				conn.ExecContext(ctx, `ROLLBACK`)
+				return err
+			}
+			_, err := conn.ExecContext(ctx, `COMMIT`)
+			return err
+		}
+		_, err := conn.ExecContext(ctx, `ROLLBACK`)
+		return err
+	}, nil
+}
+
+// foreignKeyCheck reports the first row that violates a foreign key.
+func foreignKeyCheck(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		return fmt.Errorf("migration leaves %s row %d without its %s", table, rowid.Int64, parent)
+	}
+	return rows.Err()
+}
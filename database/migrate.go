@@ -0,0 +1,419 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/mysql/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+//go:embed seeds/*.sql
+var seedFiles embed.FS
+
+const (
+	migrationsTable   = "schema_migrations"
+	migrationLockName = "simpus_schema_migrations"
+	migrationLockWait = 10 // seconds
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrMigrationLocked = errors.New("another migration is already running")
+
+// Migration is one versioned schema change, read from
+// migrations/<dialect>/<version>_<name>.up.sql and the matching .down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded migrations and records them in the
+// schema_migrations table. With DryRun set it only prints what would run.
+type Migrator struct {
+	db      *sql.DB
+	dialect Dialect
+	files   fs.FS
+	Out     io.Writer
+	DryRun  bool
+}
+
+func NewMigrator(db *sql.DB, dialect Dialect, out io.Writer) *Migrator {
+	return &Migrator{db: db, dialect: dialect, files: migrationFiles, Out: out}
+}
+
+// Migrations returns every embedded migration ordered by version.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	dir := path.Join("migrations", m.dialect.Name())
+	entries, err := fs.ReadDir(m.files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := fs.ReadFile(m.files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %03d has two names: %s and %s", version, mig.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status lists every migration together with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, mig := range migrations {
+		status[i].Migration = mig
+		if at, ok := applied[mig.Version]; ok {
+			status[i].AppliedAt = &at
+		}
+	}
+	return status, nil
+}
+
+// Pending returns the number of migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Version returns the highest applied migration version, or 0 for an empty
+// database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for _, s := range status {
+		if s.AppliedAt != nil && s.Version > version {
+			version = s.Version
+		}
+	}
+	return version, nil
+}
+
+// Up applies pending migrations in order, at most steps of them when steps
+// is positive. It returns the number of migrations applied.
+func (m *Migrator) Up(ctx context.Context, steps int) (int, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if steps > 0 && count == steps {
+				break
+			}
+			if err := m.run(ctx, conn, mig, "up", mig.Up); err != nil {
+				return err
+			}
+			if !m.DryRun {
+				_, err := conn.ExecContext(ctx, `INSERT INTO `+migrationsTable+` (version, name) VALUES (?, ?)`, mig.Version, mig.Name)
+				if err != nil {
+					return err
+				}
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts the most recently applied migrations, one by default.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	migrations, err := m.Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %03d_%s cannot be reverted: no down file", mig.Version, mig.Name)
+			}
+			if err := m.run(ctx, conn, mig, "down", mig.Down); err != nil {
+				return err
+			}
+			if !m.DryRun {
+				if _, err := conn.ExecContext(ctx, `DELETE FROM `+migrationsTable+` WHERE version = ?`, mig.Version); err != nil {
+					return err
+				}
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Baseline marks every migration up to version as applied without running
+// it. Use it once on databases that were created by importing the SQL files
+// by hand before the migration runner existed.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			fmt.Fprintf(m.Out, "baseline %03d_%s\n", mig.Version, mig.Name)
+			if m.DryRun {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, `INSERT INTO `+migrationsTable+` (version, name) VALUES (?, ?)`, mig.Version, mig.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, mig Migration, direction, script string) error {
+	fmt.Fprintf(m.Out, "%s %03d_%s\n", direction, mig.Version, mig.Name)
+
+	for _, stmt := range SplitStatements(script) {
+		if m.DryRun {
+			fmt.Fprintf(m.Out, "%s;\n\n", stmt)
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %03d_%s (%s): %w", mig.Version, mig.Name, direction, err)
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a single connection holding the dialect's migration
+// lock, so two instances starting at the same time cannot both migrate.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	release, err := m.dialect.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if releaseErr := release(err == nil); err == nil {
+			err = releaseErr
+		}
+	}()
+
+	if !m.DryRun {
+		if err := ensureMigrationsTable(ctx, conn); err != nil {
+			return err
+		}
+	}
+	return fn(conn)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (m *Migrator) applied(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+
+	// A dry run must not create the table, so a fresh database simply has
+	// nothing applied yet.
+	exists, err := m.dialect.tableExists(ctx, q, migrationsTable)
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM `+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+		version INT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// Seed loads the optional sample data in seeds/ inside one transaction.
+// It is meant for a freshly migrated database; running it twice fails on
+// duplicate keys and changes nothing.
+func Seed(ctx context.Context, db *sql.DB, out io.Writer) error {
+	entries, err := fs.ReadDir(seedFiles, "seeds")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		content, err := fs.ReadFile(seedFiles, path.Join("seeds", entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "seed %s\n", entry.Name())
+		for _, stmt := range SplitStatements(string(content)) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("seed %s: %w", entry.Name(), err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// SplitStatements breaks a SQL script into single statements. Statements end
+// with a semicolon at the end of a line; lines starting with "--" are
+// comments. This is enough for the hand-written files in this repository
+// and avoids enabling multiStatements on the driver.
+func SplitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, stmt)
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,145 @@
+package database_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"simpus/database"
+	"simpus/database/sqlitetest"
+)
+
+func TestMigrateDownAndUpAgain(t *testing.T) {
+	db := sqlitetest.Open(t)
+	ctx := context.Background()
+	migrator := database.NewMigrator(db, database.SQLite, io.Discard)
+
+	migrations, err := migrator.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := migrator.Down(ctx, len(migrations))
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if reverted != len(migrations) {
+		t.Fatalf("reverted %d migrations, want %d", reverted, len(migrations))
+	}
+	if pending, _ := migrator.Pending(ctx); pending != len(migrations) {
+		t.Errorf("pending = %d after down, want %d", pending, len(migrations))
+	}
+	if version, _ := migrator.Version(ctx); version != 0 {
+		t.Errorf("version = %d after down, want 0", version)
+	}
+
+	applied, err := migrator.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", applied, len(migrations))
+	}
+	if pending, _ := migrator.Pending(ctx); pending != 0 {
+		t.Errorf("pending = %d after up, want 0", pending)
+	}
+	if version, _ := migrator.Version(ctx); version != migrations[len(migrations)-1].Version {
+		t.Errorf("version = %d after up, want %d", version, migrations[len(migrations)-1].Version)
+	}
+}
+
+func TestMigrateDryRunChangesNothing(t *testing.T) {
+	db := sqlitetest.Open(t)
+	ctx := context.Background()
+
+	migrator := database.NewMigrator(db, database.SQLite, io.Discard)
+	migrator.DryRun = true
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %03d_%s reverted by dry run", s.Version, s.Name)
+		}
+	}
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	mysql, err := database.NewMigrator(nil, database.MySQL, io.Discard).Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := database.NewMigrator(nil, database.SQLite, io.Discard).Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mysql) != len(sqlite) {
+		t.Fatalf("mysql has %d migrations, sqlite has %d", len(mysql), len(sqlite))
+	}
+	for i := range mysql {
+		if mysql[i].Version != sqlite[i].Version || mysql[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d: mysql %03d_%s, sqlite %03d_%s",
+				i, mysql[i].Version, mysql[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestSeedLoadsSampleData(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	var books int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM books`).Scan(&books); err != nil {
+		t.Fatal(err)
+	}
+	if books == 0 {
+		t.Error("seed inserted no books")
+	}
+}
+
+func TestMigrateDownWorksKeepsBooks(t *testing.T) {
+	db := sqlitetest.Open(t)
+	ctx := context.Background()
+	migrator := database.NewMigrator(db, database.SQLite, io.Discard)
+
+	for _, stmt := range []string{
+		"INSERT INTO works (id, title) VALUES (1, 'Bumi')",
+		"INSERT INTO books (id, title, work_id, stock, available) VALUES (1, 'Bumi', 1, 2, 1)",
+		"INSERT INTO members (id, member_code, name, email, password, member_type) VALUES (1, 'MHS0001', 'Budi', 'budi@student.ac.id', 'x', 'mahasiswa')",
+		"INSERT INTO borrowings (member_id, book_id, borrow_date, due_date) VALUES (1, 1, '2026-01-01', '2026-01-08')",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleting a work unlinks its editions
+	if _, err := db.ExecContext(ctx, "DELETE FROM works WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	var workID *int
+	if err := db.QueryRowContext(ctx, "SELECT work_id FROM books WHERE id = 1").Scan(&workID); err != nil || workID != nil {
+		t.Fatalf("work_id = %v, %v after deleting the work, want NULL", workID, err)
+	}
+
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	var borrowings int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM borrowings WHERE book_id = 1").Scan(&borrowings); err != nil || borrowings != 1 {
+		t.Fatalf("borrowings = %d, %v after down, want 1", borrowings, err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO books (title) VALUES ('Bumi Manusia')"); err != nil {
+		t.Fatalf("insert book after down: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+}
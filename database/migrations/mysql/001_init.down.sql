@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS borrowings;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS authors;
+DROP TABLE IF EXISTS members;
+DROP TABLE IF EXISTS users;
@@ -1,15 +1,6 @@
 -- SIMPUS Database Schema
 -- Sistem Informasi Manajemen Perpustakaan
 
--- Drop tables if exists (for fresh install)
-DROP TABLE IF EXISTS notifications;
-DROP TABLE IF EXISTS borrowings;
-DROP TABLE IF EXISTS books;
-DROP TABLE IF EXISTS categories;
-DROP TABLE IF EXISTS authors;
-DROP TABLE IF EXISTS members;
-DROP TABLE IF EXISTS users;
-
 -- Users table (Admin/Staff)
 CREATE TABLE users (
     id INT PRIMARY KEY AUTO_INCREMENT,
@@ -114,39 +105,3 @@ CREATE INDEX idx_borrowings_status ON borrowings(status);
 CREATE INDEX idx_borrowings_due_date ON borrowings(due_date);
 CREATE INDEX idx_notifications_member ON notifications(member_id);
 CREATE INDEX idx_notifications_is_read ON notifications(is_read);
-
--- Insert default admin user (password: admin123)
-INSERT INTO users (username, email, password, name, role) VALUES
-('admin', 'admin@simpus.local', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', 'Administrator', 'admin');
-
--- Insert sample categories
-INSERT INTO categories (name, description) VALUES
-('Fiksi', 'Buku-buku cerita fiksi dan novel'),
-('Non-Fiksi', 'Buku-buku pengetahuan dan fakta'),
-('Pendidikan', 'Buku pelajaran dan akademik'),
-('Teknologi', 'Buku tentang teknologi dan komputer'),
-('Sejarah', 'Buku tentang sejarah dan budaya'),
-('Sains', 'Buku ilmu pengetahuan alam');
-
--- Insert sample authors
-INSERT INTO authors (name, bio) VALUES
-('Andrea Hirata', 'Penulis novel Laskar Pelangi'),
-('Tere Liye', 'Penulis novel populer Indonesia'),
-('Pramoedya Ananta Toer', 'Sastrawan Indonesia terkenal'),
-('J.K. Rowling', 'Penulis seri Harry Potter'),
-('Robert C. Martin', 'Penulis buku Clean Code');
-
--- Insert sample books
-INSERT INTO books (isbn, title, category_id, author_id, publisher, publish_year, stock, available, description) VALUES
-('978-602-03-1234-5', 'Laskar Pelangi', 1, 1, 'Bentang Pustaka', 2005, 5, 5, 'Novel tentang perjuangan anak-anak Belitung dalam menempuh pendidikan'),
-('978-602-03-2345-6', 'Bumi', 1, 2, 'Gramedia', 2014, 3, 3, 'Novel fantasi pertama dari serial Bumi'),
-('978-602-03-3456-7', 'Bumi Manusia', 1, 3, 'Hasta Mitra', 1980, 4, 4, 'Novel sejarah tentang perjuangan di era kolonial'),
-('978-0-13-235088-4', 'Clean Code', 4, 5, 'Prentice Hall', 2008, 2, 2, 'Panduan menulis kode yang bersih dan mudah dipelihara'),
-('978-602-03-4567-8', 'Bulan', 1, 2, 'Gramedia', 2015, 3, 3, 'Novel fantasi kedua dari serial Bumi');
-
--- Insert sample members
-INSERT INTO members (member_code, name, email, password, phone, member_type, address) VALUES
-('MHS001', 'Budi Santoso', 'budi@student.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567890', 'mahasiswa', 'Jl. Pendidikan No. 1'),
-('MHS002', 'Siti Aminah', 'siti@student.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567891', 'mahasiswa', 'Jl. Ilmu No. 2'),
-('GRU001', 'Drs. Ahmad Wijaya', 'ahmad@school.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567892', 'guru', 'Jl. Guru No. 3'),
-('KRY001', 'Dewi Lestari', 'dewi@office.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567893', 'karyawan', 'Jl. Kantor No. 4');
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS password_resets;
+
+ALTER TABLE members DROP COLUMN session_version;
+ALTER TABLE users DROP COLUMN session_version;
@@ -0,0 +1,18 @@
+-- Self-service password reset
+
+-- Bumped on every password change so previously issued JWTs stop validating
+ALTER TABLE users ADD COLUMN session_version INT NOT NULL DEFAULT 0;
+ALTER TABLE members ADD COLUMN session_version INT NOT NULL DEFAULT 0;
+
+-- Password reset tokens (only the SHA-256 hash of the token is stored)
+CREATE TABLE password_resets (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    account_type ENUM('admin', 'member') NOT NULL,
+    account_id INT NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_resets_account ON password_resets(account_type, account_id);
@@ -0,0 +1,5 @@
+DROP INDEX idx_members_status ON members;
+
+ALTER TABLE members DROP COLUMN email_verified_at;
+ALTER TABLE members DROP COLUMN status;
+ALTER TABLE members DROP COLUMN identity_number;
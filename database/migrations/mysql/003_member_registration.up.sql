@@ -0,0 +1,10 @@
+-- Member registration workflow (email verification and librarian approval)
+
+ALTER TABLE members ADD COLUMN identity_number VARCHAR(30) UNIQUE AFTER member_code;
+ALTER TABLE members ADD COLUMN status ENUM('pending_verification', 'pending_approval', 'active', 'rejected') NOT NULL DEFAULT 'active' AFTER is_active;
+ALTER TABLE members ADD COLUMN email_verified_at DATETIME AFTER status;
+
+-- Members created before this migration are treated as verified
+UPDATE members SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+CREATE INDEX idx_members_status ON members(status);
@@ -0,0 +1,15 @@
+-- Links between local accounts and external identity providers (OIDC, LDAP)
+
+CREATE TABLE external_identities (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    account_type ENUM('admin', 'member') NOT NULL,
+    account_id INT NOT NULL,
+    email VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME,
+    UNIQUE KEY uq_external_identities_subject (provider, subject, account_type)
+);
+
+CREATE INDEX idx_external_identities_account ON external_identities(account_type, account_id);
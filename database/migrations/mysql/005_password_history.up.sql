@@ -0,0 +1,11 @@
+-- Previous password hashes, used to prevent password reuse
+
+CREATE TABLE password_history (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    account_type ENUM('admin', 'member') NOT NULL,
+    account_id INT NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_history_account ON password_history(account_type, account_id, created_at);
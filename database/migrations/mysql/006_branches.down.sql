@@ -0,0 +1,11 @@
+ALTER TABLE borrowings DROP FOREIGN KEY fk_borrowings_return_branch;
+ALTER TABLE borrowings DROP FOREIGN KEY fk_borrowings_branch;
+DROP INDEX idx_borrowings_branch ON borrowings;
+ALTER TABLE borrowings DROP COLUMN return_branch_id;
+ALTER TABLE borrowings DROP COLUMN branch_id;
+
+ALTER TABLE users DROP FOREIGN KEY fk_users_branch;
+ALTER TABLE users DROP COLUMN branch_id;
+
+DROP TABLE IF EXISTS branch_stock;
+DROP TABLE IF EXISTS branches;
@@ -0,0 +1,38 @@
+-- Library branches. Copies of a book are stocked per branch and loans
+-- record where they were checked out and returned.
+
+CREATE TABLE branches (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    code VARCHAR(20) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    address TEXT,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Everything recorded before this migration belongs to the main library
+INSERT INTO branches (id, code, name) VALUES (1, 'PUSAT', 'Perpustakaan Pusat');
+
+-- books.stock and books.available remain as totals over all branches
+CREATE TABLE branch_stock (
+    book_id INT NOT NULL,
+    branch_id INT NOT NULL,
+    stock INT NOT NULL DEFAULT 0,
+    available INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, branch_id),
+    FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+    FOREIGN KEY (branch_id) REFERENCES branches(id)
+);
+
+INSERT INTO branch_stock (book_id, branch_id, stock, available) SELECT id, 1, stock, available FROM books;
+
+ALTER TABLE users ADD COLUMN branch_id INT NULL AFTER role;
+ALTER TABLE users ADD CONSTRAINT fk_users_branch FOREIGN KEY (branch_id) REFERENCES branches(id) ON DELETE SET NULL;
+UPDATE users SET branch_id = 1;
+
+ALTER TABLE borrowings ADD COLUMN branch_id INT NOT NULL DEFAULT 1 AFTER user_id;
+ALTER TABLE borrowings ADD COLUMN return_branch_id INT NULL AFTER return_date;
+ALTER TABLE borrowings ADD CONSTRAINT fk_borrowings_branch FOREIGN KEY (branch_id) REFERENCES branches(id);
+ALTER TABLE borrowings ADD CONSTRAINT fk_borrowings_return_branch FOREIGN KEY (return_branch_id) REFERENCES branches(id);
+
+CREATE INDEX idx_borrowings_branch ON borrowings(branch_id);
@@ -0,0 +1,52 @@
+-- Member holds and transfers of copies between branches. A transfer may
+-- bring a copy to the pickup branch of a waiting hold.
+
+CREATE TABLE holds (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    member_id INT NOT NULL,
+    book_id INT NOT NULL,
+    branch_id INT NOT NULL,
+    status ENUM('waiting', 'ready', 'fulfilled', 'cancelled') NOT NULL DEFAULT 'waiting',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ready_at DATETIME,
+    closed_at DATETIME,
+    FOREIGN KEY (member_id) REFERENCES members(id) ON DELETE CASCADE,
+    FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+    FOREIGN KEY (branch_id) REFERENCES branches(id)
+);
+
+CREATE INDEX idx_holds_status ON holds(status, branch_id);
+CREATE INDEX idx_holds_member ON holds(member_id);
+
+CREATE TABLE transfers (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    book_id INT NOT NULL,
+    from_branch_id INT NOT NULL,
+    to_branch_id INT NOT NULL,
+    hold_id INT,
+    status ENUM('requested', 'dispatched', 'in_transit', 'received', 'cancelled') NOT NULL DEFAULT 'requested',
+    notes TEXT,
+    requested_by INT,
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    dispatched_by INT,
+    dispatched_at DATETIME,
+    sent_by INT,
+    sent_at DATETIME,
+    received_by INT,
+    received_at DATETIME,
+    cancelled_by INT,
+    cancelled_at DATETIME,
+    FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+    FOREIGN KEY (from_branch_id) REFERENCES branches(id),
+    FOREIGN KEY (to_branch_id) REFERENCES branches(id),
+    FOREIGN KEY (hold_id) REFERENCES holds(id) ON DELETE SET NULL,
+    FOREIGN KEY (requested_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (dispatched_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (sent_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (received_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (cancelled_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_transfers_status ON transfers(status);
+CREATE INDEX idx_transfers_from ON transfers(from_branch_id);
+CREATE INDEX idx_transfers_to ON transfers(to_branch_id);
@@ -0,0 +1,5 @@
+DROP INDEX idx_books_publish_year ON books;
+DROP INDEX idx_books_publisher ON books;
+DROP INDEX idx_books_language ON books;
+
+ALTER TABLE books DROP COLUMN language;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS isbn_lookups;
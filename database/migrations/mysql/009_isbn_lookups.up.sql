@@ -0,0 +1,11 @@
+-- Cache of ISBN lookups at the book metadata provider. ISBNs the provider
+-- does not know are kept too, with found = 0, so they are not asked again
+-- on every attempt.
+
+CREATE TABLE isbn_lookups (
+    isbn VARCHAR(13) PRIMARY KEY,
+    found BOOLEAN NOT NULL,
+    metadata TEXT,
+    cover VARCHAR(255),
+    fetched_at DATETIME NOT NULL
+);
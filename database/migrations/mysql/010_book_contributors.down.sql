@@ -0,0 +1,12 @@
+-- Only the first author of each book is kept
+ALTER TABLE books ADD COLUMN author_id INT AFTER category_id;
+ALTER TABLE books ADD CONSTRAINT fk_books_author FOREIGN KEY (author_id) REFERENCES authors(id) ON DELETE SET NULL;
+CREATE INDEX idx_books_author ON books(author_id);
+
+UPDATE books b SET author_id = (
+    SELECT bc.author_id FROM book_contributors bc
+    WHERE bc.book_id = b.id AND bc.role = 'author'
+    ORDER BY bc.position LIMIT 1
+);
+
+DROP TABLE IF EXISTS book_contributors;
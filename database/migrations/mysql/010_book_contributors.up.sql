@@ -0,0 +1,32 @@
+-- Contributors of a book: its authors, editors, translators and
+-- illustrators, in the order they are credited. Replaces books.author_id.
+
+CREATE TABLE book_contributors (
+    book_id INT NOT NULL,
+    author_id INT NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'author',
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role),
+    FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES authors(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_book_contributors_author ON book_contributors(author_id);
+
+INSERT INTO book_contributors (book_id, author_id, role, position)
+SELECT id, author_id, 'author', 0 FROM books WHERE author_id IS NOT NULL;
+
+-- 001 left the foreign key on books.author_id unnamed, so its generated
+-- name is looked up rather than assumed.
+SET @fk_books_author = (
+    SELECT CONSTRAINT_NAME FROM information_schema.KEY_COLUMN_USAGE
+    WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'books'
+      AND COLUMN_NAME = 'author_id' AND REFERENCED_TABLE_NAME = 'authors'
+    LIMIT 1
+);
+SET @drop_fk_books_author = CONCAT('ALTER TABLE books DROP FOREIGN KEY `', @fk_books_author, '`');
+PREPARE drop_fk_books_author FROM @drop_fk_books_author;
+EXECUTE drop_fk_books_author;
+DEALLOCATE PREPARE drop_fk_books_author;
+DROP INDEX idx_books_author ON books;
+ALTER TABLE books DROP COLUMN author_id;
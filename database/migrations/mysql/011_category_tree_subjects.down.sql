@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS book_subjects;
+
+DROP INDEX idx_categories_parent ON categories;
+ALTER TABLE categories DROP COLUMN parent_id;
@@ -0,0 +1,17 @@
+-- Categories form a tree: Sains > Fisika > Mekanika. parent_id has no
+-- foreign key, as a category may be moved under one created after it and
+-- backups restore categories in ID order; the application keeps it valid.
+ALTER TABLE categories ADD COLUMN parent_id INT;
+
+CREATE INDEX idx_categories_parent ON categories(parent_id);
+
+-- Free subject tags of a book, in the order they were entered
+CREATE TABLE book_subjects (
+    book_id INT NOT NULL,
+    subject VARCHAR(100) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, subject),
+    FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_book_subjects_subject ON book_subjects(subject);
@@ -0,0 +1,4 @@
+DROP INDEX idx_books_ddc ON books;
+
+ALTER TABLE books DROP COLUMN cutter;
+ALTER TABLE books DROP COLUMN ddc;
@@ -0,0 +1,9 @@
+ALTER TABLE holds DROP COLUMN any_edition;
+
+ALTER TABLE books DROP FOREIGN KEY fk_books_work;
+DROP INDEX idx_books_work ON books;
+ALTER TABLE books DROP COLUMN edition;
+ALTER TABLE books DROP COLUMN work_id;
+
+DROP TABLE IF EXISTS works;
+DROP TABLE IF EXISTS series;
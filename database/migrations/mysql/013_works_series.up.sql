@@ -0,0 +1,28 @@
+-- Works group the editions of one title, and series number their works as
+-- volumes, such as the Bumi series.
+CREATE TABLE series (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE works (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(255) NOT NULL,
+    series_id INT,
+    volume INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (series_id) REFERENCES series(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_works_series ON works(series_id, volume);
+
+ALTER TABLE books ADD COLUMN work_id INT AFTER category_id;
+ALTER TABLE books ADD COLUMN edition VARCHAR(50) NOT NULL DEFAULT '' AFTER work_id;
+
+CREATE INDEX idx_books_work ON books(work_id);
+ALTER TABLE books ADD CONSTRAINT fk_books_work FOREIGN KEY (work_id) REFERENCES works(id) ON DELETE SET NULL;
+
+-- A hold on any edition of the book's work, not only the one asked for
+ALTER TABLE holds ADD COLUMN any_edition BOOLEAN NOT NULL DEFAULT FALSE AFTER branch_id;
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS trg_books_updated_at;
+DROP TRIGGER IF EXISTS trg_members_updated_at;
+DROP TRIGGER IF EXISTS trg_users_updated_at;
+
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS borrowings;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS authors;
+DROP TABLE IF EXISTS members;
+DROP TABLE IF EXISTS users;
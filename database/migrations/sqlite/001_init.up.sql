@@ -0,0 +1,106 @@
+-- SIMPUS Database Schema (SQLite)
+-- Sistem Informasi Manajemen Perpustakaan
+
+-- Users table (Admin/Staff)
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(50) UNIQUE NOT NULL,
+    email VARCHAR(100) UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    role TEXT DEFAULT 'staff' CHECK (role IN ('admin', 'staff')),
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Members table (Mahasiswa/Guru/Karyawan)
+CREATE TABLE members (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    member_code VARCHAR(20) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(100) UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    phone VARCHAR(20),
+    member_type TEXT NOT NULL CHECK (member_type IN ('mahasiswa', 'guru', 'karyawan')),
+    address TEXT,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Categories table
+CREATE TABLE categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Authors table
+CREATE TABLE authors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    bio TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Books table
+CREATE TABLE books (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    isbn VARCHAR(20) UNIQUE,
+    title VARCHAR(255) NOT NULL,
+    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    author_id INTEGER REFERENCES authors(id) ON DELETE SET NULL,
+    publisher VARCHAR(100),
+    publish_year INTEGER,
+    stock INTEGER DEFAULT 0,
+    available INTEGER DEFAULT 0,
+    cover_image VARCHAR(255),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Borrowings table
+CREATE TABLE borrowings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    member_id INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    borrow_date DATE NOT NULL,
+    due_date DATE NOT NULL,
+    return_date DATE,
+    status TEXT DEFAULT 'dipinjam' CHECK (status IN ('dipinjam', 'dikembalikan', 'terlambat')),
+    fine DECIMAL(10, 2) DEFAULT 0,
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Notifications table
+CREATE TABLE notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    borrowing_id INTEGER REFERENCES borrowings(id) ON DELETE CASCADE,
+    member_id INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    type TEXT DEFAULT 'info' CHECK (type IN ('keterlambatan', 'pengingat', 'info')),
+    title VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better performance
+CREATE INDEX idx_books_category ON books(category_id);
+CREATE INDEX idx_books_author ON books(author_id);
+CREATE INDEX idx_borrowings_member ON borrowings(member_id);
+CREATE INDEX idx_borrowings_book ON borrowings(book_id);
+CREATE INDEX idx_borrowings_status ON borrowings(status);
+CREATE INDEX idx_borrowings_due_date ON borrowings(due_date);
+CREATE INDEX idx_notifications_member ON notifications(member_id);
+CREATE INDEX idx_notifications_is_read ON notifications(is_read);
+
+-- SQLite has no ON UPDATE CURRENT_TIMESTAMP; triggers must stay on one line
+-- for the statement splitter
+CREATE TRIGGER trg_users_updated_at AFTER UPDATE ON users FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at BEGIN UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
+CREATE TRIGGER trg_members_updated_at AFTER UPDATE ON members FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at BEGIN UPDATE members SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
+CREATE TRIGGER trg_books_updated_at AFTER UPDATE ON books FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at BEGIN UPDATE books SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
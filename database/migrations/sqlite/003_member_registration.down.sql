@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_members_status;
+DROP INDEX IF EXISTS uq_members_identity_number;
+
+ALTER TABLE members DROP COLUMN email_verified_at;
+ALTER TABLE members DROP COLUMN status;
+ALTER TABLE members DROP COLUMN identity_number;
@@ -0,0 +1,12 @@
+-- Member registration workflow (email verification and librarian approval)
+
+-- SQLite cannot add a UNIQUE column; the unique index below enforces it
+ALTER TABLE members ADD COLUMN identity_number VARCHAR(30);
+ALTER TABLE members ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('pending_verification', 'pending_approval', 'active', 'rejected'));
+ALTER TABLE members ADD COLUMN email_verified_at DATETIME;
+
+-- Members created before this migration are treated as verified
+UPDATE members SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+CREATE UNIQUE INDEX uq_members_identity_number ON members(identity_number);
+CREATE INDEX idx_members_status ON members(status);
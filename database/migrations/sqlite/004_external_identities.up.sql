@@ -0,0 +1,15 @@
+-- Links between local accounts and external identity providers (OIDC, LDAP)
+
+CREATE TABLE external_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    account_type TEXT NOT NULL CHECK (account_type IN ('admin', 'member')),
+    account_id INTEGER NOT NULL,
+    email VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME,
+    UNIQUE (provider, subject, account_type)
+);
+
+CREATE INDEX idx_external_identities_account ON external_identities(account_type, account_id);
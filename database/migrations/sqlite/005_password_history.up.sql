@@ -0,0 +1,11 @@
+-- Previous password hashes, used to prevent password reuse
+
+CREATE TABLE password_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_type TEXT NOT NULL CHECK (account_type IN ('admin', 'member')),
+    account_id INTEGER NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_password_history_account ON password_history(account_type, account_id, created_at);
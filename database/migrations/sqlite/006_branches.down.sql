@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_borrowings_branch;
+
+ALTER TABLE borrowings DROP COLUMN return_branch_id;
+ALTER TABLE borrowings DROP COLUMN branch_id;
+
+ALTER TABLE users DROP COLUMN branch_id;
+
+DROP TABLE IF EXISTS branch_stock;
+DROP TABLE IF EXISTS branches;
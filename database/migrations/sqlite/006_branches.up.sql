@@ -0,0 +1,35 @@
+-- Library branches. Copies of a book are stocked per branch and loans
+-- record where they were checked out and returned.
+
+CREATE TABLE branches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(20) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    address TEXT,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Everything recorded before this migration belongs to the main library
+INSERT INTO branches (id, code, name) VALUES (1, 'PUSAT', 'Perpustakaan Pusat');
+
+-- books.stock and books.available remain as totals over all branches
+CREATE TABLE branch_stock (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    branch_id INTEGER NOT NULL REFERENCES branches(id),
+    stock INTEGER NOT NULL DEFAULT 0,
+    available INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, branch_id)
+);
+
+INSERT INTO branch_stock (book_id, branch_id, stock, available) SELECT id, 1, stock, available FROM books;
+
+-- SQLite cannot drop columns that take part in a foreign key, so the
+-- branch columns below are plain integers to keep the migration reversible
+ALTER TABLE users ADD COLUMN branch_id INTEGER;
+UPDATE users SET branch_id = 1;
+
+ALTER TABLE borrowings ADD COLUMN branch_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE borrowings ADD COLUMN return_branch_id INTEGER;
+
+CREATE INDEX idx_borrowings_branch ON borrowings(branch_id);
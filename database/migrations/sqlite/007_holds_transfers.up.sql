@@ -0,0 +1,40 @@
+-- Member holds and transfers of copies between branches. A transfer may
+-- bring a copy to the pickup branch of a waiting hold.
+
+CREATE TABLE holds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    member_id INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    branch_id INTEGER NOT NULL REFERENCES branches(id),
+    status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'ready', 'fulfilled', 'cancelled')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ready_at DATETIME,
+    closed_at DATETIME
+);
+
+CREATE INDEX idx_holds_status ON holds(status, branch_id);
+CREATE INDEX idx_holds_member ON holds(member_id);
+
+CREATE TABLE transfers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    from_branch_id INTEGER NOT NULL REFERENCES branches(id),
+    to_branch_id INTEGER NOT NULL REFERENCES branches(id),
+    hold_id INTEGER REFERENCES holds(id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'dispatched', 'in_transit', 'received', 'cancelled')),
+    notes TEXT,
+    requested_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    dispatched_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    dispatched_at DATETIME,
+    sent_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    sent_at DATETIME,
+    received_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    received_at DATETIME,
+    cancelled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    cancelled_at DATETIME
+);
+
+CREATE INDEX idx_transfers_status ON transfers(status);
+CREATE INDEX idx_transfers_from ON transfers(from_branch_id);
+CREATE INDEX idx_transfers_to ON transfers(to_branch_id);
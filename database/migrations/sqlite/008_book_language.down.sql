@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_books_publish_year;
+DROP INDEX IF EXISTS idx_books_publisher;
+DROP INDEX IF EXISTS idx_books_language;
+
+ALTER TABLE books DROP COLUMN language;
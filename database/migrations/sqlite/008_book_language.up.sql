@@ -0,0 +1,7 @@
+-- Language of each book (ISO 639-1 code) and indexes for the catalog facets
+
+ALTER TABLE books ADD COLUMN language VARCHAR(2) NOT NULL DEFAULT 'id';
+
+CREATE INDEX idx_books_language ON books(language);
+CREATE INDEX idx_books_publisher ON books(publisher);
+CREATE INDEX idx_books_publish_year ON books(publish_year);
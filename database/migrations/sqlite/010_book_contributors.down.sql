@@ -0,0 +1,10 @@
+-- Only the first author of each book is kept
+UPDATE books SET author_id = (
+    SELECT bc.author_id FROM book_contributors bc
+    WHERE bc.book_id = books.id AND bc.role = 'author'
+    ORDER BY bc.position LIMIT 1
+);
+
+CREATE INDEX IF NOT EXISTS idx_books_author ON books(author_id);
+
+DROP TABLE IF EXISTS book_contributors;
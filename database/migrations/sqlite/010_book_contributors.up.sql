@@ -0,0 +1,20 @@
+-- Contributors of a book: its authors, editors, translators and
+-- illustrators, in the order they are credited. Replaces books.author_id.
+
+CREATE TABLE book_contributors (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'author',
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX idx_book_contributors_author ON book_contributors(author_id);
+
+INSERT INTO book_contributors (book_id, author_id, role, position)
+SELECT id, author_id, 'author', 0 FROM books WHERE author_id IS NOT NULL;
+
+-- SQLite cannot drop columns that take part in a foreign key, so
+-- books.author_id stays behind, empty and unused
+DROP INDEX IF EXISTS idx_books_author;
+UPDATE books SET author_id = NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS book_subjects;
+
+DROP INDEX IF EXISTS idx_categories_parent;
+ALTER TABLE categories DROP COLUMN parent_id;
@@ -0,0 +1,16 @@
+-- Categories form a tree: Sains > Fisika > Mekanika. parent_id has no
+-- foreign key, as a category may be moved under one created after it and
+-- backups restore categories in ID order; the application keeps it valid.
+ALTER TABLE categories ADD COLUMN parent_id INTEGER;
+
+CREATE INDEX idx_categories_parent ON categories(parent_id);
+
+-- Free subject tags of a book, in the order they were entered
+CREATE TABLE book_subjects (
+    book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    subject VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, subject)
+);
+
+CREATE INDEX idx_book_subjects_subject ON book_subjects(subject);
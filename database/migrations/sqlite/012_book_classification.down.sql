@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_books_ddc;
+
+ALTER TABLE books DROP COLUMN cutter;
+ALTER TABLE books DROP COLUMN ddc;
@@ -0,0 +1,6 @@
+-- Dewey Decimal class number and author mark (Cutter) of each book; with
+-- the first letter of the title they make the call number on the spine
+ALTER TABLE books ADD COLUMN ddc VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN cutter VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_books_ddc ON books(ddc, cutter);
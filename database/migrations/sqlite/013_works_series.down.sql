@@ -0,0 +1,39 @@
+ALTER TABLE holds DROP COLUMN any_edition;
+
+-- SQLite cannot drop books.work_id while it references works, so books is
+-- rebuilt without it. The migrator runs with foreign keys off, so dropping
+-- the old table cascades nothing.
+CREATE TABLE books_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    isbn VARCHAR(20) UNIQUE,
+    title VARCHAR(255) NOT NULL,
+    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    author_id INTEGER REFERENCES authors(id) ON DELETE SET NULL,
+    publisher VARCHAR(100),
+    publish_year INTEGER,
+    stock INTEGER DEFAULT 0,
+    available INTEGER DEFAULT 0,
+    cover_image VARCHAR(255),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    language VARCHAR(2) NOT NULL DEFAULT 'id',
+    ddc VARCHAR(20) NOT NULL DEFAULT '',
+    cutter VARCHAR(20) NOT NULL DEFAULT ''
+);
+
+INSERT INTO books_new (id, isbn, title, category_id, author_id, publisher, publish_year, stock, available, cover_image, description, created_at, updated_at, language, ddc, cutter)
+SELECT id, isbn, title, category_id, author_id, publisher, publish_year, stock, available, cover_image, description, created_at, updated_at, language, ddc, cutter FROM books;
+
+DROP TABLE books;
+ALTER TABLE books_new RENAME TO books;
+
+CREATE INDEX idx_books_category ON books(category_id);
+CREATE INDEX idx_books_language ON books(language);
+CREATE INDEX idx_books_publisher ON books(publisher);
+CREATE INDEX idx_books_publish_year ON books(publish_year);
+CREATE INDEX idx_books_ddc ON books(ddc, cutter);
+CREATE TRIGGER trg_books_updated_at AFTER UPDATE ON books FOR EACH ROW WHEN NEW.updated_at = OLD.updated_at BEGIN UPDATE books SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id; END;
+
+DROP TABLE IF EXISTS works;
+DROP TABLE IF EXISTS series;
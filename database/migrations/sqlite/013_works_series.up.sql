@@ -0,0 +1,26 @@
+-- Works group the editions of one title, and series number their works as
+-- volumes, such as the Bumi series.
+CREATE TABLE series (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE works (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(255) NOT NULL,
+    series_id INTEGER REFERENCES series(id) ON DELETE SET NULL,
+    volume INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_works_series ON works(series_id, volume);
+
+ALTER TABLE books ADD COLUMN work_id INTEGER REFERENCES works(id) ON DELETE SET NULL;
+ALTER TABLE books ADD COLUMN edition VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_books_work ON books(work_id);
+
+-- A hold on any edition of the book's work, not only the one asked for
+ALTER TABLE holds ADD COLUMN any_edition BOOLEAN NOT NULL DEFAULT FALSE;
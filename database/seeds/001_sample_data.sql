@@ -0,0 +1,86 @@
+-- Sample data for development and demos (optional)
+-- Run once on a freshly migrated database: go run ./cmd seed
+
+-- Insert default admin user (password: admin123)
+INSERT INTO users (username, email, password, name, role) VALUES
+('admin', 'admin@simpus.local', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', 'Administrator', 'admin');
+
+-- Insert sample categories
+INSERT INTO categories (name, description) VALUES
+('Fiksi', 'Buku-buku cerita fiksi dan novel'),
+('Non-Fiksi', 'Buku-buku pengetahuan dan fakta'),
+('Pendidikan', 'Buku pelajaran dan akademik'),
+('Teknologi', 'Buku tentang teknologi dan komputer'),
+('Sejarah', 'Buku tentang sejarah dan budaya'),
+('Sains', 'Buku ilmu pengetahuan alam');
+
+INSERT INTO categories (name, description, parent_id) VALUES
+('Fisika', 'Buku fisika', 6),
+('Mekanika', 'Gerak dan gaya', 7);
+
+-- Insert sample authors
+INSERT INTO authors (name, bio) VALUES
+('Andrea Hirata', 'Penulis novel Laskar Pelangi'),
+('Tere Liye', 'Penulis novel populer Indonesia'),
+('Pramoedya Ananta Toer', 'Sastrawan Indonesia terkenal'),
+('J.K. Rowling', 'Penulis seri Harry Potter'),
+('Robert C. Martin', 'Penulis buku Clean Code');
+
+-- Insert sample books
+INSERT INTO books (isbn, title, category_id, publisher, publish_year, stock, available, description) VALUES
+('978-602-03-1234-5', 'Laskar Pelangi', 1, 'Bentang Pustaka', 2005, 5, 5, 'Novel tentang perjuangan anak-anak Belitung dalam menempuh pendidikan'),
+('978-602-03-2345-6', 'Bumi', 1, 'Gramedia', 2014, 3, 3, 'Novel fantasi pertama dari serial Bumi'),
+('978-602-03-3456-7', 'Bumi Manusia', 1, 'Hasta Mitra', 1980, 4, 4, 'Novel sejarah tentang perjuangan di era kolonial'),
+('978-0-13-235088-4', 'Clean Code', 4, 'Prentice Hall', 2008, 2, 2, 'Panduan menulis kode yang bersih dan mudah dipelihara'),
+('978-602-03-4567-8', 'Bulan', 1, 'Gramedia', 2015, 3, 3, 'Novel fantasi kedua dari serial Bumi');
+
+INSERT INTO book_contributors (book_id, author_id, role, position) VALUES
+(1, 1, 'author', 0),
+(2, 2, 'author', 0),
+(3, 3, 'author', 0),
+(4, 5, 'author', 0),
+(5, 2, 'author', 0);
+
+INSERT INTO book_subjects (book_id, subject, position) VALUES
+(1, 'Belitung', 0),
+(1, 'Persahabatan', 1),
+(3, 'Kolonialisme', 0),
+(4, 'Rekayasa perangkat lunak', 0);
+
+-- Books are in Indonesian unless stated otherwise
+UPDATE books SET language = 'en' WHERE isbn = '978-0-13-235088-4';
+
+-- Shelved by Dewey Decimal class: Indonesian fiction and programming
+UPDATE books SET ddc = '899.2213';
+UPDATE books SET ddc = '005.1' WHERE isbn = '978-0-13-235088-4';
+UPDATE books SET cutter = 'HIR' WHERE isbn = '978-602-03-1234-5';
+UPDATE books SET cutter = 'TOE' WHERE isbn = '978-602-03-3456-7';
+UPDATE books SET cutter = 'MAR' WHERE isbn = '978-0-13-235088-4';
+UPDATE books SET cutter = 'LIY' WHERE isbn IN ('978-602-03-2345-6', '978-602-03-4567-8');
+
+-- Bumi and Bulan are the first two volumes of the Bumi series
+INSERT INTO series (name, description) VALUES
+('Serial Bumi', 'Serial fantasi karya Tere Liye');
+
+INSERT INTO works (title, series_id, volume) VALUES
+('Bumi', 1, 1),
+('Bulan', 1, 2);
+
+UPDATE books SET work_id = 1 WHERE isbn = '978-602-03-2345-6';
+UPDATE books SET work_id = 2 WHERE isbn = '978-602-03-4567-8';
+
+-- Stock the sample books at the main library, with two copies of Laskar
+-- Pelangi at a second branch
+INSERT INTO branches (code, name, address) VALUES
+('TIMUR', 'Perpustakaan Cabang Timur', 'Jl. Merdeka Timur No. 10');
+
+INSERT INTO branch_stock (book_id, branch_id, stock, available) SELECT id, 1, stock, available FROM books;
+UPDATE branch_stock SET stock = 3, available = 3 WHERE book_id = 1 AND branch_id = 1;
+INSERT INTO branch_stock (book_id, branch_id, stock, available) VALUES (1, 2, 2, 2);
+
+-- Insert sample members
+INSERT INTO members (member_code, identity_number, name, email, password, phone, member_type, address, email_verified_at) VALUES
+('MHS001', '2021010001', 'Budi Santoso', 'budi@student.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567890', 'mahasiswa', 'Jl. Pendidikan No. 1', CURRENT_TIMESTAMP),
+('MHS002', '2021010002', 'Siti Aminah', 'siti@student.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567891', 'mahasiswa', 'Jl. Ilmu No. 2', CURRENT_TIMESTAMP),
+('GRU001', '197805122005011003', 'Drs. Ahmad Wijaya', 'ahmad@school.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567892', 'guru', 'Jl. Guru No. 3', CURRENT_TIMESTAMP),
+('KRY001', '198503152010122001', 'Dewi Lestari', 'dewi@office.ac.id', '$2a$10$N9qo8uLOickgx2ZMRZoMye.H9p4FxP7j1FQfXR2X9j5.3MLqImZ4a', '081234567893', 'karyawan', 'Jl. Kantor No. 4', CURRENT_TIMESTAMP);
@@ -0,0 +1,43 @@
+// Package sqlitetest provides throwaway SQLite databases with the full
+// schema applied, so repository tests run without a MySQL server.
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"simpus/database"
+)
+
+var counter atomic.Int64
+
+// Open returns a migrated in-memory database that is closed when t ends.
+// Every call gets its own database.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	name := fmt.Sprintf("file:simpus_test_%d?mode=memory&cache=shared", counter.Add(1))
+	db, err := database.OpenSQLite(name)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := database.NewMigrator(db, database.SQLite, io.Discard).Up(context.Background(), 0); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	return db
+}
+
+// Seed loads the sample data from database/seeds into db.
+func Seed(t testing.TB, db *sql.DB) {
+	t.Helper()
+
+	if err := database.Seed(context.Background(), db, io.Discard); err != nil {
+		t.Fatalf("seed sqlite: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"simpus/internal/metrics"
+	"simpus/internal/models"
+)
+
+// Identity is a user asserted by an external identity provider.
+type Identity struct {
+	Provider       string
+	Subject        string
+	Email          string
+	EmailVerified  bool // the provider vouches for Email; only then is it used to link accounts
+	Name           string
+	IdentityNumber string // NIM/NIP when the provider supplies it
+}
+
+// OIDCProvider runs the OpenID Connect authorization-code flow.
+type OIDCProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, nonce, redirectURL string) (string, error)
+	Exchange(ctx context.Context, code, nonce, redirectURL string) (*Identity, error)
+}
+
+// PasswordAuthenticator checks a username and password against an external
+// directory such as LDAP.
+type PasswordAuthenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*Identity, error)
+}
+
+var ErrAccountNotLinked = errors.New("akun tidak terhubung dengan data perpustakaan, silakan hubungi pustakawan")
+
+// UseOIDC enables single sign-on through provider.
+func (s *Service) UseOIDC(provider OIDCProvider) {
+	s.oidc = provider
+}
+
+// UseDirectory enables staff login against an external directory.
+func (s *Service) UseDirectory(authenticator PasswordAuthenticator) {
+	s.directory = authenticator
+}
+
+func (s *Service) OIDCEnabled() bool {
+	return s.oidc != nil
+}
+
+func (s *Service) OIDCAuthURL(ctx context.Context, state, nonce, redirectURL string) (string, error) {
+	if s.oidc == nil {
+		return "", errors.New("login SSO tidak aktif")
+	}
+	return s.oidc.AuthCodeURL(ctx, state, nonce, redirectURL)
+}
+
+// LoginOIDC completes an SSO login for accountType ("admin" or "member") and
+// returns the signed session token.
+func (s *Service) LoginOIDC(ctx context.Context, accountType, code, nonce, redirectURL string) (string, error) {
+	if s.oidc == nil {
+		return "", errors.New("login SSO tidak aktif")
+	}
+
+	identity, err := s.oidc.Exchange(ctx, code, nonce, redirectURL)
+	if err != nil {
+		slog.WarnContext(ctx, "oidc: exchange", "error", err)
+		metrics.LoginFailures.WithLabelValues(accountType).Inc()
+		return "", errors.New("login SSO gagal")
+	}
+
+	if accountType == "admin" {
+		user, err := s.linkUser(identity)
+		if err != nil {
+			return "", err
+		}
+		return s.staffToken(user)
+	}
+
+	member, err := s.linkMember(identity)
+	if err != nil {
+		return "", err
+	}
+	return s.generateToken(member.ID, member.Email, member.MemberType, "member", member.SessionVersion, 0)
+}
+
+func (s *Service) loginDirectory(ctx context.Context, username, password string) (*models.User, error) {
+	identity, err := s.directory.Authenticate(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.linkUser(identity)
+}
+
+// linkUser resolves a staff account for identity: an existing link first,
+// then a verified email address. Usernames are never matched, as anyone
+// may pick "admin" at the identity provider. Staff are never provisioned
+// automatically.
+func (s *Service) linkUser(identity *Identity) (*models.User, error) {
+	if link, err := s.identityRepo.Find(identity.Provider, identity.Subject, "admin"); err == nil {
+		user, err := s.userRepo.FindByID(link.AccountID)
+		if err != nil {
+			return nil, ErrAccountNotLinked
+		}
+		s.identityRepo.TouchLogin(link.ID)
+		return activeUser(user)
+	}
+
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, ErrAccountNotLinked
+	}
+	user, err := s.userRepo.FindByEmail(identity.Email)
+	if err != nil {
+		return nil, ErrAccountNotLinked
+	}
+
+	if err := s.createLink(identity, "admin", user.ID); err != nil {
+		return nil, err
+	}
+	return activeUser(user)
+}
+
+// linkMember resolves a member for identity by existing link, verified
+// email or NIM, provisioning a new active member when OIDC_JIT_PROVISION is
+// enabled and the email is verified.
+func (s *Service) linkMember(identity *Identity) (*models.Member, error) {
+	if link, err := s.identityRepo.Find(identity.Provider, identity.Subject, "member"); err == nil {
+		member, err := s.memberRepo.FindByID(link.AccountID)
+		if err != nil {
+			return nil, ErrAccountNotLinked
+		}
+		s.identityRepo.TouchLogin(link.ID)
+		return activeMember(member)
+	}
+
+	var member *models.Member
+	verified := identity.EmailVerified && identity.Email != ""
+	if verified {
+		member, _ = s.memberRepo.FindByEmail(identity.Email)
+	}
+	if member == nil && identity.IdentityNumber != "" {
+		member, _ = s.memberRepo.FindByIdentityNumber(identity.IdentityNumber)
+	}
+
+	if member == nil {
+		if !s.config.OIDC.JITProvision || !verified {
+			return nil, ErrAccountNotLinked
+		}
+		provisioned, err := s.provisionMember(identity)
+		if err != nil {
+			return nil, err
+		}
+		member = provisioned
+	}
+
+	if err := s.createLink(identity, "member", member.ID); err != nil {
+		return nil, err
+	}
+	return activeMember(member)
+}
+
+func (s *Service) provisionMember(identity *Identity) (*models.Member, error) {
+	memberType := s.config.OIDC.DefaultMemberType
+	memberCode, err := s.memberRepo.GenerateMemberCode(memberType)
+	if err != nil {
+		return nil, err
+	}
+
+	// SSO members never use a local password; store an unguessable one
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.credentials.Hash(base64.RawURLEncoding.EncodeToString(random))
+	if err != nil {
+		return nil, err
+	}
+
+	name := identity.Name
+	if name == "" {
+		name = strings.Split(identity.Email, "@")[0]
+	}
+
+	// The identity provider has already verified the email address
+	id, err := s.memberRepo.Create(&models.MemberCreate{
+		IdentityNumber: identity.IdentityNumber,
+		Name:           name,
+		Email:          identity.Email,
+		MemberType:     memberType,
+		Status:         models.MemberStatusActive,
+	}, hashedPassword, memberCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.memberRepo.FindByID(int(id))
+}
+
+func (s *Service) createLink(identity *Identity, accountType string, accountID int) error {
+	_, err := s.identityRepo.Create(&models.ExternalIdentity{
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		AccountType: accountType,
+		AccountID:   accountID,
+		Email:       identity.Email,
+	})
+	return err
+}
+
+func activeUser(user *models.User) (*models.User, error) {
+	if !user.IsActive {
+		return nil, errors.New("akun tidak aktif")
+	}
+	return user, nil
+}
+
+func activeMember(member *models.Member) (*models.Member, error) {
+	if !member.IsActive {
+		return nil, errors.New("akun tidak aktif")
+	}
+	if member.Status == models.MemberStatusRejected {
+		return nil, errors.New("pendaftaran akun ditolak, silakan hubungi pustakawan")
+	}
+	return member, nil
+}
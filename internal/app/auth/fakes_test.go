@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"simpus/config"
+	"simpus/internal/credentials"
+	"simpus/internal/mailer"
+	"simpus/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserRepo struct {
+	users map[int]*models.User
+}
+
+func (r *fakeUserRepo) find(match func(*models.User) bool) (*models.User, error) {
+	for _, u := range r.users {
+		if match(u) {
+			copy := *u
+			return &copy, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeUserRepo) FindByUsername(username string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Username == username })
+}
+
+func (r *fakeUserRepo) FindByEmail(email string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Email == email })
+}
+
+func (r *fakeUserRepo) FindByID(id int) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.ID == id })
+}
+
+func (r *fakeUserRepo) Create(user *models.UserCreate, hashedPassword string) (int64, error) {
+	id := len(r.users) + 1
+	r.users[id] = &models.User{ID: id, Username: user.Username, Email: user.Email, Password: hashedPassword, BranchID: user.BranchID, IsActive: true}
+	return int64(id), nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(id int, hashedPassword string) error {
+	r.users[id].Password = hashedPassword
+	r.users[id].SessionVersion++
+	return nil
+}
+
+func (r *fakeUserRepo) RehashPassword(id int, hashedPassword string) error {
+	r.users[id].Password = hashedPassword
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateBranch(id int, branchID *int) error {
+	r.users[id].BranchID = branchID
+	r.users[id].SessionVersion++
+	return nil
+}
+
+func (r *fakeUserRepo) FindAll() ([]models.User, error) {
+	var list []models.User
+	for _, u := range r.users {
+		list = append(list, *u)
+	}
+	return list, nil
+}
+
+type fakeMemberRepo struct {
+	members map[int]*models.Member
+}
+
+func (r *fakeMemberRepo) find(match func(*models.Member) bool) (*models.Member, error) {
+	for _, m := range r.members {
+		if match(m) {
+			copy := *m
+			return &copy, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeMemberRepo) FindByID(id int) (*models.Member, error) {
+	return r.find(func(m *models.Member) bool { return m.ID == id })
+}
+
+func (r *fakeMemberRepo) FindByEmail(email string) (*models.Member, error) {
+	return r.find(func(m *models.Member) bool { return m.Email == email })
+}
+
+func (r *fakeMemberRepo) FindByIdentityNumber(identityNumber string) (*models.Member, error) {
+	return r.find(func(m *models.Member) bool { return m.IdentityNumber == identityNumber })
+}
+
+func (r *fakeMemberRepo) GenerateMemberCode(memberType string) (string, error) {
+	last := ""
+	for _, m := range r.members {
+		if m.MemberType == memberType && m.MemberCode > last {
+			last = m.MemberCode
+		}
+	}
+	return models.NextMemberCode(memberType, last), nil
+}
+
+func (r *fakeMemberRepo) Create(data *models.MemberCreate, hashedPassword, memberCode string) (int64, error) {
+	id := len(r.members) + 1
+	r.members[id] = &models.Member{
+		ID:             id,
+		MemberCode:     memberCode,
+		IdentityNumber: data.IdentityNumber,
+		Name:           data.Name,
+		Email:          data.Email,
+		Password:       hashedPassword,
+		MemberType:     data.MemberType,
+		IsActive:       true,
+		Status:         data.Status,
+	}
+	return int64(id), nil
+}
+
+func (r *fakeMemberRepo) UpdatePassword(id int, hashedPassword string) error {
+	r.members[id].Password = hashedPassword
+	r.members[id].SessionVersion++
+	return nil
+}
+
+func (r *fakeMemberRepo) RehashPassword(id int, hashedPassword string) error {
+	r.members[id].Password = hashedPassword
+	return nil
+}
+
+func (r *fakeMemberRepo) MarkEmailVerified(id int, nextStatus string) error {
+	m := r.members[id]
+	if m.Status != models.MemberStatusPendingVerification {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	m.Status, m.EmailVerifiedAt = nextStatus, &now
+	return nil
+}
+
+type fakeResetRepo struct {
+	resets  map[int]*models.PasswordReset
+	users   *fakeUserRepo
+	members *fakeMemberRepo
+}
+
+func (r *fakeResetRepo) Create(accountType string, accountID int, tokenHash string, expiresAt time.Time) (int64, error) {
+	id := len(r.resets) + 1
+	r.resets[id] = &models.PasswordReset{ID: id, AccountType: accountType, AccountID: accountID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	return int64(id), nil
+}
+
+func (r *fakeResetRepo) FindByTokenHash(tokenHash string) (*models.PasswordReset, error) {
+	for _, reset := range r.resets {
+		if reset.TokenHash == tokenHash {
+			copy := *reset
+			return &copy, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeResetRepo) Consume(reset *models.PasswordReset, hashedPassword string) error {
+	if r.resets[reset.ID].UsedAt != nil {
+		return sql.ErrNoRows
+	}
+	if reset.AccountType == "member" {
+		r.members.UpdatePassword(reset.AccountID, hashedPassword)
+	} else {
+		r.users.UpdatePassword(reset.AccountID, hashedPassword)
+	}
+	return r.InvalidateAll(reset.AccountType, reset.AccountID)
+}
+
+func (r *fakeResetRepo) InvalidateAll(accountType string, accountID int) error {
+	for _, reset := range r.resets {
+		if reset.AccountType == accountType && reset.AccountID == accountID && reset.UsedAt == nil {
+			now := time.Now()
+			reset.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeIdentityRepo struct {
+	links []models.ExternalIdentity
+}
+
+func (r *fakeIdentityRepo) Find(provider, subject, accountType string) (*models.ExternalIdentity, error) {
+	for _, link := range r.links {
+		if link.Provider == provider && link.Subject == subject && link.AccountType == accountType {
+			copy := link
+			return &copy, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeIdentityRepo) Create(ei *models.ExternalIdentity) (int64, error) {
+	ei.ID = len(r.links) + 1
+	r.links = append(r.links, *ei)
+	return int64(ei.ID), nil
+}
+
+func (r *fakeIdentityRepo) TouchLogin(id int) error { return nil }
+
+type fakeHistoryRepo struct {
+	hashes map[string][]string
+}
+
+func (r *fakeHistoryRepo) Create(accountType string, accountID int, passwordHash string) error {
+	key := fmt.Sprintf("%s:%d", accountType, accountID)
+	r.hashes[key] = append([]string{passwordHash}, r.hashes[key]...)
+	return nil
+}
+
+func (r *fakeHistoryRepo) Recent(accountType string, accountID int, limit int) ([]string, error) {
+	hashes := r.hashes[fmt.Sprintf("%s:%d", accountType, accountID)]
+	if len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+	return hashes, nil
+}
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []*mailer.Message
+}
+
+func (m *fakeMailer) Send(msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+type fixture struct {
+	service *Service
+	users   *fakeUserRepo
+	members *fakeMemberRepo
+	resets  *fakeResetRepo
+	history *fakeHistoryRepo
+	creds   *credentials.Manager
+}
+
+func testPasswordConfig() config.PasswordConfig {
+	return config.PasswordConfig{
+		MinLength:   8,
+		HistorySize: 3,
+		Algorithm:   credentials.AlgorithmBcrypt,
+		BcryptCost:  bcrypt.MinCost,
+	}
+}
+
+func newFixture(t *testing.T, passwordCfg config.PasswordConfig) *fixture {
+	t.Helper()
+
+	f := &fixture{
+		users:   &fakeUserRepo{users: map[int]*models.User{}},
+		members: &fakeMemberRepo{members: map[int]*models.Member{}},
+		history: &fakeHistoryRepo{hashes: map[string][]string{}},
+	}
+	f.resets = &fakeResetRepo{resets: map[int]*models.PasswordReset{}, users: f.users, members: f.members}
+
+	creds, err := credentials.NewManager(passwordCfg, f.history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.creds = creds
+
+	cfg := &config.Config{
+		JWT:  config.JWTConfig{Secret: "test-secret", Expiry: time.Hour},
+		App:  config.AppConfig{Name: "SIMPUS", BaseURL: "http://simpus.test"},
+		Auth: config.AuthConfig{ResetTokenExpiry: time.Hour, VerificationTokenExpiry: time.Hour},
+	}
+	f.service = NewService(f.users, f.members, f.resets, &fakeIdentityRepo{}, creds, &fakeMailer{}, cfg)
+	return f
+}
+
+// hash stores password with the cost of cfg, independent of the service's
+// current configuration.
+func hash(t *testing.T, cfg config.PasswordConfig, password string) string {
+	t.Helper()
+
+	hasher, err := credentials.NewHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hashed
+}
@@ -1,39 +1,46 @@
 package auth
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
+	"strings"
 	"time"
 )
 
 type Handler struct {
-	service   *Service
-	templates *template.Template
+	service *Service
+	views   *renderer.Renderer
+	baseURL string
 }
 
-func NewHandler(service *Service, templates *template.Template) *Handler {
+func NewHandler(service *Service, views *renderer.Renderer, baseURL string) *Handler {
 	return &Handler{
-		service:   service,
-		templates: templates,
+		service: service,
+		views:   views,
+		baseURL: baseURL,
 	}
 }
 
 func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
-		"Title": "Login Admin - SIMPUS",
-		"Error": r.URL.Query().Get("error"),
+		"Title":       "Login Admin - SIMPUS",
+		"Error":       r.URL.Query().Get("error"),
+		"Success":     r.URL.Query().Get("success"),
+		"OIDCEnabled": h.service.OIDCEnabled(),
 	}
-	h.render(w, "auth/login.html", data)
+	h.views.Render(w, r, "auth/login.html", data)
 }
 
 func (h *Handler) MemberLoginPage(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
-		"Title": "Login Anggota - SIMPUS",
-		"Error": r.URL.Query().Get("error"),
+		"Title":       "Login Anggota - SIMPUS",
+		"Error":       r.URL.Query().Get("error"),
+		"Success":     r.URL.Query().Get("success"),
+		"OIDCEnabled": h.service.OIDCEnabled(),
 	}
-	h.render(w, "auth/login-member.html", data)
+	h.views.Render(w, r, "auth/login-member.html", data)
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -45,7 +52,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
-	user, token, err := h.service.LoginAdmin(username, password)
+	user, token, err := h.service.LoginAdmin(r.Context(), username, password)
 	if err != nil {
 		http.Redirect(w, r, "/login?error="+err.Error(), http.StatusSeeOther)
 		return
@@ -66,7 +73,7 @@ func (h *Handler) MemberLogin(w http.ResponseWriter, r *http.Request) {
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
-	member, token, err := h.service.LoginMember(email, password)
+	member, token, err := h.service.LoginMember(r.Context(), email, password)
 	if err != nil {
 		http.Redirect(w, r, "/login/member?error="+err.Error(), http.StatusSeeOther)
 		return
@@ -83,7 +90,7 @@ func (h *Handler) RegisterMemberPage(w http.ResponseWriter, r *http.Request) {
 		"Title": "Registrasi Anggota - SIMPUS",
 		"Error": r.URL.Query().Get("error"),
 	}
-	h.render(w, "auth/register-member.html", data)
+	h.views.Render(w, r, "auth/register-member.html", data)
 }
 
 func (h *Handler) RegisterMember(w http.ResponseWriter, r *http.Request) {
@@ -93,12 +100,13 @@ func (h *Handler) RegisterMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := &models.MemberCreate{
-		Name:       r.FormValue("name"),
-		Email:      r.FormValue("email"),
-		Password:   r.FormValue("password"),
-		Phone:      r.FormValue("phone"),
-		MemberType: r.FormValue("member_type"),
-		Address:    r.FormValue("address"),
+		IdentityNumber: r.FormValue("identity_number"),
+		Name:           r.FormValue("name"),
+		Email:          r.FormValue("email"),
+		Password:       r.FormValue("password"),
+		Phone:          r.FormValue("phone"),
+		MemberType:     r.FormValue("member_type"),
+		Address:        r.FormValue("address"),
 	}
 
 	if data.Name == "" || data.Email == "" || data.Password == "" {
@@ -106,13 +114,216 @@ func (h *Handler) RegisterMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := h.service.RegisterMember(data)
+	_, err := h.service.RegisterMember(r.Context(), data)
 	if err != nil {
 		http.Redirect(w, r, "/register?error="+err.Error(), http.StatusSeeOther)
 		return
 	}
 
-	http.Redirect(w, r, "/login/member?success=Registrasi berhasil, silakan login", http.StatusSeeOther)
+	http.Redirect(w, r, "/login/member?success=Registrasi berhasil, silakan cek email Anda untuk verifikasi akun", http.StatusSeeOther)
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	member, err := h.service.VerifyEmail(r.URL.Query().Get("token"))
+	if err != nil {
+		http.Redirect(w, r, "/login/member?error="+err.Error(), http.StatusSeeOther)
+		return
+	}
+
+	message := "Email berhasil diverifikasi, akun Anda sudah aktif"
+	if member.Status == models.MemberStatusPendingApproval {
+		message = "Email berhasil diverifikasi, akun Anda menunggu persetujuan pustakawan"
+	}
+	http.Redirect(w, r, "/login/member?success="+message, http.StatusSeeOther)
+}
+
+func (h *Handler) ResendVerificationPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Title":   "Kirim Ulang Verifikasi - SIMPUS",
+		"Error":   r.URL.Query().Get("error"),
+		"Success": r.URL.Query().Get("success"),
+	}
+	h.views.Render(w, r, "auth/resend-verification.html", data)
+}
+
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/verify-email/resend?error=Form tidak valid", http.StatusSeeOther)
+		return
+	}
+
+	email := r.FormValue("email")
+	if email == "" {
+		http.Redirect(w, r, "/verify-email/resend?error=Email wajib diisi", http.StatusSeeOther)
+		return
+	}
+
+	h.service.ResendVerification(r.Context(), email)
+
+	http.Redirect(w, r, "/verify-email/resend?success=Jika akun menunggu verifikasi, tautan baru telah dikirim ke email tersebut", http.StatusSeeOther)
+}
+
+func (h *Handler) ForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	accountType := r.URL.Query().Get("type")
+	if accountType != "admin" {
+		accountType = "member"
+	}
+
+	data := map[string]interface{}{
+		"Title":       "Lupa Password - SIMPUS",
+		"AccountType": accountType,
+		"Error":       r.URL.Query().Get("error"),
+		"Success":     r.URL.Query().Get("success"),
+	}
+	h.views.Render(w, r, "auth/forgot-password.html", data)
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/forgot-password?error=Form tidak valid", http.StatusSeeOther)
+		return
+	}
+
+	accountType := r.FormValue("type")
+	if accountType != "admin" {
+		accountType = "member"
+	}
+
+	email := r.FormValue("email")
+	if email == "" {
+		http.Redirect(w, r, "/forgot-password?type="+accountType+"&error=Email wajib diisi", http.StatusSeeOther)
+		return
+	}
+
+	h.service.RequestPasswordReset(r.Context(), accountType, email)
+
+	// Same answer whether or not the email is registered
+	http.Redirect(w, r, "/forgot-password?type="+accountType+
+		"&success=Jika email terdaftar, tautan reset password telah dikirim ke email tersebut", http.StatusSeeOther)
+}
+
+func (h *Handler) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	data := map[string]interface{}{
+		"Title": "Reset Password - SIMPUS",
+		"Token": token,
+		"Error": r.URL.Query().Get("error"),
+	}
+
+	if _, err := h.service.ValidateResetToken(token); err != nil {
+		data["Error"] = err.Error()
+		data["Invalid"] = true
+	}
+
+	h.views.Render(w, r, "auth/reset-password.html", data)
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/forgot-password?error=Form tidak valid", http.StatusSeeOther)
+		return
+	}
+
+	token := r.FormValue("token")
+	password := r.FormValue("password")
+
+	if password != r.FormValue("password_confirmation") {
+		http.Redirect(w, r, "/reset-password?token="+url.QueryEscape(token)+"&error=Konfirmasi password tidak sama", http.StatusSeeOther)
+		return
+	}
+
+	accountType, err := h.service.ResetPassword(token, password)
+	if err != nil {
+		http.Redirect(w, r, "/reset-password?token="+url.QueryEscape(token)+"&error="+err.Error(), http.StatusSeeOther)
+		return
+	}
+
+	loginURL := "/login/member"
+	if accountType == "admin" {
+		loginURL = "/login"
+	}
+	http.Redirect(w, r, loginURL+"?success=Password berhasil diubah, silakan login kembali", http.StatusSeeOther)
+}
+
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	accountType := r.URL.Query().Get("type")
+	if accountType != "admin" {
+		accountType = "member"
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := h.service.OIDCAuthURL(r.Context(), state, nonce, h.oidcRedirectURL())
+	if err != nil {
+		http.Redirect(w, r, loginPath(accountType)+"?error=Login SSO tidak tersedia", http.StatusSeeOther)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "oidc_state",
+		Value:    accountType + "|" + state + "|" + nonce,
+		Path:     "/auth/oidc",
+		Expires:  time.Now().Add(10 * time.Minute),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie("oidc_state")
+	if err != nil {
+		http.Redirect(w, r, "/login/member?error=Sesi login SSO kedaluwarsa", http.StatusSeeOther)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "oidc_state", Path: "/auth/oidc", Expires: time.Unix(0, 0), HttpOnly: true})
+
+	parts := strings.SplitN(cookie.Value, "|", 3)
+	if len(parts) != 3 || parts[1] != r.URL.Query().Get("state") {
+		http.Redirect(w, r, "/login/member?error=Sesi login SSO tidak valid", http.StatusSeeOther)
+		return
+	}
+	accountType, nonce := parts[0], parts[2]
+
+	if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+		http.Redirect(w, r, loginPath(accountType)+"?error=Login SSO dibatalkan", http.StatusSeeOther)
+		return
+	}
+
+	token, err := h.service.LoginOIDC(r.Context(), accountType, r.URL.Query().Get("code"), nonce, h.oidcRedirectURL())
+	if err != nil {
+		http.Redirect(w, r, loginPath(accountType)+"?error="+err.Error(), http.StatusSeeOther)
+		return
+	}
+
+	h.setTokenCookie(w, token)
+
+	if accountType == "admin" {
+		http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/member/dashboard", http.StatusSeeOther)
+}
+
+func (h *Handler) oidcRedirectURL() string {
+	return h.baseURL + "/auth/oidc/callback"
+}
+
+func loginPath(accountType string) string {
+	if accountType == "admin" {
+		return "/login"
+	}
+	return "/login/member"
 }
 
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -136,24 +347,3 @@ func (h *Handler) setTokenCookie(w http.ResponseWriter, token string) {
 		SameSite: http.SameSiteLaxMode,
 	})
 }
-
-func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "auth.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "auth.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+
+	"simpus/internal/models"
+)
+
+// IdentityRepository stores links to external identity providers.
+type IdentityRepository interface {
+	Find(provider, subject, accountType string) (*models.ExternalIdentity, error)
+	Create(ei *models.ExternalIdentity) (int64, error)
+	TouchLogin(id int) error
+}
+
+type identityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) Find(provider, subject, accountType string) (*models.ExternalIdentity, error) {
+	ei := &models.ExternalIdentity{}
+	var email sql.NullString
+	var lastLogin sql.NullTime
+	query := `SELECT id, provider, subject, account_type, account_id, email, created_at, last_login_at 
+			  FROM external_identities WHERE provider = ? AND subject = ? AND account_type = ?`
+
+	err := r.db.QueryRow(query, provider, subject, accountType).Scan(
+		&ei.ID, &ei.Provider, &ei.Subject, &ei.AccountType, &ei.AccountID, &email, &ei.CreatedAt, &lastLogin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ei.Email = email.String
+	if lastLogin.Valid {
+		ei.LastLoginAt = &lastLogin.Time
+	}
+	return ei, nil
+}
+
+func (r *identityRepository) Create(ei *models.ExternalIdentity) (int64, error) {
+	query := `INSERT INTO external_identities (provider, subject, account_type, account_id, email, last_login_at) 
+			  VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.Exec(query, ei.Provider, ei.Subject, ei.AccountType, ei.AccountID, ei.Email, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *identityRepository) TouchLogin(id int) error {
+	_, err := r.db.Exec(`UPDATE external_identities SET last_login_at = ? WHERE id = ?`, time.Now(), id)
+	return err
+}
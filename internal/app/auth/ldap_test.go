@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"simpus/config"
+	"simpus/internal/models"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+type directoryEntry struct {
+	dn, uid, password, mail, cn string
+}
+
+// stubDirectory is a stand-in LDAP server answering simple binds and
+// equality searches on uid. Searches need a bound connection, as on the
+// campus directory.
+type stubDirectory struct {
+	listener net.Listener
+	entries  []directoryEntry
+}
+
+func newStubDirectory(t *testing.T, entries ...directoryEntry) *stubDirectory {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &stubDirectory{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return d
+}
+
+func (d *stubDirectory) config() config.LDAPConfig {
+	return config.LDAPConfig{
+		Enabled:        true,
+		URL:            "ldap://" + d.listener.Addr().String(),
+		BindDN:         "cn=simpus,dc=kampus",
+		BindPassword:   "layanan",
+		BaseDN:         "dc=kampus",
+		UserFilter:     "(uid=%s)",
+		EmailAttribute: "mail",
+		NameAttribute:  "cn",
+	}
+}
+
+func (d *stubDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+
+	bound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			bound = d.bind(dn, password)
+			code := ldap.LDAPResultSuccess
+			if !bound {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			conn.Write(ldapResult(id, ldap.ApplicationBindResponse, code))
+
+		case ldap.ApplicationSearchRequest:
+			if !bound {
+				conn.Write(ldapResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights))
+				continue
+			}
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				conn.Write(ldapResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultFilterError))
+				continue
+			}
+			for _, e := range d.entries {
+				if filter == fmt.Sprintf("(uid=%s)", e.uid) {
+					conn.Write(searchEntry(id, e))
+				}
+			}
+			conn.Write(ldapResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *stubDirectory) bind(dn, password string) bool {
+	if dn == "cn=simpus,dc=kampus" {
+		return password == "layanan"
+	}
+	for _, e := range d.entries {
+		if e.dn == dn {
+			return password != "" && password == e.password
+		}
+	}
+	return false
+}
+
+func ldapMessage(id int64, op *ber.Packet) []byte {
+	msg := ber.NewSequence("LDAP Response")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	msg.AppendChild(op)
+	return msg.Bytes()
+}
+
+func ldapResult(id int64, tag ber.Tag, code int) []byte {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return ldapMessage(id, op)
+}
+
+func searchEntry(id int64, e directoryEntry) []byte {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+	attrs := ber.NewSequence("attributes")
+	for name, value := range map[string]string{"uid": e.uid, "mail": e.mail, "cn": e.cn} {
+		attr := ber.NewSequence("attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return ldapMessage(id, op)
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	dir := newStubDirectory(t,
+		directoryEntry{dn: "uid=pustakawan,ou=staf,dc=kampus", uid: "pustakawan", password: "rahasia123", mail: "pustakawan@kampus.ac.id", cn: "Pustakawan"},
+		// Two entries for one uid must not log in as either
+		directoryEntry{dn: "uid=ganda,ou=staf,dc=kampus", uid: "ganda", password: "rahasia123"},
+		directoryEntry{dn: "uid=ganda,ou=mhs,dc=kampus", uid: "ganda", password: "rahasia123"},
+	)
+	ctx := context.Background()
+
+	identity, err := NewLDAPAuthenticator(dir.config()).Authenticate(ctx, "pustakawan", "rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Provider: "ldap", Subject: "uid=pustakawan,ou=staf,dc=kampus", Email: "pustakawan@kampus.ac.id", EmailVerified: true, Name: "Pustakawan"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	tests := []struct {
+		name, username, password string
+	}{
+		{"wrong password", "pustakawan", "salah"},
+		{"empty password", "pustakawan", ""},
+		{"unknown user", "asing", "rahasia123"},
+		{"wildcard", "*", "rahasia123"},
+		{"filter injection", "pustakawan)(uid=*", "rahasia123"},
+		{"ambiguous user", "ganda", "rahasia123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if identity, err := NewLDAPAuthenticator(dir.config()).Authenticate(ctx, tt.username, tt.password); err == nil {
+				t.Fatalf("identity = %+v, want an error", identity)
+			}
+		})
+	}
+
+	cfg := dir.config()
+	cfg.BindPassword = "salah"
+	if _, err := NewLDAPAuthenticator(cfg).Authenticate(ctx, "pustakawan", "rahasia123"); err == nil {
+		t.Error("failed service bind accepted")
+	}
+}
+
+func TestLoginAdminLDAP(t *testing.T) {
+	dir := newStubDirectory(t,
+		directoryEntry{dn: "uid=pustakawan,ou=staf,dc=kampus", uid: "pustakawan", password: "rahasia123", mail: "pustakawan@kampus.ac.id", cn: "Pustakawan"},
+		directoryEntry{dn: "uid=asing,ou=staf,dc=kampus", uid: "asing", password: "rahasia123", mail: "asing@kampus.ac.id", cn: "Asing"},
+	)
+	f := newFixture(t, testPasswordConfig())
+	f.service.UseDirectory(NewLDAPAuthenticator(dir.config()))
+	f.users.users[1] = &models.User{ID: 1, Username: "staf1", Email: "pustakawan@kampus.ac.id", Role: "pustakawan", IsActive: true}
+	ctx := context.Background()
+
+	user, _, err := f.service.LoginAdmin(ctx, "pustakawan", "rahasia123")
+	if err != nil || user.ID != 1 {
+		t.Fatalf("user = %+v, err = %v, want staff 1", user, err)
+	}
+	if _, _, err := f.service.LoginAdmin(ctx, "pustakawan", "salah"); err == nil || errors.Is(err, ErrAccountNotLinked) {
+		t.Errorf("wrong password: err = %v", err)
+	}
+	if _, _, err := f.service.LoginAdmin(ctx, "asing", "rahasia123"); !errors.Is(err, ErrAccountNotLinked) {
+		t.Errorf("directory account without staff account: err = %v, want ErrAccountNotLinked", err)
+	}
+}
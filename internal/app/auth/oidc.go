@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"simpus/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCClient is the OpenID Connect provider used for campus SSO. Discovery
+// runs on first use so the server still starts while the IdP is down.
+type OIDCClient struct {
+	cfg config.OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOIDCClient(cfg config.OIDCConfig) *OIDCClient {
+	return &OIDCClient{cfg: cfg}
+}
+
+func (c *OIDCClient) Name() string {
+	return "oidc"
+}
+
+func (c *OIDCClient) AuthCodeURL(ctx context.Context, state, nonce, redirectURL string) (string, error) {
+	oauth, err := c.oauthConfig(ctx, redirectURL)
+	if err != nil {
+		return "", err
+	}
+	return oauth.AuthCodeURL(state, oidc.Nonce(nonce)), nil
+}
+
+func (c *OIDCClient) Exchange(ctx context.Context, code, nonce, redirectURL string) (*Identity, error) {
+	oauth, err := c.oauthConfig(ctx, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider:       c.Name(),
+		Subject:        idToken.Subject,
+		Email:          stringClaim(claims, "email"),
+		Name:           stringClaim(claims, "name"),
+		IdentityNumber: stringClaim(claims, c.cfg.NIMClaim),
+	}
+	// A missing claim counts as unverified
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+
+	return identity, nil
+}
+
+func (c *OIDCClient) oauthConfig(ctx context.Context, redirectURL string) (*oauth2.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider == nil {
+		provider, err := oidc.NewProvider(ctx, c.cfg.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		c.provider = provider
+		c.verifier = provider.Verifier(&oidc.Config{ClientID: c.cfg.ClientID})
+	}
+
+	return &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		Endpoint:     c.provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       c.cfg.Scopes,
+	}, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	if name == "" {
+		return ""
+	}
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	}
+	return ""
+}
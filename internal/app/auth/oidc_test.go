@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simpus/config"
+	"simpus/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// stubIdP is a stand-in OpenID provider serving discovery, token and JWKS
+// endpoints. Each authorization code is exchanged for an ID token with the
+// claims registered for it.
+type stubIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{key: key, codes: map[string]jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		url := idp.server.URL
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                url,
+			"authorization_endpoint":                url + "/auth",
+			"token_endpoint":                        url + "/token",
+			"jwks_uri":                              url + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "test",
+				"n": b64(key.N.Bytes()),
+				"e": b64(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "simpus" || secret != "rahasia" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		idp.mu.Lock()
+		claims, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		idp.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.sign(t, claims),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *stubIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Error(err)
+	}
+	return signed
+}
+
+// code registers an authorization code for an ID token with valid
+// standard claims, changed or extended by extra.
+func (idp *stubIdP) code(subject string, extra jwt.MapClaims) string {
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   "simpus",
+		"sub":   subject,
+		"nonce": "nonce-123",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := "code-" + subject
+	idp.codes[code] = claims
+	return code
+}
+
+func (idp *stubIdP) client() *OIDCClient {
+	return NewOIDCClient(config.OIDCConfig{
+		IssuerURL:    idp.server.URL,
+		ClientID:     "simpus",
+		ClientSecret: "rahasia",
+		Scopes:       []string{"openid", "email", "profile"},
+		NIMClaim:     "nim",
+	})
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newStubIdP(t)
+	client := idp.client()
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "state-1", "nonce-123", "http://simpus.test/auth/oidc/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, idp.server.URL+"/auth?") || !strings.Contains(authURL, "nonce=nonce-123") {
+		t.Errorf("auth URL = %s", authURL)
+	}
+
+	code := idp.code("budi", jwt.MapClaims{"email": "budi@student.ac.id", "email_verified": true, "name": "Budi", "nim": "2021001"})
+	identity, err := client.Exchange(ctx, code, "nonce-123", "http://simpus.test/auth/oidc/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Provider: "oidc", Subject: "budi", Email: "budi@student.ac.id", EmailVerified: true, Name: "Budi", IdentityNumber: "2021001"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	code = idp.code("tanpa-verifikasi", jwt.MapClaims{"email": "x@student.ac.id"})
+	if identity, err := client.Exchange(ctx, code, "nonce-123", ""); err != nil || identity.EmailVerified {
+		t.Errorf("email without email_verified: identity = %+v, err = %v", identity, err)
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidIDToken(t *testing.T) {
+	idp := newStubIdP(t)
+	client := idp.client()
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		extra jwt.MapClaims
+		nonce string
+	}{
+		{"other issuer", jwt.MapClaims{"iss": "http://idp.lain"}, "nonce-123"},
+		{"other audience", jwt.MapClaims{"aud": "aplikasi-lain"}, "nonce-123"},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}, "nonce-123"},
+		{"nonce mismatch", nil, "nonce-lain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := idp.code("budi", tt.extra)
+			if identity, err := client.Exchange(ctx, code, tt.nonce, ""); err == nil {
+				t.Fatalf("identity = %+v, want an error", identity)
+			}
+		})
+	}
+
+	// A token signed by another key
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := idp.key
+	idp.key = other
+	_, err = client.Exchange(ctx, idp.code("budi", nil), "nonce-123", "")
+	idp.key = trusted
+	if err == nil {
+		t.Error("token signed by an unknown key accepted")
+	}
+
+	if _, err := client.Exchange(ctx, "kode-tidak-dikenal", "nonce-123", ""); err == nil {
+		t.Error("unknown code accepted")
+	}
+}
+
+func TestLoginOIDCMembers(t *testing.T) {
+	idp := newStubIdP(t)
+	f := newFixture(t, testPasswordConfig())
+	f.service.UseOIDC(idp.client())
+	f.service.config.OIDC = config.OIDCConfig{JITProvision: true, DefaultMemberType: "mahasiswa"}
+	f.members.members[1] = &models.Member{ID: 1, Email: "budi@student.ac.id", IdentityNumber: "2021001", MemberType: "mahasiswa", IsActive: true, Status: models.MemberStatusActive}
+	ctx := context.Background()
+
+	login := func(subject string, claims jwt.MapClaims) (*Claims, error) {
+		t.Helper()
+		token, err := f.service.LoginOIDC(ctx, "member", idp.code(subject, claims), "nonce-123", "")
+		if err != nil {
+			return nil, err
+		}
+		return f.service.ValidateToken(token)
+	}
+
+	// Linked by NIM although the address differs
+	claims, err := login("sub-budi", jwt.MapClaims{"email": "budi@kampus.ac.id", "email_verified": true, "nim": "2021001"})
+	if err != nil || claims.UserID != 1 {
+		t.Fatalf("claims = %+v, %v, want member 1", claims, err)
+	}
+
+	// An unverified address neither links nor provisions
+	if _, err := login("sub-palsu", jwt.MapClaims{"email": "budi@student.ac.id"}); !errors.Is(err, ErrAccountNotLinked) {
+		t.Errorf("unverified email: err = %v, want ErrAccountNotLinked", err)
+	}
+	if len(f.members.members) != 1 {
+		t.Errorf("members = %d, want no member provisioned", len(f.members.members))
+	}
+
+	// A new verified account is provisioned once, then found by its link
+	for range 2 {
+		claims, err = login("sub-sari", jwt.MapClaims{"email": "sari@student.ac.id", "email_verified": true, "name": "Sari"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(f.members.members) != 2 {
+		t.Fatalf("members = %d, want 2", len(f.members.members))
+	}
+	sari := f.members.members[claims.UserID]
+	if sari.Name != "Sari" || sari.Status != models.MemberStatusActive || sari.MemberType != "mahasiswa" || sari.MemberCode == "" {
+		t.Errorf("provisioned member = %+v", sari)
+	}
+
+	f.service.config.OIDC.JITProvision = false
+	if _, err := login("sub-lain", jwt.MapClaims{"email": "lain@student.ac.id", "email_verified": true}); !errors.Is(err, ErrAccountNotLinked) {
+		t.Errorf("without provisioning: err = %v, want ErrAccountNotLinked", err)
+	}
+}
+
+func TestLoginOIDCStaff(t *testing.T) {
+	idp := newStubIdP(t)
+	f := newFixture(t, testPasswordConfig())
+	f.service.UseOIDC(idp.client())
+	f.service.config.OIDC = config.OIDCConfig{JITProvision: true, DefaultMemberType: "mahasiswa"}
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Email: "admin@kampus.ac.id", Role: "admin", IsActive: true}
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"preferred_username", jwt.MapClaims{"preferred_username": "admin", "email": "mhs@kampus.ac.id", "email_verified": true}},
+		{"email without email_verified", jwt.MapClaims{"email": "admin@kampus.ac.id"}},
+		{"unverified email", jwt.MapClaims{"email": "admin@kampus.ac.id", "email_verified": false}},
+		{"no staff account, never provisioned", jwt.MapClaims{"email": "baru@kampus.ac.id", "email_verified": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.LoginOIDC(ctx, "admin", idp.code("sub-"+tt.name, tt.claims), "nonce-123", "")
+			if !errors.Is(err, ErrAccountNotLinked) {
+				t.Fatalf("err = %v, want ErrAccountNotLinked", err)
+			}
+		})
+	}
+	if len(f.users.users) != 1 {
+		t.Errorf("users = %d, want 1", len(f.users.users))
+	}
+
+	token, err := f.service.LoginOIDC(ctx, "admin", idp.code("sub-admin", jwt.MapClaims{"email": "admin@kampus.ac.id", "email_verified": true}), "nonce-123", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := f.service.ValidateToken(token); err != nil || claims.UserID != 1 || claims.Role != "admin" {
+		t.Errorf("claims = %+v, %v", claims, err)
+	}
+}
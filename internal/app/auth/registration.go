@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"simpus/internal/mailer"
+	"simpus/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const verifyEmailPurpose = "verify_email"
+
+var ErrInvalidVerificationToken = errors.New("link verifikasi tidak valid atau sudah kedaluwarsa")
+
+type verificationClaims struct {
+	MemberID int    `json:"member_id"`
+	Email    string `json:"email"`
+	Purpose  string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// RegisterMember creates a self-registered member in pending_verification
+// status and mails a signed verification link.
+func (s *Service) RegisterMember(ctx context.Context, data *models.MemberCreate) (int64, error) {
+	data.IdentityNumber = strings.TrimSpace(data.IdentityNumber)
+	if err := validateIdentityNumber(data.MemberType, data.IdentityNumber); err != nil {
+		return 0, err
+	}
+	if err := s.credentials.Validate("member", 0, "", data.Password); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.memberRepo.FindByEmail(data.Email); err == nil {
+		return 0, errors.New("email sudah terdaftar")
+	}
+	if _, err := s.memberRepo.FindByIdentityNumber(data.IdentityNumber); err == nil {
+		return 0, errors.New("NIM/NIP sudah terdaftar")
+	}
+
+	// Generate member code
+	memberCode, err := s.memberRepo.GenerateMemberCode(data.MemberType)
+	if err != nil {
+		return 0, err
+	}
+
+	// Hash password
+	hashedPassword, err := s.credentials.Hash(data.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	data.Status = models.MemberStatusPendingVerification
+	id, err := s.memberRepo.Create(data, hashedPassword, memberCode)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.credentials.Record("member", int(id), hashedPassword); err != nil {
+		return 0, err
+	}
+
+	member, err := s.memberRepo.FindByID(int(id))
+	if err != nil {
+		return 0, err
+	}
+	if err := s.sendVerificationEmail(member); err != nil {
+		slog.ErrorContext(ctx, "registration: send verification email", "error", err)
+	}
+
+	return id, nil
+}
+
+// ResendVerification mails a fresh link to a member still waiting for email
+// verification. Like password reset, it does not reveal whether email exists.
+func (s *Service) ResendVerification(ctx context.Context, email string) {
+	member, err := s.memberRepo.FindByEmail(email)
+	if err != nil || member.Status != models.MemberStatusPendingVerification {
+		return
+	}
+
+	go func() {
+		if err := s.sendVerificationEmail(member); err != nil {
+			slog.ErrorContext(ctx, "registration: resend verification email", "error", err)
+		}
+	}()
+}
+
+// VerifyEmail checks a verification link and advances the member to
+// pending_approval or active, depending on REGISTRATION_REQUIRE_APPROVAL.
+func (s *Service) VerifyEmail(tokenString string) (*models.Member, error) {
+	claims := &verificationClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.config.JWT.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid || claims.Purpose != verifyEmailPurpose {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	member, err := s.memberRepo.FindByID(claims.MemberID)
+	if err != nil || member.Email != claims.Email {
+		return nil, ErrInvalidVerificationToken
+	}
+	if member.Status != models.MemberStatusPendingVerification {
+		// Link already used; report the current state instead of failing
+		return member, nil
+	}
+
+	nextStatus := models.MemberStatusActive
+	if s.config.Auth.RequireMemberApproval {
+		nextStatus = models.MemberStatusPendingApproval
+	}
+
+	if err := s.memberRepo.MarkEmailVerified(member.ID, nextStatus); err != nil {
+		return nil, err
+	}
+
+	return s.memberRepo.FindByID(member.ID)
+}
+
+func (s *Service) sendVerificationEmail(member *models.Member) error {
+	expiresAt := time.Now().Add(s.config.Auth.VerificationTokenExpiry)
+	claims := &verificationClaims{
+		MemberID: member.ID,
+		Email:    member.Email,
+		Purpose:  verifyEmailPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWT.Secret))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.config.App.BaseURL, url.QueryEscape(token))
+	return s.mailer.Send(&mailer.Message{
+		To:      member.Email,
+		Subject: "Verifikasi Email " + s.config.App.Name,
+		Body: fmt.Sprintf("Halo %s,\r\n\r\n"+
+			"Terima kasih telah mendaftar sebagai anggota perpustakaan.\r\n"+
+			"Buka tautan berikut untuk memverifikasi email Anda:\r\n\r\n%s\r\n\r\n"+
+			"Tautan ini berlaku sampai %s.\r\n",
+			member.Name, link, expiresAt.Format("02 Jan 2006 15:04")),
+	})
+}
+
+// validateIdentityNumber checks the NIM of students and the 18-digit NIP of
+// teachers and staff (8-digit birth date, 6-digit appointment month, gender
+// digit and 3-digit sequence).
+func validateIdentityNumber(memberType, number string) error {
+	if number == "" {
+		return errors.New("NIM/NIP wajib diisi")
+	}
+	if !isDigits(number) {
+		return errors.New("NIM/NIP hanya boleh berisi angka")
+	}
+
+	switch memberType {
+	case "mahasiswa":
+		if len(number) < 8 || len(number) > 15 {
+			return errors.New("NIM harus terdiri dari 8 sampai 15 digit")
+		}
+	case "guru", "karyawan":
+		if len(number) != 18 {
+			return errors.New("NIP harus terdiri dari 18 digit")
+		}
+		if _, err := time.Parse("20060102", number[:8]); err != nil {
+			return errors.New("NIP tidak valid: tanggal lahir salah")
+		}
+		if _, err := time.Parse("200601", number[8:14]); err != nil {
+			return errors.New("NIP tidak valid: TMT pengangkatan salah")
+		}
+		if number[14] != '1' && number[14] != '2' {
+			return errors.New("NIP tidak valid: kode jenis kelamin salah")
+		}
+	default:
+		return errors.New("tipe anggota tidak valid")
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/internal/mailer"
+	"simpus/internal/mailer/mailertest"
+	"simpus/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var verifyLink = regexp.MustCompile(`http://simpus\.test/verify-email\?token=(\S+)`)
+
+func registrationFixture(t *testing.T, requireApproval bool) (*fixture, *mailertest.Server) {
+	t.Helper()
+
+	f := newFixture(t, testPasswordConfig())
+	server := mailertest.NewServer(t, "", "")
+	f.service.mailer = mailer.NewSMTPMailer(server.Config())
+	f.service.config.Auth.RequireMemberApproval = requireApproval
+	return f, server
+}
+
+func registration() *models.MemberCreate {
+	return &models.MemberCreate{
+		IdentityNumber: " 2021001234 ",
+		Name:           "Budi",
+		Email:          "budi@student.ac.id",
+		Password:       "rahasia123",
+		MemberType:     "mahasiswa",
+	}
+}
+
+// verificationToken returns the token of the verification link in msg.
+func verificationToken(t *testing.T, msg *mailertest.Message) string {
+	t.Helper()
+
+	match := verifyLink.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("no verification link in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRegisterMemberStatusTransitions(t *testing.T) {
+	tests := []struct {
+		name            string
+		requireApproval bool
+		verified        string
+	}{
+		{"with approval", true, models.MemberStatusPendingApproval},
+		{"without approval", false, models.MemberStatusActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, server := registrationFixture(t, tt.requireApproval)
+
+			id, err := f.service.RegisterMember(context.Background(), registration())
+			if err != nil {
+				t.Fatal(err)
+			}
+			member := f.members.members[int(id)]
+			if member.Status != models.MemberStatusPendingVerification || member.CanBorrow() {
+				t.Fatalf("registered member: status %s, can borrow %v", member.Status, member.CanBorrow())
+			}
+			if member.IdentityNumber != "2021001234" || !strings.HasPrefix(member.MemberCode, "MHS") {
+				t.Errorf("identity number %q, member code %q", member.IdentityNumber, member.MemberCode)
+			}
+
+			msg := server.Next(t)
+			if len(msg.To) != 1 || msg.To[0] != "budi@student.ac.id" {
+				t.Fatalf("mail to %v", msg.To)
+			}
+			token := verificationToken(t, msg)
+
+			verified, err := f.service.VerifyEmail(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verified.Status != tt.verified || verified.EmailVerifiedAt == nil {
+				t.Errorf("verified member: status %s, verified at %v", verified.Status, verified.EmailVerifiedAt)
+			}
+			if verified.CanBorrow() != (tt.verified == models.MemberStatusActive) {
+				t.Errorf("status %s: can borrow %v", verified.Status, verified.CanBorrow())
+			}
+
+			// A second click reports the state without changing it
+			again, err := f.service.VerifyEmail(token)
+			if err != nil || again.Status != tt.verified {
+				t.Errorf("second verification: %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestRegisterMemberRejections(t *testing.T) {
+	f, server := registrationFixture(t, true)
+	f.members.members[1] = &models.Member{ID: 1, Email: "siti@student.ac.id", IdentityNumber: "2021000001", MemberType: "mahasiswa", MemberCode: "MHS0001"}
+
+	tests := []struct {
+		name    string
+		change  func(*models.MemberCreate)
+		wantErr string
+	}{
+		{"email taken", func(d *models.MemberCreate) { d.Email = "siti@student.ac.id" }, "email sudah terdaftar"},
+		{"identity number taken", func(d *models.MemberCreate) { d.IdentityNumber = "2021000001" }, "NIM/NIP sudah terdaftar"},
+		{"short password", func(d *models.MemberCreate) { d.Password = "pendek" }, "password minimal 8 karakter"},
+		{"bad NIM", func(d *models.MemberCreate) { d.IdentityNumber = "12345" }, "NIM harus terdiri dari 8 sampai 15 digit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := registration()
+			tt.change(data)
+			if _, err := f.service.RegisterMember(context.Background(), data); err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if len(f.members.members) != 1 || server.Pending() != 0 {
+		t.Errorf("%d members, %d mails after rejected registrations", len(f.members.members), server.Pending())
+	}
+}
+
+func TestVerifyEmailRejectsInvalidTokens(t *testing.T) {
+	f, server := registrationFixture(t, true)
+	id, err := f.service.RegisterMember(context.Background(), registration())
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := verificationToken(t, server.Next(t))
+
+	sign := func(claims *verificationClaims, secret string) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := func() *verificationClaims {
+		return &verificationClaims{
+			MemberID: int(id),
+			Email:    "budi@student.ac.id",
+			Purpose:  verifyEmailPurpose,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+	}
+
+	// The signing helper matches the mailed links
+	if _, err := f.service.VerifyEmail(sign(valid(), "test-secret")); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+	f.members.members[int(id)].Status = models.MemberStatusPendingVerification
+
+	loginToken, err := f.service.generateToken(int(id), "budi@student.ac.id", "mahasiswa", "member", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(good, ".")
+	payload := []byte(parts[1])
+	payload[len(payload)/2] ^= 1
+
+	// withClaims signs valid claims changed by change
+	withClaims := func(change func(*verificationClaims)) string {
+		c := valid()
+		change(c)
+		return sign(c, "test-secret")
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		"empty":          "",
+		"tampered":       parts[0] + "." + string(payload) + "." + parts[2],
+		"bad signature":  parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])),
+		"login token":    loginToken,
+		"none algorithm": none,
+		"other secret":   sign(valid(), "rahasia-lain"),
+		"other purpose":  withClaims(func(c *verificationClaims) { c.Purpose = "reset_password" }),
+		"no purpose":     withClaims(func(c *verificationClaims) { c.Purpose = "" }),
+		"expired":        withClaims(func(c *verificationClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }),
+		"other email":    withClaims(func(c *verificationClaims) { c.Email = "lain@student.ac.id" }),
+		"unknown member": withClaims(func(c *verificationClaims) { c.MemberID = 99 }),
+	}
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			if member, err := f.service.VerifyEmail(token); !errors.Is(err, ErrInvalidVerificationToken) {
+				t.Errorf("member = %+v, err = %v, want ErrInvalidVerificationToken", member, err)
+			}
+		})
+	}
+	if status := f.members.members[int(id)].Status; status != models.MemberStatusPendingVerification {
+		t.Errorf("status = %s after invalid tokens, want %s", status, models.MemberStatusPendingVerification)
+	}
+}
+
+func TestValidateIdentityNumber(t *testing.T) {
+	tests := []struct {
+		memberType, number string
+		wantErr            string
+	}{
+		{"mahasiswa", "20210012", ""},
+		{"mahasiswa", "202100123456789", ""},
+		{"mahasiswa", "2021001", "NIM harus terdiri dari 8 sampai 15 digit"},
+		{"mahasiswa", "2021001234567890", "NIM harus terdiri dari 8 sampai 15 digit"},
+		{"mahasiswa", "2021-0012", "NIM/NIP hanya boleh berisi angka"},
+		{"mahasiswa", "", "NIM/NIP wajib diisi"},
+		{"guru", "198503152010011001", ""},
+		{"karyawan", "199012312015122003", ""},
+		{"guru", "19850315201001100", "NIP harus terdiri dari 18 digit"},
+		{"guru", "198513152010011001", "NIP tidak valid: tanggal lahir salah"},
+		{"guru", "198502302010011001", "NIP tidak valid: tanggal lahir salah"},
+		{"guru", "198503152010131001", "NIP tidak valid: TMT pengangkatan salah"},
+		{"guru", "198503152010013001", "NIP tidak valid: kode jenis kelamin salah"},
+		{"karyawan", "19850315201001100A", "NIM/NIP hanya boleh berisi angka"},
+		{"umum", "20210012", "tipe anggota tidak valid"},
+	}
+	for _, tt := range tests {
+		err := validateIdentityNumber(tt.memberType, tt.number)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("validateIdentityNumber(%q, %q) = %v, want %q", tt.memberType, tt.number, err, tt.wantErr)
+		}
+	}
+}
@@ -5,56 +5,112 @@ import (
 	"simpus/internal/models"
 )
 
-type Repository struct {
+// Repository stores staff accounts.
+type Repository interface {
+	FindByUsername(username string) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	FindByID(id int) (*models.User, error)
+	Create(user *models.UserCreate, hashedPassword string) (int64, error)
+	UpdatePassword(id int, hashedPassword string) error
+	RehashPassword(id int, hashedPassword string) error
+	UpdateBranch(id int, branchID *int) error
+	FindAll() ([]models.User, error)
+}
+
+type repository struct {
 	db *sql.DB
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
 }
 
-func (r *Repository) FindByUsername(username string) (*models.User, error) {
+func (r *repository) FindByUsername(username string) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT id, username, email, password, name, role, is_active, created_at, updated_at 
+	query := `SELECT id, username, email, password, name, role, branch_id, is_active, created_at, updated_at, session_version 
 			  FROM users WHERE username = ?`
 
+	var branchID sql.NullInt64
 	err := r.db.QueryRow(query, username).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.Name, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Name, &user.Role, &branchID, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.SessionVersion,
 	)
 	if err != nil {
 		return nil, err
 	}
+	user.BranchID = nullableInt(branchID)
 	return user, nil
 }
 
-func (r *Repository) FindByID(id int) (*models.User, error) {
+func (r *repository) FindByEmail(email string) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT id, username, email, password, name, role, is_active, created_at, updated_at 
+	query := `SELECT id, username, email, password, name, role, branch_id, is_active, created_at, updated_at, session_version 
+			  FROM users WHERE email = ?`
+
+	var branchID sql.NullInt64
+	err := r.db.QueryRow(query, email).Scan(
+		&user.ID, &user.Username, &user.Email, &user.Password,
+		&user.Name, &user.Role, &branchID, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.SessionVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+	user.BranchID = nullableInt(branchID)
+	return user, nil
+}
+
+func (r *repository) FindByID(id int) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, email, password, name, role, branch_id, is_active, created_at, updated_at, session_version 
 			  FROM users WHERE id = ?`
 
+	var branchID sql.NullInt64
 	err := r.db.QueryRow(query, id).Scan(
 		&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.Name, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+		&user.Name, &user.Role, &branchID, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.SessionVersion,
 	)
 	if err != nil {
 		return nil, err
 	}
+	user.BranchID = nullableInt(branchID)
 	return user, nil
 }
 
-func (r *Repository) Create(user *models.UserCreate, hashedPassword string) (int64, error) {
-	query := `INSERT INTO users (username, email, password, name, role) VALUES (?, ?, ?, ?, ?)`
+func (r *repository) Create(user *models.UserCreate, hashedPassword string) (int64, error) {
+	query := `INSERT INTO users (username, email, password, name, role, branch_id) VALUES (?, ?, ?, ?, ?, ?)`
 
-	result, err := r.db.Exec(query, user.Username, user.Email, hashedPassword, user.Name, user.Role)
+	result, err := r.db.Exec(query, user.Username, user.Email, hashedPassword, user.Name, user.Role, user.BranchID)
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
-func (r *Repository) FindAll() ([]models.User, error) {
-	query := `SELECT id, username, email, password, name, role, is_active, created_at, updated_at 
+// UpdatePassword stores a new password hash and bumps the session version,
+// which invalidates every token issued before the change.
+func (r *repository) UpdatePassword(id int, hashedPassword string) error {
+	query := `UPDATE users SET password = ?, session_version = session_version + 1 WHERE id = ?`
+	_, err := r.db.Exec(query, hashedPassword, id)
+	return err
+}
+
+// RehashPassword replaces the hash of an unchanged password, keeping
+// existing sessions valid.
+func (r *repository) RehashPassword(id int, hashedPassword string) error {
+	_, err := r.db.Exec(`UPDATE users SET password = ? WHERE id = ?`, hashedPassword, id)
+	return err
+}
+
+// UpdateBranch assigns the account to a branch, or to all branches when
+// branchID is nil. Existing sessions carry the old branch and are revoked.
+func (r *repository) UpdateBranch(id int, branchID *int) error {
+	query := `UPDATE users SET branch_id = ?, session_version = session_version + 1 WHERE id = ?`
+	_, err := r.db.Exec(query, branchID, id)
+	return err
+}
+
+func (r *repository) FindAll() ([]models.User, error) {
+	query := `SELECT id, username, email, password, name, role, branch_id, is_active, created_at, updated_at, session_version 
 			  FROM users ORDER BY created_at DESC`
 
 	rows, err := r.db.Query(query)
@@ -66,14 +122,24 @@ func (r *Repository) FindAll() ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
+		var branchID sql.NullInt64
 		err := rows.Scan(
 			&user.ID, &user.Username, &user.Email, &user.Password,
-			&user.Name, &user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
+			&user.Name, &user.Role, &branchID, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.SessionVersion,
 		)
 		if err != nil {
 			return nil, err
 		}
+		user.BranchID = nullableInt(branchID)
 		users = append(users, user)
 	}
 	return users, nil
 }
+
+func nullableInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
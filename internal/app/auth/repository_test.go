@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+)
+
+func TestRepositoryUpdatePassword(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db)
+
+	user, err := repo.FindByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdatePassword(user.ID, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.FindByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Password != "new-hash" || updated.SessionVersion != user.SessionVersion+1 {
+		t.Errorf("password %q session version %d", updated.Password, updated.SessionVersion)
+	}
+}
+
+func TestResetRepositoryConsumeOnce(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewResetRepository(db)
+	users := NewRepository(db)
+
+	user, err := users.FindByUsername("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := repo.Create("admin", user.ID, hashResetToken("token"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create("admin", user.ID, hashResetToken("other"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	reset, err := repo.FindByTokenHash(hashResetToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.ID != int(id) || reset.UsedAt != nil {
+		t.Fatalf("reset = %+v", reset)
+	}
+
+	if err := repo.Consume(reset, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := users.FindByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Password != "new-hash" || updated.SessionVersion != user.SessionVersion+1 {
+		t.Errorf("password %q session version %d", updated.Password, updated.SessionVersion)
+	}
+	if other, err := repo.FindByTokenHash(hashResetToken("other")); err != nil || other.UsedAt == nil {
+		t.Errorf("other token = %+v, %v, want it used", other, err)
+	}
+
+	if err := repo.Consume(reset, "newer-hash"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second Consume = %v, want sql.ErrNoRows", err)
+	}
+	if updated, _ := users.FindByID(user.ID); updated.Password != "new-hash" {
+		t.Errorf("password %q after second Consume", updated.Password)
+	}
+}
+
+func TestIdentityRepositoryFind(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewIdentityRepository(db)
+
+	if _, err := repo.Find("oidc", "subject-1", "member"); err == nil {
+		t.Fatal("Find before linking succeeded, want error")
+	}
+
+	id, err := repo.Create(&models.ExternalIdentity{
+		Provider: "oidc", Subject: "subject-1", AccountType: "member", AccountID: 1, Email: "budi@student.ac.id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.TouchLogin(int(id)); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := repo.Find("oidc", "subject-1", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.AccountID != 1 {
+		t.Errorf("linked account = %d, want 1", link.AccountID)
+	}
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"simpus/internal/mailer"
+	"simpus/internal/models"
+)
+
+var ErrInvalidResetToken = errors.New("link reset password tidak valid atau sudah kedaluwarsa")
+
+// RequestPasswordReset issues a reset token for the account registered with
+// email and mails the link. It never reports whether the email exists; lookup
+// and delivery failures are only logged so the response is always the same.
+func (s *Service) RequestPasswordReset(ctx context.Context, accountType, email string) {
+	var accountID int
+	var name string
+
+	switch accountType {
+	case "admin":
+		user, err := s.userRepo.FindByEmail(email)
+		if err != nil || !user.IsActive {
+			return
+		}
+		accountID, name = user.ID, user.Name
+	case "member":
+		member, err := s.memberRepo.FindByEmail(email)
+		if err != nil || !member.IsActive {
+			return
+		}
+		accountID, name = member.ID, member.Name
+	default:
+		return
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		slog.ErrorContext(ctx, "password reset: generate token", "error", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(s.config.Auth.ResetTokenExpiry)
+	if _, err := s.resetRepo.Create(accountType, accountID, hashResetToken(token), expiresAt); err != nil {
+		slog.ErrorContext(ctx, "password reset: store token", "error", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.config.App.BaseURL, url.QueryEscape(token))
+	msg := &mailer.Message{
+		To:      email,
+		Subject: "Reset Password " + s.config.App.Name,
+		Body: fmt.Sprintf("Halo %s,\r\n\r\n"+
+			"Kami menerima permintaan untuk mengatur ulang password akun Anda.\r\n"+
+			"Buka tautan berikut untuk membuat password baru:\r\n\r\n%s\r\n\r\n"+
+			"Tautan ini berlaku sampai %s dan hanya dapat digunakan satu kali.\r\n"+
+			"Abaikan email ini jika Anda tidak meminta reset password.\r\n",
+			name, link, expiresAt.Format("02 Jan 2006 15:04")),
+	}
+
+	// Deliver in the background so response time does not depend on
+	// whether the address belongs to an account.
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			slog.ErrorContext(ctx, "password reset: send email", "error", err)
+		}
+	}()
+}
+
+// ValidateResetToken returns the pending reset for token, or
+// ErrInvalidResetToken when it is unknown, used or expired.
+func (s *Service) ValidateResetToken(token string) (*models.PasswordReset, error) {
+	if token == "" {
+		return nil, ErrInvalidResetToken
+	}
+
+	reset, err := s.resetRepo.FindByTokenHash(hashResetToken(token))
+	if err != nil {
+		return nil, ErrInvalidResetToken
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+	return reset, nil
+}
+
+// ResetPassword consumes token and sets a new password. All sessions of the
+// account are revoked and any other outstanding reset links stop working.
+func (s *Service) ResetPassword(token, password string) (string, error) {
+	reset, err := s.ValidateResetToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	currentHash, err := s.passwordHash(reset.AccountType, reset.AccountID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.credentials.Validate(reset.AccountType, reset.AccountID, currentHash, password); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := s.credentials.Hash(password)
+	if err != nil {
+		return "", err
+	}
+
+	// The token is only spent together with the password update, so a
+	// failure leaves the link usable for another attempt.
+	if err := s.resetRepo.Consume(reset, hashedPassword); errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidResetToken
+	} else if err != nil {
+		return "", err
+	}
+	if err := s.credentials.Record(reset.AccountType, reset.AccountID, hashedPassword); err != nil {
+		return "", err
+	}
+
+	return reset.AccountType, nil
+}
+
+// passwordHash returns the stored password hash of an account.
+func (s *Service) passwordHash(accountType string, accountID int) (string, error) {
+	if accountType == "member" {
+		member, err := s.memberRepo.FindByID(accountID)
+		if err != nil {
+			return "", err
+		}
+		return member.Password, nil
+	}
+	user, err := s.userRepo.FindByID(accountID)
+	if err != nil {
+		return "", err
+	}
+	return user.Password, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
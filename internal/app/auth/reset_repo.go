@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"simpus/internal/models"
+)
+
+// ResetRepository stores password reset tokens.
+type ResetRepository interface {
+	Create(accountType string, accountID int, tokenHash string, expiresAt time.Time) (int64, error)
+	FindByTokenHash(tokenHash string) (*models.PasswordReset, error)
+	Consume(reset *models.PasswordReset, hashedPassword string) error
+	InvalidateAll(accountType string, accountID int) error
+}
+
+type resetRepository struct {
+	db *sql.DB
+}
+
+func NewResetRepository(db *sql.DB) ResetRepository {
+	return &resetRepository{db: db}
+}
+
+func (r *resetRepository) Create(accountType string, accountID int, tokenHash string, expiresAt time.Time) (int64, error) {
+	query := `INSERT INTO password_resets (account_type, account_id, token_hash, expires_at) VALUES (?, ?, ?, ?)`
+
+	result, err := r.db.Exec(query, accountType, accountID, tokenHash, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *resetRepository) FindByTokenHash(tokenHash string) (*models.PasswordReset, error) {
+	pr := &models.PasswordReset{}
+	var usedAt sql.NullTime
+	query := `SELECT id, account_type, account_id, token_hash, expires_at, used_at, created_at 
+			  FROM password_resets WHERE token_hash = ?`
+
+	err := r.db.QueryRow(query, tokenHash).Scan(
+		&pr.ID, &pr.AccountType, &pr.AccountID, &pr.TokenHash, &pr.ExpiresAt, &usedAt, &pr.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		pr.UsedAt = &usedAt.Time
+	}
+	return pr, nil
+}
+
+// accountTables maps the account type of a reset to the table holding the
+// account.
+var accountTables = map[string]string{
+	"admin":  "users",
+	"member": "members",
+}
+
+// Consume uses a reset token and stores the new password in one
+// transaction, so the token is only spent once the password is saved. The
+// session version is bumped and every other outstanding token of the account
+// is consumed too. It fails with sql.ErrNoRows when the token was already
+// used, so two concurrent submissions cannot both succeed.
+func (r *resetRepository) Consume(reset *models.PasswordReset, hashedPassword string) error {
+	table, ok := accountTables[reset.AccountType]
+	if !ok {
+		return fmt.Errorf("unknown account type %q", reset.AccountType)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL`, now, reset.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	query := `UPDATE ` + table + ` SET password = ?, session_version = session_version + 1 WHERE id = ?`
+	if _, err := tx.Exec(query, hashedPassword, reset.AccountID); err != nil {
+		return err
+	}
+
+	query = `UPDATE password_resets SET used_at = ? WHERE account_type = ? AND account_id = ? AND used_at IS NULL`
+	if _, err := tx.Exec(query, now, reset.AccountType, reset.AccountID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InvalidateAll consumes every outstanding token of an account.
+func (r *resetRepository) InvalidateAll(accountType string, accountID int) error {
+	query := `UPDATE password_resets SET used_at = ? WHERE account_type = ? AND account_id = ? AND used_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), accountType, accountID)
+	return err
+}
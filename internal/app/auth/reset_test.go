@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"simpus/internal/credentials"
+	"simpus/internal/mailer"
+	"simpus/internal/mailer/mailertest"
+	"simpus/internal/models"
+)
+
+var resetLink = regexp.MustCompile(`http://simpus\.test/reset-password\?token=(\S+)`)
+
+// resetFixture delivers reset mail through the SMTP client to a local
+// listener.
+func resetFixture(t *testing.T) (*fixture, *mailertest.Server) {
+	t.Helper()
+
+	f := newFixture(t, testPasswordConfig())
+	server := mailertest.NewServer(t, "", "")
+	f.service.mailer = mailer.NewSMTPMailer(server.Config())
+	f.members.members[1] = &models.Member{ID: 1, Name: "Budi", Email: "budi@student.ac.id", Password: hash(t, testPasswordConfig(), "rahasia123"), IsActive: true, Status: models.MemberStatusActive}
+	return f, server
+}
+
+// mailedToken returns the token of the reset link in msg.
+func mailedToken(t *testing.T, msg *mailertest.Message) string {
+	t.Helper()
+
+	match := resetLink.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("no reset link in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	f, server := resetFixture(t)
+	ctx := context.Background()
+
+	// Unknown addresses, inactive accounts and unknown account types
+	// return like a known address but store and send nothing.
+	f.members.members[2] = &models.Member{ID: 2, Email: "nonaktif@student.ac.id", IsActive: false}
+	f.service.RequestPasswordReset(ctx, "member", "asing@student.ac.id")
+	f.service.RequestPasswordReset(ctx, "member", "nonaktif@student.ac.id")
+	f.service.RequestPasswordReset(ctx, "admin", "budi@student.ac.id")
+	f.service.RequestPasswordReset(ctx, "tamu", "budi@student.ac.id")
+	if len(f.resets.resets) != 0 {
+		t.Fatalf("resets = %d, want none", len(f.resets.resets))
+	}
+
+	f.service.RequestPasswordReset(ctx, "member", "budi@student.ac.id")
+	msg := server.Next(t)
+	if len(msg.To) != 1 || msg.To[0] != "budi@student.ac.id" {
+		t.Fatalf("mail to %v", msg.To)
+	}
+	if server.Pending() != 0 {
+		t.Errorf("%d more messages, want the reset mail only", server.Pending())
+	}
+
+	token := mailedToken(t, msg)
+	if len(f.resets.resets) != 1 {
+		t.Fatalf("resets = %d, want 1", len(f.resets.resets))
+	}
+	stored := f.resets.resets[1]
+	if stored.TokenHash == token || stored.TokenHash != hashResetToken(token) {
+		t.Errorf("stored %q for token %q, want its SHA-256", stored.TokenHash, token)
+	}
+	if stored.AccountType != "member" || stored.AccountID != 1 {
+		t.Errorf("reset for %s %d", stored.AccountType, stored.AccountID)
+	}
+	if d := time.Until(stored.ExpiresAt); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v, want the configured hour", d)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	f, server := resetFixture(t)
+	ctx := context.Background()
+
+	f.service.RequestPasswordReset(ctx, "member", "budi@student.ac.id")
+	first := mailedToken(t, server.Next(t))
+	f.service.RequestPasswordReset(ctx, "member", "budi@student.ac.id")
+	second := mailedToken(t, server.Next(t))
+
+	// A rejected password leaves the link usable
+	if _, err := f.service.ResetPassword(first, "pendek"); err == nil {
+		t.Fatal("short password accepted")
+	}
+	if f.resets.resets[1].UsedAt != nil {
+		t.Fatal("token spent by a rejected password")
+	}
+
+	accountType, err := f.service.ResetPassword(first, "passwordbaru1")
+	if err != nil || accountType != "member" {
+		t.Fatalf("reset = %q, %v", accountType, err)
+	}
+	member := f.members.members[1]
+	if ok, _ := f.creds.Verify(member.Password, "passwordbaru1"); !ok {
+		t.Error("new password not stored")
+	}
+	if member.SessionVersion != 1 {
+		t.Errorf("session version = %d, want 1", member.SessionVersion)
+	}
+
+	// The used token and every other outstanding link are spent
+	if _, err := f.service.ResetPassword(first, "passwordbaru2"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reused token: err = %v, want ErrInvalidResetToken", err)
+	}
+	if _, err := f.service.ResetPassword(second, "passwordbaru2"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("other outstanding token: err = %v, want ErrInvalidResetToken", err)
+	}
+	if member.SessionVersion != 1 {
+		t.Errorf("session version = %d after rejected resets, want 1", member.SessionVersion)
+	}
+}
+
+func TestResetPasswordInvalidToken(t *testing.T) {
+	f, _ := resetFixture(t)
+	f.resets.Create("member", 1, hashResetToken("kedaluwarsa"), time.Now().Add(-time.Minute))
+
+	for _, token := range []string{"", "tidak-dikenal", "kedaluwarsa", hashResetToken("kedaluwarsa")} {
+		if _, err := f.service.ResetPassword(token, "passwordbaru1"); !errors.Is(err, ErrInvalidResetToken) {
+			t.Errorf("token %q: err = %v, want ErrInvalidResetToken", token, err)
+		}
+	}
+	if f.members.members[1].SessionVersion != 0 {
+		t.Error("password changed with an invalid token")
+	}
+}
+
+func TestResetPasswordRejectsCurrentPasswordWithoutHistory(t *testing.T) {
+	// The member predates the password history and has no row there
+	f, _ := resetFixture(t)
+	f.resets.Create("member", 1, hashResetToken("token"), time.Now().Add(time.Hour))
+
+	if _, err := f.service.ResetPassword("token", "rahasia123"); !errors.Is(err, credentials.ErrPasswordReused) {
+		t.Errorf("current password: err = %v, want ErrPasswordReused", err)
+	}
+}
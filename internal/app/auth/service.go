@@ -1,59 +1,105 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"time"
 
 	"simpus/config"
+	"simpus/internal/credentials"
+	"simpus/internal/mailer"
+	"simpus/internal/metrics"
 	"simpus/internal/models"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type MemberRepository interface {
+	FindByID(id int) (*models.Member, error)
 	FindByEmail(email string) (*models.Member, error)
+	FindByIdentityNumber(identityNumber string) (*models.Member, error)
 	GenerateMemberCode(memberType string) (string, error)
 	Create(m *models.MemberCreate, hashedPassword, memberCode string) (int64, error)
+	UpdatePassword(id int, hashedPassword string) error
+	RehashPassword(id int, hashedPassword string) error
+	MarkEmailVerified(id int, nextStatus string) error
 }
 
 type Service struct {
-	userRepo   *Repository
-	memberRepo MemberRepository
-	config     *config.Config
+	userRepo     Repository
+	memberRepo   MemberRepository
+	resetRepo    ResetRepository
+	identityRepo IdentityRepository
+	credentials  *credentials.Manager
+	mailer       mailer.Mailer
+	config       *config.Config
+
+	// Optional external authenticators
+	oidc      OIDCProvider
+	directory PasswordAuthenticator
 }
 
-func NewService(userRepo *Repository, memberRepo MemberRepository, cfg *config.Config) *Service {
+func NewService(
+	userRepo Repository,
+	memberRepo MemberRepository,
+	resetRepo ResetRepository,
+	identityRepo IdentityRepository,
+	creds *credentials.Manager,
+	mail mailer.Mailer,
+	cfg *config.Config,
+) *Service {
 	return &Service{
-		userRepo:   userRepo,
-		memberRepo: memberRepo,
-		config:     cfg,
+		userRepo:     userRepo,
+		memberRepo:   memberRepo,
+		resetRepo:    resetRepo,
+		identityRepo: identityRepo,
+		credentials:  creds,
+		mailer:       mail,
+		config:       cfg,
 	}
 }
 
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	Type     string `json:"type"` // "admin" or "member"
+	UserID         int    `json:"user_id"`
+	Username       string `json:"username"`
+	Role           string `json:"role"`
+	Type           string `json:"type"`                // "admin" or "member"
+	BranchID       int    `json:"branch_id,omitempty"` // staff branch, 0 for all branches
+	SessionVersion int    `json:"session_version"`
 	jwt.RegisteredClaims
 }
 
-func (s *Service) LoginAdmin(username, password string) (*models.User, string, error) {
+func (s *Service) LoginAdmin(ctx context.Context, username, password string) (*models.User, string, error) {
 	user, err := s.userRepo.FindByUsername(username)
+	if err == nil {
+		ok, needsRehash := s.credentials.Verify(user.Password, password)
+		if !ok {
+			user, err = nil, errors.New("password salah")
+		} else if needsRehash {
+			s.rehash(ctx, func(hash string) error { return s.userRepo.RehashPassword(user.ID, hash) }, password)
+		}
+	}
+
+	// Fall back to the staff directory when the local password does not match
+	if err != nil && s.directory != nil {
+		user, err = s.loginDirectory(ctx, username, password)
+		if errors.Is(err, ErrAccountNotLinked) {
+			metrics.LoginFailures.WithLabelValues("admin").Inc()
+			return nil, "", err
+		}
+	}
 	if err != nil {
+		metrics.LoginFailures.WithLabelValues("admin").Inc()
 		return nil, "", errors.New("username atau password salah")
 	}
 
 	if !user.IsActive {
+		metrics.LoginFailures.WithLabelValues("admin").Inc()
 		return nil, "", errors.New("akun tidak aktif")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, "", errors.New("username atau password salah")
-	}
-
-	token, err := s.generateToken(user.ID, user.Username, user.Role, "admin")
+	token, err := s.staffToken(user)
 	if err != nil {
 		return nil, "", err
 	}
@@ -61,21 +107,35 @@ func (s *Service) LoginAdmin(username, password string) (*models.User, string, e
 	return user, token, nil
 }
 
-func (s *Service) LoginMember(email, password string) (*models.Member, string, error) {
+func (s *Service) LoginMember(ctx context.Context, email, password string) (*models.Member, string, error) {
 	member, err := s.memberRepo.FindByEmail(email)
 	if err != nil {
+		metrics.LoginFailures.WithLabelValues("member").Inc()
 		return nil, "", errors.New("email atau password salah")
 	}
 
 	if !member.IsActive {
+		metrics.LoginFailures.WithLabelValues("member").Inc()
 		return nil, "", errors.New("akun tidak aktif")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(member.Password), []byte(password)); err != nil {
+	ok, needsRehash := s.credentials.Verify(member.Password, password)
+	if !ok {
+		metrics.LoginFailures.WithLabelValues("member").Inc()
 		return nil, "", errors.New("email atau password salah")
 	}
+	if needsRehash {
+		s.rehash(ctx, func(hash string) error { return s.memberRepo.RehashPassword(member.ID, hash) }, password)
+	}
 
-	token, err := s.generateToken(member.ID, member.Email, member.MemberType, "member")
+	// Pending members may sign in to follow their registration, but
+	// borrowing stays blocked until the account is active.
+	if member.Status == models.MemberStatusRejected {
+		metrics.LoginFailures.WithLabelValues("member").Inc()
+		return nil, "", errors.New("pendaftaran akun ditolak, silakan hubungi pustakawan")
+	}
+
+	token, err := s.generateToken(member.ID, member.Email, member.MemberType, "member", member.SessionVersion, 0)
 	if err != nil {
 		return nil, "", err
 	}
@@ -83,28 +143,24 @@ func (s *Service) LoginMember(email, password string) (*models.Member, string, e
 	return member, token, nil
 }
 
-func (s *Service) RegisterMember(data *models.MemberCreate) (int64, error) {
-	// Generate member code
-	memberCode, err := s.memberRepo.GenerateMemberCode(data.MemberType)
-	if err != nil {
-		return 0, err
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return 0, err
+// staffToken signs a session for a staff account, carrying the branch the
+// account is assigned to.
+func (s *Service) staffToken(user *models.User) (string, error) {
+	branchID := 0
+	if user.BranchID != nil {
+		branchID = *user.BranchID
 	}
-
-	return s.memberRepo.Create(data, string(hashedPassword), memberCode)
+	return s.generateToken(user.ID, user.Username, user.Role, "admin", user.SessionVersion, branchID)
 }
 
-func (s *Service) generateToken(userID int, username, role, userType string) (string, error) {
+func (s *Service) generateToken(userID int, username, role, userType string, sessionVersion, branchID int) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
-		Type:     userType,
+		UserID:         userID,
+		Username:       username,
+		Role:           role,
+		Type:           userType,
+		BranchID:       branchID,
+		SessionVersion: sessionVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.JWT.Expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -124,14 +180,48 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	// Tokens issued before the last password change are revoked
+	version, err := s.currentSessionVersion(claims)
+	if err != nil || version != claims.SessionVersion {
+		return nil, errors.New("invalid token")
 	}
 
-	return nil, errors.New("invalid token")
+	return claims, nil
+}
+
+func (s *Service) currentSessionVersion(claims *Claims) (int, error) {
+	if claims.Type == "member" {
+		member, err := s.memberRepo.FindByID(claims.UserID)
+		if err != nil {
+			return 0, err
+		}
+		return member.SessionVersion, nil
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return user.SessionVersion, nil
 }
 
 func (s *Service) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	return s.credentials.Hash(password)
+}
+
+// rehash upgrades a stored hash after a successful login. Failures are only
+// logged; the old hash keeps working.
+func (s *Service) rehash(ctx context.Context, store func(hash string) error, password string) {
+	hash, err := s.credentials.Hash(password)
+	if err == nil {
+		err = store(hash)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "auth: rehash password", "error", err)
+	}
 }
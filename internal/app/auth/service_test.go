@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/internal/credentials"
+	"simpus/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginAdminFailures(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Password: hash(t, cfg, "rahasia123"), Role: "admin", IsActive: true}
+	f.users.users[2] = &models.User{ID: 2, Username: "lama", Password: hash(t, cfg, "rahasia123"), Role: "staff", IsActive: false}
+
+	tests := []struct {
+		name, username, password, wantErr string
+	}{
+		{"unknown username", "tidakada", "rahasia123", "username atau password salah"},
+		{"wrong password", "admin", "salah12345", "username atau password salah"},
+		{"inactive account", "lama", "rahasia123", "akun tidak aktif"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token, err := f.service.LoginAdmin(context.Background(), tt.username, tt.password)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if token != "" {
+				t.Error("token issued despite error")
+			}
+		})
+	}
+}
+
+func TestLoginAdminTokenRevokedByPasswordReset(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Email: "admin@simpus.local", Password: hash(t, cfg, "rahasia123"), Role: "admin", IsActive: true}
+
+	_, token, err := f.service.LoginAdmin(context.Background(), "admin", "rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := f.service.ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Type != "admin" || claims.Role != "admin" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	f.resets.Create("admin", 1, hashResetToken("reset-token"), claims.ExpiresAt.Time)
+	if _, err := f.service.ResetPassword("reset-token", "passwordbaru1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.service.ValidateToken(token); err == nil {
+		t.Error("token still valid after password reset")
+	}
+	if _, err := f.service.ResetPassword("reset-token", "passwordbaru2"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reusing reset token: err = %v, want ErrInvalidResetToken", err)
+	}
+	if _, _, err := f.service.LoginAdmin(context.Background(), "admin", "passwordbaru1"); err != nil {
+		t.Errorf("login with new password: %v", err)
+	}
+}
+
+func TestLoginMember(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	password := hash(t, cfg, "rahasia123")
+	f.members.members[1] = &models.Member{ID: 1, Email: "budi@student.ac.id", Password: password, IsActive: true, Status: models.MemberStatusActive}
+	f.members.members[2] = &models.Member{ID: 2, Email: "ditolak@student.ac.id", Password: password, IsActive: true, Status: models.MemberStatusRejected}
+	f.members.members[3] = &models.Member{ID: 3, Email: "nonaktif@student.ac.id", Password: password, IsActive: false, Status: models.MemberStatusActive}
+	f.members.members[4] = &models.Member{ID: 4, Email: "baru@student.ac.id", Password: password, IsActive: true, Status: models.MemberStatusPendingVerification}
+
+	tests := []struct {
+		name, email, password, wantErr string
+	}{
+		{"active", "budi@student.ac.id", "rahasia123", ""},
+		{"pending verification may sign in", "baru@student.ac.id", "rahasia123", ""},
+		{"unknown email", "siapa@student.ac.id", "rahasia123", "email atau password salah"},
+		{"wrong password", "budi@student.ac.id", "salah12345", "email atau password salah"},
+		{"rejected registration", "ditolak@student.ac.id", "rahasia123", "pendaftaran akun ditolak, silakan hubungi pustakawan"},
+		{"inactive account", "nonaktif@student.ac.id", "rahasia123", "akun tidak aktif"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token, err := f.service.LoginMember(context.Background(), tt.email, tt.password)
+			if tt.wantErr == "" {
+				if err != nil || token == "" {
+					t.Fatalf("login failed: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoginRehashesOutdatedHash(t *testing.T) {
+	oldCfg := testPasswordConfig()
+	newCfg := oldCfg
+	newCfg.BcryptCost = oldCfg.BcryptCost + 1
+
+	f := newFixture(t, newCfg)
+	f.members.members[1] = &models.Member{ID: 1, Email: "budi@student.ac.id", Password: hash(t, oldCfg, "rahasia123"), IsActive: true, Status: models.MemberStatusActive}
+
+	_, token, err := f.service.LoginMember(context.Background(), "budi@student.ac.id", "rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cost, err := bcrypt.Cost([]byte(f.members.members[1].Password))
+	if err != nil || cost != newCfg.BcryptCost {
+		t.Errorf("stored cost = %d (%v), want %d", cost, err, newCfg.BcryptCost)
+	}
+	// Rehashing must not sign the member out
+	if _, err := f.service.ValidateToken(token); err != nil {
+		t.Errorf("token invalid after rehash: %v", err)
+	}
+}
+
+func TestLoginMigratesToArgon2id(t *testing.T) {
+	bcryptCfg := testPasswordConfig()
+	argonCfg := bcryptCfg
+	argonCfg.Algorithm = credentials.AlgorithmArgon2id
+	argonCfg.Argon2Memory, argonCfg.Argon2Iterations, argonCfg.Argon2Threads = 64, 1, 1
+
+	f := newFixture(t, argonCfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Password: hash(t, bcryptCfg, "rahasia123"), IsActive: true}
+
+	if _, _, err := f.service.LoginAdmin(context.Background(), "admin", "rahasia123"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, needsRehash := f.creds.Verify(f.users.users[1].Password, "rahasia123"); !ok || needsRehash {
+		t.Errorf("stored hash %q: ok=%v needsRehash=%v", f.users.users[1].Password, ok, needsRehash)
+	}
+}
+
+func TestResetPasswordPolicy(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	current := hash(t, cfg, "rahasia123")
+	f.members.members[1] = &models.Member{ID: 1, Email: "budi@student.ac.id", Password: current, IsActive: true, Status: models.MemberStatusActive}
+	f.history.Create("member", 1, current)
+
+	f.resets.Create("member", 1, hashResetToken("token"), time.Now().Add(time.Hour))
+
+	if _, err := f.service.ResetPassword("token", "pendek"); err == nil {
+		t.Error("short password accepted")
+	}
+	if _, err := f.service.ResetPassword("token", "rahasia123"); !errors.Is(err, credentials.ErrPasswordReused) {
+		t.Errorf("reused password: err = %v, want ErrPasswordReused", err)
+	}
+	// Rejected passwords do not consume the link
+	if _, err := f.service.ResetPassword("token", "passwordbaru1"); err != nil {
+		t.Errorf("valid password: %v", err)
+	}
+}
+
+type fakeDirectory struct {
+	identity *Identity
+}
+
+func (d *fakeDirectory) Name() string { return "ldap" }
+
+func (d *fakeDirectory) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	if d.identity == nil || password != "direktori123" {
+		return nil, errors.New("invalid credentials")
+	}
+	return d.identity, nil
+}
+
+func TestLoginAdminDirectoryFallback(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "pustakawan", Email: "pustakawan@kampus.ac.id", Password: hash(t, cfg, "lokal12345"), Role: "staff", IsActive: true}
+
+	f.service.UseDirectory(&fakeDirectory{identity: &Identity{Provider: "ldap", Subject: "uid=pustakawan", Email: "pustakawan@kampus.ac.id", EmailVerified: true}})
+	if _, _, err := f.service.LoginAdmin(context.Background(), "pustakawan", "direktori123"); err != nil {
+		t.Fatalf("directory login: %v", err)
+	}
+
+	f.service.UseDirectory(&fakeDirectory{identity: &Identity{Provider: "ldap", Subject: "uid=asing", Email: "asing@kampus.ac.id", EmailVerified: true}})
+	if _, _, err := f.service.LoginAdmin(context.Background(), "asing", "direktori123"); !errors.Is(err, ErrAccountNotLinked) {
+		t.Errorf("unlinked directory account: err = %v, want ErrAccountNotLinked", err)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Email: "admin@simpus.local", IsActive: true}
+
+	tests := []struct {
+		name    string
+		data    models.UserCreate
+		wantErr string
+	}{
+		{"taken username", models.UserCreate{Username: "admin", Email: "baru@simpus.local", Name: "Baru", Password: "rahasia123"}, "username sudah digunakan"},
+		{"taken email", models.UserCreate{Username: "baru", Email: "admin@simpus.local", Name: "Baru", Password: "rahasia123"}, "email sudah terdaftar"},
+		{"unknown role", models.UserCreate{Username: "baru", Email: "baru@simpus.local", Name: "Baru", Password: "rahasia123", Role: "root"}, "role harus admin atau staff"},
+		{"short password", models.UserCreate{Username: "baru", Email: "baru@simpus.local", Name: "Baru", Password: "pendek"}, "password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.CreateUser(&tt.data); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	id, err := f.service.CreateUser(&models.UserCreate{Username: "pustakawan", Email: "pustakawan@simpus.local", Name: "Pustakawan", Password: "rahasia123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.service.LoginAdmin(context.Background(), "pustakawan", "rahasia123"); err != nil {
+		t.Errorf("login as created user: %v", err)
+	}
+	if history, _ := f.history.Recent("admin", int(id), 5); len(history) != 1 {
+		t.Errorf("password history = %d entries, want 1", len(history))
+	}
+}
+
+func TestSetUserPasswordRevokesSessions(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Email: "admin@simpus.local", Password: hash(t, cfg, "rahasia123"), Role: "admin", IsActive: true}
+
+	_, token, err := f.service.LoginAdmin(context.Background(), "admin", "rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.SetUserPassword("tidakada", "passwordbaru1"); err == nil {
+		t.Error("unknown user accepted")
+	}
+	if err := f.service.SetUserPassword("admin", "passwordbaru1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.ValidateToken(token); err == nil {
+		t.Error("token still valid after password change")
+	}
+	if _, _, err := f.service.LoginAdmin(context.Background(), "admin", "passwordbaru1"); err != nil {
+		t.Errorf("login with new password: %v", err)
+	}
+}
+
+func TestSetUserBranchReissuesSession(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "pustakawan", Email: "pustakawan@simpus.local", Password: hash(t, cfg, "rahasia123"), Role: "staff", IsActive: true}
+
+	_, token, err := f.service.LoginAdmin(context.Background(), "pustakawan", "rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := f.service.ValidateToken(token); err != nil || claims.BranchID != 0 {
+		t.Fatalf("claims = %+v, %v, want no branch", claims, err)
+	}
+
+	branchID := 2
+	if err := f.service.SetUserBranch("pustakawan", &branchID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.ValidateToken(token); err == nil {
+		t.Error("token issued for the old branch still valid")
+	}
+
+	_, token, err = f.service.LoginAdmin(context.Background(), "pustakawan", "rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := f.service.ValidateToken(token); err != nil || claims.BranchID != 2 {
+		t.Errorf("claims = %+v, %v, want branch 2", claims, err)
+	}
+}
+
+type fakeOIDC struct {
+	identity *Identity
+}
+
+func (p *fakeOIDC) Name() string { return "oidc" }
+
+func (p *fakeOIDC) AuthCodeURL(ctx context.Context, state, nonce, redirectURL string) (string, error) {
+	return "http://idp.test/auth?state=" + state, nil
+}
+
+func (p *fakeOIDC) Exchange(ctx context.Context, code, nonce, redirectURL string) (*Identity, error) {
+	return p.identity, nil
+}
+
+func TestLoginOIDCStaffLinking(t *testing.T) {
+	cfg := testPasswordConfig()
+	f := newFixture(t, cfg)
+	f.users.users[1] = &models.User{ID: 1, Username: "admin", Email: "admin@kampus.ac.id", Role: "admin", IsActive: true}
+
+	tests := []struct {
+		name     string
+		identity Identity
+	}{
+		// Anyone can choose "admin" as their name at the provider
+		{"username is not matched", Identity{Subject: "a", Email: "mhs@kampus.ac.id", EmailVerified: true}},
+		{"unverified email", Identity{Subject: "b", Email: "admin@kampus.ac.id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.identity.Provider = "oidc"
+			f.service.UseOIDC(&fakeOIDC{identity: &tt.identity})
+			if _, err := f.service.LoginOIDC(context.Background(), "admin", "code", "nonce", ""); !errors.Is(err, ErrAccountNotLinked) {
+				t.Fatalf("err = %v, want ErrAccountNotLinked", err)
+			}
+		})
+	}
+
+	// A verified email links the identity; later logins follow the link
+	// even after the address changes at the provider
+	identity := &Identity{Provider: "oidc", Subject: "c", Email: "admin@kampus.ac.id", EmailVerified: true}
+	f.service.UseOIDC(&fakeOIDC{identity: identity})
+	if _, err := f.service.LoginOIDC(context.Background(), "admin", "code", "nonce", ""); err != nil {
+		t.Fatal(err)
+	}
+	identity.Email, identity.EmailVerified = "baru@kampus.ac.id", false
+	token, err := f.service.LoginOIDC(context.Background(), "admin", "code", "nonce", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := f.service.ValidateToken(token); err != nil || claims.UserID != 1 || claims.Type != "admin" {
+		t.Errorf("claims = %+v, %v", claims, err)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"simpus/internal/models"
+)
+
+// CreateUser adds a staff account. The password must satisfy the same
+// policy as passwords chosen in the web UI.
+func (s *Service) CreateUser(data *models.UserCreate) (int64, error) {
+	data.Username = strings.TrimSpace(data.Username)
+	data.Email = strings.TrimSpace(data.Email)
+	if data.Username == "" || data.Email == "" || data.Name == "" {
+		return 0, errors.New("username, email dan nama wajib diisi")
+	}
+	if data.Role == "" {
+		data.Role = "staff"
+	}
+	if data.Role != "admin" && data.Role != "staff" {
+		return 0, errors.New("role harus admin atau staff")
+	}
+
+	if _, err := s.userRepo.FindByUsername(data.Username); err == nil {
+		return 0, errors.New("username sudah digunakan")
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if _, err := s.userRepo.FindByEmail(data.Email); err == nil {
+		return 0, errors.New("email sudah terdaftar")
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if err := s.credentials.Validate("admin", 0, "", data.Password); err != nil {
+		return 0, err
+	}
+	hashedPassword, err := s.credentials.Hash(data.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := s.userRepo.Create(data, hashedPassword)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.credentials.Record("admin", int(id), hashedPassword)
+}
+
+// SetUserPassword replaces the password of a staff account without a reset
+// link. Existing sessions and outstanding reset links are revoked.
+func (s *Service) SetUserPassword(username, password string) error {
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("user tidak ditemukan")
+		}
+		return err
+	}
+
+	if err := s.credentials.Validate("admin", user.ID, user.Password, password); err != nil {
+		return err
+	}
+	hashedPassword, err := s.credentials.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+	if err := s.credentials.Record("admin", user.ID, hashedPassword); err != nil {
+		return err
+	}
+	return s.resetRepo.InvalidateAll("admin", user.ID)
+}
+
+// SetUserBranch assigns a staff account to a branch, or to all branches
+// when branchID is nil. The account has to sign in again to pick up the new
+// branch.
+func (s *Service) SetUserBranch(username string, branchID *int) error {
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("user tidak ditemukan")
+		}
+		return err
+	}
+	return s.userRepo.UpdateBranch(user.ID, branchID)
+}
@@ -0,0 +1,87 @@
+package backups
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"simpus/internal/middleware"
+	"simpus/internal/renderer"
+)
+
+type Handler struct {
+	service *Service
+	views   *renderer.Renderer
+}
+
+func NewHandler(service *Service, views *renderer.Renderer) *Handler {
+	return &Handler{
+		service: service,
+		views:   views,
+	}
+}
+
+func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	claims := middleware.GetUserFromContext(r.Context())
+
+	data := map[string]interface{}{
+		"Title":    "Backup Data - SIMPUS",
+		"Backups":  list,
+		"Settings": h.service.Settings(),
+		"Success":  r.URL.Query().Get("success"),
+		"Error":    r.URL.Query().Get("error"),
+		"User":     claims,
+	}
+
+	h.views.Render(w, r, "admin/backups/index.html", data)
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	target := "/admin/backups?success=" + url.QueryEscape("Backup berhasil dibuat")
+
+	info, err := h.service.Create(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "backups: create", "error", err)
+		target = "/admin/backups?error=" + url.QueryEscape("Gagal membuat backup: "+err.Error())
+	} else {
+		slog.InfoContext(r.Context(), "backup created", "name", info.Name, "size", info.Size)
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (h *Handler) Download(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	f, err := h.service.Open(name)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
@@ -0,0 +1,199 @@
+package backups
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"simpus/config"
+	"simpus/internal/backup"
+)
+
+const (
+	filePrefix = "simpus-backup-"
+	fileSuffix = ".tar.gz"
+)
+
+// ErrNotFound is returned for names that are not backups in the backup
+// directory.
+var ErrNotFound = errors.New("backup tidak ditemukan")
+
+// SchemaVersioner reports the last applied migration, see
+// database.Migrator.
+type SchemaVersioner interface {
+	Version(ctx context.Context) (int, error)
+}
+
+// Info describes a backup archive in the backup directory.
+type Info struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+type Service struct {
+	db      *sql.DB
+	driver  string
+	schema  SchemaVersioner
+	cfg     config.BackupConfig
+	uploads backup.Files
+}
+
+func NewService(db *sql.DB, driver string, schema SchemaVersioner, cfg config.BackupConfig, uploads backup.Files) *Service {
+	return &Service{
+		db:      db,
+		driver:  driver,
+		schema:  schema,
+		cfg:     cfg,
+		uploads: uploads,
+	}
+}
+
+// Settings returns the backup directory, schedule and retention.
+func (s *Service) Settings() config.BackupConfig {
+	return s.cfg
+}
+
+func (s *Service) options(ctx context.Context) (backup.Options, error) {
+	version, err := s.schema.Version(ctx)
+	if err != nil {
+		return backup.Options{}, fmt.Errorf("read schema version: %w", err)
+	}
+	return backup.Options{SchemaVersion: version, Driver: s.driver, Uploads: s.uploads}, nil
+}
+
+// Write streams a backup to w.
+func (s *Service) Write(ctx context.Context, w io.Writer) (*backup.Manifest, error) {
+	opts, err := s.options(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return backup.Write(ctx, s.db, w, opts)
+}
+
+// Create writes a new archive to the backup directory and removes the
+// oldest ones beyond the retention limit.
+func (s *Service) Create(ctx context.Context) (*Info, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	// Written under a temporary name so List never shows a partial archive
+	tmp, err := os.CreateTemp(s.cfg.Dir, ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := s.Write(ctx, tmp); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	name := filePrefix + time.Now().Format("20060102-150405") + fileSuffix
+	path := filepath.Join(s.cfg.Dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, errors.New("backup dengan nama yang sama sudah ada, coba lagi sebentar lagi")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Prune(); err != nil {
+		return nil, fmt.Errorf("prune old backups: %w", err)
+	}
+	return s.stat(name)
+}
+
+// List returns the archives in the backup directory, newest first.
+func (s *Service) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Info
+	for _, e := range entries {
+		if e.IsDir() || !validName(e.Name()) {
+			continue
+		}
+		info, err := s.stat(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *info)
+	}
+	// Names embed the creation time, so they sort chronologically
+	sort.Slice(list, func(i, j int) bool { return list[i].Name > list[j].Name })
+	return list, nil
+}
+
+// Prune removes the oldest archives so that at most cfg.Keep remain.
+func (s *Service) Prune() (int, error) {
+	if s.cfg.Keep <= 0 {
+		return 0, nil
+	}
+	list, err := s.List()
+	if err != nil || len(list) <= s.cfg.Keep {
+		return 0, err
+	}
+
+	removed := 0
+	for _, info := range list[s.cfg.Keep:] {
+		if err := os.Remove(filepath.Join(s.cfg.Dir, info.Name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Open returns the named archive for downloading.
+func (s *Service) Open(name string) (*os.File, error) {
+	if !validName(name) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(s.cfg.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Restore replaces all library data with the archive read from r. The
+// archive must come from a database at the same migration version.
+func (s *Service) Restore(ctx context.Context, r io.Reader) (*backup.Manifest, error) {
+	opts, err := s.options(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return backup.Restore(ctx, s.db, r, opts)
+}
+
+func (s *Service) stat(name string) (*Info, error) {
+	fi, err := os.Stat(filepath.Join(s.cfg.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &Info{Name: name, Size: fi.Size(), CreatedAt: fi.ModTime()}, nil
+}
+
+// validName accepts only archive names created by this service, which
+// also keeps download requests inside the backup directory.
+func validName(name string) bool {
+	return strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) &&
+		!strings.ContainsAny(name, `/\`) && name != filePrefix+fileSuffix
+}
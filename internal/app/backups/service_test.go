@@ -0,0 +1,95 @@
+package backups
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpus/config"
+	"simpus/database/sqlitetest"
+	"simpus/internal/storage"
+)
+
+type fixedVersion int
+
+func (v fixedVersion) Version(ctx context.Context) (int, error) { return int(v), nil }
+
+func newTestService(t *testing.T, keep int) *Service {
+	t.Helper()
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	cfg := config.BackupConfig{Dir: filepath.Join(t.TempDir(), "backups"), Keep: keep}
+	return NewService(db, "sqlite", fixedVersion(5), cfg, storage.NewLocal(filepath.Join(t.TempDir(), "uploads")))
+}
+
+func TestCreateKeepsNewestBackups(t *testing.T) {
+	s := newTestService(t, 2)
+
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"simpus-backup-20240101-010000.tar.gz",
+		"simpus-backup-20240102-010000.tar.gz",
+		"catatan.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(s.cfg.Dir, name), []byte("lama"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := s.Create(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != info.Name || list[1].Name != "simpus-backup-20240102-010000.tar.gz" {
+		t.Errorf("backups after create = %+v", list)
+	}
+	// Files that are not backups are left alone
+	if _, err := os.Stat(filepath.Join(s.cfg.Dir, "catatan.txt")); err != nil {
+		t.Error(err)
+	}
+
+	f, err := s.Open(info.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := s.Restore(context.Background(), f); err != nil {
+		t.Errorf("restore created backup: %v", err)
+	}
+}
+
+func TestOpenRejectsOtherFiles(t *testing.T) {
+	s := newTestService(t, 0)
+	if _, err := s.Create(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"catatan.txt", "../simpus.db", "simpus-backup-../../x.tar.gz", "simpus-backup-20990101-000000.tar.gz"} {
+		if _, err := s.Open(name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q): err = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestRestoreChecksSchemaVersion(t *testing.T) {
+	s := newTestService(t, 0)
+	var buf bytes.Buffer
+	if _, err := s.Write(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s.schema = fixedVersion(6)
+	if _, err := s.Restore(context.Background(), &buf); err == nil {
+		t.Error("backup from an older schema restored")
+	}
+}
@@ -1,24 +1,23 @@
 package books
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
 	"strconv"
 
 	"simpus/internal/middleware"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
 )
 
 type AuthorHandler struct {
-	service   *Service
-	templates *template.Template
+	service *Service
+	views   *renderer.Renderer
 }
 
-func NewAuthorHandler(service *Service, templates *template.Template) *AuthorHandler {
+func NewAuthorHandler(service *Service, views *renderer.Renderer) *AuthorHandler {
 	return &AuthorHandler{
-		service:   service,
-		templates: templates,
+		service: service,
+		views:   views,
 	}
 }
 
@@ -38,11 +37,11 @@ func (h *AuthorHandler) Index(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/authors/table.html", data)
+		h.views.Partial(w, r, "admin/authors/index.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/authors/index.html", data)
+	h.views.Render(w, r, "admin/authors/index.html", data)
 }
 
 func (h *AuthorHandler) Store(w http.ResponseWriter, r *http.Request) {
@@ -114,44 +113,3 @@ func (h *AuthorHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/admin/authors", http.StatusSeeOther)
 }
-
-func (h *AuthorHandler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (h *AuthorHandler) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(filepath.Join("templates", name))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
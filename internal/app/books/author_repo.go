@@ -5,18 +5,28 @@ import (
 	"simpus/internal/models"
 )
 
-type AuthorRepository struct {
+// AuthorRepository stores book authors.
+type AuthorRepository interface {
+	FindAll() ([]models.Author, error)
+	FindByID(id int) (*models.Author, error)
+	Create(a *models.AuthorCreate) (int64, error)
+	Update(id int, a *models.AuthorCreate) error
+	Delete(id int) error
+}
+
+type authorRepository struct {
 	db *sql.DB
 }
 
-func NewAuthorRepository(db *sql.DB) *AuthorRepository {
-	return &AuthorRepository{db: db}
+func NewAuthorRepository(db *sql.DB) AuthorRepository {
+	return &authorRepository{db: db}
 }
 
-func (r *AuthorRepository) FindAll() ([]models.Author, error) {
-	query := `SELECT a.id, a.name, a.bio, a.created_at, COUNT(b.id) as book_count
+func (r *authorRepository) FindAll() ([]models.Author, error) {
+	// A book counts once for a person credited in several roles
+	query := `SELECT a.id, a.name, a.bio, a.created_at, COUNT(DISTINCT bc.book_id) as book_count
 			  FROM authors a
-			  LEFT JOIN books b ON a.id = b.author_id
+			  LEFT JOIN book_contributors bc ON a.id = bc.author_id
 			  GROUP BY a.id
 			  ORDER BY a.name`
 
@@ -40,7 +50,7 @@ func (r *AuthorRepository) FindAll() ([]models.Author, error) {
 	return authors, nil
 }
 
-func (r *AuthorRepository) FindByID(id int) (*models.Author, error) {
+func (r *authorRepository) FindByID(id int) (*models.Author, error) {
 	a := &models.Author{}
 	var bio sql.NullString
 	query := `SELECT id, name, bio, created_at FROM authors WHERE id = ?`
@@ -53,7 +63,7 @@ func (r *AuthorRepository) FindByID(id int) (*models.Author, error) {
 	return a, nil
 }
 
-func (r *AuthorRepository) Create(a *models.AuthorCreate) (int64, error) {
+func (r *authorRepository) Create(a *models.AuthorCreate) (int64, error) {
 	query := `INSERT INTO authors (name, bio) VALUES (?, ?)`
 	result, err := r.db.Exec(query, a.Name, a.Bio)
 	if err != nil {
@@ -62,13 +72,13 @@ func (r *AuthorRepository) Create(a *models.AuthorCreate) (int64, error) {
 	return result.LastInsertId()
 }
 
-func (r *AuthorRepository) Update(id int, a *models.AuthorCreate) error {
+func (r *authorRepository) Update(id int, a *models.AuthorCreate) error {
 	query := `UPDATE authors SET name = ?, bio = ? WHERE id = ?`
 	_, err := r.db.Exec(query, a.Name, a.Bio, id)
 	return err
 }
 
-func (r *AuthorRepository) Delete(id int) error {
+func (r *authorRepository) Delete(id int) error {
 	_, err := r.db.Exec(`DELETE FROM authors WHERE id = ?`, id)
 	return err
 }
@@ -1,24 +1,33 @@
 package books
 
 import (
-	"html/template"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 
+	"simpus/internal/app/branches"
 	"simpus/internal/middleware"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
 )
 
 type BookHandler struct {
-	service   *Service
-	templates *template.Template
+	service       *Service
+	branchService *branches.Service
+	views         *renderer.Renderer
 }
 
-func NewBookHandler(service *Service, templates *template.Template) *BookHandler {
+func NewBookHandler(service *Service, branchService *branches.Service, views *renderer.Renderer) *BookHandler {
 	return &BookHandler{
-		service:   service,
-		templates: templates,
+		service:       service,
+		branchService: branchService,
+		views:         views,
 	}
 }
 
@@ -29,10 +38,17 @@ func (h *BookHandler) Index(w http.ResponseWriter, r *http.Request) {
 	}
 	search := r.URL.Query().Get("search")
 	categoryID, _ := strconv.Atoi(r.URL.Query().Get("category"))
+	class := r.URL.Query().Get("class")
+	if !classDigits.MatchString(class) {
+		class = ""
+	}
+	workID, _ := strconv.Atoi(r.URL.Query().Get("work"))
 
 	filter := models.BookFilter{
 		Search:     search,
 		CategoryID: categoryID,
+		Class:      class,
+		WorkID:     workID,
 		Page:       page,
 		Limit:      10,
 	}
@@ -58,40 +74,39 @@ func (h *BookHandler) Index(w http.ResponseWriter, r *http.Request) {
 		"Search":     search,
 		"CategoryID": categoryID,
 		"Categories": categories,
+		"Class":      class,
+		"Classes":    models.DDCClasses,
+		"WorkID":     workID,
+		"Layouts":    LabelLayouts,
 		"User":       claims,
 	}
 
 	// Check if this is an HTMX request
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/books/table.html", data)
+		h.views.Partial(w, r, "admin/books/index.html", "books-table", data)
 		return
 	}
 
-	h.render(w, "admin/books/index.html", data)
+	h.views.Render(w, r, "admin/books/index.html", data)
 }
 
+// MemberIndex is the catalog. Every selection is kept in the query string
+// so a listing can be shared or bookmarked.
 func (h *BookHandler) MemberIndex(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	search := r.URL.Query().Get("search")
-	categoryID, _ := strconv.Atoi(r.URL.Query().Get("category"))
-
-	filter := models.BookFilter{
-		Search:     search,
-		CategoryID: categoryID,
-		Page:       page,
-		Limit:      12, // Grid view usually has more items
-	}
+	filter := catalogFilter(r.URL.Query())
 
 	books, total, err := h.service.GetBooks(filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	facets, err := h.service.GetFacets(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	categories, _ := h.service.GetCategories()
+	branchList, _ := h.branchService.GetActiveBranches()
 
 	totalPages := (total + filter.Limit - 1) / filter.Limit
 
@@ -101,62 +116,291 @@ func (h *BookHandler) MemberIndex(w http.ResponseWriter, r *http.Request) {
 		"Title":      "Katalog Buku - SIMPUS",
 		"Books":      books,
 		"Total":      total,
-		"Page":       page,
+		"Page":       filter.Page,
 		"TotalPages": totalPages,
-		"Search":     search,
-		"CategoryID": categoryID,
-		"Categories": categories,
+		"Filter":     filter,
+		"Query":      catalogQuery(filter),
+		"Facets":     facets,
+		"Branches":   branchList,
 		"User":       claims,
 	}
 
-	h.renderMember(w, "member/books/index.html", data)
+	h.views.Render(w, r, "member/books/index.html", data)
 }
 
-func (h *BookHandler) Create(w http.ResponseWriter, r *http.Request) {
-	categories, _ := h.service.GetCategories()
-	authors, _ := h.service.GetAuthors()
+// classDigits is a DDC class in the catalog query: a main class, division
+// or section.
+var classDigits = regexp.MustCompile(`^[0-9]{1,3}$`)
 
-	claims := middleware.GetUserFromContext(r.Context())
+// catalogFilter reads the catalog selections from a query string.
+func catalogFilter(q url.Values) models.BookFilter {
+	number := func(key string) int {
+		n, _ := strconv.Atoi(q.Get(key))
+		return max(n, 0)
+	}
+	filter := models.BookFilter{
+		Search:     strings.TrimSpace(q.Get("search")),
+		CategoryID: number("category"),
+		AuthorID:   number("author"),
+		Subject:    q.Get("subject"),
+		Publisher:  q.Get("publisher"),
+		Language:   q.Get("lang"),
+		YearFrom:   number("year_from"),
+		YearTo:     number("year_to"),
+		Available:  q.Get("available") == "1",
+		BranchID:   number("branch"),
+		SeriesID:   number("series"),
+		Page:       max(number("page"), 1),
+		Limit:      12, // Grid view usually has more items
+	}
+	if class := q.Get("class"); classDigits.MatchString(class) {
+		filter.Class = class
+	}
+	switch s := q.Get("sort"); s {
+	case models.BookSortNewest, models.BookSortTitle, models.BookSortPopular, models.BookSortYear, models.BookSortShelf, models.BookSortVolume:
+		filter.Sort = s
+	}
+	return filter
+}
 
-	data := map[string]interface{}{
-		"Title":      "Tambah Buku - SIMPUS",
-		"Categories": categories,
-		"Authors":    authors,
-		"User":       claims,
+// catalogQuery is the query string of filter without the page, for links
+// that change one selection.
+func catalogQuery(f models.BookFilter) url.Values {
+	q := url.Values{}
+	set := func(key, value string) {
+		if value != "" && value != "0" {
+			q.Set(key, value)
+		}
+	}
+	set("search", f.Search)
+	set("category", strconv.Itoa(f.CategoryID))
+	set("author", strconv.Itoa(f.AuthorID))
+	set("subject", f.Subject)
+	set("class", f.Class)
+	set("publisher", f.Publisher)
+	set("lang", f.Language)
+	set("year_from", strconv.Itoa(f.YearFrom))
+	set("year_to", strconv.Itoa(f.YearTo))
+	if f.Available {
+		set("available", "1")
+	}
+	set("branch", strconv.Itoa(f.BranchID))
+	set("series", strconv.Itoa(f.SeriesID))
+	set("sort", f.Sort)
+	return q
+}
+
+// Suggest fills the datalist of the search box with completions of what
+// has been typed so far.
+func (h *BookHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	h.suggest(w, r, "admin/books/index.html")
+}
+
+func (h *BookHandler) MemberSuggest(w http.ResponseWriter, r *http.Request) {
+	h.suggest(w, r, "member/books/index.html")
+}
+
+func (h *BookHandler) suggest(w http.ResponseWriter, r *http.Request, page string) {
+	suggestions, err := h.service.SuggestBooks(r.URL.Query().Get("search"), 8)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	h.views.Partial(w, r, page, "book-suggestions", suggestions)
+}
+
+func (h *BookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r.Context())
+	data := h.createData(r, models.BookCreate{Stock: 1, BranchID: claims.BranchID})
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/books/form.html", data)
+		h.views.Partial(w, r, "admin/books/create.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/books/create.html", data)
+	h.views.Render(w, r, "admin/books/create.html", data)
 }
 
-func (h *BookHandler) Store(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+func (h *BookHandler) createData(r *http.Request, form models.BookCreate) map[string]interface{} {
+	categories, _ := h.service.GetCategories()
+	authors, _ := h.service.GetAuthors()
+	works, _ := h.service.GetWorks()
+	branchList, _ := h.branchService.GetActiveBranches()
+
+	return map[string]interface{}{
+		"Title":         "Tambah Buku - SIMPUS",
+		"Form":          form,
+		"Categories":    categories,
+		"Authors":       authors,
+		"Works":         works,
+		"Roles":         models.ContributorRoles,
+		"Languages":     models.Languages,
+		"Branches":      branchList,
+		"LookupEnabled": h.service.LookupEnabled(),
+		"User":          middleware.GetUserFromContext(r.Context()),
+	}
+}
+
+// Lookup fills in the create form from the metadata of the ISBN typed in
+// it. Fields the lookup knows nothing of keep what was typed.
+func (h *BookHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	form := bookForm(r)
+	result, err := h.service.LookupISBN(r.Context(), form.ISBN)
+	data := h.createData(r, form)
+	if err != nil {
+		data["LookupError"] = err.Error()
+		h.views.Partial(w, r, "admin/books/create.html", "book-fields", data)
 		return
 	}
 
+	found := result.Book
+	form.ISBN = found.ISBN
+	for dst, src := range map[*string]string{
+		&form.Title:       found.Title,
+		&form.Publisher:   found.Publisher,
+		&form.Language:    found.Language,
+		&form.Description: found.Description,
+		&form.CoverImage:  found.CoverImage,
+	} {
+		if src != "" {
+			*dst = src
+		}
+	}
+	for dst, src := range map[*int]int{
+		&form.CategoryID:  found.CategoryID,
+		&form.PublishYear: found.PublishYear,
+	} {
+		if src != 0 {
+			*dst = src
+		}
+	}
+	if len(found.Contributors) > 0 {
+		form.Contributors = found.Contributors
+	}
+
+	data["Form"] = form
+	data["Lookup"] = result
+	h.views.Partial(w, r, "admin/books/create.html", "book-fields", data)
+}
+
+// bookForm reads the fields of the create form.
+func bookForm(r *http.Request) models.BookCreate {
 	categoryID, _ := strconv.Atoi(r.FormValue("category_id"))
-	authorID, _ := strconv.Atoi(r.FormValue("author_id"))
 	publishYear, _ := strconv.Atoi(r.FormValue("publish_year"))
 	stock, _ := strconv.Atoi(r.FormValue("stock"))
+	branchID, _ := strconv.Atoi(r.FormValue("branch_id"))
+	workID, newWork := workField(r)
 
-	data := &models.BookCreate{
+	return models.BookCreate{
 		ISBN:        r.FormValue("isbn"),
 		Title:       r.FormValue("title"),
 		CategoryID:  categoryID,
-		AuthorID:    authorID,
 		Publisher:   r.FormValue("publisher"),
 		PublishYear: publishYear,
+		Language:    r.FormValue("language"),
+		DDC:         r.FormValue("ddc"),
+		Cutter:      r.FormValue("cutter"),
+		WorkID:      workID,
+		NewWork:     newWork,
+		Edition:     r.FormValue("edition"),
 		Stock:       stock,
+		BranchID:    branchID,
+		CoverImage:  r.FormValue("cover_image"),
 		Description: r.FormValue("description"),
+
+		Contributors: contributorForm(r),
+		Subjects:     splitSubjects(r.FormValue("subjects")),
+	}
+}
+
+// workField reads the work select of the create and edit forms: the ID of
+// the work the book is an edition of, 0 for none, or "new" to start one.
+func workField(r *http.Request) (id int, newWork bool) {
+	value := r.FormValue("work_id")
+	if value == "new" {
+		return 0, true
 	}
+	id, _ = strconv.Atoi(value)
+	return id, false
+}
 
-	_, err := h.service.CreateBook(data)
+// contributorForm reads the contributor rows of the create and edit forms,
+// repeated contributor_author and contributor_role fields in credit order.
+// The form must have been parsed.
+func contributorForm(r *http.Request) []models.Contributor {
+	authors, roles := r.Form["contributor_author"], r.Form["contributor_role"]
+	var list []models.Contributor
+	for i, value := range authors {
+		id, _ := strconv.Atoi(value)
+		c := models.Contributor{AuthorID: id, Role: models.RoleAuthor}
+		if i < len(roles) && roles[i] != "" {
+			c.Role = roles[i]
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+// uploadCover parses the create or edit form, which is multipart when it
+// carries a cover, and saves the uploaded cover. It returns the key of the
+// cover, "" when none was uploaded, or writes the error and returns false.
+func (h *BookHandler) uploadCover(w http.ResponseWriter, r *http.Request) (string, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxCoverSize+1<<20)
+	err := r.ParseMultipartForm(1 << 20)
+	if errors.Is(err, http.ErrNotMultipart) {
+		return "", true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, ErrCoverTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return "", false
+	}
 	if err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return "", false
+	}
+
+	file, _, err := r.FormFile("cover")
+	if errors.Is(err, http.ErrMissingFile) {
+		return "", true
+	}
+	if err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return "", false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, MaxCoverSize+1))
+	if err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return "", false
+	}
+
+	cover, err := h.service.SaveCover(r.Context(), data)
+	switch {
+	case errors.Is(err, ErrCoverType), errors.Is(err, ErrCoverTooLarge), errors.Is(err, ErrCoverPixels), errors.Is(err, ErrCoverDisabled):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	case err != nil:
+		slog.ErrorContext(r.Context(), "books: save cover", "error", err)
+		http.Error(w, "Sampul tidak dapat disimpan", http.StatusInternalServerError)
+		return "", false
+	}
+	return cover, true
+}
+
+func (h *BookHandler) Store(w http.ResponseWriter, r *http.Request) {
+	cover, ok := h.uploadCover(w, r)
+	if !ok {
+		return
+	}
+
+	data := bookForm(r)
+	if cover != "" {
+		data.CoverImage = cover
+	}
+	_, err := h.service.CreateBook(&data)
+	if err != nil {
+		h.service.DiscardCover(r.Context(), cover)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -180,6 +424,7 @@ func (h *BookHandler) Edit(w http.ResponseWriter, r *http.Request) {
 
 	categories, _ := h.service.GetCategories()
 	authors, _ := h.service.GetAuthors()
+	works, _ := h.service.GetWorks()
 
 	claims := middleware.GetUserFromContext(r.Context())
 
@@ -188,46 +433,82 @@ func (h *BookHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		"Book":       book,
 		"Categories": categories,
 		"Authors":    authors,
+		"Works":      works,
+		"Roles":      models.ContributorRoles,
+		"Languages":  models.Languages,
 		"User":       claims,
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/books/form.html", data)
+		h.views.Partial(w, r, "admin/books/edit.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/books/edit.html", data)
+	h.views.Render(w, r, "admin/books/edit.html", data)
 }
 
 func (h *BookHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(r.PathValue("id"))
 
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+	cover, ok := h.uploadCover(w, r)
+	if !ok {
 		return
 	}
 
 	categoryID, _ := strconv.Atoi(r.FormValue("category_id"))
-	authorID, _ := strconv.Atoi(r.FormValue("author_id"))
 	publishYear, _ := strconv.Atoi(r.FormValue("publish_year"))
-	stock, _ := strconv.Atoi(r.FormValue("stock"))
+	workID, newWork := workField(r)
 
 	data := &models.BookUpdate{
 		ISBN:        r.FormValue("isbn"),
 		Title:       r.FormValue("title"),
 		CategoryID:  categoryID,
-		AuthorID:    authorID,
 		Publisher:   r.FormValue("publisher"),
 		PublishYear: publishYear,
-		Stock:       stock,
+		Language:    r.FormValue("language"),
+		DDC:         r.FormValue("ddc"),
+		Cutter:      r.FormValue("cutter"),
+		WorkID:      workID,
+		NewWork:     newWork,
+		Edition:     r.FormValue("edition"),
+		CoverImage:  r.FormValue("cover_image"),
 		Description: r.FormValue("description"),
+
+		Contributors: contributorForm(r),
+		Subjects:     splitSubjects(r.FormValue("subjects")),
+	}
+	switch {
+	case cover != "":
+		data.CoverImage = cover
+	case r.FormValue("remove_cover") != "":
+		data.CoverImage = ""
+	}
+
+	// Stock is edited per branch as stock_<branch id>
+	stock := map[int]int{}
+	for key, values := range r.PostForm {
+		branchID, err := strconv.Atoi(strings.TrimPrefix(key, "stock_"))
+		if !strings.HasPrefix(key, "stock_") || err != nil || len(values) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(values[0])
+		if err != nil || n < 0 {
+			http.Error(w, "Jumlah stok tidak valid", http.StatusBadRequest)
+			return
+		}
+		stock[branchID] = n
 	}
 
 	err := h.service.UpdateBook(id, data)
 	if err != nil {
+		h.service.DiscardCover(r.Context(), cover)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := h.service.SetBranchStock(id, stock); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if r.Header.Get("HX-Request") == "true" {
 		w.Header().Set("HX-Redirect", "/admin/books")
@@ -254,86 +535,61 @@ func (h *BookHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/books", http.StatusSeeOther)
 }
 
-func (h *BookHandler) MemberShow(w http.ResponseWriter, r *http.Request) {
-	id, _ := strconv.Atoi(r.PathValue("id"))
-
-	book, err := h.service.GetBook(id)
-	if err != nil {
-		http.Error(w, "Buku tidak ditemukan", http.StatusNotFound)
+// Labels prints spine or barcode labels for the books ticked in the list
+// as a PDF, to print on a sheet of labels.
+func (h *BookHandler) Labels(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var ids []int
+	seen := map[int]bool{}
+	for _, value := range q["id"] {
+		if id, err := strconv.Atoi(value); err == nil && id > 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	start, _ := strconv.Atoi(q.Get("start"))
+	opts := LabelOptions{
+		Layout:  q.Get("layout"),
+		Kind:    q.Get("kind"),
+		Start:   start,
+		PerCopy: q.Get("copies") == "1",
+		Outline: q.Get("outline") == "1",
+	}
+
+	// Made in full first so an error can still be reported as such
+	var buf bytes.Buffer
+	if err := h.service.PrintLabels(&buf, ids, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	claims := middleware.GetUserFromContext(r.Context())
-
-	data := map[string]interface{}{
-		"Title": "Detail Buku - SIMPUS",
-		"Book":  book,
-		"User":  claims,
-	}
-
-	h.renderMember(w, "member/books/show.html", data)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="label-`+opts.Kind+`.pdf"`)
+	w.Write(buf.Bytes())
 }
 
-func (h *BookHandler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
+func (h *BookHandler) MemberShow(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
 
-func (h *BookHandler) renderMember(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
+	book, err := h.service.GetBook(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Buku tidak ditemukan", http.StatusNotFound)
 		return
 	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "member.html"),
-		filepath.Join("templates", "components", "member-sidebar.html"),
-		filepath.Join("templates", "components", "member-header.html"),
-		filepath.Join("templates", name),
-	)
+	relations, err := h.service.GetRelations(book)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "member.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (h *BookHandler) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	claims := middleware.GetUserFromContext(r.Context())
 
-	tmpl, err = tmpl.ParseFiles(filepath.Join("templates", name))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	data := map[string]interface{}{
+		"Title":     "Detail Buku - SIMPUS",
+		"Book":      book,
+		"Relations": relations,
+		"Error":     r.URL.Query().Get("error"),
+		"User":      claims,
 	}
 
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	h.views.Render(w, r, "member/books/show.html", data)
 }
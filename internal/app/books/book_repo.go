@@ -2,18 +2,171 @@ package books
 
 import (
 	"database/sql"
+	"errors"
 	"simpus/internal/models"
+	"slices"
+	"strings"
 )
 
-type BookRepository struct {
+// ErrInsufficientStock is returned when a stock change would leave a branch
+// with a negative count or with more copies available than it holds.
+var ErrInsufficientStock = errors.New("stok buku di cabang ini tidak mencukupi")
+
+// BookRepository stores books and their stock counters. Copies are counted
+// per branch in branch_stock; books.stock and books.available hold the
+// totals over all branches and are kept in step by the stock methods.
+type BookRepository interface {
+	FindAll(filter models.BookFilter) ([]models.Book, int, error)
+	FindFacets(filter models.BookFilter) (*models.BookFacets, error)
+	FindByID(id int) (*models.Book, error)
+	FindContributors(bookIDs []int) (map[int][]models.Contributor, error)
+	Create(b *models.BookCreate) (int64, error)
+	Import(books []models.BookImport) error
+	FindISBNs() ([]string, error)
+	CountByCover(cover string) (int, error)
+	Update(id int, b *models.BookUpdate) error
+	Delete(id int) error
+	FindStock(bookID int) ([]models.BranchStock, error)
+	FindStockByBooks(bookIDs []int) (map[int][]models.BranchStock, error)
+	AdjustStock(bookID int, changes ...models.StockChange) error
+	// Count and CountAvailable cover all branches when branchID is 0.
+	Count(branchID int) (int, error)
+	CountAvailable(branchID int) (int, error)
+}
+
+type bookRepository struct {
 	db *sql.DB
 }
 
-func NewBookRepository(db *sql.DB) *BookRepository {
-	return &BookRepository{db: db}
+func NewBookRepository(db *sql.DB) BookRepository {
+	return &bookRepository{db: db}
+}
+
+// bookFrom joins the category and the work shown with each book.
+// Contributors are loaded separately, as a book has any number of them.
+const bookFrom = ` FROM books b
+				  LEFT JOIN categories c ON b.category_id = c.id
+				  LEFT JOIN works w ON b.work_id = w.id
+				  LEFT JOIN series s ON w.series_id = s.id`
+
+// Facets, as left out by bookWhere
+const (
+	facetNone      = ""
+	facetCategory  = "category"
+	facetAuthor    = "author"
+	facetSubject   = "subject"
+	facetClass     = "class"
+	facetSeries    = "series"
+	facetPublisher = "publisher"
+	facetLanguage  = "language"
+	facetYear      = "year"
+)
+
+// bookWhere builds the WHERE clause of filter. The facet named by skip is
+// left out, so its choices can be counted against the other selections.
+func bookWhere(filter models.BookFilter, skip string) (string, []interface{}) {
+	where := ` WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.IDs != nil {
+		where += ` AND b.id IN (?` + strings.Repeat(", ?", len(filter.IDs)-1) + `)`
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+	if skip != facetCategory {
+		if len(filter.CategoryIDs) > 0 {
+			where += ` AND b.category_id IN (?` + strings.Repeat(", ?", len(filter.CategoryIDs)-1) + `)`
+			for _, id := range filter.CategoryIDs {
+				args = append(args, id)
+			}
+		} else if filter.CategoryID > 0 {
+			where += ` AND b.category_id = ?`
+			args = append(args, filter.CategoryID)
+		}
+	}
+	if filter.AuthorID > 0 && skip != facetAuthor {
+		where += ` AND EXISTS (SELECT 1 FROM book_contributors bc WHERE bc.book_id = b.id AND bc.author_id = ?)`
+		args = append(args, filter.AuthorID)
+	}
+	if filter.Subject != "" && skip != facetSubject {
+		where += ` AND EXISTS (SELECT 1 FROM book_subjects sj WHERE sj.book_id = b.id AND sj.subject = ?)`
+		args = append(args, filter.Subject)
+	}
+	if filter.WorkID > 0 {
+		where += ` AND b.work_id = ?`
+		args = append(args, filter.WorkID)
+	}
+	if filter.SeriesID > 0 && skip != facetSeries {
+		where += ` AND w.series_id = ?`
+		args = append(args, filter.SeriesID)
+	}
+	if filter.Class != "" && skip != facetClass {
+		where += ` AND b.ddc LIKE ?`
+		args = append(args, filter.Class+"%")
+	}
+	if filter.Publisher != "" && skip != facetPublisher {
+		where += ` AND b.publisher = ?`
+		args = append(args, filter.Publisher)
+	}
+	if filter.Language != "" && skip != facetLanguage {
+		where += ` AND b.language = ?`
+		args = append(args, filter.Language)
+	}
+	if skip != facetYear {
+		if filter.YearFrom > 0 {
+			where += ` AND b.publish_year >= ?`
+			args = append(args, filter.YearFrom)
+		}
+		if filter.YearTo > 0 {
+			where += ` AND b.publish_year <= ?`
+			args = append(args, filter.YearTo)
+		}
+	}
+
+	if filter.BranchID > 0 {
+		column := "stock"
+		if filter.Available {
+			column = "available"
+		}
+		where += ` AND EXISTS (SELECT 1 FROM branch_stock bs WHERE bs.book_id = b.id AND bs.branch_id = ? AND bs.` + column + ` > 0)`
+		args = append(args, filter.BranchID)
+	} else if filter.Available {
+		where += ` AND b.available > 0`
+	}
+	return where, args
 }
 
-func (r *BookRepository) FindAll(filter models.BookFilter) ([]models.Book, int, error) {
+// bookOrder returns the ORDER BY expression for the filter's sort. Without
+// one, search results keep the order of their IDs and other listings show
+// the newest books first.
+func bookOrder(filter models.BookFilter) (string, []interface{}) {
+	switch filter.Sort {
+	case models.BookSortTitle:
+		return `b.title, b.id`, nil
+	case models.BookSortPopular:
+		return `(SELECT COUNT(*) FROM borrowings br WHERE br.book_id = b.id) DESC, b.title`, nil
+	case models.BookSortYear:
+		return `b.publish_year DESC, b.title`, nil
+	case models.BookSortNewest:
+		return `b.created_at DESC, b.id DESC`, nil
+	case models.BookSortShelf:
+		// Books without a class number go last
+		return `CASE WHEN b.ddc = '' THEN 1 ELSE 0 END, b.ddc, b.cutter, b.title, b.id`, nil
+	case models.BookSortVolume:
+		return `s.name, w.volume, b.publish_year DESC, b.id`, nil
+	}
+	if filter.IDs == nil {
+		return `b.created_at DESC, b.id DESC`, nil
+	}
+	args := make([]interface{}, 0, 2*len(filter.IDs))
+	for i, id := range filter.IDs {
+		args = append(args, id, i)
+	}
+	return `CASE b.id` + strings.Repeat(" WHEN ? THEN ?", len(filter.IDs)) + ` END`, args
+}
+
+func (r *bookRepository) FindAll(filter models.BookFilter) ([]models.Book, int, error) {
 	if filter.Page < 1 {
 		filter.Page = 1
 	}
@@ -22,44 +175,26 @@ func (r *BookRepository) FindAll(filter models.BookFilter) ([]models.Book, int,
 	}
 	offset := (filter.Page - 1) * filter.Limit
 
-	// Build query
-	baseQuery := `FROM books b 
-				  LEFT JOIN categories c ON b.category_id = c.id
-				  LEFT JOIN authors a ON b.author_id = a.id
-				  WHERE 1=1`
-	args := []interface{}{}
-
-	if filter.Search != "" {
-		baseQuery += ` AND (b.title LIKE ? OR b.isbn LIKE ? OR a.name LIKE ?)`
-		searchPattern := "%" + filter.Search + "%"
-		args = append(args, searchPattern, searchPattern, searchPattern)
-	}
-	if filter.CategoryID > 0 {
-		baseQuery += ` AND b.category_id = ?`
-		args = append(args, filter.CategoryID)
-	}
-	if filter.AuthorID > 0 {
-		baseQuery += ` AND b.author_id = ?`
-		args = append(args, filter.AuthorID)
-	}
-	if filter.Available {
-		baseQuery += ` AND b.available > 0`
+	// A search that found nothing
+	if filter.IDs != nil && len(filter.IDs) == 0 {
+		return nil, 0, nil
 	}
+	where, args := bookWhere(filter, facetNone)
 
 	// Count
 	var total int
-	countQuery := `SELECT COUNT(*) ` + baseQuery
-	err := r.db.QueryRow(countQuery, args...).Scan(&total)
+	err := r.db.QueryRow(`SELECT COUNT(*)`+bookFrom+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// Get data
-	query := `SELECT b.id, b.isbn, b.title, b.category_id, b.author_id, b.publisher, 
-			  b.publish_year, b.stock, b.available, b.cover_image, b.description, 
+	order, orderArgs := bookOrder(filter)
+	query := `SELECT b.id, b.isbn, b.title, b.category_id, b.work_id, b.edition, b.publisher, 
+			  b.publish_year, b.language, b.ddc, b.cutter, b.stock, b.available, b.cover_image, b.description, 
 			  b.created_at, b.updated_at,
-			  c.id, c.name, a.id, a.name ` + baseQuery + ` ORDER BY b.created_at DESC LIMIT ? OFFSET ?`
-	args = append(args, filter.Limit, offset)
+			  c.id, c.name, w.title, w.volume, s.id, s.name` + bookFrom + where + ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+	args = append(append(args, orderArgs...), filter.Limit, offset)
 
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
@@ -70,14 +205,14 @@ func (r *BookRepository) FindAll(filter models.BookFilter) ([]models.Book, int,
 	var books []models.Book
 	for rows.Next() {
 		var b models.Book
-		var categoryID, authorID, catID, authID sql.NullInt64
-		var isbn, publisher, cover, desc, catName, authName sql.NullString
+		var categoryID, catID, workID, volume, seriesID sql.NullInt64
+		var isbn, publisher, cover, desc, catName, workTitle, seriesName sql.NullString
 
 		err := rows.Scan(
-			&b.ID, &isbn, &b.Title, &categoryID, &authorID, &publisher,
-			&b.PublishYear, &b.Stock, &b.Available, &cover, &desc,
+			&b.ID, &isbn, &b.Title, &categoryID, &workID, &b.Edition, &publisher,
+			&b.PublishYear, &b.Language, &b.DDC, &b.Cutter, &b.Stock, &b.Available, &cover, &desc,
 			&b.CreatedAt, &b.UpdatedAt,
-			&catID, &catName, &authID, &authName,
+			&catID, &catName, &workTitle, &volume, &seriesID, &seriesName,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -93,29 +228,206 @@ func (r *BookRepository) FindAll(filter models.BookFilter) ([]models.Book, int,
 			b.CategoryID = &id
 			b.Category = &models.Category{ID: int(catID.Int64), Name: catName.String}
 		}
-		if authorID.Valid {
-			id := int(authorID.Int64)
-			b.AuthorID = &id
-			b.Author = &models.Author{ID: int(authID.Int64), Name: authName.String}
+		if workID.Valid {
+			id := int(workID.Int64)
+			b.WorkID = &id
+			b.Work = &models.Work{ID: id, Title: workTitle.String, Volume: int(volume.Int64)}
+			if seriesID.Valid {
+				sid := int(seriesID.Int64)
+				b.Work.SeriesID = &sid
+				b.Work.Series = &models.Series{ID: sid, Name: seriesName.String}
+			}
 		}
 
 		books = append(books, b)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]int, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+	}
+	contributors, err := r.FindContributors(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	subjects, err := r.findSubjects(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range books {
+		books[i].Contributors = contributors[books[i].ID]
+		books[i].Subjects = subjects[books[i].ID]
+	}
 	return books, total, nil
 }
 
-func (r *BookRepository) FindByID(id int) (*models.Book, error) {
+// FindContributors returns the contributors of each book in credit order,
+// keyed by book ID.
+func (r *bookRepository) FindContributors(bookIDs []int) (map[int][]models.Contributor, error) {
+	contributors := make(map[int][]models.Contributor, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return contributors, nil
+	}
+
+	args := make([]interface{}, len(bookIDs))
+	for i, id := range bookIDs {
+		args[i] = id
+	}
+	query := `SELECT bc.book_id, a.id, a.name, bc.role
+			  FROM book_contributors bc
+			  JOIN authors a ON a.id = bc.author_id
+			  WHERE bc.book_id IN (?` + strings.Repeat(", ?", len(bookIDs)-1) + `)
+			  ORDER BY bc.book_id, bc.position`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var c models.Contributor
+		if err := rows.Scan(&bookID, &c.AuthorID, &c.Name, &c.Role); err != nil {
+			return nil, err
+		}
+		contributors[bookID] = append(contributors[bookID], c)
+	}
+	return contributors, rows.Err()
+}
+
+// findSubjects returns the subjects of each book in the order entered,
+// keyed by book ID.
+func (r *bookRepository) findSubjects(bookIDs []int) (map[int][]string, error) {
+	subjects := make(map[int][]string, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return subjects, nil
+	}
+
+	args := make([]interface{}, len(bookIDs))
+	for i, id := range bookIDs {
+		args[i] = id
+	}
+	query := `SELECT book_id, subject FROM book_subjects
+			  WHERE book_id IN (?` + strings.Repeat(", ?", len(bookIDs)-1) + `)
+			  ORDER BY book_id, position`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var subject string
+		if err := rows.Scan(&bookID, &subject); err != nil {
+			return nil, err
+		}
+		subjects[bookID] = append(subjects[bookID], subject)
+	}
+	return subjects, rows.Err()
+}
+
+// facetQuery describes how to count the choices of one facet.
+type facetQuery struct {
+	skip  string
+	join  string // tables the value and label come from, besides bookFrom
+	value string // expression grouped on
+	label string
+	cond  string // rows without a value
+	order string
+	limit int
+}
+
+// FindFacets counts, for every choice of each facet, the books the filter
+// would show with that choice made instead.
+func (r *bookRepository) FindFacets(filter models.BookFilter) (*models.BookFacets, error) {
+	facets := &models.BookFacets{}
+	if filter.IDs != nil && len(filter.IDs) == 0 {
+		return facets, nil
+	}
+
+	decade := `b.publish_year - b.publish_year % 10`
+	// A book counts once for a person credited in several roles
+	authorJoin := ` JOIN book_contributors bc ON bc.book_id = b.id
+				   JOIN authors a ON a.id = bc.author_id`
+	subjectJoin := ` JOIN book_subjects sj ON sj.book_id = b.id`
+	section := `SUBSTR(b.ddc, 1, 3)`
+	queries := []struct {
+		dest *[]models.FacetValue
+		q    facetQuery
+	}{
+		// Every category, for the service to roll the counts up the tree
+		{&facets.Categories, facetQuery{facetCategory, ``, `c.id`, `c.name`, `c.id IS NOT NULL`, `COUNT(*) DESC, c.name`, 0}},
+		{&facets.Authors, facetQuery{facetAuthor, authorJoin, `a.id`, `a.name`, `a.id IS NOT NULL`, `COUNT(DISTINCT b.id) DESC, a.name`, 20}},
+		{&facets.Subjects, facetQuery{facetSubject, subjectJoin, `sj.subject`, `sj.subject`, `sj.subject <> ''`, `COUNT(DISTINCT b.id) DESC, sj.subject`, 20}},
+		// Every section (the first three digits), for the service to roll
+		// the counts up to divisions and main classes
+		{&facets.Classes, facetQuery{facetClass, ``, section, section, `b.ddc <> ''`, section, 0}},
+		{&facets.Series, facetQuery{facetSeries, ``, `s.id`, `s.name`, `s.id IS NOT NULL`, `COUNT(*) DESC, s.name`, 20}},
+		{&facets.Publishers, facetQuery{facetPublisher, ``, `b.publisher`, `b.publisher`, `b.publisher <> ''`, `COUNT(*) DESC, b.publisher`, 20}},
+		{&facets.Languages, facetQuery{facetLanguage, ``, `b.language`, `b.language`, `b.language <> ''`, `COUNT(*) DESC, b.language`, 20}},
+		{&facets.Decades, facetQuery{facetYear, ``, decade, decade, `b.publish_year > 0`, decade + ` DESC`, 0}},
+	}
+	for _, fq := range queries {
+		values, err := r.facet(filter, fq.q)
+		if err != nil {
+			return nil, err
+		}
+		*fq.dest = values
+	}
+
+	available := filter
+	available.Available = true
+	where, args := bookWhere(available, facetNone)
+	if err := r.db.QueryRow(`SELECT COUNT(*)`+bookFrom+where, args...).Scan(&facets.Available); err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+func (r *bookRepository) facet(filter models.BookFilter, fq facetQuery) ([]models.FacetValue, error) {
+	where, args := bookWhere(filter, fq.skip)
+	query := `SELECT ` + fq.value + `, ` + fq.label + `, COUNT(DISTINCT b.id)` + bookFrom + fq.join + where +
+		` AND ` + fq.cond + ` GROUP BY ` + fq.value + `, ` + fq.label + ` ORDER BY ` + fq.order
+	if fq.limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, fq.limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []models.FacetValue
+	for rows.Next() {
+		var v models.FacetValue
+		if err := rows.Scan(&v.Value, &v.Label, &v.Count); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func (r *bookRepository) FindByID(id int) (*models.Book, error) {
 	b := &models.Book{}
-	var categoryID, authorID sql.NullInt64
+	var categoryID, workID sql.NullInt64
 	var isbn, publisher, cover, desc sql.NullString
 
-	query := `SELECT id, isbn, title, category_id, author_id, publisher, 
-			  publish_year, stock, available, cover_image, description, 
+	query := `SELECT id, isbn, title, category_id, work_id, edition, publisher, 
+			  publish_year, language, ddc, cutter, stock, available, cover_image, description, 
 			  created_at, updated_at FROM books WHERE id = ?`
 
 	err := r.db.QueryRow(query, id).Scan(
-		&b.ID, &isbn, &b.Title, &categoryID, &authorID, &publisher,
-		&b.PublishYear, &b.Stock, &b.Available, &cover, &desc,
+		&b.ID, &isbn, &b.Title, &categoryID, &workID, &b.Edition, &publisher,
+		&b.PublishYear, &b.Language, &b.DDC, &b.Cutter, &b.Stock, &b.Available, &cover, &desc,
 		&b.CreatedAt, &b.UpdatedAt,
 	)
 	if err != nil {
@@ -130,61 +442,344 @@ func (r *BookRepository) FindByID(id int) (*models.Book, error) {
 		id := int(categoryID.Int64)
 		b.CategoryID = &id
 	}
-	if authorID.Valid {
-		id := int(authorID.Int64)
-		b.AuthorID = &id
+	if workID.Valid {
+		id := int(workID.Int64)
+		b.WorkID = &id
 	}
 
+	contributors, err := r.FindContributors([]int{b.ID})
+	if err != nil {
+		return nil, err
+	}
+	b.Contributors = contributors[b.ID]
+	subjects, err := r.findSubjects([]int{b.ID})
+	if err != nil {
+		return nil, err
+	}
+	b.Subjects = subjects[b.ID]
 	return b, nil
 }
 
-func (r *BookRepository) Create(b *models.BookCreate) (int64, error) {
-	query := `INSERT INTO books (isbn, title, category_id, author_id, publisher, 
-			  publish_year, stock, available, cover_image, description) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+func (r *bookRepository) Create(b *models.BookCreate) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertBook(tx, b)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Import creates all books in one transaction, together with the categories
+// and authors they name without an ID. Names differing only in case are
+// created once. Nothing is saved if any book fails.
+func (r *bookRepository) Import(books []models.BookImport) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	var catID, authID interface{}
+	categories := map[string]int{}
+	authors := map[string]int{}
+	for _, b := range books {
+		data := b.BookCreate
+		if data.CategoryID == 0 {
+			if data.CategoryID, err = createName(tx, "categories", b.Category, categories); err != nil {
+				return err
+			}
+		}
+		data.Contributors = slices.Clone(data.Contributors)
+		for i, c := range data.Contributors {
+			if c.AuthorID == 0 && i < len(b.Authors) {
+				if data.Contributors[i].AuthorID, err = createName(tx, "authors", b.Authors[i], authors); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := insertBook(tx, &data); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// createName inserts a category or author unless created already, as
+// recorded in created by lower-case name. An empty name is none.
+func createName(tx *sql.Tx, table, name string, created map[string]int) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+	key := strings.ToLower(name)
+	if id, ok := created[key]; ok {
+		return id, nil
+	}
+	result, err := tx.Exec(`INSERT INTO `+table+` (name) VALUES (?)`, name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	created[key] = int(id)
+	return int(id), nil
+}
+
+// insertBook adds a book with its contributors and, when it comes with
+// stock, the copies held by its branch.
+func insertBook(tx *sql.Tx, b *models.BookCreate) (int64, error) {
+	query := `INSERT INTO books (isbn, title, category_id, work_id, edition, publisher, 
+			  publish_year, language, ddc, cutter, stock, available, cover_image, description) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var isbn, catID, workID interface{}
+	if b.ISBN != "" {
+		isbn = b.ISBN
+	}
 	if b.CategoryID > 0 {
 		catID = b.CategoryID
 	}
-	if b.AuthorID > 0 {
-		authID = b.AuthorID
+	if b.WorkID > 0 {
+		workID = b.WorkID
 	}
 
-	result, err := r.db.Exec(query, b.ISBN, b.Title, catID, authID, b.Publisher,
-		b.PublishYear, b.Stock, b.Stock, b.CoverImage, b.Description)
+	result, err := tx.Exec(query, isbn, b.Title, catID, workID, b.Edition, b.Publisher,
+		b.PublishYear, b.Language, b.DDC, b.Cutter, b.Stock, b.Stock, b.CoverImage, b.Description)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	if err := saveContributors(tx, int(id), b.Contributors); err != nil {
+		return 0, err
+	}
+	if err := saveSubjects(tx, int(id), b.Subjects); err != nil {
+		return 0, err
+	}
+
+	if b.BranchID > 0 {
+		_, err = tx.Exec(`INSERT INTO branch_stock (book_id, branch_id, stock, available) VALUES (?, ?, ?, ?)`,
+			id, b.BranchID, b.Stock, b.Stock)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
 }
 
-func (r *BookRepository) Update(id int, b *models.BookUpdate) error {
-	query := `UPDATE books SET isbn = ?, title = ?, category_id = ?, author_id = ?, 
-			  publisher = ?, publish_year = ?, stock = ?, cover_image = ?, description = ? 
+// FindISBNs returns the ISBN of every book that has one, as stored.
+func (r *bookRepository) FindISBNs() ([]string, error) {
+	rows, err := r.db.Query(`SELECT isbn FROM books WHERE isbn IS NOT NULL AND isbn <> ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []string
+	for rows.Next() {
+		var isbn string
+		if err := rows.Scan(&isbn); err != nil {
+			return nil, err
+		}
+		list = append(list, isbn)
+	}
+	return list, rows.Err()
+}
+
+// CountByCover counts the books showing a cover image.
+func (r *bookRepository) CountByCover(cover string) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM books WHERE cover_image = ?`, cover).Scan(&n)
+	return n, err
+}
+
+// saveContributors replaces the contributors of a book, numbering their
+// positions in the order given. Contributors without an author are
+// skipped.
+func saveContributors(tx *sql.Tx, bookID int, contributors []models.Contributor) error {
+	if _, err := tx.Exec(`DELETE FROM book_contributors WHERE book_id = ?`, bookID); err != nil {
+		return err
+	}
+	position := 0
+	for _, c := range contributors {
+		if c.AuthorID == 0 {
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO book_contributors (book_id, author_id, role, position) VALUES (?, ?, ?, ?)`,
+			bookID, c.AuthorID, c.Role, position)
+		if err != nil {
+			return err
+		}
+		position++
+	}
+	return nil
+}
+
+// saveSubjects replaces the subjects of a book, in the order given.
+func saveSubjects(tx *sql.Tx, bookID int, subjects []string) error {
+	if _, err := tx.Exec(`DELETE FROM book_subjects WHERE book_id = ?`, bookID); err != nil {
+		return err
+	}
+	for i, subject := range subjects {
+		_, err := tx.Exec(`INSERT INTO book_subjects (book_id, subject, position) VALUES (?, ?, ?)`, bookID, subject, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *bookRepository) Update(id int, b *models.BookUpdate) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE books SET isbn = ?, title = ?, category_id = ?, work_id = ?, edition = ?, 
+			  publisher = ?, publish_year = ?, language = ?, ddc = ?, cutter = ?, cover_image = ?, description = ? 
 			  WHERE id = ?`
 
-	var catID, authID interface{}
+	var isbn, catID, workID interface{}
+	if b.ISBN != "" {
+		isbn = b.ISBN
+	}
 	if b.CategoryID > 0 {
 		catID = b.CategoryID
 	}
-	if b.AuthorID > 0 {
-		authID = b.AuthorID
+	if b.WorkID > 0 {
+		workID = b.WorkID
 	}
 
-	_, err := r.db.Exec(query, b.ISBN, b.Title, catID, authID, b.Publisher,
-		b.PublishYear, b.Stock, b.CoverImage, b.Description, id)
-	return err
+	_, err = tx.Exec(query, isbn, b.Title, catID, workID, b.Edition, b.Publisher,
+		b.PublishYear, b.Language, b.DDC, b.Cutter, b.CoverImage, b.Description, id)
+	if err != nil {
+		return err
+	}
+	if err := saveContributors(tx, id, b.Contributors); err != nil {
+		return err
+	}
+	if err := saveSubjects(tx, id, b.Subjects); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *BookRepository) Delete(id int) error {
+func (r *bookRepository) Delete(id int) error {
 	_, err := r.db.Exec(`DELETE FROM books WHERE id = ?`, id)
 	return err
 }
 
-func (r *BookRepository) UpdateAvailable(id int, delta int) error {
-	query := `UPDATE books SET available = available + ? WHERE id = ? AND available + ? >= 0`
-	result, err := r.db.Exec(query, delta, id, delta)
+// FindStock lists the copies of a book at every active branch, including
+// branches that hold none, plus inactive branches that still hold copies.
+func (r *bookRepository) FindStock(bookID int) ([]models.BranchStock, error) {
+	query := `SELECT br.id, br.code, br.name, COALESCE(bs.stock, 0), COALESCE(bs.available, 0)
+			  FROM branches br
+			  LEFT JOIN branch_stock bs ON bs.branch_id = br.id AND bs.book_id = ?
+			  WHERE br.is_active = TRUE OR bs.stock > 0
+			  ORDER BY br.id`
+
+	rows, err := r.db.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stock []models.BranchStock
+	for rows.Next() {
+		var s models.BranchStock
+		if err := rows.Scan(&s.BranchID, &s.BranchCode, &s.BranchName, &s.Stock, &s.Available); err != nil {
+			return nil, err
+		}
+		stock = append(stock, s)
+	}
+	return stock, rows.Err()
+}
+
+// FindStockByBooks returns the branches holding copies of each book, keyed
+// by book ID. Branches without copies are left out.
+func (r *bookRepository) FindStockByBooks(bookIDs []int) (map[int][]models.BranchStock, error) {
+	stock := make(map[int][]models.BranchStock, len(bookIDs))
+	if len(bookIDs) == 0 {
+		return stock, nil
+	}
+
+	args := make([]interface{}, len(bookIDs))
+	for i, id := range bookIDs {
+		args[i] = id
+	}
+	query := `SELECT bs.book_id, br.id, br.code, br.name, bs.stock, bs.available
+			  FROM branch_stock bs
+			  JOIN branches br ON br.id = bs.branch_id
+			  WHERE bs.stock > 0 AND bs.book_id IN (?` + strings.Repeat(", ?", len(bookIDs)-1) + `)
+			  ORDER BY br.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bookID int
+		var s models.BranchStock
+		if err := rows.Scan(&bookID, &s.BranchID, &s.BranchCode, &s.BranchName, &s.Stock, &s.Available); err != nil {
+			return nil, err
+		}
+		stock[bookID] = append(stock[bookID], s)
+	}
+	return stock, rows.Err()
+}
+
+// AdjustStock applies all changes and updates the book totals in one
+// transaction. Nothing is changed when any branch would end up with fewer
+// than zero copies or more copies available than it holds.
+func (r *bookRepository) AdjustStock(bookID int, changes ...models.StockChange) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ApplyStock(tx, bookID, changes...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApplyStock makes the changes of AdjustStock inside tx, so a loan, hold or
+// transfer can change status and move its copy in the same transaction.
+// Without changes nothing is written.
+func ApplyStock(tx *sql.Tx, bookID int, changes ...models.StockChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	for _, c := range changes {
+		if err := adjustBranchStock(tx, bookID, c); err != nil {
+			return err
+		}
+	}
+	return syncBookTotals(tx, bookID)
+}
+
+// adjustBranchStock moves the counters of one branch with a conditional
+// update, so concurrent loans cannot take the same copy twice.
+func adjustBranchStock(tx *sql.Tx, bookID int, c models.StockChange) error {
+	if c.Stock == 0 && c.Available == 0 {
+		return nil
+	}
+
+	query := `UPDATE branch_stock SET stock = stock + ?, available = available + ?
+			  WHERE book_id = ? AND branch_id = ?
+			  AND available + ? >= 0 AND available + ? <= stock + ?`
+	result, err := tx.Exec(query, c.Stock, c.Available, bookID, c.BranchID, c.Available, c.Available, c.Stock)
 	if err != nil {
 		return err
 	}
@@ -192,20 +787,54 @@ func (r *BookRepository) UpdateAvailable(id int, delta int) error {
 	if err != nil {
 		return err
 	}
-	if rows == 0 {
-		return sql.ErrNoRows
+	if rows > 0 {
+		return nil
 	}
-	return nil
+
+	// Either the change was refused or the branch holds no copies yet
+	var exists int
+	err = tx.QueryRow(`SELECT 1 FROM branch_stock WHERE book_id = ? AND branch_id = ?`, bookID, c.BranchID).Scan(&exists)
+	if err == nil {
+		return ErrInsufficientStock
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if c.Available < 0 || c.Available > c.Stock {
+		return ErrInsufficientStock
+	}
+	_, err = tx.Exec(`INSERT INTO branch_stock (book_id, branch_id, stock, available) VALUES (?, ?, ?, ?)`,
+		bookID, c.BranchID, c.Stock, c.Available)
+	return err
 }
 
-func (r *BookRepository) Count() (int, error) {
+// syncBookTotals recomputes books.stock and books.available from the
+// branch counters.
+func syncBookTotals(tx *sql.Tx, bookID int) error {
+	query := `UPDATE books SET
+			  stock = (SELECT COALESCE(SUM(stock), 0) FROM branch_stock WHERE book_id = ?),
+			  available = (SELECT COALESCE(SUM(available), 0) FROM branch_stock WHERE book_id = ?)
+			  WHERE id = ?`
+	_, err := tx.Exec(query, bookID, bookID, bookID)
+	return err
+}
+
+func (r *bookRepository) Count(branchID int) (int, error) {
 	var count int
+	if branchID > 0 {
+		err := r.db.QueryRow(`SELECT COUNT(*) FROM branch_stock WHERE branch_id = ? AND stock > 0`, branchID).Scan(&count)
+		return count, err
+	}
 	err := r.db.QueryRow(`SELECT COUNT(*) FROM books`).Scan(&count)
 	return count, err
 }
 
-func (r *BookRepository) CountAvailable() (int, error) {
+func (r *bookRepository) CountAvailable(branchID int) (int, error) {
 	var count int
-	err := r.db.QueryRow(`SELECT SUM(available) FROM books`).Scan(&count)
+	if branchID > 0 {
+		err := r.db.QueryRow(`SELECT COALESCE(SUM(available), 0) FROM branch_stock WHERE branch_id = ?`, branchID).Scan(&count)
+		return count, err
+	}
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(available), 0) FROM books`).Scan(&count)
 	return count, err
 }
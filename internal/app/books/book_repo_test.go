@@ -0,0 +1,266 @@
+package books
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+)
+
+func TestBookRepositoryFindAllByIDs(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	books, total, err := repo.FindAll(models.BookFilter{IDs: []int{3, 2, 5}, CategoryID: 1, Page: 1, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(books) != 2 || books[0].ID != 3 || books[1].ID != 2 {
+		t.Fatalf("books by IDs = %+v, total %d, want 3 and 2 of 3", books, total)
+	}
+	for _, b := range books {
+		if len(b.Authors()) != 1 || b.Category == nil {
+			t.Errorf("book %q missing author or category", b.Title)
+		}
+	}
+
+	if books, total, err := repo.FindAll(models.BookFilter{IDs: []int{}}); err != nil || total != 0 || len(books) != 0 {
+		t.Errorf("empty IDs = %d books, total %d, err %v, want none", len(books), total, err)
+	}
+}
+
+func TestBookRepositoryFindAllByBranch(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	books, total, err := repo.FindAll(models.BookFilter{BranchID: 2, Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || books[0].Title != "Laskar Pelangi" {
+		t.Fatalf("books at branch 2 = %+v", books)
+	}
+
+	if err := repo.AdjustStock(1, models.StockChange{BranchID: 2, Available: -2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := repo.FindAll(models.BookFilter{BranchID: 2, Available: true}); total != 0 {
+		t.Errorf("available at branch 2 = %d books, want 0", total)
+	}
+
+	stock, err := repo.FindStockByBooks([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stock[1]) != 2 || len(stock[2]) != 1 {
+		t.Errorf("stock by books = %+v", stock)
+	}
+}
+
+func TestBookRepositoryAdjustStockStopsAtZero(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	book, err := repo.FindByID(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AdjustStock(book.ID, models.StockChange{BranchID: 1, Available: -book.Available}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AdjustStock(book.ID, models.StockChange{BranchID: 1, Available: -1}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("AdjustStock below zero: err = %v, want ErrInsufficientStock", err)
+	}
+	if err := repo.AdjustStock(book.ID, models.StockChange{BranchID: 2, Available: -1}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("AdjustStock at branch without copies: err = %v, want ErrInsufficientStock", err)
+	}
+}
+
+func TestBookRepositoryAdjustStockMovesCopies(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	// Clean Code is only held at branch 1; lend a copy and return it at 2
+	if err := repo.AdjustStock(4, models.StockChange{BranchID: 1, Available: -1}); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.AdjustStock(4,
+		models.StockChange{BranchID: 1, Stock: -1},
+		models.StockChange{BranchID: 2, Stock: 1, Available: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stock, err := repo.FindStock(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int][2]int{1: {1, 1}, 2: {1, 1}}
+	for _, s := range stock {
+		if got := [2]int{s.Stock, s.Available}; got != want[s.BranchID] {
+			t.Errorf("branch %d stock/available = %v, want %v", s.BranchID, got, want[s.BranchID])
+		}
+	}
+
+	book, _ := repo.FindByID(4)
+	if book.Stock != 2 || book.Available != 2 {
+		t.Errorf("totals = %d/%d, want 2/2", book.Stock, book.Available)
+	}
+
+	// A failing change leaves the other branches untouched
+	err = repo.AdjustStock(4,
+		models.StockChange{BranchID: 2, Available: -1},
+		models.StockChange{BranchID: 1, Available: -5},
+	)
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("err = %v, want ErrInsufficientStock", err)
+	}
+	if book, _ := repo.FindByID(4); book.Available != 2 {
+		t.Errorf("available = %d after failed change, want 2", book.Available)
+	}
+}
+
+func TestBookRepositoryFindFacets(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	facets, err := repo.FindFacets(models.BookFilter{CategoryID: 1, Language: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := func(values []models.FacetValue) map[string]int {
+		m := map[string]int{}
+		for _, v := range values {
+			m[v.Label] = v.Count
+		}
+		return m
+	}
+	// A facet is counted without its own selection
+	if got := counts(facets.Categories); got["Fiksi"] != 4 || got["Teknologi"] != 0 {
+		t.Errorf("categories = %v", got)
+	}
+	if got := counts(facets.Languages); got["id"] != 4 || got["en"] != 0 {
+		t.Errorf("languages = %v", got)
+	}
+	if got := counts(facets.Authors); got["Tere Liye"] != 2 || got["Robert C. Martin"] != 0 {
+		t.Errorf("authors = %v", got)
+	}
+	if got := counts(facets.Publishers); got["Gramedia"] != 2 || len(got) != 3 {
+		t.Errorf("publishers = %v", got)
+	}
+
+	var decades []string
+	for _, d := range facets.Decades {
+		decades = append(decades, d.Value)
+	}
+	if strings.Join(decades, ",") != "2010,2000,1980" {
+		t.Errorf("decades = %v", decades)
+	}
+	if facets.Available != 4 {
+		t.Errorf("available = %d, want 4", facets.Available)
+	}
+}
+
+func TestBookRepositoryFindAllSorted(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	// Bumi has been borrowed twice, Clean Code once
+	_, err := db.Exec(`INSERT INTO borrowings (member_id, book_id, borrow_date, due_date) VALUES
+		(1, 2, CURRENT_DATE, CURRENT_DATE), (2, 2, CURRENT_DATE, CURRENT_DATE), (1, 4, CURRENT_DATE, CURRENT_DATE)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter models.BookFilter
+		want   string
+	}{
+		{models.BookFilter{Sort: models.BookSortTitle}, "Bulan,Bumi,Bumi Manusia,Clean Code,Laskar Pelangi"},
+		{models.BookFilter{Sort: models.BookSortPopular, Limit: 2}, "Bumi,Clean Code"},
+		{models.BookFilter{Sort: models.BookSortYear, YearFrom: 2000, YearTo: 2009}, "Clean Code,Laskar Pelangi"},
+		{models.BookFilter{IDs: []int{5, 1}, Sort: models.BookSortTitle}, "Bulan,Laskar Pelangi"},
+		{models.BookFilter{Publisher: "Gramedia", Language: "id", AuthorID: 2, Sort: models.BookSortYear}, "Bulan,Bumi"},
+	}
+	for _, tt := range tests {
+		books, _, err := repo.FindAll(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, b := range books {
+			got = append(got, b.Title)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("FindAll(%+v) = %v, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestBookRepositoryContributors(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewBookRepository(db)
+
+	// Tere Liye both writes and illustrates, Andrea Hirata translates
+	id, err := repo.Create(&models.BookCreate{Title: "Hujan", Language: "id", Contributors: []models.Contributor{
+		{AuthorID: 3, Role: models.RoleAuthor},
+		{AuthorID: 2, Role: models.RoleAuthor},
+		{AuthorID: 1, Role: models.RoleTranslator},
+		{AuthorID: 2, Role: models.RoleIllustrator},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := repo.FindByID(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.AuthorNames() != "Pramoedya Ananta Toer, Tere Liye" || len(b.Others()) != 2 || b.Others()[0].Name != "Andrea Hirata" {
+		t.Errorf("contributors = %+v", b.Contributors)
+	}
+
+	// Any role finds the book, which counts once per person
+	if _, total, _ := repo.FindAll(models.BookFilter{AuthorID: 1}); total != 2 {
+		t.Errorf("books of Andrea Hirata = %d, want 2", total)
+	}
+	facets, err := repo.FindFacets(models.BookFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range facets.Authors {
+		if v.Label == "Tere Liye" && v.Count != 3 {
+			t.Errorf("Tere Liye facet = %d, want 3", v.Count)
+		}
+	}
+	authors, err := NewAuthorRepository(db).FindAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range authors {
+		if a.Name == "Tere Liye" && a.BookCount != 3 {
+			t.Errorf("Tere Liye has %d books, want 3", a.BookCount)
+		}
+	}
+
+	// Updating replaces the list
+	err = repo.Update(int(id), &models.BookUpdate{Title: "Hujan", Language: "id", Contributors: []models.Contributor{
+		{AuthorID: 2, Role: models.RoleAuthor},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := repo.FindByID(int(id)); len(b.Contributors) != 1 || b.AuthorNames() != "Tere Liye" {
+		t.Errorf("contributors after update = %+v", b.Contributors)
+	}
+}
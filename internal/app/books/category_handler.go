@@ -1,24 +1,24 @@
 package books
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
+	"net/url"
 	"strconv"
 
 	"simpus/internal/middleware"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
 )
 
 type CategoryHandler struct {
-	service   *Service
-	templates *template.Template
+	service *Service
+	views   *renderer.Renderer
 }
 
-func NewCategoryHandler(service *Service, templates *template.Template) *CategoryHandler {
+func NewCategoryHandler(service *Service, views *renderer.Renderer) *CategoryHandler {
 	return &CategoryHandler{
-		service:   service,
-		templates: templates,
+		service: service,
+		views:   views,
 	}
 }
 
@@ -35,14 +35,16 @@ func (h *CategoryHandler) Index(w http.ResponseWriter, r *http.Request) {
 		"Title":      "Manajemen Kategori - SIMPUS",
 		"Categories": categories,
 		"User":       claims,
+		"Success":    r.URL.Query().Get("success"),
+		"Error":      r.URL.Query().Get("error"),
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/categories/table.html", data)
+		h.views.Partial(w, r, "admin/categories/index.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/categories/index.html", data)
+	h.views.Render(w, r, "admin/categories/index.html", data)
 }
 
 func (h *CategoryHandler) Store(w http.ResponseWriter, r *http.Request) {
@@ -51,24 +53,12 @@ func (h *CategoryHandler) Store(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := &models.CategoryCreate{
-		Name:        r.FormValue("name"),
-		Description: r.FormValue("description"),
-	}
-
-	_, err := h.service.CreateCategory(data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	data := categoryForm(r)
+	if _, err := h.service.CreateCategory(data); err != nil {
+		categoryRedirect(w, r, "error", err.Error())
 		return
 	}
-
-	if r.Header.Get("HX-Request") == "true" {
-		w.Header().Set("HX-Trigger", "refreshTable")
-		w.WriteHeader(http.StatusCreated)
-		return
-	}
-
-	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+	categoryRedirect(w, r, "success", "Kategori "+data.Name+" berhasil ditambahkan")
 }
 
 func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -79,23 +69,24 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := &models.CategoryCreate{
-		Name:        r.FormValue("name"),
-		Description: r.FormValue("description"),
-	}
-
-	err := h.service.UpdateCategory(id, data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	data := categoryForm(r)
+	if err := h.service.UpdateCategory(id, data); err != nil {
+		categoryRedirect(w, r, "error", err.Error())
 		return
 	}
+	categoryRedirect(w, r, "success", "Kategori "+data.Name+" berhasil diperbarui")
+}
 
-	if r.Header.Get("HX-Request") == "true" {
-		w.Header().Set("HX-Trigger", "refreshTable")
+// Merge folds a duplicate category into the one picked in the form.
+func (h *CategoryHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	into, _ := strconv.Atoi(r.FormValue("into"))
+
+	if err := h.service.MergeCategory(id, into); err != nil {
+		categoryRedirect(w, r, "error", err.Error())
 		return
 	}
-
-	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+	categoryRedirect(w, r, "success", "Kategori berhasil digabung")
 }
 
 func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -115,43 +106,20 @@ func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
 }
 
-func (h *CategoryHandler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func categoryForm(r *http.Request) *models.CategoryCreate {
+	parent, _ := strconv.Atoi(r.FormValue("parent_id"))
+	return &models.CategoryCreate{
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+		ParentID:    parent,
 	}
 }
 
-func (h *CategoryHandler) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(filepath.Join("templates", name))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func categoryRedirect(w http.ResponseWriter, r *http.Request, key, message string) {
+	target := "/admin/categories?" + key + "=" + url.QueryEscape(message)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
 		return
 	}
-
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
 }
@@ -5,19 +5,32 @@ import (
 	"simpus/internal/models"
 )
 
-type CategoryRepository struct {
+// CategoryRepository stores book categories. Categories form a tree through
+// their parent_id, which the service keeps free of cycles.
+type CategoryRepository interface {
+	FindAll() ([]models.Category, error)
+	FindByID(id int) (*models.Category, error)
+	Create(c *models.CategoryCreate) (int64, error)
+	Update(id int, c *models.CategoryCreate) error
+	Delete(id int) error
+	Merge(from, into int) error
+}
+
+type categoryRepository struct {
 	db *sql.DB
 }
 
-func NewCategoryRepository(db *sql.DB) *CategoryRepository {
-	return &CategoryRepository{db: db}
+func NewCategoryRepository(db *sql.DB) CategoryRepository {
+	return &categoryRepository{db: db}
 }
 
-func (r *CategoryRepository) FindAll() ([]models.Category, error) {
-	query := `SELECT c.id, c.name, c.description, c.created_at, COUNT(b.id) as book_count
+// FindAll returns every category by name with the books filed directly
+// under it; the service rolls the counts up the tree.
+func (r *categoryRepository) FindAll() ([]models.Category, error) {
+	query := `SELECT c.id, c.name, c.description, c.parent_id, c.created_at, COUNT(b.id) as book_count
 			  FROM categories c
 			  LEFT JOIN books b ON c.id = b.category_id
-			  GROUP BY c.id
+			  GROUP BY c.id, c.name, c.description, c.parent_id, c.created_at
 			  ORDER BY c.name`
 
 	rows, err := r.db.Query(query)
@@ -30,45 +43,106 @@ func (r *CategoryRepository) FindAll() ([]models.Category, error) {
 	for rows.Next() {
 		var c models.Category
 		var desc sql.NullString
-		err := rows.Scan(&c.ID, &c.Name, &desc, &c.CreatedAt, &c.BookCount)
+		var parentID sql.NullInt64
+		err := rows.Scan(&c.ID, &c.Name, &desc, &parentID, &c.CreatedAt, &c.DirectBookCount)
 		if err != nil {
 			return nil, err
 		}
 		c.Description = desc.String
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			c.ParentID = &id
+		}
+		c.BookCount = c.DirectBookCount
 		categories = append(categories, c)
 	}
-	return categories, nil
+	return categories, rows.Err()
 }
 
-func (r *CategoryRepository) FindByID(id int) (*models.Category, error) {
+func (r *categoryRepository) FindByID(id int) (*models.Category, error) {
 	c := &models.Category{}
 	var desc sql.NullString
-	query := `SELECT id, name, description, created_at FROM categories WHERE id = ?`
+	var parentID sql.NullInt64
+	query := `SELECT id, name, description, parent_id, created_at FROM categories WHERE id = ?`
 
-	err := r.db.QueryRow(query, id).Scan(&c.ID, &c.Name, &desc, &c.CreatedAt)
+	err := r.db.QueryRow(query, id).Scan(&c.ID, &c.Name, &desc, &parentID, &c.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	c.Description = desc.String
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
 	return c, nil
 }
 
-func (r *CategoryRepository) Create(c *models.CategoryCreate) (int64, error) {
-	query := `INSERT INTO categories (name, description) VALUES (?, ?)`
-	result, err := r.db.Exec(query, c.Name, c.Description)
+func (r *categoryRepository) Create(c *models.CategoryCreate) (int64, error) {
+	query := `INSERT INTO categories (name, description, parent_id) VALUES (?, ?, ?)`
+	result, err := r.db.Exec(query, c.Name, c.Description, nullID(c.ParentID))
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
-func (r *CategoryRepository) Update(id int, c *models.CategoryCreate) error {
-	query := `UPDATE categories SET name = ?, description = ? WHERE id = ?`
-	_, err := r.db.Exec(query, c.Name, c.Description, id)
+func (r *categoryRepository) Update(id int, c *models.CategoryCreate) error {
+	query := `UPDATE categories SET name = ?, description = ?, parent_id = ? WHERE id = ?`
+	_, err := r.db.Exec(query, c.Name, c.Description, nullID(c.ParentID), id)
 	return err
 }
 
-func (r *CategoryRepository) Delete(id int) error {
-	_, err := r.db.Exec(`DELETE FROM categories WHERE id = ?`, id)
-	return err
+// Delete removes a category. Its subcategories move up to its parent and
+// its books are left without a category.
+func (r *categoryRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// MySQL reads the table being updated only through a derived table
+	_, err = tx.Exec(`UPDATE categories SET parent_id = (SELECT parent_id FROM (SELECT parent_id FROM categories WHERE id = ?) p)
+			  WHERE parent_id = ?`, id, id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE books SET category_id = NULL WHERE category_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Merge files the books and subcategories of one category under another
+// and deletes it, in one transaction.
+func (r *categoryRepository) Merge(from, into int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`UPDATE books SET category_id = ? WHERE category_id = ?`,
+		`UPDATE categories SET parent_id = ? WHERE parent_id = ?`,
+	} {
+		if _, err := tx.Exec(query, into, from); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, from); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// nullID stores an ID of 0 as NULL.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
 }
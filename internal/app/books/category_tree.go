@@ -0,0 +1,97 @@
+package books
+
+import "simpus/internal/models"
+
+// categoryTree indexes categories by ID and by parent. A parent that does
+// not exist makes its children top-level categories.
+type categoryTree struct {
+	byID     map[int]models.Category
+	children map[int][]int // IDs by parent ID, 0 for the top level, in the order listed
+}
+
+func newCategoryTree(list []models.Category) *categoryTree {
+	t := &categoryTree{
+		byID:     make(map[int]models.Category, len(list)),
+		children: make(map[int][]int),
+	}
+	for _, c := range list {
+		t.byID[c.ID] = c
+	}
+	for _, c := range list {
+		t.children[t.parent(c.ID)] = append(t.children[t.parent(c.ID)], c.ID)
+	}
+	return t
+}
+
+// parent returns the ID of the parent of a category, 0 for a top-level one.
+func (t *categoryTree) parent(id int) int {
+	c := t.byID[id]
+	if c.ParentID == nil {
+		return 0
+	}
+	if _, ok := t.byID[*c.ParentID]; !ok {
+		return 0
+	}
+	return *c.ParentID
+}
+
+// subtree returns the ID of a category followed by those of all its
+// descendants.
+func (t *categoryTree) subtree(id int) []int {
+	ids := []int{id}
+	seen := map[int]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, c := range t.children[ids[i]] {
+			if !seen[c] {
+				seen[c] = true
+				ids = append(ids, c)
+			}
+		}
+	}
+	return ids
+}
+
+// ancestors returns the categories above one, from the top level down.
+func (t *categoryTree) ancestors(id int) []models.Category {
+	var path []models.Category
+	seen := map[int]bool{id: true}
+	for p := t.parent(id); p != 0 && !seen[p]; p = t.parent(p) {
+		seen[p] = true
+		path = append([]models.Category{t.byID[p]}, path...)
+	}
+	return path
+}
+
+// bookCount returns the books of a category and its descendants, given the
+// books filed directly under each category.
+func (t *categoryTree) bookCount(id int, direct map[int]int) int {
+	n := 0
+	for _, c := range t.subtree(id) {
+		n += direct[c]
+	}
+	return n
+}
+
+// list returns the whole tree depth first, each category followed by its
+// subcategories, with Depth, Path and rolled-up BookCount set.
+func (t *categoryTree) list() []models.Category {
+	direct := make(map[int]int, len(t.byID))
+	for id, c := range t.byID {
+		direct[id] = c.DirectBookCount
+	}
+
+	list := make([]models.Category, 0, len(t.byID))
+	var walk func(parent int, path []models.Category)
+	walk = func(parent int, path []models.Category) {
+		for _, id := range t.children[parent] {
+			c := t.byID[id]
+			c.Depth = len(path)
+			c.Path = path
+			c.BookCount = t.bookCount(id, direct)
+			list = append(list, c)
+			walk(id, append(path[:len(path):len(path)], c))
+		}
+	}
+	walk(0, nil)
+	return list
+}
@@ -0,0 +1,128 @@
+package books
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"simpus/internal/models"
+	"simpus/internal/storage"
+	"simpus/internal/thumbnail"
+)
+
+// MaxCoverSize caps an uploaded or downloaded cover image.
+const MaxCoverSize = 5 << 20
+
+const (
+	// maxCoverPixels refuses images that would take hundreds of megabytes
+	// to decode, whatever their file size.
+	maxCoverPixels = 25_000_000
+	thumbQuality   = 85
+)
+
+var (
+	ErrCoverType     = errors.New("sampul harus berupa gambar JPEG, PNG atau GIF")
+	ErrCoverTooLarge = fmt.Errorf("ukuran berkas sampul maksimal %d MB", MaxCoverSize>>20)
+	ErrCoverDisabled = errors.New("penyimpanan sampul tidak aktif")
+	ErrCoverPixels   = fmt.Errorf("dimensi sampul maksimal %d megapiksel", maxCoverPixels/1_000_000)
+)
+
+var coverExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// UseCovers keeps uploaded covers and their thumbnails in store.
+func (s *Service) UseCovers(store storage.Storage) {
+	s.covers = store
+}
+
+// SaveCover checks a cover image and stores it, with a thumbnail of each of
+// models.CoverSizes, under a name derived from its content. The returned
+// key goes into CoverImage. The same image uploaded twice is stored once.
+func (s *Service) SaveCover(ctx context.Context, data []byte) (string, error) {
+	if s.covers == nil {
+		return "", ErrCoverDisabled
+	}
+	if len(data) > MaxCoverSize {
+		return "", ErrCoverTooLarge
+	}
+	ext, ok := coverExtensions[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrCoverType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrCoverType
+	}
+	if cfg.Width*cfg.Height > maxCoverPixels {
+		return "", ErrCoverPixels
+	}
+
+	sum := sha256.Sum256(data)
+	key := "covers/" + hex.EncodeToString(sum[:16]) + ext
+	// Thumbnails are stored first, so a cover that exists has them
+	if ok, err := s.covers.Exists(ctx, key); err != nil {
+		return "", err
+	} else if ok {
+		return key, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrCoverType
+	}
+	for size, width := range models.CoverSizes {
+		thumb, err := thumbnail.JPEG(img, width, thumbQuality)
+		if err != nil {
+			return "", err
+		}
+		if err := s.covers.Put(ctx, models.CoverThumbnail(key, size), thumb, "image/jpeg"); err != nil {
+			return "", err
+		}
+	}
+	if err := s.covers.Put(ctx, key, data, http.DetectContentType(data)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// DiscardCover deletes a cover and its thumbnails unless a book still uses
+// it. Only covers saved by the library, under covers/, are deleted. A
+// failure is logged, as a leftover file costs no more than space.
+func (s *Service) DiscardCover(ctx context.Context, key string) {
+	if s.covers == nil || !strings.HasPrefix(key, "covers/") || !storage.ValidKey(key) {
+		return
+	}
+	n, err := s.bookRepo.CountByCover(key)
+	if err != nil {
+		slog.ErrorContext(ctx, "books: count cover users", "cover", key, "error", err)
+		return
+	}
+	if n > 0 {
+		return
+	}
+
+	keys := []string{key}
+	for size := range models.CoverSizes {
+		if thumb := models.CoverThumbnail(key, size); thumb != key {
+			keys = append(keys, thumb)
+		}
+	}
+	for _, k := range keys {
+		if err := s.covers.Delete(ctx, k); err != nil {
+			slog.ErrorContext(ctx, "books: delete cover", "key", k, "error", err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package books
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"simpus/internal/models"
+	"simpus/internal/storage"
+	"simpus/internal/storage/storagetest"
+)
+
+func coverPNG(t *testing.T, w, h int, c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exists reports whether the storage holds key.
+func exists(t *testing.T, s storage.Storage, key string) bool {
+	ok, err := s.Exists(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestSaveCover(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.SaveCover(ctx, coverPNG(t, 10, 10, color.White)); !errors.Is(err, ErrCoverDisabled) {
+		t.Errorf("SaveCover without storage = %v", err)
+	}
+	store := storage.NewLocal(t.TempDir())
+	s.UseCovers(store)
+
+	data := coverPNG(t, 800, 1200, color.RGBA{200, 30, 30, 255})
+	key, err := s.SaveCover(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != len("covers/")+32+len(".png") {
+		t.Errorf("key = %q, want covers/<hash>.png", key)
+	}
+	for size, width := range models.CoverSizes {
+		f, err := store.Open(ctx, models.CoverThumbnail(key, size))
+		if err != nil {
+			t.Fatalf("thumbnail %s: %v", size, err)
+		}
+		cfg, err := jpeg.DecodeConfig(f)
+		f.Close()
+		if err != nil || cfg.Width != width || cfg.Height != width*3/2 {
+			t.Errorf("thumbnail %s is %dx%d (%v), want width %d", size, cfg.Width, cfg.Height, err, width)
+		}
+	}
+
+	// The same image is the same cover
+	if again, err := s.SaveCover(ctx, data); err != nil || again != key {
+		t.Errorf("saving again = %q, %v, want %q", again, err, key)
+	}
+
+	for name, data := range map[string][]byte{
+		"text":      []byte("bukan gambar"),
+		"truncated": data[:100],
+		"webp":      append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 32)...),
+	} {
+		if _, err := s.SaveCover(ctx, data); !errors.Is(err, ErrCoverType) {
+			t.Errorf("%s: err = %v, want ErrCoverType", name, err)
+		}
+	}
+	if _, err := s.SaveCover(ctx, append(data, make([]byte, MaxCoverSize)...)); !errors.Is(err, ErrCoverTooLarge) {
+		t.Errorf("large file: err = %v, want ErrCoverTooLarge", err)
+	}
+	// A GIF header announcing 6000x5000 pixels
+	huge := []byte("GIF89a\x70\x17\x88\x13\x00\x00\x00;")
+	if _, err := s.SaveCover(ctx, huge); !errors.Is(err, ErrCoverPixels) {
+		t.Errorf("large image: err = %v, want ErrCoverPixels", err)
+	}
+}
+
+func TestCoverCleanup(t *testing.T) {
+	s := newTestService(t)
+	store := storage.NewLocal(t.TempDir())
+	s.UseCovers(store)
+	ctx := context.Background()
+
+	red, err := s.SaveCover(ctx, coverPNG(t, 300, 450, color.RGBA{255, 0, 0, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blue, err := s.SaveCover(ctx, coverPNG(t, 300, 450, color.RGBA{0, 0, 255, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.CreateBook(&models.BookCreate{Title: "Pertama", CoverImage: red})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateBook(&models.BookCreate{Title: "Kedua", CoverImage: red})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Still shown by the second book
+	if err := s.DeleteBook(int(first)); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(t, store, red) {
+		t.Fatal("shared cover deleted with the first book")
+	}
+
+	// Replaced: the old cover and its thumbnails go
+	if err := s.UpdateBook(int(second), &models.BookUpdate{Title: "Kedua", CoverImage: blue}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{red, models.CoverThumbnail(red, "small"), models.CoverThumbnail(red, "large")} {
+		if exists(t, store, key) {
+			t.Errorf("%s kept after the cover was replaced", key)
+		}
+	}
+
+	if err := s.DeleteBook(int(second)); err != nil {
+		t.Fatal(err)
+	}
+	if exists(t, store, blue) || exists(t, store, models.CoverThumbnail(blue, "medium")) {
+		t.Error("cover kept after its book was deleted")
+	}
+
+	// Files the library did not save are left alone
+	if err := store.Put(ctx, "sampul.jpg", []byte("x"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.CreateBook(&models.BookCreate{Title: "Lama", CoverImage: "sampul.jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteBook(int(id)); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(t, store, "sampul.jpg") {
+		t.Error("cover outside covers/ deleted")
+	}
+}
+
+func TestCoversOnS3(t *testing.T) {
+	fake := storagetest.NewFakeS3("simpus", "us-east-1", "key", "secret")
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	store, err := storage.NewS3(storage.S3Options{
+		Endpoint: srv.URL, Region: "us-east-1", Bucket: "simpus", AccessKey: "key", SecretKey: "secret", PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestService(t)
+	s.UseCovers(store)
+
+	key, err := s.SaveCover(context.Background(), coverPNG(t, 400, 600, color.Black))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.CreateBook(&models.BookCreate{Title: "Di S3", CoverImage: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fake.Keys()); n != 1+len(models.CoverSizes) {
+		t.Errorf("bucket holds %d objects, want the cover and its thumbnails", n)
+	}
+	if err := s.DeleteBook(int(id)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fake.Keys(); len(keys) != 0 {
+		slices.Sort(keys)
+		t.Errorf("bucket holds %v after the book was deleted", keys)
+	}
+}
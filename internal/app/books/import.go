@@ -0,0 +1,642 @@
+package books
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"simpus/internal/isbn"
+	"simpus/internal/marc"
+	"simpus/internal/models"
+	"simpus/internal/xlsx"
+)
+
+// Fields of a book that a column of an import file can be mapped to.
+const (
+	FieldISBN        = "isbn"
+	FieldTitle       = "title"
+	FieldAuthor      = "author"
+	FieldCategory    = "category"
+	FieldPublisher   = "publisher"
+	FieldPublishYear = "publish_year"
+	FieldLanguage    = "language"
+	FieldDescription = "description"
+	FieldSubjects    = "subjects"
+	FieldDDC         = "ddc"
+	FieldCutter      = "cutter"
+	FieldStock       = "stock"
+)
+
+// ImportField describes a field for the column-mapping step. Headers equal
+// to the name or one of the aliases are mapped to it without asking.
+type ImportField struct {
+	Name     string
+	Label    string
+	Required bool
+	aliases  []string
+}
+
+// ImportFields lists the fields in the order of the export columns.
+var ImportFields = []ImportField{
+	{FieldISBN, "ISBN", false, []string{"isbn13", "isbn 13", "isbn10", "isbn 10"}},
+	{FieldTitle, "Judul", true, []string{"judul", "judul buku"}},
+	{FieldAuthor, "Penulis", false, []string{"penulis", "pengarang", "author name", "authors", "kontributor"}},
+	{FieldCategory, "Kategori", false, []string{"kategori"}},
+	{FieldPublisher, "Penerbit", false, []string{"penerbit"}},
+	{FieldPublishYear, "Tahun Terbit", false, []string{"tahun terbit", "tahun", "year"}},
+	{FieldLanguage, "Bahasa", false, []string{"bahasa", "lang"}},
+	{FieldDescription, "Deskripsi", false, []string{"deskripsi", "keterangan", "sinopsis"}},
+	{FieldSubjects, "Subjek", false, []string{"subjek", "subject", "tags", "topik"}},
+	{FieldDDC, "Klasifikasi DDC", false, []string{"klasifikasi", "dewey", "no klas", "nomor klas"}},
+	{FieldCutter, "Tanda Pengarang", false, []string{"tanda pengarang", "author mark"}},
+	{FieldStock, "Stok", false, []string{"stok", "jumlah", "eksemplar"}},
+}
+
+// Limits on an import file
+const (
+	MaxImportSize = 10 << 20
+	MaxImportRows = 20000
+)
+
+// ImportExtensions are the extensions of the files ReadSheet reads.
+var ImportExtensions = []string{".csv", ".xlsx", ".mrc", ".marc", ".xml"}
+
+// ErrImportFormat is returned for files of any other format.
+var ErrImportFormat = errors.New("format berkas harus CSV, XLSX, MARC (.mrc) atau MARCXML (.xml)")
+
+// Sheet is the content of an import file: a header row and the rows below
+// it, as text.
+type Sheet struct {
+	Header []string
+	Rows   [][]string
+}
+
+// ReadSheet reads a CSV, XLSX or MARC file, told apart by the extension of
+// name. CSV files may be separated by commas or semicolons, as spreadsheets
+// set to Indonesian save them. MARC records, in ISO 2709 or MARCXML, become
+// rows under a header naming the fields.
+func ReadSheet(name string, r io.Reader) (*Sheet, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxImportSize {
+		return nil, fmt.Errorf("berkas terlalu besar (maksimal %d MB)", MaxImportSize>>20)
+	}
+
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".txt":
+		rows, err = readCSV(data)
+	case ".xlsx":
+		rows, err = xlsx.Read(bytes.NewReader(data), int64(len(data)))
+	case ".mrc", ".marc":
+		rows, err = readMARC(data, false)
+	case ".xml":
+		rows, err = readMARC(data, true)
+	default:
+		return nil, ErrImportFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("berkas tidak dapat dibaca: %w", err)
+	}
+
+	if len(rows) == 0 || blank(rows[0]) {
+		return nil, errors.New("baris pertama berkas harus berisi nama kolom")
+	}
+	sheet := &Sheet{Header: rows[0], Rows: rows[1:]}
+	for i := range sheet.Header {
+		sheet.Header[i] = strings.TrimSpace(sheet.Header[i])
+	}
+	if len(sheet.Rows) > MaxImportRows {
+		return nil, fmt.Errorf("berkas berisi %d baris, maksimal %d per impor", len(sheet.Rows), MaxImportRows)
+	}
+	return sheet, nil
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	if !utf8.Valid(data) {
+		return nil, errors.New("CSV harus disimpan dengan encoding UTF-8")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	first, _ := bufio.NewReader(bytes.NewReader(data)).ReadString('\n')
+	if strings.Count(first, ";") > strings.Count(first, ",") {
+		reader.Comma = ';'
+	}
+	return reader.ReadAll()
+}
+
+func blank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ImportMapping maps field names to the column of the file holding them,
+// counted from 0. Fields left out are not imported.
+type ImportMapping map[string]int
+
+// GuessMapping maps every header that names a field, ignoring case,
+// spaces and underscores. The first column naming a field wins.
+func GuessMapping(header []string) ImportMapping {
+	key := func(s string) string {
+		s = strings.ToLower(strings.TrimSpace(s))
+		return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+			return r == ' ' || r == '_' || r == '-'
+		}), " ")
+	}
+	mapping := ImportMapping{}
+	for col, h := range header {
+		h = key(h)
+		for _, f := range ImportFields {
+			if _, done := mapping[f.Name]; done {
+				continue
+			}
+			match := h == key(f.Name)
+			for _, alias := range f.aliases {
+				match = match || h == alias
+			}
+			if match {
+				mapping[f.Name] = col
+				break
+			}
+		}
+	}
+	return mapping
+}
+
+// ImportOptions are chosen in the mapping step.
+type ImportOptions struct {
+	Mapping ImportMapping
+	// CreateMissing creates categories and authors the library does not
+	// have yet instead of rejecting the rows naming them.
+	CreateMissing bool
+	// BranchID receives the stock of every imported book.
+	BranchID int
+}
+
+// ImportRow is one row of the file with the book read from it.
+type ImportRow struct {
+	Row    int // as in the file, counting the header as row 1
+	Book   models.BookImport
+	Errors []string
+
+	NewCategory bool
+	NewAuthors  []string // authors the row is the first to name
+}
+
+func (r ImportRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// ImportResult is the outcome of checking or importing a file. Blank rows
+// are left out.
+type ImportResult struct {
+	Rows          []ImportRow
+	Valid         int
+	Invalid       int
+	NewCategories []string
+	NewAuthors    []string
+	Created       int // books saved, 0 for a dry run
+}
+
+// InvalidRows returns the rows that are not imported.
+func (r *ImportResult) InvalidRows() []ImportRow {
+	var rows []ImportRow
+	for _, row := range r.Rows {
+		if !row.Valid() {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// PreviewImport checks every row of sheet without saving anything.
+func (s *Service) PreviewImport(sheet *Sheet, opts ImportOptions) (*ImportResult, error) {
+	if _, ok := opts.Mapping[FieldTitle]; !ok {
+		return nil, errors.New("kolom judul wajib dipetakan")
+	}
+	for field, col := range opts.Mapping {
+		if col < 0 || col >= len(sheet.Header) {
+			return nil, fmt.Errorf("kolom untuk %s tidak ada di berkas", field)
+		}
+	}
+
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	authors, err := s.authorRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	isbns, err := s.bookRepo.FindISBNs()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &importValidator{
+		opts:       opts,
+		categories: map[string]int{},
+		authors:    map[string]int{},
+		isbns:      map[string]int{},
+		newNames:   map[string]bool{},
+		maxYear:    time.Now().Year() + 1,
+	}
+	for _, c := range categories {
+		v.categories[nameKey(c.Name)] = c.ID
+	}
+	for _, a := range authors {
+		v.authors[nameKey(a.Name)] = a.ID
+	}
+	for _, n := range isbns {
+		v.isbns[isbn.Normalize(n)] = 0
+	}
+
+	result := &ImportResult{}
+	for i, record := range sheet.Rows {
+		if blank(record) {
+			continue
+		}
+		row := v.check(i+2, record)
+		if row.Valid() {
+			result.Valid++
+			if row.NewCategory {
+				result.NewCategories = append(result.NewCategories, row.Book.Category)
+			}
+			result.NewAuthors = append(result.NewAuthors, row.NewAuthors...)
+		} else {
+			result.Invalid++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, nil
+}
+
+// ImportBooks saves the valid rows of sheet in one transaction and rebuilds
+// the search index. Invalid rows are skipped and listed in the result, to
+// be fixed and imported again.
+func (s *Service) ImportBooks(sheet *Sheet, opts ImportOptions) (*ImportResult, error) {
+	result, err := s.PreviewImport(sheet, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]models.BookImport, 0, result.Valid)
+	for _, row := range result.Rows {
+		if row.Valid() {
+			books = append(books, row.Book)
+		}
+	}
+	if len(books) == 0 {
+		return result, nil
+	}
+	if err := s.bookRepo.Import(books); err != nil {
+		return nil, err
+	}
+	result.Created = len(books)
+	s.rebuild()
+	return result, nil
+}
+
+// importValidator checks rows against the catalog and the rows before them.
+type importValidator struct {
+	opts       ImportOptions
+	categories map[string]int // existing IDs by nameKey
+	authors    map[string]int
+	isbns      map[string]int // normalized ISBN -> row, 0 for books in the catalog
+	newNames   map[string]bool
+	maxYear    int
+}
+
+func (v *importValidator) check(n int, record []string) ImportRow {
+	field := func(name string) string {
+		if col, ok := v.opts.Mapping[name]; ok && col < len(record) {
+			return strings.TrimSpace(record[col])
+		}
+		return ""
+	}
+	row := ImportRow{Row: n}
+	fail := func(format string, args ...interface{}) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+	}
+	b := &row.Book
+	b.ISBN = field(FieldISBN)
+	b.Title = strings.Join(strings.Fields(field(FieldTitle)), " ")
+	b.Category = strings.Join(strings.Fields(field(FieldCategory)), " ")
+	b.Contributors = parseContributors(field(FieldAuthor))
+	b.Publisher = field(FieldPublisher)
+	b.Description = field(FieldDescription)
+	b.BranchID = v.opts.BranchID
+
+	subjects, err := checkSubjects(splitSubjects(field(FieldSubjects)))
+	if err != nil {
+		fail("%v", err)
+	}
+	b.Subjects = subjects
+	if b.DDC, err = checkDDC(field(FieldDDC)); err != nil {
+		fail("%v", err)
+	}
+	if b.Cutter, err = checkCutter(field(FieldCutter)); err != nil {
+		fail("%v", err)
+	}
+
+	if b.Title == "" {
+		fail("judul wajib diisi")
+	}
+	for _, c := range []struct {
+		label string
+		value string
+		max   int
+	}{
+		{"judul", b.Title, 255},
+		{"penerbit", b.Publisher, 100},
+		{"kategori", b.Category, 100},
+	} {
+		if utf8.RuneCountInString(c.value) > c.max {
+			fail("%s terlalu panjang (maksimal %d karakter)", c.label, c.max)
+		}
+	}
+	for i, c := range b.Contributors {
+		if utf8.RuneCountInString(c.Name) > 100 {
+			fail("penulis %q terlalu panjang (maksimal 100 karakter)", c.Name)
+		}
+		for _, prev := range b.Contributors[:i] {
+			if nameKey(prev.Name) == nameKey(c.Name) && prev.Role == c.Role {
+				fail("penulis %q tercantum dua kali", c.Name)
+			}
+		}
+	}
+
+	if b.ISBN != "" {
+		normalized := isbn.Normalize(b.ISBN)
+		switch first, seen := v.isbns[normalized]; {
+		case len(normalized) != 10 && len(normalized) != 13:
+			fail("ISBN %s harus terdiri dari 10 atau 13 digit", b.ISBN)
+		case !isbn.Valid(normalized):
+			fail("digit pemeriksa ISBN %s salah", b.ISBN)
+		case seen && first == 0:
+			fail("ISBN %s sudah ada di katalog", b.ISBN)
+		case seen:
+			fail("ISBN %s sama dengan baris %d", b.ISBN, first)
+		default:
+			v.isbns[normalized] = n
+		}
+	}
+
+	if year := field(FieldPublishYear); year != "" {
+		y, err := strconv.Atoi(year)
+		if err != nil || y < 1000 || y > v.maxYear {
+			fail("tahun terbit %q tidak valid", year)
+		}
+		b.PublishYear = y
+	}
+
+	lang, err := checkLanguage(languageFromName(field(FieldLanguage)))
+	if err != nil {
+		fail("%v", err)
+	}
+	b.Language = lang
+
+	if stock := field(FieldStock); stock != "" {
+		s, err := strconv.Atoi(stock)
+		if err != nil || s < 0 {
+			fail("stok %q harus bilangan bulat tidak negatif", stock)
+		}
+		b.Stock = s
+	}
+	if b.Stock > 0 && b.BranchID == 0 {
+		fail("cabang untuk stok awal wajib dipilih")
+	}
+
+	b.CategoryID, row.NewCategory = v.checkName(&row, b.Category, v.categories, "kategori")
+	for i, c := range b.Contributors {
+		var isNew bool
+		b.Contributors[i].AuthorID, isNew = v.checkName(&row, c.Name, v.authors, "penulis")
+		b.Authors = append(b.Authors, c.Name)
+		if isNew {
+			row.NewAuthors = append(row.NewAuthors, c.Name)
+		}
+	}
+	if b.Cutter == "" {
+		b.Cutter = models.AuthorMark(b.Contributors, b.Title)
+	}
+	return row
+}
+
+// checkName looks up a category or author by name. One the library does
+// not have is created with CreateMissing, and the row is the first to name
+// it when isNew is true; without CreateMissing the row is rejected.
+func (v *importValidator) checkName(row *ImportRow, name string, existing map[string]int, label string) (id int, isNew bool) {
+	key := nameKey(name)
+	if id, ok := existing[key]; ok || name == "" {
+		return id, false
+	}
+	if !v.opts.CreateMissing {
+		row.Errors = append(row.Errors, fmt.Sprintf("%s %q belum terdaftar", label, name))
+		return 0, false
+	}
+	if !row.Valid() {
+		return 0, false
+	}
+	seen := v.newNames[label+"\x00"+key]
+	v.newNames[label+"\x00"+key] = true
+	return 0, !seen
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// parseContributors reads the author column: names separated by
+// semicolons, in credit order, each an author unless followed by its role
+// in parentheses, "Budi Santoso (Penerjemah)". The role may be named by its
+// code or its name.
+func parseContributors(s string) []models.Contributor {
+	var list []models.Contributor
+	for _, part := range strings.Split(s, ";") {
+		name := strings.Join(strings.Fields(part), " ")
+		role := models.RoleAuthor
+		if open := strings.LastIndex(name, " ("); open > 0 && strings.HasSuffix(name, ")") {
+			label := name[open+2 : len(name)-1]
+			for _, r := range models.ContributorRoles {
+				if strings.EqualFold(label, r.Code) || strings.EqualFold(label, r.Name) {
+					name, role = name[:open], r.Code
+				}
+			}
+		}
+		if name != "" {
+			list = append(list, models.Contributor{Name: name, Role: role})
+		}
+	}
+	return list
+}
+
+// splitSubjects reads subject tags separated by semicolons, as the subjects
+// column and the book forms take them.
+func splitSubjects(s string) []string {
+	var list []string
+	for _, part := range strings.Split(s, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// formatContributors writes contributors as parseContributors reads them.
+func formatContributors(list []models.Contributor) string {
+	parts := make([]string, len(list))
+	for i, c := range list {
+		parts[i] = c.Name
+		if c.Role != models.RoleAuthor {
+			parts[i] += " (" + c.RoleName() + ")"
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// languageFromName accepts the name of a language as well as its code:
+// "Inggris" is "en".
+func languageFromName(s string) string {
+	for _, l := range models.Languages {
+		if strings.EqualFold(s, l.Name) {
+			return l.Code
+		}
+	}
+	return s
+}
+
+// WriteImportErrors writes the rows that were not imported as CSV, with the
+// columns of the original file and the reasons in a first "kesalahan"
+// column. Once fixed, the file can be imported again as is.
+func WriteImportErrors(w io.Writer, sheet *Sheet, result *ImportResult) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"kesalahan"}, sheet.Header...)); err != nil {
+		return err
+	}
+	for _, row := range result.InvalidRows() {
+		record := make([]string, len(sheet.Header))
+		copy(record, sheet.Rows[row.Row-2])
+		reason := fmt.Sprintf("baris %d: %s", row.Row, strings.Join(row.Errors, "; "))
+		if err := writer.Write(append([]string{reason}, record...)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Formats of ExportCatalog
+const (
+	ExportCSV     = "csv"
+	ExportXLSX    = "xlsx"
+	ExportMARC    = "marc"    // MARC 21 in ISO 2709
+	ExportMARCXML = "marcxml" // MARC 21 in MARCXML
+)
+
+// exportColumns are the import fields followed by the availability, which
+// is ignored when the file is imported again.
+var exportColumns = []string{
+	FieldISBN, FieldTitle, FieldAuthor, FieldCategory, FieldPublisher, FieldPublishYear,
+	FieldLanguage, FieldDescription, FieldSubjects, FieldDDC, FieldCutter, FieldStock, "available", "branches",
+}
+
+// ExportCatalog writes the books selected by filter, sorted by title. CSV
+// and XLSX carry the current availability: the branches column reads
+// "PUSAT 2/3; TIMUR 1/1", copies available out of those held at each
+// branch. MARC formats hold one bibliographic record per book.
+func (s *Service) ExportCatalog(w io.Writer, format string, filter models.BookFilter) error {
+	var write func(models.Book) error
+	var done func() error
+	switch format {
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(exportColumns); err != nil {
+			return err
+		}
+		write = func(b models.Book) error {
+			return writer.Write(exportRow(b))
+		}
+		done = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case ExportXLSX:
+		rows := [][]string{exportColumns}
+		write = func(b models.Book) error {
+			rows = append(rows, exportRow(b))
+			return nil
+		}
+		done = func() error {
+			return xlsx.Write(w, "Katalog", rows)
+		}
+	case ExportMARC:
+		writer := marc.NewWriter(w)
+		write = func(b models.Book) error {
+			return writer.Write(bookRecord(b))
+		}
+		done = func() error { return nil }
+	case ExportMARCXML:
+		writer := marc.NewXMLWriter(w)
+		write = func(b models.Book) error {
+			return writer.Write(bookRecord(b))
+		}
+		done = writer.Close
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	filter.Page, filter.Limit = 1, 500
+	if filter.Sort == "" && filter.Search == "" {
+		filter.Sort = models.BookSortTitle
+	}
+	for {
+		books, _, err := s.GetBooks(filter)
+		if err != nil {
+			return err
+		}
+		for _, b := range books {
+			if err := write(b); err != nil {
+				return err
+			}
+		}
+		if len(books) < filter.Limit {
+			break
+		}
+		filter.Page++
+	}
+	return done()
+}
+
+func exportRow(b models.Book) []string {
+	var category, year string
+	if b.Category != nil {
+		category = b.Category.Name
+	}
+	if b.PublishYear > 0 {
+		year = strconv.Itoa(b.PublishYear)
+	}
+	branches := make([]string, len(b.Branches))
+	for i, bs := range b.Branches {
+		branches[i] = fmt.Sprintf("%s %d/%d", bs.BranchCode, bs.Available, bs.Stock)
+	}
+	return []string{
+		b.ISBN, b.Title, formatContributors(b.Contributors), category, b.Publisher, year, b.Language, b.Description,
+		strings.Join(b.Subjects, "; "), b.DDC, b.Cutter, strconv.Itoa(b.Stock), strconv.Itoa(b.Available), strings.Join(branches, "; "),
+	}
+}
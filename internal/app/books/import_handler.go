@@ -0,0 +1,398 @@
+package books
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"simpus/internal/app/branches"
+	"simpus/internal/middleware"
+	"simpus/internal/renderer"
+)
+
+// Uploads and error reports older than importTTL are removed on the next
+// upload.
+const importTTL = 24 * time.Hour
+
+// How many rows the preview lists; the counts cover the whole file.
+const (
+	previewInvalidRows = 200
+	previewValidRows   = 20
+)
+
+var importToken = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ImportHandler runs a catalog import in steps: the file is uploaded and
+// kept in dir under a random token, its columns are mapped, a dry run shows
+// what would be imported, and the import is committed. Each step posts the
+// mapping again, so nothing but the file is kept between requests.
+type ImportHandler struct {
+	service       *Service
+	branchService *branches.Service
+	views         *renderer.Renderer
+	dir           string
+}
+
+func NewImportHandler(service *Service, branchService *branches.Service, views *renderer.Renderer, dir string) *ImportHandler {
+	return &ImportHandler{
+		service:       service,
+		branchService: branchService,
+		views:         views,
+		dir:           dir,
+	}
+}
+
+func (h *ImportHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r.Context())
+
+	data := map[string]interface{}{
+		"Title":  "Impor Buku - SIMPUS",
+		"Fields": ImportFields,
+		"Error":  r.URL.Query().Get("error"),
+		"User":   claims,
+	}
+	h.views.Render(w, r, "admin/books/import.html", data)
+}
+
+// Store keeps the uploaded file and moves on to the mapping step.
+func (h *ImportHandler) Store(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		http.Redirect(w, r, "/admin/books/import?error="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		fail("Pilih berkas yang akan diimpor")
+		return
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if !slices.Contains(ImportExtensions, ext) {
+		fail(ErrImportFormat.Error())
+		return
+	}
+	if _, err := ReadSheet(header.Filename, file); err != nil {
+		fail(err.Error())
+		return
+	}
+
+	token, err := h.save(header.Filename, file)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "books: keep import file", "error", err)
+		http.Error(w, "Berkas tidak dapat disimpan", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/books/import/"+token, http.StatusSeeOther)
+}
+
+// Mapping asks which column holds which field, starting from the columns
+// whose header names a field.
+func (h *ImportHandler) Mapping(w http.ResponseWriter, r *http.Request) {
+	token, name, sheet, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	h.renderMapping(w, r, token, name, sheet, GuessMapping(sheet.Header), ImportOptions{}, "")
+}
+
+func (h *ImportHandler) renderMapping(w http.ResponseWriter, r *http.Request, token, name string, sheet *Sheet,
+	mapping ImportMapping, opts ImportOptions, errMsg string) {
+	branchList, _ := h.branchService.GetActiveBranches()
+	claims := middleware.GetUserFromContext(r.Context())
+	if opts.BranchID == 0 {
+		opts.BranchID = claims.BranchID
+	}
+
+	sample := sheet.Rows
+	if len(sample) > 5 {
+		sample = sample[:5]
+	}
+	selected := map[string]int{}
+	for _, f := range ImportFields {
+		selected[f.Name] = -1
+		if col, ok := mapping[f.Name]; ok {
+			selected[f.Name] = col
+		}
+	}
+
+	data := map[string]interface{}{
+		"Title":    "Impor Buku - SIMPUS",
+		"Token":    token,
+		"FileName": name,
+		"Header":   sheet.Header,
+		"Sample":   sample,
+		"RowCount": len(sheet.Rows),
+		"Fields":   ImportFields,
+		"Selected": selected,
+		"Options":  opts,
+		"Branches": branchList,
+		"Error":    errMsg,
+		"User":     claims,
+	}
+	h.views.Render(w, r, "admin/books/import_mapping.html", data)
+}
+
+// Preview is the dry run: every row is checked and nothing is saved.
+func (h *ImportHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	token, name, sheet, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	opts := importOptions(r)
+
+	result, err := h.service.PreviewImport(sheet, opts)
+	if err != nil {
+		h.renderMapping(w, r, token, name, sheet, opts.Mapping, opts, err.Error())
+		return
+	}
+
+	var invalid, valid []ImportRow
+	for _, row := range result.Rows {
+		if !row.Valid() && len(invalid) < previewInvalidRows {
+			invalid = append(invalid, row)
+		}
+		if row.Valid() && len(valid) < previewValidRows {
+			valid = append(valid, row)
+		}
+	}
+	branchName := ""
+	if opts.BranchID > 0 {
+		if branch, err := h.branchService.GetBranch(opts.BranchID); err == nil {
+			branchName = branch.Name
+		}
+	}
+
+	claims := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"Title":       "Pratinjau Impor Buku - SIMPUS",
+		"Token":       token,
+		"FileName":    name,
+		"Result":      result,
+		"InvalidRows": invalid,
+		"ValidRows":   valid,
+		"BranchName":  branchName,
+		"Form":        r.PostForm,
+		"User":        claims,
+	}
+	h.views.Render(w, r, "admin/books/import_preview.html", data)
+}
+
+// Commit imports the valid rows and writes the error report of the others.
+// The uploaded file is removed once imported, so a reload cannot import it
+// twice.
+func (h *ImportHandler) Commit(w http.ResponseWriter, r *http.Request) {
+	token, name, sheet, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	opts := importOptions(r)
+
+	result, err := h.service.ImportBooks(sheet, opts)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "books: import", "file", name, "error", err)
+		h.renderMapping(w, r, token, name, sheet, opts.Mapping, opts, "Impor dibatalkan, tidak ada buku yang disimpan: "+err.Error())
+		return
+	}
+	slog.InfoContext(r.Context(), "books imported", "file", name, "created", result.Created, "rejected", result.Invalid)
+
+	if result.Invalid > 0 {
+		if err := h.writeReport(token, sheet, result); err != nil {
+			slog.ErrorContext(r.Context(), "books: write import error report", "error", err)
+		}
+	}
+	if path, err := h.upload(token); err == nil {
+		os.Remove(path)
+	}
+
+	claims := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"Title":    "Impor Buku - SIMPUS",
+		"Token":    token,
+		"FileName": name,
+		"Result":   result,
+		"User":     claims,
+	}
+	h.views.Render(w, r, "admin/books/import_result.html", data)
+}
+
+// Errors downloads the error report of an import.
+func (h *ImportHandler) Errors(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if !importToken.MatchString(token) {
+		http.NotFound(w, r)
+		return
+	}
+	path := filepath.Join(h.dir, token+".errors.csv")
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "Laporan kesalahan tidak ditemukan atau sudah kedaluwarsa", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="kesalahan-impor-buku.csv"`)
+	http.ServeFile(w, r, path)
+}
+
+// exportTypes are the content type and file extension of each export
+// format.
+var exportTypes = map[string]struct{ contentType, ext string }{
+	ExportCSV:     {"text/csv; charset=utf-8", "csv"},
+	ExportXLSX:    {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+	ExportMARC:    {"application/marc", "mrc"},
+	ExportMARCXML: {"application/marcxml+xml", "xml"},
+}
+
+// Export downloads the catalog, narrowed down by the same query as the
+// catalog listing.
+func (h *ImportHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	typ, ok := exportTypes[format]
+	if !ok {
+		http.Error(w, "Format ekspor harus csv, xlsx, marc atau marcxml", http.StatusBadRequest)
+		return
+	}
+
+	name := fmt.Sprintf("katalog-%s.%s", time.Now().Format("20060102"), typ.ext)
+	w.Header().Set("Content-Type", typ.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if err := h.service.ExportCatalog(w, format, catalogFilter(r.URL.Query())); err != nil {
+		// Headers are sent with the first rows; all that is left is to log
+		slog.ErrorContext(r.Context(), "books: export catalog", "error", err)
+	}
+}
+
+// importOptions reads the mapping step: map_<field> holds a column index
+// and is empty for fields not imported.
+func importOptions(r *http.Request) ImportOptions {
+	r.ParseForm()
+	opts := ImportOptions{
+		Mapping:       ImportMapping{},
+		CreateMissing: r.PostFormValue("create_missing") == "1",
+	}
+	opts.BranchID, _ = strconv.Atoi(r.PostFormValue("branch_id"))
+	for _, f := range ImportFields {
+		if col, err := strconv.Atoi(r.PostFormValue("map_" + f.Name)); err == nil && col >= 0 {
+			opts.Mapping[f.Name] = col
+		}
+	}
+	return opts
+}
+
+// save keeps an uploaded file as <token><ext> and its original name in
+// <token>.name, removing what earlier imports left behind.
+func (h *ImportHandler) save(filename string, file io.ReadSeeker) (string, error) {
+	if err := os.MkdirAll(h.dir, 0o750); err != nil {
+		return "", err
+	}
+	h.cleanup()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	out, err := os.OpenFile(filepath.Join(h.dir, token+strings.ToLower(filepath.Ext(filename))), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return "", err
+	}
+	if _, err := out.ReadFrom(file); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return token, os.WriteFile(filepath.Join(h.dir, token+".name"), []byte(filepath.Base(filename)), 0o640)
+}
+
+// open reads the file uploaded under the token in the URL, answering the
+// request itself when there is none.
+func (h *ImportHandler) open(w http.ResponseWriter, r *http.Request) (token, name string, sheet *Sheet, ok bool) {
+	token = r.PathValue("token")
+	path, err := h.upload(token)
+	if err != nil {
+		http.Redirect(w, r, "/admin/books/import?error="+url.QueryEscape("Berkas impor tidak ditemukan atau sudah kedaluwarsa, silakan unggah ulang"), http.StatusSeeOther)
+		return "", "", nil, false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", "", nil, false
+	}
+	defer f.Close()
+	if sheet, err = ReadSheet(path, f); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", "", nil, false
+	}
+
+	name = filepath.Base(path)
+	if original, err := os.ReadFile(filepath.Join(h.dir, token+".name")); err == nil {
+		name = string(original)
+	}
+	return token, name, sheet, true
+}
+
+// upload returns the path of the file uploaded under token.
+func (h *ImportHandler) upload(token string) (string, error) {
+	if !importToken.MatchString(token) {
+		return "", fs.ErrNotExist
+	}
+	for _, ext := range ImportExtensions {
+		path := filepath.Join(h.dir, token+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fs.ErrNotExist
+}
+
+func (h *ImportHandler) writeReport(token string, sheet *Sheet, result *ImportResult) error {
+	f, err := os.OpenFile(filepath.Join(h.dir, token+".errors.csv"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if err := WriteImportErrors(f, sheet, result); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// cleanup removes the files of imports started more than importTTL ago.
+func (h *ImportHandler) cleanup() {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || time.Since(info.ModTime()) < importTTL {
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.dir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("books: remove old import file", "file", e.Name(), "error", err)
+		}
+	}
+}
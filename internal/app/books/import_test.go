@@ -0,0 +1,301 @@
+package books
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+
+	"simpus/internal/models"
+	"simpus/internal/xlsx"
+)
+
+const importCSV = `Judul;Pengarang;Kategori;ISBN;Tahun;Bahasa;Stok
+Sang Pemimpi;Andrea Hirata;fiksi;978-0-306-40615-7;2006;Indonesia;2
+Refactoring;Martin Fowler;Pemrograman;0-201-48567-2;1999;en;1
+;Tanpa Judul;Fiksi;;;;
+Duplikat;Andrea Hirata;Fiksi;978-0-13-235088-4;2008;id;1
+Salah Cek;Andrea Hirata;Fiksi;978-0-306-40615-8;2008;id;1
+;;;;;;
+Tahun Aneh;martin fowler;Pemrograman;;20xx;;-1
+`
+
+func readTestSheet(t *testing.T, data string) *Sheet {
+	sheet, err := ReadSheet("buku.csv", strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sheet
+}
+
+func rowErrors(result *ImportResult) map[int]string {
+	errs := map[int]string{}
+	for _, row := range result.InvalidRows() {
+		errs[row.Row] = strings.Join(row.Errors, "; ")
+	}
+	return errs
+}
+
+func TestGuessMapping(t *testing.T) {
+	got := GuessMapping([]string{"No", "Judul Buku", "ISBN-13", "penulis", "Tahun_Terbit", "isbn", "Keterangan"})
+	want := ImportMapping{FieldTitle: 1, FieldISBN: 2, FieldAuthor: 3, FieldPublishYear: 4, FieldDescription: 6}
+	if len(got) != len(want) {
+		t.Fatalf("GuessMapping = %v, want %v", got, want)
+	}
+	for field, col := range want {
+		if got[field] != col {
+			t.Errorf("GuessMapping[%s] = %d, want %d", field, got[field], col)
+		}
+	}
+}
+
+func TestPreviewImport(t *testing.T) {
+	s := newTestService(t)
+	sheet := readTestSheet(t, importCSV)
+	opts := ImportOptions{Mapping: GuessMapping(sheet.Header), BranchID: 1}
+
+	result, err := s.PreviewImport(sheet, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid != 1 || result.Invalid != 5 {
+		t.Errorf("valid, invalid = %d, %d, want 1, 5", result.Valid, result.Invalid)
+	}
+	errs := rowErrors(result)
+	for row, want := range map[int]string{
+		3: `kategori "Pemrograman" belum terdaftar`,
+		4: "judul wajib diisi",
+		5: "sudah ada di katalog",
+		6: "digit pemeriksa ISBN 978-0-306-40615-8 salah",
+		8: `tahun terbit "20xx" tidak valid`,
+	} {
+		if !strings.Contains(errs[row], want) {
+			t.Errorf("row %d: errors %q, want %q", row, errs[row], want)
+		}
+	}
+	if !strings.Contains(errs[8], "stok") {
+		t.Errorf("row 8: errors %q, want the negative stock reported", errs[8])
+	}
+
+	valid := result.Rows[0]
+	if valid.Row != 2 || valid.Book.CategoryID != 1 || len(valid.Book.Contributors) != 1 || valid.Book.Contributors[0].AuthorID != 1 || valid.Book.Language != "id" ||
+		valid.Book.PublishYear != 2006 || valid.Book.Stock != 2 {
+		t.Errorf("row 2 = %+v, want existing Fiksi and Andrea Hirata, year 2006, 2 copies in Indonesian", valid)
+	}
+
+	if n, _ := s.GetBookCount(0); n != 5 {
+		t.Errorf("preview saved books: %d in catalog", n)
+	}
+}
+
+func TestImportBooks(t *testing.T) {
+	s := newTestService(t)
+	sheet := readTestSheet(t, importCSV)
+	opts := ImportOptions{Mapping: GuessMapping(sheet.Header), BranchID: 2, CreateMissing: true}
+
+	result, err := s.ImportBooks(sheet, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 {
+		t.Fatalf("created %d books, want 2; errors %v", result.Created, rowErrors(result))
+	}
+	if !slices.Equal(result.NewCategories, []string{"Pemrograman"}) || !slices.Equal(result.NewAuthors, []string{"Martin Fowler"}) {
+		t.Errorf("new categories %v and authors %v, want Pemrograman and Martin Fowler", result.NewCategories, result.NewAuthors)
+	}
+
+	books, _, err := s.GetBooks(models.BookFilter{Search: "refactoring"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 {
+		t.Fatalf("search for the imported book found %d", len(books))
+	}
+	b := books[0]
+	if b.Category == nil || b.Category.Name != "Pemrograman" || b.AuthorNames() != "Martin Fowler" ||
+		b.Language != "en" || b.Available != 1 || len(b.Branches) != 1 || b.Branches[0].BranchID != 2 {
+		t.Errorf("imported book = %+v", b)
+	}
+
+	// Importing the same file again finds every ISBN in the catalog
+	again, err := s.ImportBooks(sheet, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created != 0 {
+		t.Errorf("second import created %d books", again.Created)
+	}
+}
+
+func TestImportBooksWithoutBranch(t *testing.T) {
+	s := newTestService(t)
+	sheet := readTestSheet(t, "title,stock\nTanpa Stok,\nDengan Stok,3\n")
+
+	result, err := s.PreviewImport(sheet, ImportOptions{Mapping: GuessMapping(sheet.Header)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := rowErrors(result); len(errs) != 1 || !strings.Contains(errs[3], "cabang") {
+		t.Errorf("errors = %v, want row 3 to need a branch", errs)
+	}
+}
+
+func TestParseContributors(t *testing.T) {
+	got := parseContributors(" Robert C.  Martin; Budi Santoso (penerjemah);Sari (editor) ; Tim (Redaksi);")
+	want := []models.Contributor{
+		{Name: "Robert C. Martin", Role: models.RoleAuthor},
+		{Name: "Budi Santoso", Role: models.RoleTranslator},
+		{Name: "Sari", Role: models.RoleEditor},
+		{Name: "Tim (Redaksi)", Role: models.RoleAuthor},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseContributors = %+v, want %+v", got, want)
+	}
+	if s := formatContributors(want[:3]); s != "Robert C. Martin; Budi Santoso (Penerjemah); Sari (Editor)" {
+		t.Errorf("formatContributors = %q", s)
+	}
+}
+
+func TestPreviewImportNeedsTitle(t *testing.T) {
+	s := newTestService(t)
+	sheet := readTestSheet(t, "isbn,judul\n")
+
+	if _, err := s.PreviewImport(sheet, ImportOptions{Mapping: ImportMapping{FieldISBN: 0}}); err == nil {
+		t.Error("preview without a title column succeeded")
+	}
+	if _, err := s.PreviewImport(sheet, ImportOptions{Mapping: ImportMapping{FieldTitle: 2}}); err == nil {
+		t.Error("preview with a column beyond the header succeeded")
+	}
+}
+
+func TestReadSheet(t *testing.T) {
+	if _, err := ReadSheet("buku.pdf", strings.NewReader("x")); err != ErrImportFormat {
+		t.Errorf("ReadSheet of a PDF = %v, want ErrImportFormat", err)
+	}
+
+	sheet := readTestSheet(t, "\ufeffjudul,\"penulis, utama\"\nBumi,Tere Liye\n")
+	if !slices.Equal(sheet.Header, []string{"judul", "penulis, utama"}) || len(sheet.Rows) != 1 {
+		t.Errorf("ReadSheet = %+v", sheet)
+	}
+
+	var buf bytes.Buffer
+	if err := xlsx.Write(&buf, "Buku", [][]string{{"Judul"}, {"Bumi"}}); err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := ReadSheet("BUKU.XLSX", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sheet.Header, []string{"Judul"}) || len(sheet.Rows) != 1 || sheet.Rows[0][0] != "Bumi" {
+		t.Errorf("ReadSheet of XLSX = %+v", sheet)
+	}
+}
+
+func TestWriteImportErrors(t *testing.T) {
+	s := newTestService(t)
+	sheet := readTestSheet(t, importCSV)
+
+	result, err := s.PreviewImport(sheet, ImportOptions{Mapping: GuessMapping(sheet.Header), BranchID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteImportErrors(&buf, sheet, result); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("report has %d lines, want a header and 5 rows", len(records))
+	}
+	if records[0][0] != "kesalahan" || records[0][1] != "Judul" {
+		t.Errorf("header = %v", records[0])
+	}
+	if !strings.HasPrefix(records[1][0], "baris 3: ") || records[1][1] != "Refactoring" {
+		t.Errorf("first row = %v", records[1])
+	}
+
+	// The report maps like the original file
+	if m := GuessMapping(records[0]); m[FieldTitle] != 1 {
+		t.Errorf("mapping of the report = %v", m)
+	}
+}
+
+func TestExportCatalog(t *testing.T) {
+	s := newTestService(t)
+
+	var buf bytes.Buffer
+	if err := s.ExportCatalog(&buf, ExportCSV, models.BookFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("export has %d lines, want a header and 5 books", len(records))
+	}
+	if !slices.Equal(records[0], exportColumns) {
+		t.Errorf("header = %v", records[0])
+	}
+	want := []string{"978-0-13-235088-4", "Clean Code", "Robert C. Martin", "Teknologi", "Prentice Hall", "2008",
+		"en", "Panduan menulis kode yang bersih dan mudah dipelihara", "Rekayasa perangkat lunak", "005.1", "MAR", "2", "2", "PUSAT 2/2"}
+	if !slices.Equal(records[4], want) {
+		t.Errorf("Clean Code = %q, want %q", records[4], want)
+	}
+	if records[5][1] != "Laskar Pelangi" || records[5][8] != "Belitung; Persahabatan" || records[5][13] != "PUSAT 3/3; TIMUR 2/2" {
+		t.Errorf("Laskar Pelangi = %q", records[5])
+	}
+
+	// A filtered export in XLSX
+	buf.Reset()
+	if err := s.ExportCatalog(&buf, ExportXLSX, models.BookFilter{Search: "bumi", CategoryID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := xlsx.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range rows[1:] {
+		got = append(got, row[1])
+	}
+	if want := []string{"Bumi", "Bumi Manusia", "Bulan"}; !slices.Equal(got, want) {
+		t.Errorf("exported %v, want %v", got, want)
+	}
+
+	if err := s.ExportCatalog(&buf, "pdf", models.BookFilter{}); err == nil {
+		t.Error("export as PDF succeeded")
+	}
+}
+
+// An exported catalog imports again with its columns recognized.
+func TestExportImportRoundTrip(t *testing.T) {
+	s := newTestService(t)
+
+	var buf bytes.Buffer
+	if err := s.ExportCatalog(&buf, ExportXLSX, models.BookFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := ReadSheet("katalog.xlsx", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := GuessMapping(sheet.Header)
+	if len(mapping) != len(ImportFields) {
+		t.Errorf("mapping = %v, want every field", mapping)
+	}
+	result, err := s.PreviewImport(sheet, ImportOptions{Mapping: mapping, BranchID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every book is in the catalog already; only Clean Code has a valid
+	// ISBN, the seeded ones are made up.
+	if result.Valid != 0 || result.Invalid != 5 {
+		t.Errorf("valid, invalid = %d, %d, want 0, 5", result.Valid, result.Invalid)
+	}
+}
@@ -0,0 +1,204 @@
+package books
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"simpus/internal/barcode"
+	"simpus/internal/models"
+	"simpus/internal/pdf"
+)
+
+// Kinds of label
+const (
+	LabelSpine   = "spine"   // the call number, for the spine
+	LabelBarcode = "barcode" // the book's barcode with its title and call number
+)
+
+// LabelLayout is a sheet of self-adhesive labels, measured in millimetres
+// from the top-left corner of the page.
+type LabelLayout struct {
+	Code           string
+	Name           string
+	PageWidth      float64
+	PageHeight     float64
+	Columns, Rows  int
+	Left, Top      float64 // corner of the first label
+	Width, Height  float64
+	PitchX, PitchY float64 // from one label to the next
+}
+
+// PerSheet returns the number of labels on a sheet.
+func (l LabelLayout) PerSheet() int {
+	return l.Columns * l.Rows
+}
+
+// LabelLayouts are the label sheets offered, small spine labels first.
+var LabelLayouts = []LabelLayout{
+	{"L7651", "A4, 65 label 38,1 × 21,2 mm (Avery L7651)", 210, 297, 5, 13, 4.75, 10.7, 38.1, 21.2, 40.64, 21.2},
+	{"L7160", "A4, 21 label 63,5 × 38,1 mm (Avery L7160)", 210, 297, 3, 7, 7.21, 15.15, 63.5, 38.1, 66.04, 38.1},
+	{"5167", "Letter, 80 label 44,5 × 12,7 mm (Avery 5167)", 215.9, 279.4, 4, 20, 7.62, 12.7, 44.45, 12.7, 52.07, 12.7},
+	{"5160", "Letter, 30 label 66,7 × 25,4 mm (Avery 5160)", 215.9, 279.4, 3, 10, 4.76, 12.7, 66.68, 25.4, 69.85, 25.4},
+}
+
+// LabelOptions choose what PrintLabels prints and where.
+type LabelOptions struct {
+	Layout  string // Code of one of LabelLayouts
+	Kind    string
+	Start   int  // position of the first label on the first sheet, from 1, to use up a partly used sheet
+	PerCopy bool // a label for every copy rather than one per book
+	Outline bool // draw the edge of each label, for a test print on plain paper
+}
+
+// MaxLabelBooks caps the books of one label printout.
+const MaxLabelBooks = 500
+
+// labelMargin is kept blank inside the edge of each label, in millimetres.
+const labelMargin = 1.5
+
+// PrintLabels writes a PDF of labels for the books with the IDs, in the
+// order given, filling each sheet row by row.
+func (s *Service) PrintLabels(w io.Writer, ids []int, opts LabelOptions) error {
+	var layout *LabelLayout
+	for i := range LabelLayouts {
+		if LabelLayouts[i].Code == opts.Layout {
+			layout = &LabelLayouts[i]
+		}
+	}
+	if layout == nil {
+		return fmt.Errorf("lembar label %q tidak dikenal", opts.Layout)
+	}
+	var draw func(*pdf.Page, labelBox, models.Book) error
+	switch opts.Kind {
+	case LabelSpine:
+		draw = spineLabel
+	case LabelBarcode:
+		draw = barcodeLabel
+	default:
+		return fmt.Errorf("jenis label %q tidak dikenal", opts.Kind)
+	}
+	if opts.Start == 0 {
+		opts.Start = 1
+	}
+	if opts.Start < 1 || opts.Start > layout.PerSheet() {
+		return fmt.Errorf("posisi label pertama harus antara 1 dan %d", layout.PerSheet())
+	}
+	if len(ids) == 0 {
+		return errors.New("pilih buku yang labelnya akan dicetak")
+	}
+	if len(ids) > MaxLabelBooks {
+		return fmt.Errorf("label dapat dicetak untuk paling banyak %d buku sekaligus", MaxLabelBooks)
+	}
+
+	books, _, err := s.GetBooks(models.BookFilter{IDs: ids, Page: 1, Limit: len(ids)})
+	if err != nil {
+		return err
+	}
+	if len(books) == 0 {
+		return errors.New("buku tidak ditemukan")
+	}
+
+	doc := pdf.New(layout.PageWidth*pdf.MM, layout.PageHeight*pdf.MM)
+	var page *pdf.Page
+	slot := opts.Start - 1
+	for _, b := range books {
+		copies := 1
+		if opts.PerCopy {
+			copies = max(b.Stock, 1)
+		}
+		for range copies {
+			if slot == layout.PerSheet() {
+				page, slot = nil, 0
+			}
+			if page == nil {
+				page = doc.AddPage()
+			}
+			col, row := slot%layout.Columns, slot/layout.Columns
+			box := labelBox{
+				x:      (layout.Left + float64(col)*layout.PitchX) * pdf.MM,
+				y:      (layout.PageHeight - layout.Top - float64(row)*layout.PitchY - layout.Height) * pdf.MM,
+				width:  layout.Width * pdf.MM,
+				height: layout.Height * pdf.MM,
+			}
+			if opts.Outline {
+				page.StrokeRect(box.x, box.y, box.width, box.height, 0.25)
+			}
+			if err := draw(page, box.inset(labelMargin*pdf.MM), b); err != nil {
+				return err
+			}
+			slot++
+		}
+	}
+	_, err = doc.WriteTo(w)
+	return err
+}
+
+// labelBox is the area of a label on the page, in points from the
+// bottom-left corner.
+type labelBox struct {
+	x, y, width, height float64
+}
+
+func (b labelBox) inset(d float64) labelBox {
+	return labelBox{b.x + d, b.y + d, b.width - 2*d, b.height - 2*d}
+}
+
+// centered writes a line of text centred across the box.
+func (b labelBox) centered(p *pdf.Page, y float64, font pdf.Font, size float64, s string) {
+	p.Text(b.x+(b.width-pdf.Width(font, size, s))/2, y, font, size, s)
+}
+
+// spineLabel prints the call number one part per line, as large as the
+// label allows.
+func spineLabel(p *pdf.Page, box labelBox, b models.Book) error {
+	lines := b.CallNumberLines()
+	size := math.Min(14, box.height/(1.15*float64(len(lines))))
+	for _, line := range lines {
+		if w := pdf.Width(pdf.HelveticaBold, 1, line); w > 0 {
+			size = math.Min(size, box.width/w)
+		}
+	}
+	leading := size * 1.15
+	// Baselines sit about a fifth of the size above the bottom of a line
+	y := box.y + (box.height+leading*float64(len(lines)))/2 - leading + size*0.2
+	for _, line := range lines {
+		box.centered(p, y, pdf.HelveticaBold, size, line)
+		y -= leading
+	}
+	return nil
+}
+
+// barcodeLabel prints the title, the Code 128 barcode of the book and,
+// below it, the code as text and the call number.
+func barcodeLabel(p *pdf.Page, box labelBox, b models.Book) error {
+	code := b.Barcode()
+	widths, err := barcode.Code128(code)
+	if err != nil {
+		return err
+	}
+	modules := 2 * barcode.QuietZone
+	for _, w := range widths {
+		modules += w
+	}
+	module := math.Min(box.width/float64(modules), 0.5*pdf.MM)
+
+	text := math.Max(4.5, math.Min(8, box.height*0.12))
+	p.Text(box.x, box.y+box.height-text, pdf.Helvetica, text, pdf.Fit(pdf.Helvetica, text, box.width, b.Title))
+	bottom := box.y + text*1.3
+	barsHeight := box.height - 2*text*1.3
+
+	x := box.x + (box.width-module*float64(modules))/2 + barcode.QuietZone*module
+	for i, w := range widths {
+		if i%2 == 0 {
+			p.Rect(x, bottom, float64(w)*module, barsHeight)
+		}
+		x += float64(w) * module
+	}
+
+	p.Text(box.x, box.y, pdf.HelveticaBold, text, code)
+	call := pdf.Fit(pdf.Helvetica, text, box.width-pdf.Width(pdf.HelveticaBold, text, code+"  "), b.CallNumber())
+	p.Text(box.x+box.width-pdf.Width(pdf.Helvetica, text, call), box.y, pdf.Helvetica, text, call)
+	return nil
+}
@@ -0,0 +1,59 @@
+package books
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintLabels(t *testing.T) {
+	s := newTestService(t)
+
+	// A copy of each of the five books, 17 labels, from the tenth label of
+	// a sheet of 21 fill two sheets
+	var buf bytes.Buffer
+	err := s.PrintLabels(&buf, []int{1, 2, 3, 4, 5}, LabelOptions{Layout: "L7160", Kind: LabelSpine, Start: 10, PerCopy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("not a PDF document")
+	}
+	if !strings.Contains(pdf, "/Count 2 ") {
+		t.Error("want 2 pages")
+	}
+	if n := strings.Count(pdf, "(HIR) Tj"); n != 5 {
+		t.Errorf("%d labels for the 5 copies of Laskar Pelangi", n)
+	}
+	if !strings.Contains(pdf, "(899.2213) Tj") || !strings.Contains(pdf, "(005.1) Tj") {
+		t.Error("class numbers missing")
+	}
+
+	buf.Reset()
+	if err := s.PrintLabels(&buf, []int{4, 1}, LabelOptions{Layout: "5167", Kind: LabelBarcode, Outline: true}); err != nil {
+		t.Fatal(err)
+	}
+	pdf = buf.String()
+	if !strings.Contains(pdf, "/Count 1 ") || strings.Count(pdf, "re S") != 2 {
+		t.Error("want one page with two outlined labels")
+	}
+	if i, j := strings.Index(pdf, "(B000004) Tj"), strings.Index(pdf, "(B000001) Tj"); i < 0 || j < i {
+		t.Error("barcode labels missing or out of the order given")
+	}
+
+	for name, tt := range map[string]struct {
+		ids  []int
+		opts LabelOptions
+	}{
+		"unknown layout": {[]int{1}, LabelOptions{Layout: "A4", Kind: LabelSpine}},
+		"unknown kind":   {[]int{1}, LabelOptions{Layout: "L7651", Kind: "qr"}},
+		"start past end": {[]int{1}, LabelOptions{Layout: "L7160", Kind: LabelSpine, Start: 22}},
+		"no books":       {nil, LabelOptions{Layout: "L7160", Kind: LabelSpine}},
+		"unknown books":  {[]int{99}, LabelOptions{Layout: "L7160", Kind: LabelSpine}},
+	} {
+		if err := s.PrintLabels(&bytes.Buffer{}, tt.ids, tt.opts); err == nil {
+			t.Errorf("%s: PrintLabels succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package books
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"simpus/internal/isbn"
+	"simpus/internal/models"
+)
+
+// MetadataProvider looks up editions by ISBN in an external catalog such
+// as Open Library.
+type MetadataProvider interface {
+	Name() string
+	// Lookup returns ErrISBNNotFound for an ISBN the provider does not know.
+	Lookup(ctx context.Context, isbn13 string) (*models.BookMetadata, error)
+	// Cover downloads the image at the CoverURL of a lookup.
+	Cover(ctx context.Context, url string) ([]byte, error)
+}
+
+var (
+	ErrLookupDisabled    = errors.New("pencarian data buku berdasarkan ISBN tidak aktif")
+	ErrISBNNotFound      = errors.New("data buku dengan ISBN ini tidak ditemukan, silakan isi secara manual")
+	ErrLookupUnavailable = errors.New("layanan data buku sedang tidak dapat dihubungi, silakan isi secara manual")
+)
+
+// An ISBN the provider does not know is asked again after notFoundTTL, as
+// new titles reach it within days.
+const notFoundTTL = 24 * time.Hour
+
+type lookup struct {
+	provider MetadataProvider
+	cache    LookupCache
+	ttl      time.Duration
+}
+
+// UseLookup enables ISBN lookups at provider. Answers are cached for ttl,
+// and covers are saved like uploaded ones when covers are enabled.
+func (s *Service) UseLookup(provider MetadataProvider, cache LookupCache, ttl time.Duration) {
+	s.lookup = &lookup{provider: provider, cache: cache, ttl: ttl}
+}
+
+func (s *Service) LookupEnabled() bool {
+	return s.lookup != nil
+}
+
+// ISBNLookup is a new book filled in from the metadata of its ISBN. The
+// authors and a subject naming a category are matched against the
+// library's own lists.
+type ISBNLookup struct {
+	Book       models.BookCreate
+	ISBN10     string   // "" for ISBN-13s starting with 979
+	NewAuthors []string // authors as the provider names them that are not in the library yet
+	Subjects   []string
+	Existing   bool // the catalog has a book with this ISBN already
+	Source     string
+	Cached     bool
+}
+
+// LookupISBN checks an ISBN-10 or ISBN-13 and fills in a new book from
+// what the metadata provider knows of it, asking the provider only when
+// the cache has no fresh answer.
+func (s *Service) LookupISBN(ctx context.Context, raw string) (*ISBNLookup, error) {
+	if s.lookup == nil {
+		return nil, ErrLookupDisabled
+	}
+	n := isbn.Normalize(raw)
+	if len(n) != 10 && len(n) != 13 {
+		return nil, fmt.Errorf("ISBN %s harus terdiri dari 10 atau 13 digit", raw)
+	}
+	isbn13 := isbn.To13(n)
+	if isbn13 == "" {
+		return nil, fmt.Errorf("digit pemeriksa ISBN %s salah", raw)
+	}
+
+	entry, cached, err := s.fetchLookup(ctx, isbn13)
+	if err != nil {
+		return nil, err
+	}
+	m := entry.Metadata
+	if m == nil {
+		return nil, ErrISBNNotFound
+	}
+
+	result := &ISBNLookup{
+		ISBN10:   isbn.To10(isbn13),
+		Subjects: m.Subjects,
+		Source:   s.lookup.provider.Name(),
+		Cached:   cached,
+	}
+	b := &result.Book
+	b.ISBN = isbn13
+	b.Title = m.Title
+	b.Publisher = m.Publisher
+	b.PublishYear = m.PublishYear
+	b.Description = m.Description
+	if languageCode.MatchString(m.Language) {
+		b.Language = m.Language
+	}
+	if entry.Cover != "" && s.covers != nil {
+		if ok, err := s.covers.Exists(ctx, entry.Cover); err == nil && ok {
+			b.CoverImage = entry.Cover
+		}
+	}
+
+	if len(m.Authors) > 0 {
+		authors, err := s.authorRepo.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[string]int, len(authors))
+		for _, a := range authors {
+			ids[nameKey(a.Name)] = a.ID
+		}
+		for _, name := range m.Authors {
+			id, ok := ids[nameKey(name)]
+			if !ok {
+				result.NewAuthors = append(result.NewAuthors, name)
+				continue
+			}
+			b.Contributors = append(b.Contributors, models.Contributor{AuthorID: id, Name: name, Role: models.RoleAuthor})
+		}
+	}
+
+	categories, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, subject := range m.Subjects {
+		category := false
+		for _, c := range categories {
+			if nameKey(c.Name) == nameKey(subject) {
+				category = category || b.CategoryID == 0 || b.CategoryID == c.ID
+				if b.CategoryID == 0 {
+					b.CategoryID = c.ID
+				}
+			}
+		}
+		// The others are kept as subject tags, as many as a book takes
+		if !category && len(b.Subjects) < maxSubjects && utf8.RuneCountInString(subject) <= 100 {
+			b.Subjects = append(b.Subjects, subject)
+		}
+	}
+
+	isbns, err := s.bookRepo.FindISBNs()
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range isbns {
+		result.Existing = result.Existing || isbn.To13(existing) == isbn13
+	}
+	return result, nil
+}
+
+// fetchLookup returns the cache entry of an ISBN, asking the provider when
+// there is none or it is stale. A stale entry is still used while the
+// provider cannot be reached, and a cache that fails only costs a lookup.
+func (s *Service) fetchLookup(ctx context.Context, isbn13 string) (entry *LookupEntry, cached bool, err error) {
+	l := s.lookup
+	entry, err = l.cache.Find(isbn13)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.ErrorContext(ctx, "books: read isbn lookup cache", "isbn", isbn13, "error", err)
+	}
+	if entry != nil && l.fresh(entry) {
+		return entry, true, nil
+	}
+
+	m, err := l.provider.Lookup(ctx, isbn13)
+	if err != nil && !errors.Is(err, ErrISBNNotFound) {
+		slog.WarnContext(ctx, "books: isbn lookup", "provider", l.provider.Name(), "isbn", isbn13, "error", err)
+		if entry != nil {
+			return entry, true, nil
+		}
+		return nil, false, ErrLookupUnavailable
+	}
+
+	entry = &LookupEntry{ISBN: isbn13, Metadata: m, FetchedAt: time.Now()}
+	if m != nil && m.CoverURL != "" && s.covers != nil {
+		if entry.Cover, err = s.downloadCover(ctx, m.CoverURL); err != nil {
+			slog.WarnContext(ctx, "books: save cover", "isbn", isbn13, "url", m.CoverURL, "error", err)
+		}
+	}
+	if err := l.cache.Save(entry); err != nil {
+		slog.ErrorContext(ctx, "books: cache isbn lookup", "isbn", isbn13, "error", err)
+	}
+	return entry, false, nil
+}
+
+func (l *lookup) fresh(e *LookupEntry) bool {
+	ttl := l.ttl
+	if e.Metadata == nil {
+		ttl = min(ttl, notFoundTTL)
+	}
+	return time.Since(e.FetchedAt) < ttl
+}
+
+// downloadCover saves the cover at a lookup's CoverURL.
+func (s *Service) downloadCover(ctx context.Context, url string) (string, error) {
+	data, err := s.lookup.provider.Cover(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	return s.SaveCover(ctx, data)
+}
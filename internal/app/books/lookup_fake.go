@@ -0,0 +1,114 @@
+package books
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+
+	"simpus/internal/models"
+)
+
+// FakeProvider answers ISBN lookups from a fixed list of books without
+// network access, for development and tests. Its covers are plain
+// generated images.
+type FakeProvider struct {
+	// Err, when set, is returned by every lookup, as from a provider that
+	// cannot be reached.
+	Err error
+
+	mu      sync.Mutex
+	books   map[string]models.BookMetadata
+	lookups int
+}
+
+// NewFakeProvider returns a provider knowing a few Indonesian and English
+// titles.
+func NewFakeProvider() *FakeProvider {
+	p := &FakeProvider{books: map[string]models.BookMetadata{}}
+	for _, m := range []models.BookMetadata{
+		{
+			ISBN: "9789793062792", Title: "Laskar Pelangi", Authors: []string{"Andrea Hirata"},
+			Publisher: "Bentang Pustaka", PublishYear: 2005, Language: "id",
+			Description: "Kisah sepuluh anak Belitung yang bersekolah di SD Muhammadiyah.",
+			Subjects:    []string{"Fiksi", "Pendidikan"},
+		},
+		{
+			ISBN: "9789799731234", Title: "Bumi Manusia", Authors: []string{"Pramoedya Ananta Toer"},
+			Publisher: "Lentera Dipantara", PublishYear: 2005, Language: "id",
+			Subjects: []string{"Fiksi sejarah", "Sejarah"},
+		},
+		{
+			ISBN: "9786020332956", Title: "Bumi", Authors: []string{"Tere Liye"},
+			Publisher: "Gramedia Pustaka Utama", PublishYear: 2014, Language: "id",
+			Subjects: []string{"Fiksi"},
+		},
+		{
+			ISBN: "9780132350884", Title: "Clean Code: A Handbook of Agile Software Craftsmanship",
+			Authors: []string{"Robert C. Martin"}, Publisher: "Prentice Hall", PublishYear: 2008, Language: "en",
+			Subjects: []string{"Agile software development", "Computer software -- Reliability"},
+		},
+		{
+			ISBN: "9780201485677", Title: "Refactoring: Improving the Design of Existing Code",
+			Authors: []string{"Martin Fowler"}, Publisher: "Addison-Wesley", PublishYear: 1999, Language: "en",
+			Subjects: []string{"Teknologi", "Software refactoring"},
+		},
+	} {
+		m.CoverURL = "fake:cover/" + m.ISBN
+		p.Add(m)
+	}
+	return p
+}
+
+// Add makes the provider know a book.
+func (p *FakeProvider) Add(m models.BookMetadata) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.books[m.ISBN] = m
+}
+
+// Lookups returns how many lookups reached the provider.
+func (p *FakeProvider) Lookups() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lookups
+}
+
+func (p *FakeProvider) Name() string {
+	return "contoh offline"
+}
+
+func (p *FakeProvider) Lookup(ctx context.Context, isbn13 string) (*models.BookMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lookups++
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	m, ok := p.books[isbn13]
+	if !ok {
+		return nil, ErrISBNNotFound
+	}
+	return &m, nil
+}
+
+// Cover draws a 200x300 cover in a colour derived from url.
+func (p *FakeProvider) Cover(ctx context.Context, url string) ([]byte, error) {
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	sum := h.Sum32()
+	fill := color.RGBA{R: uint8(sum), G: uint8(sum >> 8), B: uint8(sum >> 16), A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 300))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package books
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"simpus/internal/models"
+)
+
+// LookupEntry is a cached answer of the metadata provider. Metadata is nil
+// when the provider did not know the ISBN.
+type LookupEntry struct {
+	ISBN      string
+	Metadata  *models.BookMetadata
+	Cover     string // saved cover, relative to the upload directory
+	FetchedAt time.Time
+}
+
+// LookupCache stores ISBN lookups so that each ISBN is asked once.
+type LookupCache interface {
+	// Find returns sql.ErrNoRows for an ISBN never looked up.
+	Find(isbn string) (*LookupEntry, error)
+	Save(e *LookupEntry) error
+}
+
+type lookupCache struct {
+	db *sql.DB
+}
+
+func NewLookupCache(db *sql.DB) LookupCache {
+	return &lookupCache{db: db}
+}
+
+func (r *lookupCache) Find(isbn string) (*LookupEntry, error) {
+	e := &LookupEntry{ISBN: isbn}
+	var found bool
+	var metadata, cover sql.NullString
+	err := r.db.QueryRow(`SELECT found, metadata, cover, fetched_at FROM isbn_lookups WHERE isbn = ?`, isbn).
+		Scan(&found, &metadata, &cover, &e.FetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		e.Metadata = &models.BookMetadata{}
+		if err := json.Unmarshal([]byte(metadata.String), e.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	e.Cover = cover.String
+	return e, nil
+}
+
+// Save replaces the entry of the ISBN.
+func (r *lookupCache) Save(e *LookupEntry) error {
+	var metadata, cover sql.NullString
+	if e.Metadata != nil {
+		data, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = sql.NullString{String: string(data), Valid: true}
+	}
+	if e.Cover != "" {
+		cover = sql.NullString{String: e.Cover, Valid: true}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM isbn_lookups WHERE isbn = ?`, e.ISBN); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO isbn_lookups (isbn, found, metadata, cover, fetched_at) VALUES (?, ?, ?, ?, ?)`,
+		e.ISBN, e.Metadata != nil, metadata, cover, e.FetchedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
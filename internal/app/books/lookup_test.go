@@ -0,0 +1,181 @@
+package books
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+	"simpus/internal/search"
+	"simpus/internal/storage"
+)
+
+func newLookupService(t *testing.T, ttl time.Duration) (*Service, *FakeProvider, string) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	s := NewService(NewBookRepository(db), NewCategoryRepository(db), NewAuthorRepository(db), NewWorkRepository(db), search.NewMemory())
+	provider := NewFakeProvider()
+	dir := t.TempDir()
+	s.UseCovers(storage.NewLocal(dir))
+	s.UseLookup(provider, NewLookupCache(db), ttl)
+	return s, provider, dir
+}
+
+func TestLookupISBN(t *testing.T) {
+	s, provider, dir := newLookupService(t, time.Hour)
+	ctx := context.Background()
+
+	// Clean Code by its ISBN-10: in the catalog already, author known
+	got, err := s.LookupISBN(ctx, "0-13-235088-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := got.Book
+	if b.ISBN != "9780132350884" || got.ISBN10 != "0132350882" || b.Title != "Clean Code: A Handbook of Agile Software Craftsmanship" ||
+		len(b.Contributors) != 1 || b.Contributors[0].AuthorID != 5 || got.NewAuthors != nil || b.Language != "en" || b.PublishYear != 2008 || !got.Existing || got.Cached {
+		t.Errorf("lookup = %+v", got)
+	}
+	if !strings.HasPrefix(b.CoverImage, "covers/") || !strings.HasSuffix(b.CoverImage, ".png") {
+		t.Errorf("cover = %q", b.CoverImage)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(models.CoverThumbnail(b.CoverImage, "small")))); err != nil {
+		t.Errorf("cover not saved: %v", err)
+	}
+
+	// Asked again, from the cache
+	again, err := s.LookupISBN(ctx, "978-0-13-235088-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Cached || !reflect.DeepEqual(again.Book, b) || provider.Lookups() != 1 {
+		t.Errorf("second lookup = %+v after %d provider lookups, want the cached one", again, provider.Lookups())
+	}
+
+	// A subject naming a category picks it; an unknown author is reported
+	got, err = s.LookupISBN(ctx, "9780201485677")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Book.CategoryID != 4 || got.Book.Contributors != nil || !slices.Equal(got.NewAuthors, []string{"Martin Fowler"}) || got.Existing {
+		t.Errorf("Refactoring = %+v", got)
+	}
+	got, err = s.LookupISBN(ctx, "9789793062792")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Book.CategoryID != 1 || len(got.Book.Contributors) != 1 || got.Book.Contributors[0].AuthorID != 1 || got.Book.Language != "id" {
+		t.Errorf("Laskar Pelangi = %+v", got)
+	}
+}
+
+func TestLookupISBNNotFound(t *testing.T) {
+	s, provider, _ := newLookupService(t, time.Hour)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := s.LookupISBN(ctx, "978-0-306-40615-7"); err != ErrISBNNotFound {
+			t.Errorf("lookup of an unknown ISBN = %v, want ErrISBNNotFound", err)
+		}
+	}
+	if provider.Lookups() != 1 {
+		t.Errorf("%d provider lookups, want the unknown ISBN remembered", provider.Lookups())
+	}
+
+	for _, bad := range []string{"978-0-13-235088-5", "12345", ""} {
+		if _, err := s.LookupISBN(ctx, bad); err == nil {
+			t.Errorf("lookup of %q succeeded", bad)
+		}
+	}
+	if provider.Lookups() != 1 {
+		t.Errorf("invalid ISBNs reached the provider")
+	}
+}
+
+// A provider that cannot be reached falls back on stale answers.
+func TestLookupISBNProviderDown(t *testing.T) {
+	s, provider, _ := newLookupService(t, 0)
+	ctx := context.Background()
+
+	if _, err := s.LookupISBN(ctx, "9789793062792"); err != nil {
+		t.Fatal(err)
+	}
+	provider.Err = errors.New("connection refused")
+
+	got, err := s.LookupISBN(ctx, "9789793062792")
+	if err != nil || !got.Cached || got.Book.Title != "Laskar Pelangi" {
+		t.Errorf("lookup while down = %+v, %v, want the stale answer", got, err)
+	}
+	if _, err := s.LookupISBN(ctx, "9786020332956"); err != ErrLookupUnavailable {
+		t.Errorf("lookup of an uncached ISBN while down = %v, want ErrLookupUnavailable", err)
+	}
+}
+
+func TestLookupISBNDisabled(t *testing.T) {
+	s := newTestService(t)
+	if s.LookupEnabled() {
+		t.Error("lookup enabled without a provider")
+	}
+	if _, err := s.LookupISBN(context.Background(), "9780132350884"); err != ErrLookupDisabled {
+		t.Errorf("LookupISBN = %v, want ErrLookupDisabled", err)
+	}
+}
+
+func TestCreateBookCover(t *testing.T) {
+	s := newTestService(t)
+
+	for _, cover := range []string{"../simpus.db", "/etc/passwd", "covers/../../x.png"} {
+		if _, err := s.CreateBook(&models.BookCreate{Title: "Sampul", CoverImage: cover}); err == nil {
+			t.Errorf("CreateBook with cover %q succeeded", cover)
+		}
+	}
+	id, err := s.CreateBook(&models.BookCreate{Title: "Sampul", CoverImage: "covers/9780132350884.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.GetBook(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.CoverImage != "covers/9780132350884.png" {
+		t.Errorf("cover = %q", b.CoverImage)
+	}
+}
+
+func TestLookupCache(t *testing.T) {
+	cache := NewLookupCache(sqlitetest.Open(t))
+	m := &models.BookMetadata{ISBN: "9780132350884", Title: "Clean Code", Authors: []string{"Robert C. Martin"}}
+	fetched := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := cache.Save(&LookupEntry{ISBN: m.ISBN, Metadata: m, Cover: "covers/x.jpg", FetchedAt: fetched}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(&LookupEntry{ISBN: "9780306406157", FetchedAt: fetched}); err != nil {
+		t.Fatal(err)
+	}
+	// Saving again replaces the entry
+	if err := cache.Save(&LookupEntry{ISBN: m.ISBN, Metadata: m, Cover: "covers/y.jpg", FetchedAt: fetched}); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := cache.Find(m.ISBN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Metadata == nil || e.Metadata.Title != "Clean Code" || !slices.Equal(e.Metadata.Authors, m.Authors) ||
+		e.Cover != "covers/y.jpg" || !e.FetchedAt.Equal(fetched) {
+		t.Errorf("Find = %+v", e)
+	}
+	if e, err := cache.Find("9780306406157"); err != nil || e.Metadata != nil {
+		t.Errorf("Find of a not found ISBN = %+v, %v", e, err)
+	}
+	if _, err := cache.Find("9789793062792"); err == nil {
+		t.Error("Find of an ISBN never looked up succeeded")
+	}
+}
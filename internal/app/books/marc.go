@@ -0,0 +1,339 @@
+package books
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"simpus/internal/isbn"
+	"simpus/internal/marc"
+	"simpus/internal/models"
+)
+
+// marcColumns are the columns of the sheet made of MARC records, named
+// like the fields so that GuessMapping maps all of them. Records carry no
+// stock; copies are added per branch after the import.
+var marcColumns = []string{
+	FieldISBN, FieldTitle, FieldAuthor, FieldCategory, FieldPublisher,
+	FieldPublishYear, FieldLanguage, FieldDescription, FieldSubjects, FieldDDC, FieldCutter,
+}
+
+// marcLanguages maps the MARC codes of 008/35-37 and 041 to ISO 639-1.
+var marcLanguages = map[string]string{
+	"ind": "id", "eng": "en", "ara": "ar", "may": "ms", "jav": "jv", "sun": "su",
+	"dut": "nl", "ger": "de", "fre": "fr", "jpn": "ja", "chi": "zh",
+	"spa": "es", "por": "pt", "ita": "it", "rus": "ru", "kor": "ko", "tha": "th",
+}
+
+var yearPattern = regexp.MustCompile(`[0-9]{4}`)
+
+// readMARC reads ISO 2709 records, or MARCXML with asXML, into the rows of
+// a sheet, the first row being marcColumns.
+func readMARC(data []byte, asXML bool) ([][]string, error) {
+	var read func() (*marc.Record, error)
+	if asXML {
+		read = marc.NewXMLReader(bytes.NewReader(data)).Read
+	} else {
+		read = marc.NewReader(bytes.NewReader(data)).Read
+	}
+
+	rows := [][]string{append([]string(nil), marcColumns...)}
+	for len(rows) <= MaxImportRows+1 {
+		rec, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rekaman MARC ke-%d: %w", len(rows), err)
+		}
+		rows = append(rows, recordRow(rec))
+	}
+	if len(rows) == 1 {
+		return nil, errors.New("berkas tidak berisi rekaman MARC")
+	}
+	return rows, nil
+}
+
+// recordRow reads a bibliographic record in the order of marcColumns.
+func recordRow(rec *marc.Record) []string {
+	var publisher, year string
+	for _, f := range append(rec.All("264"), rec.All("260")...) {
+		// 264 with the second indicator 1 is the publication; the others
+		// are production, distribution, manufacture or copyright
+		if f.Tag == "264" && f.Ind2 != '1' {
+			continue
+		}
+		publisher = trimISBD(f.Subfield('b'))
+		year = yearPattern.FindString(f.Subfield('c'))
+		break
+	}
+	fixed := rec.Control("008")
+	if year == "" && len(fixed) >= 11 && yearPattern.MatchString(fixed[7:11]) {
+		year = fixed[7:11]
+	}
+
+	language := ""
+	if len(fixed) >= 38 {
+		language = strings.TrimSpace(fixed[35:38])
+	}
+	if language == "" || language == "|||" {
+		language = rec.Subfield("041", 'a')
+	}
+
+	// The first topical term is the category; the others and the
+	// uncontrolled index terms of 653 are subjects
+	var category string
+	var subjects []string
+	for i, f := range append(rec.All("650"), rec.All("653")...) {
+		term := trimISBD(f.Subfield('a'))
+		if i == 0 && f.Tag == "650" {
+			category = term
+		} else if term != "" {
+			subjects = append(subjects, term)
+		}
+	}
+
+	var summaries []string
+	for _, f := range rec.All("520") {
+		if s := f.Subfield('a'); s != "" {
+			summaries = append(summaries, s)
+		}
+	}
+
+	return []string{
+		recordISBN(rec),
+		recordTitle(rec),
+		formatContributors(recordContributors(rec)),
+		category,
+		publisher,
+		year,
+		fromMARCLanguage(language),
+		strings.Join(summaries, "\n\n"),
+		strings.Join(subjects, "; "),
+		rec.Subfield("082", 'a'),
+		recordCutter(rec),
+	}
+}
+
+// recordCutter returns the author mark of the item number in 082 $b,
+// which may go on with the title mark: "HIR l".
+func recordCutter(rec *marc.Record) string {
+	if fields := strings.Fields(rec.Subfield("082", 'b')); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// recordISBN returns the first valid ISBN of the 020 fields, whose $a may
+// carry a qualifier: "9786020332956 (pbk.)". With none valid, the first is
+// returned for the import to report.
+func recordISBN(rec *marc.Record) string {
+	var first string
+	for _, f := range rec.All("020") {
+		fields := strings.Fields(f.Subfield('a'))
+		if len(fields) == 0 {
+			continue
+		}
+		if isbn.Valid(isbn.Normalize(fields[0])) {
+			return fields[0]
+		}
+		if first == "" {
+			first = fields[0]
+		}
+	}
+	return first
+}
+
+// recordTitle joins the title proper and the remainder of the title.
+func recordTitle(rec *marc.Record) string {
+	fields := rec.All("245")
+	if len(fields) == 0 {
+		return ""
+	}
+	title := trimISBD(fields[0].Subfield('a'))
+	if rest := trimISBD(fields[0].Subfield('b')); rest != "" {
+		title += ": " + rest
+	}
+	return title
+}
+
+// marcRelators maps the relator codes ($4) and terms ($e) of name entries
+// to contributor roles. Other relators are read as authors.
+var marcRelators = map[string]string{
+	"aut": models.RoleAuthor, "author": models.RoleAuthor,
+	"edt": models.RoleEditor, "editor": models.RoleEditor,
+	"trl": models.RoleTranslator, "translator": models.RoleTranslator,
+	"ill": models.RoleIllustrator, "illustrator": models.RoleIllustrator,
+}
+
+// recordContributors returns the main entry, a person or else an
+// organization, followed by the added entries of persons and
+// organizations, with their roles. A surname entered first is put back in
+// place: "Toer, Pramoedya Ananta" reads "Pramoedya Ananta Toer".
+func recordContributors(rec *marc.Record) []models.Contributor {
+	var fields []marc.Field
+	if main := rec.All("100"); len(main) > 0 {
+		fields = append(fields, main[0])
+	} else if main := rec.All("110"); len(main) > 0 {
+		fields = append(fields, main[0])
+	}
+	fields = append(fields, rec.All("700")...)
+	fields = append(fields, rec.All("710")...)
+
+	var list []models.Contributor
+	for _, f := range fields {
+		name := trimISBD(f.Subfield('a'))
+		if name == "" {
+			continue
+		}
+		if (f.Tag == "100" || f.Tag == "700") && f.Ind1 == '1' {
+			if surname, forenames, ok := strings.Cut(name, ", "); ok {
+				name = forenames + " " + surname
+			}
+		}
+		role := models.RoleAuthor
+		for _, relator := range []string{f.Subfield('4'), trimISBD(f.Subfield('e'))} {
+			if r, ok := marcRelators[strings.ToLower(relator)]; ok {
+				role = r
+				break
+			}
+		}
+		list = append(list, models.Contributor{Name: name, Role: role})
+	}
+	return list
+}
+
+// trimISBD removes the punctuation a cataloguer ends a subfield with
+// before the next one: " /", " :", ",", and a full stop unless it ends an
+// initial ("Martin, Robert C.").
+func trimISBD(s string) string {
+	s = strings.TrimSpace(s)
+	for {
+		trimmed := strings.TrimRight(s, " /:;,=")
+		if strings.HasSuffix(trimmed, ".") && !endsWithInitial(trimmed) {
+			trimmed = strings.TrimSuffix(trimmed, ".")
+		}
+		if trimmed == s {
+			return s
+		}
+		s = trimmed
+	}
+}
+
+func endsWithInitial(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	i := strings.LastIndexAny(s, " .")
+	return utf8.RuneCountInString(s[i+1:]) == 1
+}
+
+func fromMARCLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if iso, ok := marcLanguages[code]; ok {
+		return iso
+	}
+	switch code {
+	case "und", "mul", "zxx":
+		return ""
+	}
+	return code
+}
+
+func toMARCLanguage(code string) string {
+	for marcCode, iso := range marcLanguages {
+		if iso == code {
+			return marcCode
+		}
+	}
+	return "und"
+}
+
+// bookRecord describes a book as a MARC 21 bibliographic record.
+func bookRecord(b models.Book) *marc.Record {
+	sf := func(code byte, value string) marc.Subfield {
+		return marc.Subfield{Code: code, Value: value}
+	}
+
+	year := "    "
+	dateType := "n"
+	if b.PublishYear > 0 {
+		year = fmt.Sprintf("%04d", b.PublishYear)
+		dateType = "s"
+	}
+	// Date entered, type of date and the year, no place of publication,
+	// and the language
+	fixed := b.CreatedAt.Format("060102") + dateType + year + "    " + "xx " +
+		strings.Repeat(" ", 17) + toMARCLanguage(b.Language) + " d"
+
+	rec := marc.NewRecord()
+	rec.AddControl("001", strconv.Itoa(b.ID))
+	rec.AddControl("005", b.UpdatedAt.Format("20060102150405.0"))
+	rec.AddControl("008", fixed)
+	if b.ISBN != "" {
+		rec.AddData("020", ' ', ' ', sf('a', isbn.Normalize(b.ISBN)))
+	}
+	if b.DDC != "" {
+		rec.AddData("082", '0', '4', sf('a', b.DDC), sf('b', b.Cutter))
+	}
+
+	// The first author is the main entry and everybody else an added
+	// entry with the relator of their role
+	var main string
+	var added []models.Contributor
+	for _, c := range b.Contributors {
+		if main == "" && c.Role == models.RoleAuthor {
+			main = c.Name
+			continue
+		}
+		added = append(added, c)
+	}
+	if main != "" {
+		rec.AddData("100", '0', ' ', sf('a', main+","), sf('e', "author."), sf('4', "aut"))
+		rec.AddData("245", '1', '0', sf('a', b.Title+" /"), sf('c', endISBD(b.AuthorNames())))
+	} else {
+		rec.AddData("245", '0', '0', sf('a', endISBD(b.Title)))
+	}
+
+	publisher := b.Publisher
+	var published string
+	if b.PublishYear > 0 {
+		published = strconv.Itoa(b.PublishYear) + "."
+		if publisher != "" {
+			publisher += ","
+		}
+	}
+	rec.AddData("264", ' ', '1', sf('b', publisher), sf('c', published))
+	rec.AddData("520", ' ', ' ', sf('a', b.Description))
+	if b.Category != nil {
+		rec.AddData("650", ' ', '4', sf('a', endISBD(b.Category.Name)))
+	}
+	for _, subject := range b.Subjects {
+		rec.AddData("653", ' ', ' ', sf('a', subject))
+	}
+	for _, c := range added {
+		rec.AddData("700", '0', ' ', sf('a', c.Name+","), sf('e', c.Role+"."), sf('4', marcRelatorCode(c.Role)))
+	}
+	return rec
+}
+
+// marcRelatorCode returns the relator code of a contributor role.
+func marcRelatorCode(role string) string {
+	for code, r := range marcRelators {
+		if len(code) == 3 && r == role {
+			return code
+		}
+	}
+	return "aut"
+}
+
+// endISBD ends the last subfield of a field with a full stop.
+func endISBD(s string) string {
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
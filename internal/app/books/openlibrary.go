@@ -0,0 +1,149 @@
+package books
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"simpus/internal/models"
+)
+
+// OpenLibrary looks up ISBNs with the Books API of Open Library, or of a
+// mirror answering the same JSON at another base URL.
+type OpenLibrary struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenLibrary(baseURL string, timeout time.Duration) *OpenLibrary {
+	return &OpenLibrary{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *OpenLibrary) Name() string {
+	return "Open Library"
+}
+
+// openLibraryBook is an entry of /api/books with jscmd=data.
+type openLibraryBook struct {
+	Title       string `json:"title"`
+	Subtitle    string `json:"subtitle"`
+	PublishDate string `json:"publish_date"`
+	Authors     []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	Subjects []struct {
+		Name string `json:"name"`
+	} `json:"subjects"`
+	Languages []struct {
+		Key string `json:"key"` // "/languages/ind"
+	} `json:"languages"`
+	Notes json.RawMessage `json:"notes"` // a string or {"type": ..., "value": ...}
+	Cover struct {
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+}
+
+func (o *OpenLibrary) Lookup(ctx context.Context, isbn13 string) (*models.BookMetadata, error) {
+	q := url.Values{"bibkeys": {"ISBN:" + isbn13}, "format": {"json"}, "jscmd": {"data"}}
+	data, err := o.get(ctx, o.baseURL+"/api/books?"+q.Encode(), 1<<20)
+	if err != nil {
+		return nil, err
+	}
+
+	var books map[string]openLibraryBook
+	if err := json.Unmarshal(data, &books); err != nil {
+		return nil, fmt.Errorf("open library: %w", err)
+	}
+	b, ok := books["ISBN:"+isbn13]
+	if !ok {
+		return nil, ErrISBNNotFound
+	}
+
+	m := &models.BookMetadata{
+		ISBN:        isbn13,
+		Title:       b.Title,
+		PublishYear: publishYear(b.PublishDate),
+		Description: noteText(b.Notes),
+		CoverURL:    b.Cover.Large,
+	}
+	if b.Subtitle != "" {
+		m.Title += ": " + b.Subtitle
+	}
+	if m.CoverURL == "" {
+		m.CoverURL = b.Cover.Medium
+	}
+	for _, a := range b.Authors {
+		m.Authors = append(m.Authors, a.Name)
+	}
+	if len(b.Publishers) > 0 {
+		m.Publisher = b.Publishers[0].Name
+	}
+	for _, s := range b.Subjects {
+		m.Subjects = append(m.Subjects, s.Name)
+	}
+	if len(b.Languages) > 0 {
+		m.Language = fromMARCLanguage(strings.TrimPrefix(b.Languages[0].Key, "/languages/"))
+	}
+	return m, nil
+}
+
+func (o *OpenLibrary) Cover(ctx context.Context, url string) ([]byte, error) {
+	return o.get(ctx, url, MaxCoverSize)
+}
+
+// get reads a response body of up to limit bytes.
+func (o *OpenLibrary) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "SIMPUS library catalog")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open library: GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("open library: GET %s: response over %d bytes", url, limit)
+	}
+	return data, nil
+}
+
+// publishYear finds the year in a free-form date: "2008", "Aug 11, 2008",
+// "c2005".
+func publishYear(date string) int {
+	year, _ := strconv.Atoi(yearPattern.FindString(date))
+	return year
+}
+
+func noteText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var text struct {
+		Value string `json:"value"`
+	}
+	json.Unmarshal(raw, &text)
+	return text.Value
+}
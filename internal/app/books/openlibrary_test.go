@@ -0,0 +1,79 @@
+package books
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOpenLibrary(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/books", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("format") != "json" || q.Get("jscmd") != "data" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		switch q.Get("bibkeys") {
+		case "ISBN:9780132350884":
+			w.Write([]byte(`{"ISBN:9780132350884": {
+				"title": "Clean Code",
+				"subtitle": "A Handbook of Agile Software Craftsmanship",
+				"authors": [{"url": "https://openlibrary.org/authors/OL216228A", "name": "Robert C. Martin"}],
+				"publishers": [{"name": "Prentice Hall"}],
+				"publish_date": "Aug 11, 2008",
+				"subjects": [{"name": "Agile software development", "url": "x"}],
+				"languages": [{"key": "/languages/eng"}],
+				"notes": {"type": "/type/text", "value": "Includes index."},
+				"cover": {"small": "S", "medium": "http://` + r.Host + `/covers/m.jpg", "large": "http://` + r.Host + `/covers/l.jpg"}
+			}}`))
+		case "ISBN:9789793062792":
+			w.Write([]byte(`{"ISBN:9789793062792": {"title": "Laskar pelangi", "publish_date": "c2005", "notes": "Novel."}}`))
+		case "ISBN:9780306406157":
+			w.Write([]byte(`{}`))
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	})
+	mux.HandleFunc("/covers/l.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\xff\xd8\xff\xe0 jpeg"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ol := NewOpenLibrary(srv.URL+"/", time.Second)
+	ctx := context.Background()
+
+	m, err := ol.Lookup(ctx, "9780132350884")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Clean Code: A Handbook of Agile Software Craftsmanship" || !slices.Equal(m.Authors, []string{"Robert C. Martin"}) ||
+		m.Publisher != "Prentice Hall" || m.PublishYear != 2008 || m.Language != "en" || m.Description != "Includes index." ||
+		!slices.Equal(m.Subjects, []string{"Agile software development"}) || m.CoverURL != srv.URL+"/covers/l.jpg" {
+		t.Errorf("Lookup = %+v", m)
+	}
+	cover, err := ol.Cover(ctx, m.CoverURL)
+	if err != nil || string(cover) != "\xff\xd8\xff\xe0 jpeg" {
+		t.Errorf("Cover = %q, %v", cover, err)
+	}
+
+	m, err = ol.Lookup(ctx, "9789793062792")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Laskar pelangi" || m.PublishYear != 2005 || m.Description != "Novel." || m.CoverURL != "" {
+		t.Errorf("Lookup = %+v", m)
+	}
+
+	if _, err := ol.Lookup(ctx, "9780306406157"); err != ErrISBNNotFound {
+		t.Errorf("Lookup of an unknown ISBN = %v, want ErrISBNNotFound", err)
+	}
+	if _, err := ol.Lookup(ctx, "9786020332956"); err == nil || errors.Is(err, ErrISBNNotFound) {
+		t.Errorf("Lookup while the API fails = %v, want an error other than not found", err)
+	}
+}
@@ -1,25 +1,304 @@
 package books
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"simpus/internal/models"
+	"simpus/internal/search"
+	"simpus/internal/storage"
 )
 
+// maxSearchHits caps how many ranked books a search hands to the
+// repository; nobody pages past the first thousand results.
+const maxSearchHits = 1000
+
+var languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+
 type Service struct {
-	bookRepo     *BookRepository
-	categoryRepo *CategoryRepository
-	authorRepo   *AuthorRepository
+	bookRepo     BookRepository
+	categoryRepo CategoryRepository
+	authorRepo   AuthorRepository
+	workRepo     WorkRepository
+	index        search.Index
+	lookup       *lookup         // nil when ISBN lookups are disabled
+	covers       storage.Storage // nil when covers cannot be uploaded
 }
 
-func NewService(bookRepo *BookRepository, categoryRepo *CategoryRepository, authorRepo *AuthorRepository) *Service {
+func NewService(bookRepo BookRepository, categoryRepo CategoryRepository, authorRepo AuthorRepository, workRepo WorkRepository, index search.Index) *Service {
 	return &Service{
 		bookRepo:     bookRepo,
 		categoryRepo: categoryRepo,
 		authorRepo:   authorRepo,
+		workRepo:     workRepo,
+		index:        index,
 	}
 }
 
+// GetBooks returns a page of books with the branches that hold them. A
+// search query is ranked by the search index, best match first.
 func (s *Service) GetBooks(filter models.BookFilter) ([]models.Book, int, error) {
-	return s.bookRepo.FindAll(filter)
+	if err := s.resolveSearch(&filter); err != nil {
+		return nil, 0, err
+	}
+	if err := s.resolveCategory(&filter); err != nil {
+		return nil, 0, err
+	}
+
+	books, total, err := s.bookRepo.FindAll(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]int, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+	}
+	stock, err := s.bookRepo.FindStockByBooks(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range books {
+		books[i].Branches = stock[books[i].ID]
+	}
+	return books, total, nil
+}
+
+// SuggestBooks completes a partly typed search into titles, authors and
+// categories for autocomplete.
+func (s *Service) SuggestBooks(prefix string, limit int) ([]string, error) {
+	return s.index.Suggest(prefix, limit)
+}
+
+// RebuildIndex loads every book into the search index, replacing what it
+// held before.
+func (s *Service) RebuildIndex() (int, error) {
+	var docs []search.Document
+	filter := models.BookFilter{Page: 1, Limit: 500}
+	for {
+		books, total, err := s.bookRepo.FindAll(filter)
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range books {
+			docs = append(docs, document(b))
+		}
+		if len(books) == 0 || len(docs) >= total {
+			break
+		}
+		filter.Page++
+	}
+	return len(docs), s.index.Replace(docs)
+}
+
+// reindex brings the index entry of one book up to date after it changed.
+// The change itself is already saved, so failures are only logged; the
+// next rebuild repairs the entry.
+func (s *Service) reindex(id int) {
+	book, err := s.GetBook(id)
+	if err == nil {
+		err = s.index.Put(document(*book))
+	}
+	if err != nil {
+		slog.Error("books: update search index", "book", id, "error", err)
+	}
+}
+
+// rebuild reindexes every book after a category or author changed, as
+// their names are part of each book's entry.
+func (s *Service) rebuild() {
+	if _, err := s.RebuildIndex(); err != nil {
+		slog.Error("books: rebuild search index", "error", err)
+	}
+}
+
+func document(b models.Book) search.Document {
+	doc := search.Document{
+		ID:          b.ID,
+		ISBN:        b.ISBN,
+		Title:       b.Title,
+		Description: b.Description,
+		Publisher:   b.Publisher,
+	}
+	for _, c := range b.Contributors {
+		doc.Authors = append(doc.Authors, c.Name)
+	}
+	doc.Subjects = b.Subjects
+	if b.Category != nil {
+		doc.Category = b.Category.Name
+	}
+	return doc
+}
+
+// GetFacets returns the choices to narrow a catalog listing down by, with
+// the selected ones marked.
+func (s *Service) GetFacets(filter models.BookFilter) (*models.BookFacets, error) {
+	if err := s.resolveSearch(&filter); err != nil {
+		return nil, err
+	}
+	if err := s.resolveCategory(&filter); err != nil {
+		return nil, err
+	}
+	facets, err := s.bookRepo.FindFacets(filter)
+	if err != nil {
+		return nil, err
+	}
+	if facets.Categories, err = s.categoryFacet(facets.Categories, filter.CategoryID); err != nil {
+		return nil, err
+	}
+	facets.Classes = classFacet(facets.Classes, filter.Class)
+
+	mark := func(values []models.FacetValue, selected string) {
+		for i := range values {
+			values[i].Selected = selected != "" && values[i].Value == selected
+		}
+	}
+	mark(facets.Authors, strconv.Itoa(filter.AuthorID))
+	mark(facets.Subjects, filter.Subject)
+	mark(facets.Series, strconv.Itoa(filter.SeriesID))
+	mark(facets.Publishers, filter.Publisher)
+	mark(facets.Languages, filter.Language)
+	for i := range facets.Languages {
+		facets.Languages[i].Label = models.LanguageName(facets.Languages[i].Value)
+	}
+	for i, d := range facets.Decades {
+		from, _ := strconv.Atoi(d.Value)
+		facets.Decades[i].Label = d.Value + "-an"
+		facets.Decades[i].Selected = filter.YearFrom == from && filter.YearTo == from+9
+	}
+	return facets, nil
+}
+
+// categoryFacet rolls the counts of the category facet, made per category,
+// up the tree. Without a category selected it offers the top-level
+// categories; with one, its ancestors, itself and its subcategories.
+func (s *Service) categoryFacet(counts []models.FacetValue, selected int) ([]models.FacetValue, error) {
+	list, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	tree := newCategoryTree(list)
+	direct := make(map[int]int, len(counts))
+	for _, v := range counts {
+		id, _ := strconv.Atoi(v.Value)
+		direct[id] = v.Count
+	}
+	value := func(c models.Category, depth int) models.FacetValue {
+		return models.FacetValue{
+			Value:    strconv.Itoa(c.ID),
+			Label:    c.Name,
+			Count:    tree.bookCount(c.ID, direct),
+			Selected: c.ID == selected,
+			Depth:    depth,
+		}
+	}
+
+	var values []models.FacetValue
+	parent := 0
+	if c, ok := tree.byID[selected]; ok {
+		for i, a := range tree.ancestors(selected) {
+			values = append(values, value(a, i))
+		}
+		values = append(values, value(c, len(values)))
+		parent = selected
+	}
+	var children []models.FacetValue
+	for _, id := range tree.children[parent] {
+		if v := value(tree.byID[id], len(values)); v.Count > 0 {
+			children = append(children, v)
+		}
+	}
+	slices.SortStableFunc(children, func(a, b models.FacetValue) int {
+		return b.Count - a.Count
+	})
+	return append(values, children...), nil
+}
+
+// classFacet rolls the counts of the class facet, made per DDC section (the
+// first three digits), up to divisions and main classes. Without a class
+// selected it offers the main classes; with one, the classes it falls
+// under, itself and its subdivisions.
+func classFacet(counts []models.FacetValue, selected string) []models.FacetValue {
+	value := func(digits string) models.FacetValue {
+		v := models.FacetValue{
+			Value:    digits,
+			Label:    models.ClassName(digits),
+			Selected: digits == selected,
+			Depth:    len(digits) - 1,
+		}
+		for _, c := range counts {
+			if strings.HasPrefix(c.Value, digits) {
+				v.Count += c.Count
+			}
+		}
+		return v
+	}
+
+	var values []models.FacetValue
+	for i := 1; i <= len(selected); i++ {
+		values = append(values, value(selected[:i]))
+	}
+	var last string
+	for _, c := range counts { // in the order of the sections
+		if len(c.Value) <= len(selected) || !strings.HasPrefix(c.Value, selected) {
+			continue
+		}
+		if next := c.Value[:len(selected)+1]; next != last {
+			values = append(values, value(next))
+			last = next
+		}
+	}
+	return values
+}
+
+// resolveCategory widens the category of filter to its subcategories.
+func (s *Service) resolveCategory(filter *models.BookFilter) error {
+	if filter.CategoryID == 0 {
+		return nil
+	}
+	list, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	filter.CategoryIDs = newCategoryTree(list).subtree(filter.CategoryID)
+	return nil
+}
+
+// resolveSearch turns the search query of filter into the IDs of the
+// matching books, best match first.
+func (s *Service) resolveSearch(filter *models.BookFilter) error {
+	if filter.Search == "" {
+		return nil
+	}
+	// A scanned barcode label finds its book
+	if id, ok := models.ParseBarcode(filter.Search); ok {
+		filter.IDs = []int{id}
+		return nil
+	}
+	hits, err := s.index.Search(filter.Search, maxSearchHits)
+	if err != nil {
+		return err
+	}
+	filter.IDs = make([]int, len(hits))
+	for i, h := range hits {
+		filter.IDs[i] = h.ID
+	}
+	return nil
+}
+
+// GetStock returns the branches holding copies of each book, keyed by book
+// ID.
+func (s *Service) GetStock(bookIDs []int) (map[int][]models.BranchStock, error) {
+	return s.bookRepo.FindStockByBooks(bookIDs)
 }
 
 func (s *Service) GetBook(id int) (*models.Book, error) {
@@ -30,47 +309,364 @@ func (s *Service) GetBook(id int) (*models.Book, error) {
 
 	// Load relations
 	if book.CategoryID != nil {
-		category, _ := s.categoryRepo.FindByID(*book.CategoryID)
+		category, _ := s.GetCategory(*book.CategoryID)
 		book.Category = category
 	}
-	if book.AuthorID != nil {
-		author, _ := s.authorRepo.FindByID(*book.AuthorID)
-		book.Author = author
+	if book.WorkID != nil {
+		work, _ := s.workRepo.FindByID(*book.WorkID)
+		book.Work = work
+	}
+
+	book.Branches, err = s.bookRepo.FindStock(id)
+	if err != nil {
+		return nil, err
 	}
 
 	return book, nil
 }
 
 func (s *Service) CreateBook(data *models.BookCreate) (int64, error) {
-	return s.bookRepo.Create(data)
+	var err error
+	if data.Language, err = checkLanguage(data.Language); err != nil {
+		return 0, err
+	}
+	if data.Stock < 0 {
+		return 0, errors.New("stok tidak boleh negatif")
+	}
+	if data.Stock > 0 && data.BranchID == 0 {
+		return 0, errors.New("cabang untuk stok awal wajib dipilih")
+	}
+	if data.CoverImage != "" && !storage.ValidKey(data.CoverImage) {
+		return 0, errors.New("sampul buku tidak valid")
+	}
+	if data.Contributors, err = checkContributors(data.Contributors); err != nil {
+		return 0, err
+	}
+	if data.Subjects, err = checkSubjects(data.Subjects); err != nil {
+		return 0, err
+	}
+	if err := s.checkClassification(&data.DDC, &data.Cutter, data.Contributors, data.Title); err != nil {
+		return 0, err
+	}
+	if err := s.checkEdition(data.WorkID, data.NewWork, &data.Edition); err != nil {
+		return 0, err
+	}
+	if err := s.linkWork(&data.WorkID, data.NewWork, data.Title); err != nil {
+		return 0, err
+	}
+	id, err := s.bookRepo.Create(data)
+	if err != nil {
+		return 0, err
+	}
+	s.reindex(int(id))
+	return id, nil
 }
 
 func (s *Service) UpdateBook(id int, data *models.BookUpdate) error {
-	return s.bookRepo.Update(id, data)
+	var err error
+	if data.Language, err = checkLanguage(data.Language); err != nil {
+		return err
+	}
+	if data.CoverImage != "" && !storage.ValidKey(data.CoverImage) {
+		return errors.New("sampul buku tidak valid")
+	}
+	if data.Contributors, err = checkContributors(data.Contributors); err != nil {
+		return err
+	}
+	if data.Subjects, err = checkSubjects(data.Subjects); err != nil {
+		return err
+	}
+	if err := s.checkClassification(&data.DDC, &data.Cutter, data.Contributors, data.Title); err != nil {
+		return err
+	}
+	if err := s.checkEdition(data.WorkID, data.NewWork, &data.Edition); err != nil {
+		return err
+	}
+	old, err := s.bookRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("buku tidak ditemukan")
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.linkWork(&data.WorkID, data.NewWork, data.Title); err != nil {
+		return err
+	}
+	if err := s.bookRepo.Update(id, data); err != nil {
+		return err
+	}
+	if old.CoverImage != data.CoverImage {
+		s.DiscardCover(context.Background(), old.CoverImage)
+	}
+	s.reindex(id)
+	return nil
+}
+
+// SetBranchStock sets the number of copies each listed branch holds, keyed
+// by branch ID. All branches are updated together or not at all.
+func (s *Service) SetBranchStock(bookID int, stock map[int]int) error {
+	current, err := s.bookRepo.FindStock(bookID)
+	if err != nil {
+		return err
+	}
+
+	var changes []models.StockChange
+	for _, c := range current {
+		want, ok := stock[c.BranchID]
+		if !ok || want == c.Stock {
+			continue
+		}
+		if onLoan := c.Stock - c.Available; want < onLoan {
+			return fmt.Errorf("stok %s tidak boleh kurang dari jumlah yang sedang dipinjam (%d)", c.BranchName, onLoan)
+		}
+		delta := want - c.Stock
+		changes = append(changes, models.StockChange{BranchID: c.BranchID, Stock: delta, Available: delta})
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return s.bookRepo.AdjustStock(bookID, changes...)
+}
+
+// checkLanguage normalizes a language code, defaulting to Indonesian.
+func checkLanguage(code string) (string, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return "id", nil
+	}
+	if !languageCode.MatchString(code) {
+		return "", errors.New("kode bahasa harus 2 huruf sesuai ISO 639-1, misalnya id atau en")
+	}
+	return code, nil
+}
+
+// checkContributors drops the rows of a contributor form left without an
+// author and defaults the role to author. Each person is credited once per
+// role.
+func checkContributors(list []models.Contributor) ([]models.Contributor, error) {
+	var checked []models.Contributor
+	for _, c := range list {
+		if c.AuthorID == 0 {
+			continue
+		}
+		if c.Role == "" {
+			c.Role = models.RoleAuthor
+		}
+		if !models.ValidRole(c.Role) {
+			return nil, fmt.Errorf("peran kontributor %q tidak dikenal", c.Role)
+		}
+		for _, prev := range checked {
+			if prev.AuthorID == c.AuthorID && prev.Role == c.Role {
+				return nil, errors.New("kontributor yang sama tercantum dua kali dengan peran yang sama")
+			}
+		}
+		checked = append(checked, c)
+	}
+	return checked, nil
+}
+
+var (
+	ddcPattern    = regexp.MustCompile(`^[0-9]{3}(\.[0-9]+)?$`)
+	cutterPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{0,9}$`)
+)
+
+// checkDDC normalizes a Dewey Decimal class number, dropping the spaces,
+// slashes and primes that mark where it may be shortened: "899.221 3" and
+// "899.221/3" are "899.2213".
+func checkDDC(ddc string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '/' || r == '\'' {
+			return -1
+		}
+		return r
+	}, ddc)
+	if normalized != "" && (!ddcPattern.MatchString(normalized) || len(normalized) > 20) {
+		return "", fmt.Errorf("nomor klasifikasi DDC %q tidak valid, contoh: 899.221", ddc)
+	}
+	return normalized, nil
+}
+
+// checkCutter normalizes an author mark to upper case.
+func checkCutter(cutter string) (string, error) {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(cutter), ""))
+	if normalized != "" && !cutterPattern.MatchString(normalized) {
+		return "", fmt.Errorf("tanda pengarang %q tidak valid, contoh: HIR", cutter)
+	}
+	return normalized, nil
+}
+
+// checkClassification normalizes the class number and author mark of a
+// book, making the mark from its main entry when none is given.
+func (s *Service) checkClassification(ddc, cutter *string, contributors []models.Contributor, title string) error {
+	var err error
+	if *ddc, err = checkDDC(*ddc); err != nil {
+		return err
+	}
+	if *cutter, err = checkCutter(*cutter); err != nil {
+		return err
+	}
+	if *cutter != "" {
+		return nil
+	}
+	named := slices.Clone(contributors)
+	for i, c := range named {
+		if c.Name != "" {
+			continue
+		}
+		if a, err := s.authorRepo.FindByID(c.AuthorID); err == nil {
+			named[i].Name = a.Name
+		}
+	}
+	*cutter = models.AuthorMark(named, title)
+	return nil
+}
+
+// maxSubjects caps the subject tags of a book.
+const maxSubjects = 30
+
+// checkSubjects tidies the spacing of subject tags and drops empty ones
+// and those repeated in another case.
+func checkSubjects(list []string) ([]string, error) {
+	var checked []string
+	seen := map[string]bool{}
+	for _, subject := range list {
+		subject = strings.Join(strings.Fields(subject), " ")
+		if subject == "" || seen[nameKey(subject)] {
+			continue
+		}
+		if utf8.RuneCountInString(subject) > 100 {
+			return nil, fmt.Errorf("subjek %q terlalu panjang (maksimal 100 karakter)", subject)
+		}
+		seen[nameKey(subject)] = true
+		checked = append(checked, subject)
+	}
+	if len(checked) > maxSubjects {
+		return nil, fmt.Errorf("subjek buku maksimal %d", maxSubjects)
+	}
+	return checked, nil
 }
 
+// DeleteBook deletes a book, and its cover when no other book shows it.
 func (s *Service) DeleteBook(id int) error {
-	return s.bookRepo.Delete(id)
+	book, err := s.bookRepo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.bookRepo.Delete(id); err != nil {
+		return err
+	}
+	s.DiscardCover(context.Background(), book.CoverImage)
+	if err := s.index.Delete(id); err != nil {
+		slog.Error("books: update search index", "book", id, "error", err)
+	}
+	return nil
 }
 
+// GetCategories returns the category tree depth first, with the books of
+// each category counting towards its ancestors.
 func (s *Service) GetCategories() ([]models.Category, error) {
-	return s.categoryRepo.FindAll()
+	list, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	return newCategoryTree(list).list(), nil
 }
 
+// GetCategory returns a category with the path of its ancestors.
 func (s *Service) GetCategory(id int) (*models.Category, error) {
-	return s.categoryRepo.FindByID(id)
+	c, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	c.Path = newCategoryTree(list).ancestors(id)
+	c.Depth = len(c.Path)
+	return c, nil
 }
 
 func (s *Service) CreateCategory(data *models.CategoryCreate) (int64, error) {
+	if err := s.checkCategory(0, data); err != nil {
+		return 0, err
+	}
 	return s.categoryRepo.Create(data)
 }
 
 func (s *Service) UpdateCategory(id int, data *models.CategoryCreate) error {
-	return s.categoryRepo.Update(id, data)
+	if err := s.checkCategory(id, data); err != nil {
+		return err
+	}
+	if err := s.categoryRepo.Update(id, data); err != nil {
+		return err
+	}
+	s.rebuild()
+	return nil
 }
 
+// checkCategory requires a name and a parent that exists and is neither
+// the category itself nor one of its descendants, id being 0 for a new
+// category.
+func (s *Service) checkCategory(id int, data *models.CategoryCreate) error {
+	data.Name = strings.Join(strings.Fields(data.Name), " ")
+	if data.Name == "" {
+		return errors.New("nama kategori wajib diisi")
+	}
+	if data.ParentID == 0 {
+		return nil
+	}
+	list, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	tree := newCategoryTree(list)
+	if _, ok := tree.byID[data.ParentID]; !ok {
+		return errors.New("kategori induk tidak ditemukan")
+	}
+	if id != 0 && slices.Contains(tree.subtree(id), data.ParentID) {
+		return errors.New("kategori tidak dapat dipindahkan ke dalam dirinya sendiri atau subkategorinya")
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category, moving its subcategories up to its
+// parent. Its books are left without a category.
 func (s *Service) DeleteCategory(id int) error {
-	return s.categoryRepo.Delete(id)
+	if err := s.categoryRepo.Delete(id); err != nil {
+		return err
+	}
+	s.rebuild()
+	return nil
+}
+
+// MergeCategory merges a duplicate category into another: its books and
+// subcategories move to into and it is deleted.
+func (s *Service) MergeCategory(from, into int) error {
+	if from == into {
+		return errors.New("pilih dua kategori yang berbeda untuk digabung")
+	}
+	list, err := s.categoryRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	tree := newCategoryTree(list)
+	_, fromOK := tree.byID[from]
+	_, intoOK := tree.byID[into]
+	if !fromOK || !intoOK {
+		return errors.New("kategori tidak ditemukan")
+	}
+	if slices.Contains(tree.subtree(from), into) {
+		return errors.New("kategori tidak dapat digabung ke dalam subkategorinya sendiri")
+	}
+	if err := s.categoryRepo.Merge(from, into); err != nil {
+		return err
+	}
+	s.rebuild()
+	return nil
 }
 
 func (s *Service) GetAuthors() ([]models.Author, error) {
@@ -86,22 +682,34 @@ func (s *Service) CreateAuthor(data *models.AuthorCreate) (int64, error) {
 }
 
 func (s *Service) UpdateAuthor(id int, data *models.AuthorCreate) error {
-	return s.authorRepo.Update(id, data)
+	if err := s.authorRepo.Update(id, data); err != nil {
+		return err
+	}
+	s.rebuild()
+	return nil
 }
 
 func (s *Service) DeleteAuthor(id int) error {
-	return s.authorRepo.Delete(id)
+	if err := s.authorRepo.Delete(id); err != nil {
+		return err
+	}
+	s.rebuild()
+	return nil
 }
 
-func (s *Service) GetStats() (totalBooks int, availableBooks int, err error) {
-	totalBooks, err = s.bookRepo.Count()
+// GetStats counts titles and available copies at a branch, or in the whole
+// library when branchID is 0.
+func (s *Service) GetStats(branchID int) (totalBooks int, availableBooks int, err error) {
+	totalBooks, err = s.bookRepo.Count(branchID)
 	if err != nil {
 		return
 	}
-	availableBooks, err = s.bookRepo.CountAvailable()
+	availableBooks, err = s.bookRepo.CountAvailable(branchID)
 	return
 }
 
-func (s *Service) GetBookCount() (int, error) {
-	return s.bookRepo.Count()
+// GetBookCount counts the titles held at a branch, or all titles when
+// branchID is 0.
+func (s *Service) GetBookCount(branchID int) (int, error) {
+	return s.bookRepo.Count(branchID)
 }
@@ -0,0 +1,583 @@
+package books
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+	"simpus/internal/search"
+)
+
+func newTestService(t *testing.T) *Service {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	s := NewService(NewBookRepository(db), NewCategoryRepository(db), NewAuthorRepository(db), NewWorkRepository(db), search.NewMemory())
+	if n, err := s.RebuildIndex(); err != nil || n != 5 {
+		t.Fatalf("RebuildIndex = %d, %v, want the 5 seeded books", n, err)
+	}
+	return s
+}
+
+func titles(books []models.Book) []string {
+	list := make([]string, len(books))
+	for i, b := range books {
+		list[i] = b.Title
+	}
+	return list
+}
+
+func TestGetBooksSearch(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name   string
+		filter models.BookFilter
+		want   []string
+	}{
+		{"title before description", models.BookFilter{Search: "bumi"}, []string{"Bumi", "Bumi Manusia", "Bulan"}},
+		{"description and stemming", models.BookFilter{Search: "berjuang kolonial"}, []string{"Bumi Manusia"}},
+		{"author with typo", models.BookFilter{Search: "andrea hiratta"}, []string{"Laskar Pelangi"}},
+		{"isbn", models.BookFilter{Search: "9780132350884"}, []string{"Clean Code"}},
+		{"with category", models.BookFilter{Search: "bumi", CategoryID: 4}, []string{}},
+		{"paged", models.BookFilter{Search: "bumi", Page: 2, Limit: 2}, []string{"Bulan"}},
+		{"nothing found", models.BookFilter{Search: "kriptografi"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books, _, err := s.GetBooks(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(books); !slices.Equal(got, tt.want) {
+				t.Errorf("GetBooks(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFollowsChanges(t *testing.T) {
+	s := newTestService(t)
+
+	id, err := s.CreateBook(&models.BookCreate{Title: "Pemrograman Go", Publisher: "Informatika", CategoryID: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	books, _, _ := s.GetBooks(models.BookFilter{Search: "teknologi"})
+	if got := titles(books); !slices.Contains(got, "Pemrograman Go") {
+		t.Errorf("after create, teknologi = %v", got)
+	}
+
+	err = s.UpdateBook(int(id), &models.BookUpdate{Title: "Belajar Go", Publisher: "Informatika", CategoryID: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if books, _, _ := s.GetBooks(models.BookFilter{Search: "pemrograman"}); len(books) != 0 {
+		t.Errorf("after update, old title still found: %v", titles(books))
+	}
+	if books, _, _ := s.GetBooks(models.BookFilter{Search: "pelajaran"}); !slices.Equal(titles(books), []string{"Belajar Go"}) {
+		t.Errorf("after update, pelajaran = %v", titles(books))
+	}
+
+	if err := s.UpdateCategory(4, &models.CategoryCreate{Name: "Komputer"}); err != nil {
+		t.Fatal(err)
+	}
+	if books, _, _ := s.GetBooks(models.BookFilter{Search: "komputer"}); len(books) != 2 {
+		t.Errorf("after renaming the category, komputer = %v", titles(books))
+	}
+
+	if err := s.DeleteBook(int(id)); err != nil {
+		t.Fatal(err)
+	}
+	if books, _, _ := s.GetBooks(models.BookFilter{Search: "belajar"}); len(books) != 0 {
+		t.Errorf("after delete, belajar = %v", titles(books))
+	}
+}
+
+func TestBookContributors(t *testing.T) {
+	s := newTestService(t)
+
+	// Rows of the form left without an author are dropped
+	id, err := s.CreateBook(&models.BookCreate{Title: "Cantik Itu Luka", Contributors: []models.Contributor{
+		{AuthorID: 3},
+		{AuthorID: 0, Role: models.RoleEditor},
+		{AuthorID: 4, Role: models.RoleTranslator},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := s.GetBook(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Contributor{
+		{AuthorID: 3, Name: "Pramoedya Ananta Toer", Role: models.RoleAuthor},
+		{AuthorID: 4, Name: "J.K. Rowling", Role: models.RoleTranslator},
+	}
+	if !slices.Equal(book.Contributors, want) {
+		t.Errorf("contributors = %+v, want %+v", book.Contributors, want)
+	}
+
+	// Every contributor is searchable
+	if books, _, _ := s.GetBooks(models.BookFilter{Search: "rowling"}); !slices.Equal(titles(books), []string{"Cantik Itu Luka"}) {
+		t.Errorf("search for the translator = %v", titles(books))
+	}
+
+	for name, list := range map[string][]models.Contributor{
+		"unknown role": {{AuthorID: 1, Role: "penyunting"}},
+		"twice":        {{AuthorID: 1}, {AuthorID: 1, Role: models.RoleAuthor}},
+	} {
+		if _, err := s.CreateBook(&models.BookCreate{Title: "Salah", Contributors: list}); err == nil {
+			t.Errorf("%s: CreateBook succeeded", name)
+		}
+	}
+}
+
+func TestSuggestBooks(t *testing.T) {
+	s := newTestService(t)
+
+	got, err := s.SuggestBooks("bu", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Bulan", "Bumi", "Bumi Manusia"}
+	if !slices.Equal(got, want) {
+		t.Errorf("SuggestBooks(bu) = %v, want %v", got, want)
+	}
+}
+
+func TestGetFacets(t *testing.T) {
+	s := newTestService(t)
+
+	facets, err := s.GetFacets(models.BookFilter{Search: "bumi", Language: "id", YearFrom: 2010, YearTo: 2019})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facets.Languages) != 1 || facets.Languages[0].Label != "Indonesia" || !facets.Languages[0].Selected {
+		t.Errorf("languages = %+v", facets.Languages)
+	}
+	// Only the search narrows the decades: Bumi and Bulan in the 2010s,
+	// Bumi Manusia in the 1980s
+	want := []models.FacetValue{
+		{Value: "2010", Label: "2010-an", Count: 2, Selected: true},
+		{Value: "1980", Label: "1980-an", Count: 1},
+	}
+	if !slices.Equal(facets.Decades, want) {
+		t.Errorf("decades = %+v, want %+v", facets.Decades, want)
+	}
+}
+
+func TestBookLanguage(t *testing.T) {
+	s := newTestService(t)
+
+	id, err := s.CreateBook(&models.BookCreate{Title: "Sejarah Dunia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book, _ := s.GetBook(int(id)); book.Language != "id" {
+		t.Errorf("default language = %q, want id", book.Language)
+	}
+
+	if err := s.UpdateBook(int(id), &models.BookUpdate{Title: "A History of the World", Language: " EN "}); err != nil {
+		t.Fatal(err)
+	}
+	if book, _ := s.GetBook(int(id)); book.Language != "en" {
+		t.Errorf("language = %q, want en", book.Language)
+	}
+
+	err = s.UpdateBook(int(id), &models.BookUpdate{Title: "A History of the World", Language: "english"})
+	if err == nil {
+		t.Error("UpdateBook accepted language \"english\"")
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	s := newTestService(t)
+
+	// Sains › Fisika › Mekanika are seeded without books
+	id, err := s.CreateBook(&models.BookCreate{Title: "Mekanika Klasik", CategoryID: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	categories, err := s.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	counts := map[string][2]int{}
+	for _, c := range categories {
+		names = append(names, c.TreeName())
+		counts[c.Name] = [2]int{c.BookCount, c.DirectBookCount}
+	}
+	if i := slices.Index(names, "Sains"); i < 0 || !slices.Equal(names[i:i+3], []string{"Sains", "— Fisika", "— — Mekanika"}) {
+		t.Errorf("tree = %v", names)
+	}
+	if counts["Sains"] != [2]int{1, 0} || counts["Mekanika"] != [2]int{1, 1} || counts["Fiksi"] != [2]int{4, 4} {
+		t.Errorf("book counts = %v", counts)
+	}
+
+	// Browsing a category includes its subcategories
+	books, total, err := s.GetBooks(models.BookFilter{CategoryID: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || books[0].ID != int(id) {
+		t.Errorf("books in Sains = %v", titles(books))
+	}
+	if book, _ := s.GetBook(int(id)); book.Category.FullName() != "Sains › Fisika › Mekanika" {
+		t.Errorf("category = %q", book.Category.FullName())
+	}
+
+	// The facet offers the top-level categories, then the path down to the
+	// one selected and its subcategories
+	facets, err := s.GetFacets(models.BookFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := slices.IndexFunc(facets.Categories, func(v models.FacetValue) bool { return v.Label == "Sains" }); i < 0 || facets.Categories[i].Count != 1 {
+		t.Errorf("categories = %+v", facets.Categories)
+	}
+	facets, err = s.GetFacets(models.BookFilter{CategoryID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.FacetValue{
+		{Value: "6", Label: "Sains", Count: 1},
+		{Value: "7", Label: "Fisika", Count: 1, Selected: true, Depth: 1},
+		{Value: "8", Label: "Mekanika", Count: 1, Depth: 2},
+	}
+	if !slices.Equal(facets.Categories, want) {
+		t.Errorf("categories = %+v, want %+v", facets.Categories, want)
+	}
+
+	// A category cannot move under itself
+	if err := s.UpdateCategory(6, &models.CategoryCreate{Name: "Sains", ParentID: 8}); err == nil {
+		t.Error("UpdateCategory moved Sains under Mekanika")
+	}
+	if _, err := s.CreateCategory(&models.CategoryCreate{Name: "Kimia", ParentID: 99}); err == nil {
+		t.Error("CreateCategory accepted a missing parent")
+	}
+}
+
+func TestMergeCategory(t *testing.T) {
+	s := newTestService(t)
+
+	id, err := s.CreateBook(&models.BookCreate{Title: "Fisika Dasar", CategoryID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MergeCategory(6, 8); err == nil {
+		t.Error("MergeCategory merged Sains into its own subcategory")
+	}
+
+	// Fisika is a duplicate of Teknologi, say
+	if err := s.MergeCategory(7, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetCategory(7); err == nil {
+		t.Error("merged category still exists")
+	}
+	if book, _ := s.GetBook(int(id)); book.CategoryID == nil || *book.CategoryID != 4 {
+		t.Errorf("book category = %v, want 4", book.CategoryID)
+	}
+	if c, _ := s.GetCategory(8); c.ParentID == nil || *c.ParentID != 4 {
+		t.Errorf("Mekanika parent = %v, want 4", c.ParentID)
+	}
+	books, _, err := s.GetBooks(models.BookFilter{Search: "fisika dasar", CategoryID: 4})
+	if err != nil || len(books) != 1 {
+		t.Errorf("search after merge = %v, %v", titles(books), err)
+	}
+}
+
+func TestDeleteCategoryKeepsSubcategories(t *testing.T) {
+	s := newTestService(t)
+
+	id, err := s.CreateBook(&models.BookCreate{Title: "Fisika Dasar", CategoryID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteCategory(7); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.GetCategory(8); c.ParentID == nil || *c.ParentID != 6 {
+		t.Errorf("Mekanika parent = %v, want 6", c.ParentID)
+	}
+	if book, _ := s.GetBook(int(id)); book.CategoryID != nil {
+		t.Errorf("book category = %v, want none", *book.CategoryID)
+	}
+}
+
+func TestBookSubjects(t *testing.T) {
+	s := newTestService(t)
+
+	id, err := s.CreateBook(&models.BookCreate{
+		Title:    "Fisika Dasar",
+		Subjects: []string{" Gerak  lurus", "gerak lurus", "", "Gaya"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := s.GetBook(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Gerak lurus", "Gaya"}; !slices.Equal(book.Subjects, want) {
+		t.Errorf("subjects = %q, want %q", book.Subjects, want)
+	}
+
+	books, _, err := s.GetBooks(models.BookFilter{Subject: "Gaya"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(books); !slices.Equal(got, []string{"Fisika Dasar"}) {
+		t.Errorf("books with subject Gaya = %v", got)
+	}
+	books, _, err = s.GetBooks(models.BookFilter{Search: "gerak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(books); !slices.Equal(got, []string{"Fisika Dasar"}) {
+		t.Errorf("search gerak = %v", got)
+	}
+
+	facets, err := s.GetFacets(models.BookFilter{Search: "fisika", Subject: "Gaya"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.FacetValue{{Value: "Gaya", Label: "Gaya", Count: 1, Selected: true}, {Value: "Gerak lurus", Label: "Gerak lurus", Count: 1}}
+	if !slices.Equal(facets.Subjects, want) {
+		t.Errorf("subjects facet = %+v, want %+v", facets.Subjects, want)
+	}
+
+	long := strings.Repeat("x", 101)
+	if err := s.UpdateBook(int(id), &models.BookUpdate{Title: "Fisika Dasar", Subjects: []string{long}}); err == nil {
+		t.Error("UpdateBook accepted a subject of 101 characters")
+	}
+}
+
+func TestBookClassification(t *testing.T) {
+	s := newTestService(t)
+
+	// The author mark is made from the author's surname when left empty
+	id, err := s.CreateBook(&models.BookCreate{
+		Title:        "Rumah Kaca",
+		DDC:          " 899.221 3",
+		Contributors: []models.Contributor{{AuthorID: 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := s.GetBook(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.DDC != "899.2213" || book.Cutter != "TOE" || book.CallNumber() != "899.2213 TOE r" {
+		t.Errorf("classification = %q %q, call number %q", book.DDC, book.Cutter, book.CallNumber())
+	}
+
+	if err := s.UpdateBook(int(id), &models.BookUpdate{Title: "Rumah Kaca", DDC: "899.2213", Cutter: "pra"}); err != nil {
+		t.Fatal(err)
+	}
+	if book, _ := s.GetBook(int(id)); book.Cutter != "PRA" {
+		t.Errorf("cutter = %q, want PRA", book.Cutter)
+	}
+
+	for _, tt := range []models.BookUpdate{
+		{Title: "Salah", DDC: "89"},
+		{Title: "Salah", DDC: "899.22a"},
+		{Title: "Salah", Cutter: "1AB"},
+		{Title: "Salah", Cutter: "ABCDEFGHIJK"},
+	} {
+		if err := s.UpdateBook(int(id), &tt); err == nil {
+			t.Errorf("UpdateBook accepted DDC %q, cutter %q", tt.DDC, tt.Cutter)
+		}
+	}
+
+	// A book without an author is marked by its title
+	id, err = s.CreateBook(&models.BookCreate{Title: "Ensiklopedia Indonesia"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book, _ := s.GetBook(int(id)); book.Cutter != "ENS" || book.CallNumber() != "ENS e" {
+		t.Errorf("cutter = %q, call number %q", book.Cutter, book.CallNumber())
+	}
+}
+
+func TestBrowseByClass(t *testing.T) {
+	s := newTestService(t)
+
+	books, _, err := s.GetBooks(models.BookFilter{Sort: models.BookSortShelf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Clean Code", "Laskar Pelangi", "Bulan", "Bumi", "Bumi Manusia"}
+	if got := titles(books); !slices.Equal(got, want) {
+		t.Errorf("shelf order = %v, want %v", got, want)
+	}
+
+	books, _, err = s.GetBooks(models.BookFilter{Class: "89", Sort: models.BookSortShelf, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(books); !slices.Equal(got, []string{"Laskar Pelangi", "Bulan"}) {
+		t.Errorf("class 89 = %v", got)
+	}
+
+	facets, err := s.GetFacets(models.BookFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFacet := []models.FacetValue{
+		{Value: "0", Label: "000 Karya Umum", Count: 1},
+		{Value: "8", Label: "800 Kesusastraan", Count: 4},
+	}
+	if !slices.Equal(facets.Classes, wantFacet) {
+		t.Errorf("classes = %+v, want %+v", facets.Classes, wantFacet)
+	}
+	facets, err = s.GetFacets(models.BookFilter{Class: "89"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFacet = []models.FacetValue{
+		{Value: "8", Label: "800 Kesusastraan", Count: 4},
+		{Value: "89", Label: "890", Count: 4, Selected: true, Depth: 1},
+		{Value: "899", Label: "899", Count: 4, Depth: 2},
+	}
+	if !slices.Equal(facets.Classes, wantFacet) {
+		t.Errorf("classes under 89 = %+v, want %+v", facets.Classes, wantFacet)
+	}
+
+	// A scanned barcode label finds its book
+	books, _, err = s.GetBooks(models.BookFilter{Search: "B000003"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(books); !slices.Equal(got, []string{"Bumi Manusia"}) {
+		t.Errorf("search B000003 = %v", got)
+	}
+}
+
+func TestWorksAndSeries(t *testing.T) {
+	s := newTestService(t)
+
+	// Seeded: Serial Bumi (1) with Bumi (work 1, book 2) and Bulan (work 2,
+	// book 5) as volumes 1 and 2
+	invalid := []struct {
+		work models.WorkCreate
+		want string
+	}{
+		{models.WorkCreate{Title: " "}, "judul karya wajib diisi"},
+		{models.WorkCreate{Title: "Bintang", SeriesID: 99, Volume: 4}, "seri tidak ditemukan"},
+		{models.WorkCreate{Title: "Bintang", SeriesID: 1}, "nomor jilid wajib diisi"},
+		{models.WorkCreate{Title: "Bintang", SeriesID: 1, Volume: 2}, "jilid 2 Serial Bumi sudah ada: Bulan"},
+	}
+	for _, tt := range invalid {
+		if _, err := s.CreateWork(&tt.work); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CreateWork(%+v) err = %v, want %q", tt.work, err, tt.want)
+		}
+	}
+	if _, err := s.CreateSeries(&models.SeriesCreate{Name: "  "}); err == nil {
+		t.Error("series created without a name")
+	}
+
+	// Volume 3 is not in the library, so Bulan has no next volume yet
+	bintang, err := s.CreateWork(&models.WorkCreate{Title: "Bintang", SeriesID: 1, Volume: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relations := func(id int) *models.BookRelations {
+		t.Helper()
+		book, err := s.GetBook(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := s.GetRelations(book)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rel
+	}
+	rel := relations(5)
+	if rel.Previous == nil || rel.Previous.Title != "Bumi" || rel.Next != nil || len(rel.Editions) != 0 {
+		t.Errorf("relations of Bulan = %+v", rel)
+	}
+
+	if _, err := s.CreateBook(&models.BookCreate{Title: "Bintang", WorkID: int(bintang), PublishYear: 2017}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateBook(&models.BookCreate{Title: "Bulan", WorkID: 2, Edition: " Edisi  ke-2 ", PublishYear: 2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel = relations(5)
+	if rel.Next == nil || rel.Next.Title != "Bintang" || len(rel.Editions) != 1 || rel.Editions[0].Edition != "Edisi ke-2" {
+		t.Errorf("relations of Bulan = %+v", rel)
+	}
+	if rel := relations(int(second)); len(rel.Editions) != 1 || rel.Editions[0].ID != 5 {
+		t.Errorf("other editions of the second edition = %+v", rel.Editions)
+	}
+
+	books, _, err := s.GetBooks(models.BookFilter{SeriesID: 1, Sort: models.BookSortVolume})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(books); !slices.Equal(got, []string{"Bumi", "Bulan", "Bulan", "Bintang"}) || books[1].ID != int(second) {
+		t.Errorf("series in volume order = %v", got)
+	}
+	if label := books[3].Work.SeriesLabel(); label != "Serial Bumi #4" {
+		t.Errorf("series label = %q", label)
+	}
+	facets, err := s.GetFacets(models.BookFilter{SeriesID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.FacetValue{{Value: "1", Label: "Serial Bumi", Count: 4, Selected: true}}
+	if !slices.Equal(facets.Series, want) {
+		t.Errorf("series facet = %+v, want %+v", facets.Series, want)
+	}
+
+	// Deleting a work leaves its editions as books of their own
+	if err := s.DeleteWork(2); err != nil {
+		t.Fatal(err)
+	}
+	book, err := s.GetBook(int(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.WorkID != nil || book.Edition != "" {
+		t.Errorf("edition of a deleted work = %v, %q", book.WorkID, book.Edition)
+	}
+	if err := s.DeleteSeries(1); err != nil {
+		t.Fatal(err)
+	}
+	works, err := s.GetWorks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range works {
+		if w.SeriesID != nil || w.Volume != 0 {
+			t.Errorf("work %q still in a deleted series", w.Title)
+		}
+	}
+}
+
+func TestBookNewWork(t *testing.T) {
+	s := newTestService(t)
+
+	if _, err := s.CreateBook(&models.BookCreate{Title: "Negeri 5 Menara", WorkID: 99}); err == nil || !strings.Contains(err.Error(), "karya tidak ditemukan") {
+		t.Fatalf("CreateBook with an unknown work: err = %v", err)
+	}
+	id, err := s.CreateBook(&models.BookCreate{Title: "Negeri 5 Menara", NewWork: true, Edition: "Cetakan pertama"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := s.GetBook(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Work == nil || book.Work.Title != "Negeri 5 Menara" || book.Edition != "Cetakan pertama" {
+		t.Fatalf("book = %+v, work = %+v", book, book.Work)
+	}
+}
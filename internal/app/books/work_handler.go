@@ -0,0 +1,174 @@
+package books
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simpus/internal/middleware"
+	"simpus/internal/models"
+	"simpus/internal/renderer"
+)
+
+// WorkHandler manages works, which group the editions of a title, and the
+// series they are volumes of, on one page.
+type WorkHandler struct {
+	service *Service
+	views   *renderer.Renderer
+}
+
+func NewWorkHandler(service *Service, views *renderer.Renderer) *WorkHandler {
+	return &WorkHandler{
+		service: service,
+		views:   views,
+	}
+}
+
+func (h *WorkHandler) Index(w http.ResponseWriter, r *http.Request) {
+	works, err := h.service.GetWorks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	series, err := h.service.GetSeries()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	claims := middleware.GetUserFromContext(r.Context())
+
+	data := map[string]interface{}{
+		"Title":   "Karya & Seri - SIMPUS",
+		"Works":   works,
+		"Series":  series,
+		"User":    claims,
+		"Success": r.URL.Query().Get("success"),
+		"Error":   r.URL.Query().Get("error"),
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		h.views.Partial(w, r, "admin/works/index.html", "content", data)
+		return
+	}
+
+	h.views.Render(w, r, "admin/works/index.html", data)
+}
+
+func (h *WorkHandler) Store(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	data := workForm(r)
+	if _, err := h.service.CreateWork(data); err != nil {
+		workRedirect(w, r, "error", err.Error())
+		return
+	}
+	workRedirect(w, r, "success", "Karya "+data.Title+" berhasil ditambahkan")
+}
+
+func (h *WorkHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	data := workForm(r)
+	if err := h.service.UpdateWork(id, data); err != nil {
+		workRedirect(w, r, "error", err.Error())
+		return
+	}
+	workRedirect(w, r, "success", "Karya "+data.Title+" berhasil diperbarui")
+}
+
+func (h *WorkHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := h.service.DeleteWork(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/works", http.StatusSeeOther)
+}
+
+func (h *WorkHandler) StoreSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	data := seriesForm(r)
+	if _, err := h.service.CreateSeries(data); err != nil {
+		workRedirect(w, r, "error", err.Error())
+		return
+	}
+	workRedirect(w, r, "success", "Seri "+data.Name+" berhasil ditambahkan")
+}
+
+func (h *WorkHandler) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	data := seriesForm(r)
+	if err := h.service.UpdateSeries(id, data); err != nil {
+		workRedirect(w, r, "error", err.Error())
+		return
+	}
+	workRedirect(w, r, "success", "Seri "+data.Name+" berhasil diperbarui")
+}
+
+func (h *WorkHandler) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := h.service.DeleteSeries(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/works", http.StatusSeeOther)
+}
+
+func workForm(r *http.Request) *models.WorkCreate {
+	series, _ := strconv.Atoi(r.FormValue("series_id"))
+	volume, _ := strconv.Atoi(r.FormValue("volume"))
+	return &models.WorkCreate{
+		Title:    r.FormValue("title"),
+		SeriesID: series,
+		Volume:   volume,
+	}
+}
+
+func seriesForm(r *http.Request) *models.SeriesCreate {
+	return &models.SeriesCreate{
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+	}
+}
+
+func workRedirect(w http.ResponseWriter, r *http.Request, key, message string) {
+	target := "/admin/works?" + key + "=" + url.QueryEscape(message)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -0,0 +1,195 @@
+package books
+
+import (
+	"database/sql"
+	"simpus/internal/models"
+)
+
+// WorkRepository stores works, which group the editions of a title, and
+// the series they are volumes of.
+type WorkRepository interface {
+	FindAll() ([]models.Work, error)
+	FindByID(id int) (*models.Work, error)
+	// FindBySeries returns the works of a series by volume.
+	FindBySeries(seriesID int) ([]models.Work, error)
+	Create(w *models.WorkCreate) (int64, error)
+	Update(id int, w *models.WorkCreate) error
+	// Delete removes a work and unlinks its editions.
+	Delete(id int) error
+
+	FindSeries() ([]models.Series, error)
+	FindSeriesByID(id int) (*models.Series, error)
+	CreateSeries(s *models.SeriesCreate) (int64, error)
+	UpdateSeries(id int, s *models.SeriesCreate) error
+	// DeleteSeries removes a series; its works stay, outside any series.
+	DeleteSeries(id int) error
+}
+
+type workRepository struct {
+	db *sql.DB
+}
+
+func NewWorkRepository(db *sql.DB) WorkRepository {
+	return &workRepository{db: db}
+}
+
+const selectWork = `SELECT w.id, w.title, w.series_id, w.volume, w.created_at, s.name,
+			  (SELECT COUNT(*) FROM books b WHERE b.work_id = w.id) as edition_count
+			  FROM works w
+			  LEFT JOIN series s ON w.series_id = s.id`
+
+// FindAll returns the works of each series by volume, series by name,
+// then the works outside a series by title.
+func (r *workRepository) FindAll() ([]models.Work, error) {
+	return r.findWorks(selectWork + ` ORDER BY CASE WHEN s.id IS NULL THEN 1 ELSE 0 END, s.name, w.volume, w.title`)
+}
+
+func (r *workRepository) FindBySeries(seriesID int) ([]models.Work, error) {
+	return r.findWorks(selectWork+` WHERE w.series_id = ? ORDER BY w.volume, w.id`, seriesID)
+}
+
+func (r *workRepository) findWorks(query string, args ...interface{}) ([]models.Work, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var works []models.Work
+	for rows.Next() {
+		w, err := scanWork(rows)
+		if err != nil {
+			return nil, err
+		}
+		works = append(works, *w)
+	}
+	return works, rows.Err()
+}
+
+func (r *workRepository) FindByID(id int) (*models.Work, error) {
+	return scanWork(r.db.QueryRow(selectWork+` WHERE w.id = ?`, id))
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWork(row scanner) (*models.Work, error) {
+	w := &models.Work{}
+	var seriesID sql.NullInt64
+	var seriesName sql.NullString
+	err := row.Scan(&w.ID, &w.Title, &seriesID, &w.Volume, &w.CreatedAt, &seriesName, &w.EditionCount)
+	if err != nil {
+		return nil, err
+	}
+	if seriesID.Valid {
+		id := int(seriesID.Int64)
+		w.SeriesID = &id
+		w.Series = &models.Series{ID: id, Name: seriesName.String}
+	}
+	return w, nil
+}
+
+func (r *workRepository) Create(w *models.WorkCreate) (int64, error) {
+	var seriesID interface{}
+	if w.SeriesID > 0 {
+		seriesID = w.SeriesID
+	}
+	result, err := r.db.Exec(`INSERT INTO works (title, series_id, volume) VALUES (?, ?, ?)`, w.Title, seriesID, w.Volume)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *workRepository) Update(id int, w *models.WorkCreate) error {
+	var seriesID interface{}
+	if w.SeriesID > 0 {
+		seriesID = w.SeriesID
+	}
+	_, err := r.db.Exec(`UPDATE works SET title = ?, series_id = ?, volume = ? WHERE id = ?`, w.Title, seriesID, w.Volume, id)
+	return err
+}
+
+func (r *workRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE books SET work_id = NULL, edition = '' WHERE work_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM works WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *workRepository) FindSeries() ([]models.Series, error) {
+	query := `SELECT s.id, s.name, s.description, s.created_at, COUNT(w.id) as work_count
+			  FROM series s
+			  LEFT JOIN works w ON s.id = w.series_id
+			  GROUP BY s.id, s.name, s.description, s.created_at
+			  ORDER BY s.name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Series
+	for rows.Next() {
+		var s models.Series
+		var desc sql.NullString
+		if err := rows.Scan(&s.ID, &s.Name, &desc, &s.CreatedAt, &s.WorkCount); err != nil {
+			return nil, err
+		}
+		s.Description = desc.String
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (r *workRepository) FindSeriesByID(id int) (*models.Series, error) {
+	s := &models.Series{}
+	var desc sql.NullString
+	err := r.db.QueryRow(`SELECT id, name, description, created_at FROM series WHERE id = ?`, id).
+		Scan(&s.ID, &s.Name, &desc, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.Description = desc.String
+	return s, nil
+}
+
+func (r *workRepository) CreateSeries(s *models.SeriesCreate) (int64, error) {
+	result, err := r.db.Exec(`INSERT INTO series (name, description) VALUES (?, ?)`, s.Name, s.Description)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *workRepository) UpdateSeries(id int, s *models.SeriesCreate) error {
+	_, err := r.db.Exec(`UPDATE series SET name = ?, description = ? WHERE id = ?`, s.Name, s.Description, id)
+	return err
+}
+
+func (r *workRepository) DeleteSeries(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE works SET series_id = NULL, volume = 0 WHERE series_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM series WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,202 @@
+package books
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"simpus/internal/models"
+)
+
+// maxEditions caps the editions of a work listed together.
+const maxEditions = 100
+
+func (s *Service) GetWorks() ([]models.Work, error) {
+	return s.workRepo.FindAll()
+}
+
+func (s *Service) CreateWork(data *models.WorkCreate) (int64, error) {
+	if err := s.checkWork(0, data); err != nil {
+		return 0, err
+	}
+	return s.workRepo.Create(data)
+}
+
+func (s *Service) UpdateWork(id int, data *models.WorkCreate) error {
+	if _, err := s.workRepo.FindByID(id); errors.Is(err, sql.ErrNoRows) {
+		return errors.New("karya tidak ditemukan")
+	} else if err != nil {
+		return err
+	}
+	if err := s.checkWork(id, data); err != nil {
+		return err
+	}
+	return s.workRepo.Update(id, data)
+}
+
+// checkWork requires a title and, in a series, a volume number no other
+// work of the series has, id being 0 for a new work.
+func (s *Service) checkWork(id int, data *models.WorkCreate) error {
+	data.Title = strings.Join(strings.Fields(data.Title), " ")
+	if data.Title == "" {
+		return errors.New("judul karya wajib diisi")
+	}
+	if utf8.RuneCountInString(data.Title) > 255 {
+		return errors.New("judul karya terlalu panjang (maksimal 255 karakter)")
+	}
+	if data.SeriesID == 0 {
+		data.Volume = 0
+		return nil
+	}
+	series, err := s.workRepo.FindSeriesByID(data.SeriesID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("seri tidak ditemukan")
+	}
+	if err != nil {
+		return err
+	}
+	if data.Volume < 1 {
+		return errors.New("nomor jilid wajib diisi untuk karya dalam seri")
+	}
+	works, err := s.workRepo.FindBySeries(data.SeriesID)
+	if err != nil {
+		return err
+	}
+	for _, w := range works {
+		if w.ID != id && w.Volume == data.Volume {
+			return fmt.Errorf("jilid %d %s sudah ada: %s", data.Volume, series.Name, w.Title)
+		}
+	}
+	return nil
+}
+
+// DeleteWork deletes a work. Its editions stay as books of their own.
+func (s *Service) DeleteWork(id int) error {
+	return s.workRepo.Delete(id)
+}
+
+func (s *Service) GetSeries() ([]models.Series, error) {
+	return s.workRepo.FindSeries()
+}
+
+func (s *Service) CreateSeries(data *models.SeriesCreate) (int64, error) {
+	if err := checkSeries(data); err != nil {
+		return 0, err
+	}
+	return s.workRepo.CreateSeries(data)
+}
+
+func (s *Service) UpdateSeries(id int, data *models.SeriesCreate) error {
+	if err := checkSeries(data); err != nil {
+		return err
+	}
+	return s.workRepo.UpdateSeries(id, data)
+}
+
+func checkSeries(data *models.SeriesCreate) error {
+	data.Name = strings.Join(strings.Fields(data.Name), " ")
+	if data.Name == "" {
+		return errors.New("nama seri wajib diisi")
+	}
+	if utf8.RuneCountInString(data.Name) > 200 {
+		return errors.New("nama seri terlalu panjang (maksimal 200 karakter)")
+	}
+	return nil
+}
+
+// DeleteSeries deletes a series. Its works stay, outside any series.
+func (s *Service) DeleteSeries(id int) error {
+	return s.workRepo.DeleteSeries(id)
+}
+
+// checkEdition checks the work and edition of a book form. A new work
+// asked for is only created by linkWork, once the rest of the book is
+// valid.
+func (s *Service) checkEdition(workID int, newWork bool, edition *string) error {
+	*edition = strings.Join(strings.Fields(*edition), " ")
+	if utf8.RuneCountInString(*edition) > 50 {
+		return errors.New("keterangan edisi terlalu panjang (maksimal 50 karakter)")
+	}
+	if workID == 0 || newWork {
+		return nil
+	}
+	if _, err := s.workRepo.FindByID(workID); errors.Is(err, sql.ErrNoRows) {
+		return errors.New("karya tidak ditemukan")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// linkWork starts the work a book form asked for, titled like the book.
+func (s *Service) linkWork(workID *int, newWork bool, title string) error {
+	if !newWork {
+		return nil
+	}
+	id, err := s.CreateWork(&models.WorkCreate{Title: title})
+	if err != nil {
+		return err
+	}
+	*workID = int(id)
+	return nil
+}
+
+// GetRelations finds the other editions of a book loaded by GetBook and
+// its neighbours in its series.
+func (s *Service) GetRelations(book *models.Book) (*models.BookRelations, error) {
+	rel := &models.BookRelations{}
+	work := book.Work
+	if work == nil {
+		return rel, nil
+	}
+
+	editions, err := s.editions(work.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range editions {
+		if e.ID != book.ID {
+			rel.Editions = append(rel.Editions, e)
+		}
+	}
+
+	if work.SeriesID == nil {
+		return rel, nil
+	}
+	works, err := s.workRepo.FindBySeries(*work.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+	at := slices.IndexFunc(works, func(w models.Work) bool { return w.ID == work.ID })
+	// Volumes without any edition in the library are skipped
+	for i := at - 1; i >= 0 && rel.Previous == nil; i-- {
+		if rel.Previous, err = s.latestEdition(works[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	for i := at + 1; at >= 0 && i < len(works) && rel.Next == nil; i++ {
+		if rel.Next, err = s.latestEdition(works[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return rel, nil
+}
+
+// editions returns the editions of a work, most recently published first.
+func (s *Service) editions(workID int) ([]models.Book, error) {
+	books, _, err := s.bookRepo.FindAll(models.BookFilter{WorkID: workID, Sort: models.BookSortYear, Page: 1, Limit: maxEditions})
+	return books, err
+}
+
+// latestEdition returns the most recently published edition of a work, or
+// nil when the library has none.
+func (s *Service) latestEdition(workID int) (*models.Book, error) {
+	books, err := s.editions(workID)
+	if err != nil || len(books) == 0 {
+		return nil, err
+	}
+	return &books[0], nil
+}
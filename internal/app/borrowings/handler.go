@@ -1,35 +1,38 @@
 package borrowings
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
 	"strconv"
 
 	"simpus/internal/app/books"
+	"simpus/internal/app/branches"
 	"simpus/internal/app/members"
 	"simpus/internal/middleware"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
 )
 
 type Handler struct {
 	service       *Service
 	bookService   *books.Service
 	memberService *members.Service
-	templates     *template.Template
+	branchService *branches.Service
+	views         *renderer.Renderer
 }
 
 func NewHandler(
 	service *Service,
 	bookService *books.Service,
 	memberService *members.Service,
-	templates *template.Template,
+	branchService *branches.Service,
+	views *renderer.Renderer,
 ) *Handler {
 	return &Handler{
 		service:       service,
 		bookService:   bookService,
 		memberService: memberService,
-		templates:     templates,
+		branchService: branchService,
+		views:         views,
 	}
 }
 
@@ -39,11 +42,14 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 		page = 1
 	}
 	status := r.URL.Query().Get("status")
+	claims := middleware.GetUserFromContext(r.Context())
+	branchID := branches.Selected(r, claims.BranchID)
 
 	filter := models.BorrowingFilter{
-		Status: status,
-		Page:   page,
-		Limit:  10,
+		BranchID: branchID,
+		Status:   status,
+		Page:     page,
+		Limit:    10,
 	}
 
 	borrowings, total, err := h.service.GetBorrowings(filter)
@@ -54,7 +60,7 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 
 	totalPages := (total + 10 - 1) / 10
 
-	claims := middleware.GetUserFromContext(r.Context())
+	branchList, _ := h.branchService.GetBranches()
 
 	data := map[string]interface{}{
 		"Title":      "Manajemen Peminjaman - SIMPUS",
@@ -63,38 +69,50 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 		"Page":       page,
 		"TotalPages": totalPages,
 		"Status":     status,
+		"BranchID":   branchID,
+		"Branches":   branchList,
 		"User":       claims,
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/borrowings/table.html", data)
+		h.views.Partial(w, r, "admin/borrowings/index.html", "borrowings-table", data)
 		return
 	}
 
-	h.render(w, "admin/borrowings/index.html", data)
+	h.views.Render(w, r, "admin/borrowings/index.html", data)
 }
 
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	members, _, _ := h.memberService.GetMembers(1, 100, "")
 
-	filter := models.BookFilter{Page: 1, Limit: 100, Available: true}
-	books, _, _ := h.bookService.GetBooks(filter)
-
 	claims := middleware.GetUserFromContext(r.Context())
+	branchList, _ := h.branchService.GetActiveBranches()
+
+	// Books are lent from the staff's own branch unless another is picked
+	branchID := branches.Selected(r, claims.BranchID)
+	if branchID == 0 && len(branchList) > 0 {
+		branchID = branchList[0].ID
+	}
+
+	filter := models.BookFilter{Page: 1, Limit: 100, Available: true, BranchID: branchID}
+	books, _, _ := h.bookService.GetBooks(filter)
 
 	data := map[string]interface{}{
-		"Title":   "Tambah Peminjaman - SIMPUS",
-		"Members": members,
-		"Books":   books,
-		"User":    claims,
+		"Title":    "Tambah Peminjaman - SIMPUS",
+		"Members":  members,
+		"Books":    books,
+		"Branches": branchList,
+		"BranchID": branchID,
+		"Policy":   h.service.LoanPolicy(),
+		"User":     claims,
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/borrowings/form.html", data)
+		h.views.Partial(w, r, "admin/borrowings/create.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/borrowings/create.html", data)
+	h.views.Render(w, r, "admin/borrowings/create.html", data)
 }
 
 func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
@@ -105,6 +123,7 @@ func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
 
 	memberID, _ := strconv.Atoi(r.FormValue("member_id"))
 	bookID, _ := strconv.Atoi(r.FormValue("book_id"))
+	branchID, _ := strconv.Atoi(r.FormValue("branch_id"))
 	borrowDays, _ := strconv.Atoi(r.FormValue("borrow_days"))
 
 	claims := middleware.GetUserFromContext(r.Context())
@@ -112,6 +131,7 @@ func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
 	data := &models.BorrowingCreate{
 		MemberID:   memberID,
 		BookID:     bookID,
+		BranchID:   branchID,
 		BorrowDays: borrowDays,
 		Notes:      r.FormValue("notes"),
 	}
@@ -138,7 +158,14 @@ func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Return(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(r.PathValue("id"))
 
-	borrowing, err := h.service.ReturnBook(id)
+	// Returns are booked at the staff's branch unless the form names one
+	claims := middleware.GetUserFromContext(r.Context())
+	branchID, err := strconv.Atoi(r.FormValue("branch_id"))
+	if err != nil {
+		branchID = claims.BranchID
+	}
+
+	borrowing, err := h.service.ReturnBook(id, branchID)
 	if err != nil {
 		if r.Header.Get("HX-Request") == "true" {
 			w.WriteHeader(http.StatusBadRequest)
@@ -163,53 +190,6 @@ func (h *Handler) Return(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/borrowings", http.StatusSeeOther)
 }
 
-func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	files := []string{
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	}
-
-	if name == "admin/borrowings/index.html" {
-		files = append(files, filepath.Join("templates", "admin", "borrowings", "table.html"))
-	}
-
-	tmpl, err = tmpl.ParseFiles(files...)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (h *Handler) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(filepath.Join("templates", name))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
 func (h *Handler) MemberRequest(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Redirect(w, r, "/member/books?error=Form tidak valid", http.StatusSeeOther)
@@ -217,12 +197,14 @@ func (h *Handler) MemberRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	bookID, _ := strconv.Atoi(r.FormValue("book_id"))
+	branchID, _ := strconv.Atoi(r.FormValue("branch_id"))
 	claims := middleware.GetUserFromContext(r.Context())
 
 	data := &models.BorrowingCreate{
 		MemberID:   claims.UserID,
 		BookID:     bookID,
-		BorrowDays: 7, // Default peminjaman mandiri 7 hari
+		BranchID:   branchID,
+		BorrowDays: 0, // Lama peminjaman mandiri mengikuti kebijakan default
 		Notes:      "Peminjaman Mandiri",
 	}
 
@@ -250,28 +232,5 @@ func (h *Handler) MemberHistory(w http.ResponseWriter, r *http.Request) {
 		"Success":    r.URL.Query().Get("success"),
 	}
 
-	h.renderMember(w, "member/borrowings/history.html", data)
-}
-
-func (h *Handler) renderMember(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "member.html"),
-		filepath.Join("templates", "components", "member-sidebar.html"),
-		filepath.Join("templates", "components", "member-header.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "member.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	h.views.Render(w, r, "member/borrowings/history.html", data)
 }
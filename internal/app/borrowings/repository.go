@@ -2,19 +2,52 @@ package borrowings
 
 import (
 	"database/sql"
+	"errors"
+	"simpus/database"
+	"simpus/internal/app/books"
 	"simpus/internal/models"
 	"time"
 )
 
-type Repository struct {
-	db *sql.DB
+// dateLayout is used for DATE columns so SQLite stores plain dates that
+// compare correctly with the dialect's CurrentDate.
+const dateLayout = "2006-01-02"
+
+// ErrHoldChanged is returned when a loan was to take the copy kept for a
+// hold that is no longer ready.
+var ErrHoldChanged = errors.New("pesanan anggota sudah berubah, muat ulang halaman")
+
+// Repository stores borrowing transactions.
+type Repository interface {
+	FindAll(filter models.BorrowingFilter) ([]models.Borrowing, int, error)
+	FindByID(id int) (*models.Borrowing, error)
+	// Create records a loan and takes its copy in the same transaction:
+	// the copy kept for the member's ready hold when holdID is set, which
+	// fulfils the hold, otherwise one off the shelf at the branch. It
+	// returns ErrHoldChanged or books.ErrInsufficientStock when the copy
+	// is gone.
+	Create(br *models.BorrowingCreate, userID int, dueDate time.Time, holdID int) (int64, error)
+	// Return closes a loan and applies the stock changes for its copy in
+	// the same transaction. It reports whether the loan was still out, so
+	// a copy returned twice at once is only counted back once.
+	Return(id int, returnData *models.BorrowingReturn, bookID int, changes ...models.StockChange) (bool, error)
+	// CountActive and CountOverdue cover all branches when branchID is 0.
+	CountActive(branchID int) (int, error)
+	CountOverdue(branchID int) (int, error)
+	FindOverdue() ([]models.Borrowing, error)
+	GetMemberBorrowings(memberID int) ([]models.Borrowing, error)
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+type repository struct {
+	db      *sql.DB
+	dialect database.Dialect
 }
 
-func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing, int, error) {
+func NewRepository(db *sql.DB, dialect database.Dialect) Repository {
+	return &repository{db: db, dialect: dialect}
+}
+
+func (r *repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing, int, error) {
 	if filter.Page < 1 {
 		filter.Page = 1
 	}
@@ -27,6 +60,8 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 				  LEFT JOIN members m ON br.member_id = m.id
 				  LEFT JOIN books b ON br.book_id = b.id
 				  LEFT JOIN users u ON br.user_id = u.id
+				  LEFT JOIN branches bb ON br.branch_id = bb.id
+				  LEFT JOIN branches rb ON br.return_branch_id = rb.id
 				  WHERE 1=1`
 	args := []interface{}{}
 
@@ -38,17 +73,21 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 		baseQuery += ` AND br.book_id = ?`
 		args = append(args, filter.BookID)
 	}
+	if filter.BranchID > 0 {
+		baseQuery += ` AND br.branch_id = ?`
+		args = append(args, filter.BranchID)
+	}
 	if filter.Status != "" {
 		baseQuery += ` AND br.status = ?`
 		args = append(args, filter.Status)
 	}
 	if !filter.FromDate.IsZero() {
 		baseQuery += ` AND br.borrow_date >= ?`
-		args = append(args, filter.FromDate)
+		args = append(args, filter.FromDate.Format(dateLayout))
 	}
 	if !filter.ToDate.IsZero() {
 		baseQuery += ` AND br.borrow_date <= ?`
-		args = append(args, filter.ToDate)
+		args = append(args, filter.ToDate.Format(dateLayout))
 	}
 
 	// Count
@@ -60,11 +99,12 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 	}
 
 	// Get data
-	query := `SELECT br.id, br.member_id, br.book_id, br.user_id, br.borrow_date, 
-			  br.due_date, br.return_date, br.status, br.fine, br.notes, br.created_at,
+	query := `SELECT br.id, br.member_id, br.book_id, br.user_id, br.branch_id, br.borrow_date, 
+			  br.due_date, br.return_date, br.return_branch_id, br.status, br.fine, br.notes, br.created_at,
 			  m.id, m.member_code, m.name, m.email, m.member_type,
 			  b.id, b.isbn, b.title,
-			  u.id, u.name ` + baseQuery + ` ORDER BY br.created_at DESC LIMIT ? OFFSET ?`
+			  u.id, u.name,
+			  bb.code, bb.name, rb.code, rb.name ` + baseQuery + ` ORDER BY br.created_at DESC LIMIT ? OFFSET ?`
 	args = append(args, filter.Limit, offset)
 
 	rows, err := r.db.Query(query, args...)
@@ -76,7 +116,7 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 	var borrowings []models.Borrowing
 	for rows.Next() {
 		var br models.Borrowing
-		var userID sql.NullInt64
+		var userID, returnBranchID sql.NullInt64
 		var returnDate sql.NullTime
 		var notes sql.NullString
 
@@ -86,13 +126,15 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 		var bookISBN, bookTitle sql.NullString
 		var uID sql.NullInt64
 		var uName sql.NullString
+		var branchCode, branchName, returnCode, returnName sql.NullString
 
 		err := rows.Scan(
-			&br.ID, &br.MemberID, &br.BookID, &userID, &br.BorrowDate,
-			&br.DueDate, &returnDate, &br.Status, &br.Fine, &notes, &br.CreatedAt,
+			&br.ID, &br.MemberID, &br.BookID, &userID, &br.BranchID, &br.BorrowDate,
+			&br.DueDate, &returnDate, &returnBranchID, &br.Status, &br.Fine, &notes, &br.CreatedAt,
 			&memberID, &memberCode, &memberName, &memberEmail, &memberType,
 			&bookID, &bookISBN, &bookTitle,
 			&uID, &uName,
+			&branchCode, &branchName, &returnCode, &returnName,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -107,6 +149,13 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 		}
 		br.Notes = notes.String
 
+		br.Branch = &models.Branch{ID: br.BranchID, Code: branchCode.String, Name: branchName.String}
+		if returnBranchID.Valid {
+			id := int(returnBranchID.Int64)
+			br.ReturnBranchID = &id
+			br.ReturnBranch = &models.Branch{ID: id, Code: returnCode.String, Name: returnName.String}
+		}
+
 		br.Member = &models.Member{
 			ID:         memberID,
 			MemberCode: memberCode,
@@ -131,18 +180,18 @@ func (r *Repository) FindAll(filter models.BorrowingFilter) ([]models.Borrowing,
 	return borrowings, total, nil
 }
 
-func (r *Repository) FindByID(id int) (*models.Borrowing, error) {
+func (r *repository) FindByID(id int) (*models.Borrowing, error) {
 	br := &models.Borrowing{}
-	var userID sql.NullInt64
+	var userID, returnBranchID sql.NullInt64
 	var returnDate sql.NullTime
 	var notes sql.NullString
 
-	query := `SELECT id, member_id, book_id, user_id, borrow_date, due_date, 
-			  return_date, status, fine, notes, created_at FROM borrowings WHERE id = ?`
+	query := `SELECT id, member_id, book_id, user_id, branch_id, borrow_date, due_date, 
+			  return_date, return_branch_id, status, fine, notes, created_at FROM borrowings WHERE id = ?`
 
 	err := r.db.QueryRow(query, id).Scan(
-		&br.ID, &br.MemberID, &br.BookID, &userID, &br.BorrowDate,
-		&br.DueDate, &returnDate, &br.Status, &br.Fine, &notes, &br.CreatedAt,
+		&br.ID, &br.MemberID, &br.BookID, &userID, &br.BranchID, &br.BorrowDate,
+		&br.DueDate, &returnDate, &returnBranchID, &br.Status, &br.Fine, &notes, &br.CreatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -155,53 +204,109 @@ func (r *Repository) FindByID(id int) (*models.Borrowing, error) {
 	if returnDate.Valid {
 		br.ReturnDate = &returnDate.Time
 	}
+	if returnBranchID.Valid {
+		id := int(returnBranchID.Int64)
+		br.ReturnBranchID = &id
+	}
 	br.Notes = notes.String
 
 	return br, nil
 }
 
-func (r *Repository) Create(br *models.BorrowingCreate, userID int, dueDate time.Time) (int64, error) {
-	query := `INSERT INTO borrowings (member_id, book_id, user_id, borrow_date, due_date, status, notes) 
-			  VALUES (?, ?, ?, CURDATE(), ?, 'dipinjam', ?)`
+func (r *repository) Create(br *models.BorrowingCreate, userID int, dueDate time.Time, holdID int) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	result, err := r.db.Exec(query, br.MemberID, br.BookID, userID, dueDate, br.Notes)
+	if holdID > 0 {
+		result, err := tx.Exec(`UPDATE holds SET status = ?, closed_at = ? WHERE id = ? AND status = ?`,
+			models.HoldStatusFulfilled, time.Now(), holdID, models.HoldStatusReady)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return 0, err
+		} else if n == 0 {
+			return 0, ErrHoldChanged
+		}
+	} else if err := books.ApplyStock(tx, br.BookID, models.StockChange{BranchID: br.BranchID, Available: -1}); err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO borrowings (member_id, book_id, user_id, branch_id, borrow_date, due_date, status, notes) 
+			  VALUES (?, ?, ?, ?, ` + r.dialect.CurrentDate() + `, ?, 'dipinjam', ?)`
+
+	result, err := tx.Exec(query, br.MemberID, br.BookID, userID, br.BranchID, dueDate.Format(dateLayout), br.Notes)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	return id, tx.Commit()
 }
 
-func (r *Repository) Return(id int, returnData *models.BorrowingReturn) error {
+func (r *repository) Return(id int, returnData *models.BorrowingReturn, bookID int, changes ...models.StockChange) (bool, error) {
 	status := "dikembalikan"
 	if returnData.Fine > 0 {
 		status = "terlambat"
 	}
 
-	query := `UPDATE borrowings SET return_date = ?, status = ?, fine = ?, notes = CONCAT(IFNULL(notes, ''), ?) WHERE id = ?`
-	_, err := r.db.Exec(query, returnData.ReturnDate, status, returnData.Fine, returnData.Notes, id)
-	return err
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE borrowings SET return_date = ?, return_branch_id = ?, status = ?, fine = ?, notes = ` + r.dialect.Concat("notes", "?") + `
+			  WHERE id = ? AND status = 'dipinjam'`
+	result, err := tx.Exec(query, returnData.ReturnDate.Format(dateLayout), returnData.BranchID, status, returnData.Fine, returnData.Notes, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n != 1 {
+		return false, err
+	}
+
+	if err := books.ApplyStock(tx, bookID, changes...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
 }
 
-func (r *Repository) CountActive() (int, error) {
-	var count int
-	err := r.db.QueryRow(`SELECT COUNT(*) FROM borrowings WHERE status = 'dipinjam'`).Scan(&count)
-	return count, err
+func (r *repository) CountActive(branchID int) (int, error) {
+	return r.count(`status = 'dipinjam'`, branchID)
+}
+
+func (r *repository) CountOverdue(branchID int) (int, error) {
+	return r.count(`status = 'dipinjam' AND due_date < `+r.dialect.CurrentDate(), branchID)
 }
 
-func (r *Repository) CountOverdue() (int, error) {
+func (r *repository) count(where string, branchID int) (int, error) {
+	query := `SELECT COUNT(*) FROM borrowings WHERE ` + where
+	args := []interface{}{}
+	if branchID > 0 {
+		query += ` AND branch_id = ?`
+		args = append(args, branchID)
+	}
+
 	var count int
-	err := r.db.QueryRow(`SELECT COUNT(*) FROM borrowings WHERE status = 'dipinjam' AND due_date < CURDATE()`).Scan(&count)
+	err := r.db.QueryRow(query, args...).Scan(&count)
 	return count, err
 }
 
-func (r *Repository) FindOverdue() ([]models.Borrowing, error) {
+func (r *repository) FindOverdue() ([]models.Borrowing, error) {
 	query := `SELECT br.id, br.member_id, br.book_id, br.borrow_date, br.due_date, br.status,
 			  m.id, m.member_code, m.name, m.email,
 			  b.id, b.title
 			  FROM borrowings br
 			  LEFT JOIN members m ON br.member_id = m.id
 			  LEFT JOIN books b ON br.book_id = b.id
-			  WHERE br.status = 'dipinjam' AND br.due_date < CURDATE()
+			  WHERE br.status = 'dipinjam' AND br.due_date < ` + r.dialect.CurrentDate() + `
 			  ORDER BY br.due_date`
 
 	rows, err := r.db.Query(query)
@@ -242,7 +347,7 @@ func (r *Repository) FindOverdue() ([]models.Borrowing, error) {
 	return borrowings, nil
 }
 
-func (r *Repository) GetMemberBorrowings(memberID int) ([]models.Borrowing, error) {
+func (r *repository) GetMemberBorrowings(memberID int) ([]models.Borrowing, error) {
 	filter := models.BorrowingFilter{
 		MemberID: memberID,
 		Page:     1,
@@ -0,0 +1,201 @@
+package borrowings
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"simpus/database"
+	"simpus/database/sqlitetest"
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+)
+
+// assertStock checks the stock and available counters of a book at a branch.
+func assertStock(t *testing.T, db *sql.DB, bookID, branchID, stock, available int) {
+	t.Helper()
+	var gotStock, gotAvailable int
+	err := db.QueryRow(`SELECT stock, available FROM branch_stock WHERE book_id = ? AND branch_id = ?`, bookID, branchID).Scan(&gotStock, &gotAvailable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotStock != stock || gotAvailable != available {
+		t.Errorf("book %d at branch %d = %d/%d, want %d/%d", bookID, branchID, gotStock, gotAvailable, stock, available)
+	}
+}
+
+func TestRepositoryOverdueAndReturn(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db, database.SQLite)
+
+	overdueID, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1, Notes: "Pinjam."}, 1, time.Now().AddDate(0, 0, -3), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(&models.BorrowingCreate{MemberID: 2, BookID: 1, BranchID: 2}, 1, time.Now().AddDate(0, 0, 7), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for branchID, want := range map[int]int{0: 1, 1: 1, 2: 0} {
+		count, err := repo.CountOverdue(branchID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("CountOverdue(%d) = %d, want %d", branchID, count, want)
+		}
+	}
+	if count, _ := repo.CountActive(2); count != 1 {
+		t.Errorf("CountActive(2) = %d, want 1", count)
+	}
+
+	overdue, err := repo.FindOverdue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overdue) != 1 || overdue[0].ID != int(overdueID) {
+		t.Fatalf("FindOverdue = %+v, want borrowing %d", overdue, overdueID)
+	}
+
+	returned, err := repo.Return(int(overdueID), &models.BorrowingReturn{ReturnDate: time.Now(), BranchID: 2, Fine: 3000, Notes: " Terlambat."},
+		1, models.StockChange{BranchID: 1, Stock: -1}, models.StockChange{BranchID: 2, Stock: 1, Available: 1})
+	if err != nil || !returned {
+		t.Fatalf("Return = %v, %v", returned, err)
+	}
+	// A second return changes nothing
+	returned, err = repo.Return(int(overdueID), &models.BorrowingReturn{ReturnDate: time.Now(), BranchID: 1, Notes: " Lagi."},
+		1, models.StockChange{BranchID: 1, Available: 1})
+	if err != nil || returned {
+		t.Fatalf("second Return = %v, %v, want false", returned, err)
+	}
+	assertStock(t, db, 1, 1, 2, 2)
+	assertStock(t, db, 1, 2, 3, 2)
+
+	br, err := repo.FindByID(int(overdueID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if br.Status != "terlambat" || br.Notes != "Pinjam. Terlambat." || br.ReturnDate == nil {
+		t.Errorf("returned borrowing = status %q notes %q return %v", br.Status, br.Notes, br.ReturnDate)
+	}
+	if br.BranchID != 1 || br.ReturnBranchID == nil || *br.ReturnBranchID != 2 {
+		t.Errorf("branches = checkout %d return %v, want 1 and 2", br.BranchID, br.ReturnBranchID)
+	}
+}
+
+func TestRepositoryFindAllByBranch(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db, database.SQLite)
+
+	for _, branchID := range []int{1, 1, 2} {
+		if _, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: branchID}, 1, time.Now().AddDate(0, 0, 7), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, total, err := repo.FindAll(models.BorrowingFilter{BranchID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(list) != 1 {
+		t.Fatalf("borrowings at branch 2 = %d, want 1", total)
+	}
+	if list[0].Branch == nil || list[0].Branch.Code != "TIMUR" || list[0].ReturnBranch != nil {
+		t.Errorf("branch = %+v, return branch = %+v", list[0].Branch, list[0].ReturnBranch)
+	}
+}
+
+func TestRepositoryFindAllDateRange(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db, database.SQLite)
+
+	if _, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1, time.Now().AddDate(0, 0, 7), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now()
+	_, total, err := repo.FindAll(models.BorrowingFilter{FromDate: today, ToDate: today})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Errorf("borrowings today = %d, want 1", total)
+	}
+
+	_, total, err = repo.FindAll(models.BorrowingFilter{FromDate: today.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Errorf("borrowings from tomorrow = %d, want 0", total)
+	}
+}
+
+func TestRepositoryCreateTakesCopy(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db, database.SQLite)
+
+	// Laskar Pelangi (1) has 2 copies at the east branch (2)
+	for range 2 {
+		if _, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 2}, 1, time.Now().AddDate(0, 0, 7), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 2}, 1, time.Now().AddDate(0, 0, 7), 0); !errors.Is(err, books.ErrInsufficientStock) {
+		t.Fatalf("third loan: err = %v, want ErrInsufficientStock", err)
+	}
+	assertStock(t, db, 1, 2, 2, 0)
+
+	// The held copy is already off the shelf; a hold that is not ready
+	// lends nothing
+	if _, err := db.Exec(`INSERT INTO holds (id, member_id, book_id, branch_id, status) VALUES (1, 1, 1, 2, ?), (2, 2, 1, 2, ?)`,
+		models.HoldStatusReady, models.HoldStatusWaiting); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 2}, 1, time.Now().AddDate(0, 0, 7), 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(&models.BorrowingCreate{MemberID: 2, BookID: 1, BranchID: 2}, 1, time.Now().AddDate(0, 0, 7), 2); !errors.Is(err, ErrHoldChanged) {
+		t.Fatalf("loan for a waiting hold: err = %v, want ErrHoldChanged", err)
+	}
+	var status string
+	if err := db.QueryRow(`SELECT status FROM holds WHERE id = 1`).Scan(&status); err != nil || status != models.HoldStatusFulfilled {
+		t.Errorf("hold status = %q, %v, want fulfilled", status, err)
+	}
+	if count, _ := repo.CountActive(2); count != 3 {
+		t.Errorf("CountActive(2) = %d, want 3", count)
+	}
+}
+
+func TestRepositoryReturnKeepsLoanWhenStockIsRefused(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db, database.SQLite)
+
+	id, err := repo.Create(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 2}, 1, time.Now().AddDate(0, 0, 7), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The east branch cannot give up more copies than it holds
+	_, err = repo.Return(int(id), &models.BorrowingReturn{ReturnDate: time.Now(), BranchID: 1},
+		1, models.StockChange{BranchID: 2, Stock: -3}, models.StockChange{BranchID: 1, Stock: 3, Available: 3})
+	if !errors.Is(err, books.ErrInsufficientStock) {
+		t.Fatalf("err = %v, want ErrInsufficientStock", err)
+	}
+
+	br, err := repo.FindByID(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if br.Status != "dipinjam" || br.ReturnDate != nil {
+		t.Errorf("loan = status %q return %v, want still out", br.Status, br.ReturnDate)
+	}
+	assertStock(t, db, 1, 1, 3, 3)
+	assertStock(t, db, 1, 2, 2, 1)
+}
@@ -1,44 +1,81 @@
 package borrowings
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"math"
 	"time"
 
+	"simpus/config"
 	"simpus/internal/app/books"
-	"simpus/internal/app/members"
+	"simpus/internal/metrics"
 	"simpus/internal/models"
 )
 
-// NotificationRepository defines the interface for notification repository
-// to avoid import cycle with legacy repository package if needed,
-// though currently we might still import it if no cycle exists.
+// BookRepository is the part of books.BookRepository needed to lend books.
+type BookRepository interface {
+	FindByID(id int) (*models.Book, error)
+	FindStock(bookID int) ([]models.BranchStock, error)
+}
+
+// BranchRepository is the part of branches.Repository needed to check
+// where books are lent and returned.
+type BranchRepository interface {
+	FindByID(id int) (*models.Branch, error)
+}
+
+// MemberRepository is the part of members.Repository needed to check
+// borrowers.
+type MemberRepository interface {
+	FindByID(id int) (*models.Member, error)
+}
+
+// HoldRepository is the part of holds.Repository needed to lend the copy
+// kept for a member's ready hold.
+type HoldRepository interface {
+	FindReady(memberID, bookID, branchID int) (*models.Hold, error)
+}
+
 type NotificationRepository interface {
 	Create(notif *models.NotificationCreate) (int64, error)
 }
 
 type Service struct {
-	repo       *Repository
-	bookRepo   *books.BookRepository
-	memberRepo *members.Repository
+	repo       Repository
+	bookRepo   BookRepository
+	branchRepo BranchRepository
+	memberRepo MemberRepository
+	holdRepo   HoldRepository
 	notifRepo  NotificationRepository
+	policy     config.LoanConfig
 }
 
 func NewService(
-	repo *Repository,
-	bookRepo *books.BookRepository,
-	memberRepo *members.Repository,
+	repo Repository,
+	bookRepo BookRepository,
+	branchRepo BranchRepository,
+	memberRepo MemberRepository,
+	holdRepo HoldRepository,
 	notifRepo NotificationRepository,
+	policy config.LoanConfig,
 ) *Service {
 	return &Service{
 		repo:       repo,
 		bookRepo:   bookRepo,
+		branchRepo: branchRepo,
 		memberRepo: memberRepo,
+		holdRepo:   holdRepo,
 		notifRepo:  notifRepo,
+		policy:     policy,
 	}
 }
 
+// LoanPolicy returns the configured loan periods and fine rate.
+func (s *Service) LoanPolicy() config.LoanConfig {
+	return s.policy
+}
+
 func (s *Service) GetBorrowings(filter models.BorrowingFilter) ([]models.Borrowing, int, error) {
 	return s.repo.FindAll(filter)
 }
@@ -48,13 +85,31 @@ func (s *Service) GetBorrowing(id int) (*models.Borrowing, error) {
 }
 
 func (s *Service) CreateBorrowing(data *models.BorrowingCreate, userID int) (int64, error) {
-	// Check if book is available
-	book, err := s.bookRepo.FindByID(data.BookID)
-	if err != nil {
+	if data.BorrowDays > s.policy.MaxDays {
+		return 0, fmt.Errorf("lama peminjaman maksimal %d hari", s.policy.MaxDays)
+	}
+
+	// Check if book is available at the branch
+	if _, err := s.bookRepo.FindByID(data.BookID); err != nil {
 		return 0, errors.New("buku tidak ditemukan")
 	}
-	if book.Available <= 0 {
-		return 0, errors.New("buku tidak tersedia")
+	if err := s.checkBranch(data.BranchID); err != nil {
+		return 0, err
+	}
+
+	// A copy kept for the member's ready hold is already off the shelf
+	hold, err := s.holdRepo.FindReady(data.MemberID, data.BookID, data.BranchID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if hold == nil {
+		available, err := s.availableAt(data.BookID, data.BranchID)
+		if err != nil {
+			return 0, err
+		}
+		if available <= 0 {
+			return 0, errors.New("buku tidak tersedia di cabang ini")
+		}
 	}
 
 	// Check if member exists and is active
@@ -65,30 +120,39 @@ func (s *Service) CreateBorrowing(data *models.BorrowingCreate, userID int) (int
 	if !member.IsActive {
 		return 0, errors.New("anggota tidak aktif")
 	}
+	if !member.CanBorrow() {
+		return 0, errors.New("akun anggota belum aktif, selesaikan verifikasi email atau tunggu persetujuan pustakawan")
+	}
 
-	// Calculate due date (default 7 days)
+	// Calculate due date
 	borrowDays := data.BorrowDays
 	if borrowDays <= 0 {
-		borrowDays = 7
+		borrowDays = s.policy.DefaultDays
 	}
 	dueDate := time.Now().AddDate(0, 0, borrowDays)
 
-	// Create borrowing
-	id, err := s.repo.Create(data, userID, dueDate)
-	if err != nil {
-		return 0, err
+	// The copy is taken in the same transaction, so two loans cannot get
+	// the last one or claim the held copy
+	holdID := 0
+	if hold != nil {
+		holdID = hold.ID
+	}
+	id, err := s.repo.Create(data, userID, dueDate, holdID)
+	if errors.Is(err, books.ErrInsufficientStock) {
+		return 0, errors.New("buku tidak tersedia di cabang ini")
 	}
-
-	// Update book availability
-	err = s.bookRepo.UpdateAvailable(data.BookID, -1)
 	if err != nil {
 		return 0, err
 	}
 
+	metrics.LoansCreated.Inc()
 	return id, nil
 }
 
-func (s *Service) ReturnBook(id int) (*models.Borrowing, error) {
+// ReturnBook closes a loan at branchID, or at the checkout branch when
+// branchID is 0. A copy returned at another branch moves to that branch's
+// stock.
+func (s *Service) ReturnBook(id, branchID int) (*models.Borrowing, error) {
 	borrowing, err := s.repo.FindByID(id)
 	if err != nil {
 		return nil, errors.New("peminjaman tidak ditemukan")
@@ -98,41 +162,89 @@ func (s *Service) ReturnBook(id int) (*models.Borrowing, error) {
 		return nil, errors.New("buku sudah dikembalikan")
 	}
 
+	if branchID == 0 {
+		branchID = borrowing.BranchID
+	}
+	if branchID != borrowing.BranchID {
+		if err := s.checkBranch(branchID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Calculate fine if overdue
 	now := time.Now()
-	var fine float64
-	if now.After(borrowing.DueDate) {
-		days := int(math.Ceil(now.Sub(borrowing.DueDate).Hours() / 24))
-		fine = float64(days) * models.FinePerDay
-	}
+	fine := float64(overdueDays(borrowing.DueDate, now)) * s.policy.FinePerDay
 
 	returnData := &models.BorrowingReturn{
 		ReturnDate: now,
+		BranchID:   branchID,
 		Fine:       fine,
 	}
 
-	err = s.repo.Return(id, returnData)
-	if err != nil {
-		return nil, err
+	// Put the copy back on the shelf where it was returned
+	changes := []models.StockChange{{BranchID: branchID, Available: 1}}
+	if branchID != borrowing.BranchID {
+		changes = []models.StockChange{
+			{BranchID: borrowing.BranchID, Stock: -1},
+			{BranchID: branchID, Stock: 1, Available: 1},
+		}
 	}
 
-	// Update book availability
-	err = s.bookRepo.UpdateAvailable(borrowing.BookID, 1)
+	returned, err := s.repo.Return(id, returnData, borrowing.BookID, changes...)
 	if err != nil {
 		return nil, err
 	}
+	if !returned {
+		// Another request returned the loan since it was read
+		return nil, errors.New("buku sudah dikembalikan")
+	}
+
+	metrics.Returns.Inc()
+	metrics.FinesCharged.Add(fine)
 
 	// Get updated borrowing
 	borrowing, _ = s.repo.FindByID(id)
 	return borrowing, nil
 }
 
-func (s *Service) GetActiveCount() (int, error) {
-	return s.repo.CountActive()
+// GetActiveCount counts open loans checked out at a branch, or at all
+// branches when branchID is 0.
+func (s *Service) GetActiveCount(branchID int) (int, error) {
+	return s.repo.CountActive(branchID)
+}
+
+// GetOverdueCount counts overdue loans checked out at a branch, or at all
+// branches when branchID is 0.
+func (s *Service) GetOverdueCount(branchID int) (int, error) {
+	return s.repo.CountOverdue(branchID)
+}
+
+// checkBranch makes sure books can be lent or returned at the branch.
+func (s *Service) checkBranch(branchID int) error {
+	if branchID <= 0 {
+		return errors.New("cabang wajib dipilih")
+	}
+	branch, err := s.branchRepo.FindByID(branchID)
+	if err != nil {
+		return errors.New("cabang tidak ditemukan")
+	}
+	if !branch.IsActive {
+		return fmt.Errorf("cabang %s sedang tutup", branch.Name)
+	}
+	return nil
 }
 
-func (s *Service) GetOverdueCount() (int, error) {
-	return s.repo.CountOverdue()
+func (s *Service) availableAt(bookID, branchID int) (int, error) {
+	stock, err := s.bookRepo.FindStock(bookID)
+	if err != nil {
+		return 0, err
+	}
+	for _, st := range stock {
+		if st.BranchID == branchID {
+			return st.Available, nil
+		}
+	}
+	return 0, nil
 }
 
 func (s *Service) GetMemberBorrowings(memberID int) ([]models.Borrowing, error) {
@@ -147,8 +259,8 @@ func (s *Service) CheckAndCreateOverdueNotifications() (int, error) {
 
 	count := 0
 	for _, br := range overdue {
-		days := int(math.Ceil(time.Since(br.DueDate).Hours() / 24))
-		fine := float64(days) * models.FinePerDay
+		days := overdueDays(br.DueDate, time.Now())
+		fine := float64(days) * s.policy.FinePerDay
 
 		notif := &models.NotificationCreate{
 			BorrowingID: br.ID,
@@ -160,9 +272,23 @@ func (s *Service) CheckAndCreateOverdueNotifications() (int, error) {
 
 		_, err := s.notifRepo.Create(notif)
 		if err == nil {
+			metrics.NotificationsSent.WithLabelValues(notif.Type).Inc()
 			count++
 		}
 	}
 
 	return count, nil
 }
+
+// overdueDays counts calendar days from the due date to now. A book returned
+// on its due date is not late.
+func overdueDays(due, now time.Time) int {
+	dueDay := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	days := int(math.Round(today.Sub(dueDay).Hours() / 24))
+	if days < 0 {
+		return 0
+	}
+	return days
+}
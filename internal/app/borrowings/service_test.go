@@ -0,0 +1,506 @@
+package borrowings
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/config"
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+)
+
+// fakeBorrowingRepo takes and returns copies through the fake book and hold
+// repositories, changing nothing when the copy cannot be moved.
+type fakeBorrowingRepo struct {
+	borrowings map[int]*models.Borrowing
+	nextID     int
+	books      *fakeBookRepo
+	holds      *fakeHoldRepo
+}
+
+func newFakeBorrowingRepo(books *fakeBookRepo, holds *fakeHoldRepo) *fakeBorrowingRepo {
+	return &fakeBorrowingRepo{borrowings: map[int]*models.Borrowing{}, books: books, holds: holds}
+}
+
+func (r *fakeBorrowingRepo) FindAll(filter models.BorrowingFilter) ([]models.Borrowing, int, error) {
+	var list []models.Borrowing
+	for _, br := range r.borrowings {
+		if filter.MemberID > 0 && br.MemberID != filter.MemberID {
+			continue
+		}
+		list = append(list, *br)
+	}
+	return list, len(list), nil
+}
+
+func (r *fakeBorrowingRepo) FindByID(id int) (*models.Borrowing, error) {
+	br, ok := r.borrowings[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copy := *br
+	return &copy, nil
+}
+
+func (r *fakeBorrowingRepo) Create(data *models.BorrowingCreate, userID int, dueDate time.Time, holdID int) (int64, error) {
+	if holdID > 0 {
+		h, ok := r.holds.holds[holdID]
+		if !ok || h.Status != models.HoldStatusReady {
+			return 0, ErrHoldChanged
+		}
+		h.Status = models.HoldStatusFulfilled
+	} else if err := r.books.AdjustStock(data.BookID, models.StockChange{BranchID: data.BranchID, Available: -1}); err != nil {
+		return 0, err
+	}
+
+	r.nextID++
+	r.borrowings[r.nextID] = &models.Borrowing{
+		ID:         r.nextID,
+		MemberID:   data.MemberID,
+		BookID:     data.BookID,
+		UserID:     &userID,
+		BranchID:   data.BranchID,
+		BorrowDate: time.Now(),
+		DueDate:    dueDate,
+		Status:     "dipinjam",
+		Notes:      data.Notes,
+	}
+	return int64(r.nextID), nil
+}
+
+func (r *fakeBorrowingRepo) Return(id int, data *models.BorrowingReturn, bookID int, changes ...models.StockChange) (bool, error) {
+	br := r.borrowings[id]
+	if br.Status != "dipinjam" {
+		return false, nil
+	}
+	if err := r.books.AdjustStock(bookID, changes...); err != nil {
+		return false, err
+	}
+	br.ReturnBranchID = &data.BranchID
+	br.Status = "dikembalikan"
+	if data.Fine > 0 {
+		br.Status = "terlambat"
+	}
+	br.ReturnDate = &data.ReturnDate
+	br.Fine = data.Fine
+	br.Notes += data.Notes
+	return true, nil
+}
+
+func (r *fakeBorrowingRepo) CountActive(branchID int) (int, error) {
+	count := 0
+	for _, br := range r.borrowings {
+		if br.Status == "dipinjam" && (branchID == 0 || br.BranchID == branchID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeBorrowingRepo) CountOverdue(branchID int) (int, error) {
+	overdue, err := r.FindOverdue()
+	count := 0
+	for _, br := range overdue {
+		if branchID == 0 || br.BranchID == branchID {
+			count++
+		}
+	}
+	return count, err
+}
+
+func (r *fakeBorrowingRepo) FindOverdue() ([]models.Borrowing, error) {
+	var list []models.Borrowing
+	for _, br := range r.borrowings {
+		if br.Status == "dipinjam" && overdueDays(br.DueDate, time.Now()) > 0 {
+			b := *br
+			b.Book = &models.Book{ID: br.BookID, Title: "Laskar Pelangi"}
+			list = append(list, b)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeBorrowingRepo) GetMemberBorrowings(memberID int) ([]models.Borrowing, error) {
+	list, _, err := r.FindAll(models.BorrowingFilter{MemberID: memberID})
+	return list, err
+}
+
+type fakeBookRepo struct {
+	books map[int]*models.Book
+	stock map[int]map[int]*models.BranchStock // by book, then branch
+}
+
+func (r *fakeBookRepo) FindByID(id int) (*models.Book, error) {
+	b, ok := r.books[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copy := *b
+	return &copy, nil
+}
+
+func (r *fakeBookRepo) FindStock(bookID int) ([]models.BranchStock, error) {
+	var list []models.BranchStock
+	for _, s := range r.stock[bookID] {
+		list = append(list, *s)
+	}
+	return list, nil
+}
+
+// AdjustStock checks every change before applying any, like the
+// transaction in the real repository. The fake loans move stock with it.
+func (r *fakeBookRepo) AdjustStock(bookID int, changes ...models.StockChange) error {
+	for _, c := range changes {
+		s := r.stock[bookID][c.BranchID]
+		if s == nil {
+			s = &models.BranchStock{}
+		}
+		if s.Available+c.Available < 0 || s.Available+c.Available > s.Stock+c.Stock {
+			return books.ErrInsufficientStock
+		}
+	}
+
+	b := r.books[bookID]
+	for _, c := range changes {
+		s := r.stock[bookID][c.BranchID]
+		if s == nil {
+			s = &models.BranchStock{BranchID: c.BranchID}
+			r.stock[bookID][c.BranchID] = s
+		}
+		s.Stock += c.Stock
+		s.Available += c.Available
+		b.Stock += c.Stock
+		b.Available += c.Available
+	}
+	return nil
+}
+
+type fakeBranchRepo struct {
+	branches map[int]*models.Branch
+}
+
+func (r *fakeBranchRepo) FindByID(id int) (*models.Branch, error) {
+	b, ok := r.branches[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return b, nil
+}
+
+type fakeMemberRepo struct {
+	members map[int]*models.Member
+}
+
+func (r *fakeMemberRepo) FindByID(id int) (*models.Member, error) {
+	m, ok := r.members[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return m, nil
+}
+
+type fakeNotificationRepo struct {
+	created []models.NotificationCreate
+}
+
+func (r *fakeNotificationRepo) Create(n *models.NotificationCreate) (int64, error) {
+	r.created = append(r.created, *n)
+	return int64(len(r.created)), nil
+}
+
+type fakeHoldRepo struct {
+	holds map[int]*models.Hold
+}
+
+func (r *fakeHoldRepo) FindReady(memberID, bookID, branchID int) (*models.Hold, error) {
+	for _, h := range r.holds {
+		if h.MemberID == memberID && h.BookID == bookID && h.BranchID == branchID && h.Status == models.HoldStatusReady {
+			copy := *h
+			return &copy, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type fixture struct {
+	service   *Service
+	repo      *fakeBorrowingRepo
+	books     *fakeBookRepo
+	holds     *fakeHoldRepo
+	notifRepo *fakeNotificationRepo
+}
+
+var testPolicy = config.LoanConfig{DefaultDays: 7, MaxDays: 14, FinePerDay: 1500}
+
+func newFixture() *fixture {
+	f := &fixture{
+		books: &fakeBookRepo{
+			books: map[int]*models.Book{
+				1: {ID: 1, Title: "Laskar Pelangi", Stock: 2, Available: 2},
+				2: {ID: 2, Title: "Bumi", Stock: 1, Available: 0},
+			},
+			stock: map[int]map[int]*models.BranchStock{
+				1: {1: {BranchID: 1, Stock: 2, Available: 2}},
+				2: {1: {BranchID: 1, Stock: 1, Available: 0}},
+			},
+		},
+		holds:     &fakeHoldRepo{holds: map[int]*models.Hold{}},
+		notifRepo: &fakeNotificationRepo{},
+	}
+	f.repo = newFakeBorrowingRepo(f.books, f.holds)
+	branches := &fakeBranchRepo{branches: map[int]*models.Branch{
+		1: {ID: 1, Code: "PUSAT", Name: "Perpustakaan Pusat", IsActive: true},
+		2: {ID: 2, Code: "TIMUR", Name: "Cabang Timur", IsActive: true},
+		3: {ID: 3, Code: "BARAT", Name: "Cabang Barat", IsActive: false},
+	}}
+	members := &fakeMemberRepo{members: map[int]*models.Member{
+		1: {ID: 1, Name: "Budi", IsActive: true, Status: models.MemberStatusActive},
+		2: {ID: 2, Name: "Siti", IsActive: false, Status: models.MemberStatusActive},
+		3: {ID: 3, Name: "Rina", IsActive: true, Status: models.MemberStatusPendingApproval},
+		4: {ID: 4, Name: "Dewi", IsActive: true, Status: models.MemberStatusPendingVerification},
+	}}
+	f.service = NewService(f.repo, f.books, branches, members, f.holds, f.notifRepo, testPolicy)
+	return f
+}
+
+func TestCreateBorrowingDecrementsAvailability(t *testing.T) {
+	f := newFixture()
+
+	id, err := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.books.books[1].Available; got != 1 {
+		t.Errorf("available = %d, want 1", got)
+	}
+
+	br := f.repo.borrowings[int(id)]
+	if days := overdueDays(time.Now(), br.DueDate); days != 7 {
+		t.Errorf("loan period = %d days, want default 7", days)
+	}
+}
+
+func TestCreateBorrowingUsesRequestedPeriod(t *testing.T) {
+	f := newFixture()
+
+	id, err := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1, BorrowDays: 14}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days := overdueDays(time.Now(), f.repo.borrowings[int(id)].DueDate); days != 14 {
+		t.Errorf("loan period = %d days, want 14", days)
+	}
+}
+
+func TestCreateBorrowingRejections(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    models.BorrowingCreate
+		wantErr string
+	}{
+		{"unknown book", models.BorrowingCreate{MemberID: 1, BookID: 99, BranchID: 1}, "buku tidak ditemukan"},
+		{"no copies left", models.BorrowingCreate{MemberID: 1, BookID: 2, BranchID: 1}, "buku tidak tersedia di cabang ini"},
+		{"unknown member", models.BorrowingCreate{MemberID: 99, BookID: 1, BranchID: 1}, "anggota tidak ditemukan"},
+		{"inactive member", models.BorrowingCreate{MemberID: 2, BookID: 1, BranchID: 1}, "anggota tidak aktif"},
+		{"pending approval", models.BorrowingCreate{MemberID: 3, BookID: 1, BranchID: 1}, "akun anggota belum aktif"},
+		{"unverified email", models.BorrowingCreate{MemberID: 4, BookID: 1, BranchID: 1}, "akun anggota belum aktif"},
+		{"no branch", models.BorrowingCreate{MemberID: 1, BookID: 1}, "cabang wajib dipilih"},
+		{"branch without copies", models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 2}, "buku tidak tersedia di cabang ini"},
+		{"closed branch", models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 3}, "cabang Cabang Barat sedang tutup"},
+		{"longer than policy allows", models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1, BorrowDays: 15}, "lama peminjaman maksimal 14 hari"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			_, err := f.service.CreateBorrowing(&tt.data, 1)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if len(f.repo.borrowings) != 0 {
+				t.Error("borrowing created despite error")
+			}
+			if got := f.books.books[1].Available; got != 2 {
+				t.Errorf("available = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestReturnBookFines(t *testing.T) {
+	tests := []struct {
+		name       string
+		dueIn      int // days from today
+		wantFine   float64
+		wantStatus string
+	}{
+		{"early", 3, 0, "dikembalikan"},
+		{"on due date", 0, 0, "dikembalikan"},
+		{"one day late", -1, testPolicy.FinePerDay, "terlambat"},
+		{"five days late", -5, 5 * testPolicy.FinePerDay, "terlambat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture()
+			id, err := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Due dates come back from the DATE column at midnight
+			due := time.Now().AddDate(0, 0, tt.dueIn)
+			f.repo.borrowings[int(id)].DueDate = time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.Local)
+
+			br, err := f.service.ReturnBook(int(id), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if br.Fine != tt.wantFine || br.Status != tt.wantStatus {
+				t.Errorf("fine %.0f status %q, want %.0f %q", br.Fine, br.Status, tt.wantFine, tt.wantStatus)
+			}
+			if got := f.books.books[1].Available; got != 2 {
+				t.Errorf("available = %d after return, want 2", got)
+			}
+		})
+	}
+}
+
+func TestReturnBookTwice(t *testing.T) {
+	f := newFixture()
+	id, _ := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+
+	if _, err := f.service.ReturnBook(int(id), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.ReturnBook(int(id), 0); err == nil {
+		t.Fatal("second return succeeded, want error")
+	}
+	if got := f.books.books[1].Available; got != 2 {
+		t.Errorf("available = %d, want 2", got)
+	}
+}
+
+// staleBorrowingRepo reads every loan as still out, like a request that
+// loaded the loan just before another request returned it.
+type staleBorrowingRepo struct {
+	*fakeBorrowingRepo
+}
+
+func (r staleBorrowingRepo) FindByID(id int) (*models.Borrowing, error) {
+	br, err := r.fakeBorrowingRepo.FindByID(id)
+	if err == nil {
+		br.Status, br.ReturnDate = "dipinjam", nil
+	}
+	return br, err
+}
+
+func TestReturnBookConcurrently(t *testing.T) {
+	f := newFixture()
+	id, _ := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+	if _, err := f.service.ReturnBook(int(id), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	f.service.repo = staleBorrowingRepo{f.repo}
+	if _, err := f.service.ReturnBook(int(id), 2); err == nil || err.Error() != "buku sudah dikembalikan" {
+		t.Fatalf("err = %v, want buku sudah dikembalikan", err)
+	}
+	if got := f.books.books[1].Available; got != 2 {
+		t.Errorf("available = %d, want 2", got)
+	}
+	if _, moved := f.books.stock[1][2]; moved {
+		t.Error("stock moved to the second branch")
+	}
+}
+
+func TestReturnBookKeepsLoanWhenStockIsRefused(t *testing.T) {
+	f := newFixture()
+	id, _ := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+
+	// The checkout branch has no copy to give up to the second branch
+	f.books.stock[1][1].Available = 2
+	if _, err := f.service.ReturnBook(int(id), 2); !errors.Is(err, books.ErrInsufficientStock) {
+		t.Fatalf("err = %v, want ErrInsufficientStock", err)
+	}
+	if br := f.repo.borrowings[int(id)]; br.Status != "dipinjam" || br.ReturnDate != nil {
+		t.Errorf("loan = status %q return %v, want still out", br.Status, br.ReturnDate)
+	}
+	if _, moved := f.books.stock[1][2]; moved {
+		t.Error("stock moved to the second branch")
+	}
+}
+
+func TestCreateBorrowingLendsHeldCopy(t *testing.T) {
+	f := newFixture()
+
+	// The only copy of Bumi is kept for Budi's ready hold
+	f.holds.holds[1] = &models.Hold{ID: 1, MemberID: 1, BookID: 2, BranchID: 1, Status: models.HoldStatusReady}
+
+	if _, err := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 3, BookID: 2, BranchID: 1}, 1); err == nil {
+		t.Fatal("another member borrowed the held copy")
+	}
+	if _, err := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 2, BranchID: 1}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.holds.holds[1].Status; got != models.HoldStatusFulfilled {
+		t.Errorf("hold status = %q, want fulfilled", got)
+	}
+	if s := f.books.stock[2][1]; s.Stock != 1 || s.Available != 0 {
+		t.Errorf("stock = %d/%d, want 1/0", s.Stock, s.Available)
+	}
+}
+
+func TestReturnBookAtAnotherBranch(t *testing.T) {
+	f := newFixture()
+	id, err := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.service.ReturnBook(int(id), 3); err == nil || !strings.Contains(err.Error(), "sedang tutup") {
+		t.Fatalf("return at closed branch: err = %v", err)
+	}
+
+	br, err := f.service.ReturnBook(int(id), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if br.ReturnBranchID == nil || *br.ReturnBranchID != 2 {
+		t.Errorf("return branch = %v, want 2", br.ReturnBranchID)
+	}
+
+	// The copy now belongs to the branch where it was returned
+	pusat, timur := f.books.stock[1][1], f.books.stock[1][2]
+	if pusat.Stock != 1 || pusat.Available != 1 {
+		t.Errorf("checkout branch = %d/%d, want 1/1", pusat.Stock, pusat.Available)
+	}
+	if timur == nil || timur.Stock != 1 || timur.Available != 1 {
+		t.Errorf("return branch = %+v, want 1/1", timur)
+	}
+	if b := f.books.books[1]; b.Stock != 2 || b.Available != 2 {
+		t.Errorf("totals = %d/%d, want 2/2", b.Stock, b.Available)
+	}
+}
+
+func TestOverdueNotifications(t *testing.T) {
+	f := newFixture()
+	late, _ := f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+	f.service.CreateBorrowing(&models.BorrowingCreate{MemberID: 1, BookID: 1, BranchID: 1}, 1)
+	f.repo.borrowings[int(late)].DueDate = time.Now().AddDate(0, 0, -2)
+
+	count, err := f.service.CheckAndCreateOverdueNotifications()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || len(f.notifRepo.created) != 1 {
+		t.Fatalf("notifications = %d, want 1", count)
+	}
+
+	n := f.notifRepo.created[0]
+	if n.BorrowingID != int(late) || n.Type != "keterlambatan" || !strings.Contains(n.Message, "terlambat 2 hari. Denda: Rp 3000") {
+		t.Errorf("notification = %+v", n)
+	}
+}
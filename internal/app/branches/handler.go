@@ -0,0 +1,108 @@
+package branches
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simpus/internal/middleware"
+	"simpus/internal/models"
+	"simpus/internal/renderer"
+)
+
+type Handler struct {
+	service *Service
+	views   *renderer.Renderer
+}
+
+func NewHandler(service *Service, views *renderer.Renderer) *Handler {
+	return &Handler{
+		service: service,
+		views:   views,
+	}
+}
+
+func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	branches, err := h.service.GetBranches()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	claims := middleware.GetUserFromContext(r.Context())
+
+	data := map[string]interface{}{
+		"Title":    "Manajemen Cabang - SIMPUS",
+		"Branches": branches,
+		"Success":  r.URL.Query().Get("success"),
+		"Error":    r.URL.Query().Get("error"),
+		"User":     claims,
+	}
+
+	h.views.Render(w, r, "admin/branches/index.html", data)
+}
+
+func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	data := &models.BranchCreate{
+		Code:     r.FormValue("code"),
+		Name:     r.FormValue("name"),
+		Address:  r.FormValue("address"),
+		IsActive: true,
+	}
+
+	if _, err := h.service.CreateBranch(data); err != nil {
+		redirect(w, r, "error", err.Error())
+		return
+	}
+	redirect(w, r, "success", "Cabang "+data.Name+" berhasil ditambahkan")
+}
+
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	data := &models.BranchCreate{
+		Code:     r.FormValue("code"),
+		Name:     r.FormValue("name"),
+		Address:  r.FormValue("address"),
+		IsActive: r.FormValue("is_active") == "on",
+	}
+
+	if err := h.service.UpdateBranch(id, data); err != nil {
+		redirect(w, r, "error", err.Error())
+		return
+	}
+	redirect(w, r, "success", "Cabang "+data.Name+" berhasil diperbarui")
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, key, message string) {
+	target := "/admin/branches?" + key + "=" + url.QueryEscape(message)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", target)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// Selected returns the branch picked with the "branch" query parameter, or
+// fallback when there is none. "0" selects all branches.
+func Selected(r *http.Request, fallback int) int {
+	value := r.URL.Query().Get("branch")
+	if value == "" {
+		return fallback
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return fallback
+	}
+	return id
+}
@@ -0,0 +1,85 @@
+package branches
+
+import (
+	"database/sql"
+	"simpus/internal/models"
+)
+
+// Repository stores library branches.
+type Repository interface {
+	FindAll() ([]models.Branch, error)
+	FindByID(id int) (*models.Branch, error)
+	FindByCode(code string) (*models.Branch, error)
+	Create(b *models.BranchCreate) (int64, error)
+	Update(id int, b *models.BranchCreate) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) FindAll() ([]models.Branch, error) {
+	query := `SELECT br.id, br.code, br.name, br.address, br.is_active, br.created_at,
+			  COALESCE(SUM(bs.stock), 0) as copies
+			  FROM branches br
+			  LEFT JOIN branch_stock bs ON bs.branch_id = br.id
+			  GROUP BY br.id, br.code, br.name, br.address, br.is_active, br.created_at
+			  ORDER BY br.id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var branches []models.Branch
+	for rows.Next() {
+		var b models.Branch
+		var address sql.NullString
+		err := rows.Scan(&b.ID, &b.Code, &b.Name, &address, &b.IsActive, &b.CreatedAt, &b.Copies)
+		if err != nil {
+			return nil, err
+		}
+		b.Address = address.String
+		branches = append(branches, b)
+	}
+	return branches, rows.Err()
+}
+
+func (r *repository) FindByID(id int) (*models.Branch, error) {
+	return r.findOne(`SELECT id, code, name, address, is_active, created_at FROM branches WHERE id = ?`, id)
+}
+
+func (r *repository) FindByCode(code string) (*models.Branch, error) {
+	return r.findOne(`SELECT id, code, name, address, is_active, created_at FROM branches WHERE code = ?`, code)
+}
+
+func (r *repository) findOne(query string, arg interface{}) (*models.Branch, error) {
+	b := &models.Branch{}
+	var address sql.NullString
+	err := r.db.QueryRow(query, arg).Scan(&b.ID, &b.Code, &b.Name, &address, &b.IsActive, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	b.Address = address.String
+	return b, nil
+}
+
+func (r *repository) Create(b *models.BranchCreate) (int64, error) {
+	query := `INSERT INTO branches (code, name, address, is_active) VALUES (?, ?, ?, ?)`
+	result, err := r.db.Exec(query, b.Code, b.Name, b.Address, b.IsActive)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *repository) Update(id int, b *models.BranchCreate) error {
+	query := `UPDATE branches SET code = ?, name = ?, address = ?, is_active = ? WHERE id = ?`
+	_, err := r.db.Exec(query, b.Code, b.Name, b.Address, b.IsActive, id)
+	return err
+}
@@ -0,0 +1,114 @@
+package branches
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"simpus/internal/models"
+)
+
+// ErrNotFound is returned for unknown branch IDs and codes.
+var ErrNotFound = errors.New("cabang tidak ditemukan")
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// GetBranches returns all branches, including inactive ones, with the
+// number of copies each holds.
+func (s *Service) GetBranches() ([]models.Branch, error) {
+	return s.repo.FindAll()
+}
+
+// GetActiveBranches returns the branches where books can be lent and
+// returned.
+func (s *Service) GetActiveBranches() ([]models.Branch, error) {
+	all, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var active []models.Branch
+	for _, b := range all {
+		if b.IsActive {
+			active = append(active, b)
+		}
+	}
+	return active, nil
+}
+
+func (s *Service) GetBranch(id int) (*models.Branch, error) {
+	b, err := s.repo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// GetBranchByCode looks a branch up by its code, ignoring case.
+func (s *Service) GetBranchByCode(code string) (*models.Branch, error) {
+	b, err := s.repo.FindByCode(strings.ToUpper(strings.TrimSpace(code)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+func (s *Service) CreateBranch(data *models.BranchCreate) (int64, error) {
+	if err := s.validate(0, data); err != nil {
+		return 0, err
+	}
+	return s.repo.Create(data)
+}
+
+func (s *Service) UpdateBranch(id int, data *models.BranchCreate) error {
+	if _, err := s.GetBranch(id); err != nil {
+		return err
+	}
+	if err := s.validate(id, data); err != nil {
+		return err
+	}
+	return s.repo.Update(id, data)
+}
+
+// SetBranchActive opens or closes a branch. Closed branches keep their
+// history but no longer take loans or returns.
+func (s *Service) SetBranchActive(id int, active bool) error {
+	b, err := s.GetBranch(id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Update(id, &models.BranchCreate{
+		Code:     b.Code,
+		Name:     b.Name,
+		Address:  b.Address,
+		IsActive: active,
+	})
+}
+
+// validate normalises the code and checks it is unique. id is the branch
+// being updated, or 0 for a new branch.
+func (s *Service) validate(id int, data *models.BranchCreate) error {
+	data.Code = strings.ToUpper(strings.TrimSpace(data.Code))
+	data.Name = strings.TrimSpace(data.Name)
+	if data.Code == "" || data.Name == "" {
+		return errors.New("kode dan nama cabang wajib diisi")
+	}
+	if len(data.Code) > 20 || strings.ContainsAny(data.Code, " \t") {
+		return errors.New("kode cabang maksimal 20 karakter tanpa spasi")
+	}
+
+	existing, err := s.repo.FindByCode(data.Code)
+	if err == nil && existing.ID != id {
+		return errors.New("kode cabang sudah digunakan")
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package branches
+
+import (
+	"errors"
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+)
+
+func newTestService(t *testing.T) *Service {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	return NewService(NewRepository(db))
+}
+
+func TestGetBranchesCountsCopies(t *testing.T) {
+	s := newTestService(t)
+
+	list, err := s.GetBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) < 2 {
+		t.Fatalf("got %d branches, want the seeded two", len(list))
+	}
+	for _, b := range list {
+		if b.Copies <= 0 {
+			t.Errorf("branch %s holds %d copies", b.Code, b.Copies)
+		}
+	}
+}
+
+func TestCreateBranchValidation(t *testing.T) {
+	s := newTestService(t)
+
+	tests := []struct {
+		name string
+		data models.BranchCreate
+		want string
+	}{
+		{"missing name", models.BranchCreate{Code: "BARAT"}, "kode dan nama cabang wajib diisi"},
+		{"space in code", models.BranchCreate{Code: "CAB BARAT", Name: "Cabang Barat"}, "kode cabang maksimal 20 karakter tanpa spasi"},
+		{"duplicate code", models.BranchCreate{Code: "pusat", Name: "Pusat Lagi"}, "kode cabang sudah digunakan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CreateBranch(&tt.data)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseBranch(t *testing.T) {
+	s := newTestService(t)
+
+	id, err := s.CreateBranch(&models.BranchCreate{Code: " barat ", Name: "Cabang Barat", IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := s.GetBranchByCode("Barat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ID != int(id) || b.Code != "BARAT" {
+		t.Fatalf("branch = %+v", b)
+	}
+
+	if err := s.SetBranchActive(int(id), false); err != nil {
+		t.Fatal(err)
+	}
+	active, err := s.GetActiveBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range active {
+		if a.ID == int(id) {
+			t.Fatal("closed branch is still listed as active")
+		}
+	}
+
+	if _, err := s.GetBranch(999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown branch: err = %v", err)
+	}
+}
@@ -1,59 +1,66 @@
 package dashboard
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
 
 	"simpus/internal/app/books"
 	"simpus/internal/app/borrowings"
+	"simpus/internal/app/branches"
 	"simpus/internal/app/members"
 	"simpus/internal/middleware"
+	"simpus/internal/renderer"
 )
 
 type Handler struct {
 	bookService   *books.Service
 	memberService *members.Service
 	borrowService *borrowings.Service
-	templates     *template.Template
+	branchService *branches.Service
+	views         *renderer.Renderer
 }
 
 func NewHandler(
 	bookService *books.Service,
 	memberService *members.Service,
 	borrowService *borrowings.Service,
-	templates *template.Template,
+	branchService *branches.Service,
+	views *renderer.Renderer,
 ) *Handler {
 	return &Handler{
 		bookService:   bookService,
 		memberService: memberService,
 		borrowService: borrowService,
-		templates:     templates,
+		branchService: branchService,
+		views:         views,
 	}
 }
 
 func (h *Handler) AdminDashboard(w http.ResponseWriter, r *http.Request) {
-	// Get stats
-	activeBorrowings, _ := h.borrowService.GetActiveCount()
-	overdueBorrowings, _ := h.borrowService.GetOverdueCount()
-	totalBooks, _ := h.bookService.GetBookCount()
-	totalMembers, _ := h.memberService.GetMemberCount()
+	claims := middleware.GetUserFromContext(r.Context())
 
-	// Check overdue and create notifications
-	h.borrowService.CheckAndCreateOverdueNotifications()
+	// Staff see their own branch by default; members are shared by all
+	branchID := branches.Selected(r, claims.BranchID)
 
-	claims := middleware.GetUserFromContext(r.Context())
+	// Get stats
+	activeBorrowings, _ := h.borrowService.GetActiveCount(branchID)
+	overdueBorrowings, _ := h.borrowService.GetOverdueCount(branchID)
+	totalBooks, availableBooks, _ := h.bookService.GetStats(branchID)
+	totalMembers, _ := h.memberService.GetMemberCount()
+	branchList, _ := h.branchService.GetBranches()
 
 	data := map[string]interface{}{
 		"Title":             "Dashboard - SIMPUS",
 		"ActiveBorrowings":  activeBorrowings,
 		"OverdueBorrowings": overdueBorrowings,
 		"TotalBooks":        totalBooks,
+		"AvailableBooks":    availableBooks,
 		"TotalMembers":      totalMembers,
+		"BranchID":          branchID,
+		"Branches":          branchList,
 		"User":              claims,
 	}
 
-	h.render(w, "admin/dashboard.html", data)
+	h.views.Render(w, r, "admin/dashboard.html", data)
 }
 
 func (h *Handler) MemberDashboard(w http.ResponseWriter, r *http.Request) {
@@ -61,58 +68,14 @@ func (h *Handler) MemberDashboard(w http.ResponseWriter, r *http.Request) {
 
 	// Get member borrowings
 	borrowings, _ := h.borrowService.GetMemberBorrowings(claims.UserID)
+	member, _ := h.memberService.GetMember(claims.UserID)
 
 	data := map[string]interface{}{
 		"Title":      "Dashboard - SIMPUS",
 		"Borrowings": borrowings,
+		"Member":     member,
 		"User":       claims,
 	}
 
-	h.renderMember(w, "member/dashboard.html", data)
-}
-
-func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (h *Handler) renderMember(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "member.html"),
-		filepath.Join("templates", "components", "member-sidebar.html"),
-		filepath.Join("templates", "components", "member-header.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "member.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	h.views.Render(w, r, "member/dashboard.html", data)
 }
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Check is one readiness condition; Func returns nil when it is satisfied.
+type Check struct {
+	Name string
+	Func func(ctx context.Context) error
+}
+
+type Handler struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+func NewHandler(checks ...Check) *Handler {
+	return &Handler{
+		checks:  checks,
+		timeout: 2 * time.Second,
+	}
+}
+
+// Live reports that the process is up and serving requests.
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// Ready runs every check and answers 503 when any of them fails, so the
+// orchestrator stops routing traffic to this instance.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	status, code := "ok", http.StatusOK
+	results := make(map[string]string, len(h.checks))
+	for _, c := range h.checks {
+		if err := c.Func(ctx); err != nil {
+			results[c.Name] = err.Error()
+			status, code = "unavailable", http.StatusServiceUnavailable
+			continue
+		}
+		results[c.Name] = "ok"
+	}
+
+	writeJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
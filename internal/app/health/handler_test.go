@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	ok := Check{Name: "database", Func: func(ctx context.Context) error { return nil }}
+	failing := Check{Name: "migrations", Func: func(ctx context.Context) error { return errors.New("2 pending migration(s)") }}
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantCode   int
+		wantChecks map[string]string
+	}{
+		{"all passing", []Check{ok}, http.StatusOK, map[string]string{"database": "ok"}},
+		{"one failing", []Check{ok, failing}, http.StatusServiceUnavailable, map[string]string{"database": "ok", "migrations": "2 pending migration(s)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			NewHandler(tt.checks...).Ready(w, httptest.NewRequest("GET", "/readyz", nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			var body struct {
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.wantChecks {
+				if body.Checks[name] != want {
+					t.Errorf("check %s = %q, want %q", name, body.Checks[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package holds
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simpus/internal/middleware"
+	"simpus/internal/renderer"
+)
+
+type Handler struct {
+	service *Service
+	views   *renderer.Renderer
+}
+
+func NewHandler(service *Service, views *renderer.Renderer) *Handler {
+	return &Handler{
+		service: service,
+		views:   views,
+	}
+}
+
+func (h *Handler) MemberIndex(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r.Context())
+	holds, err := h.service.GetMemberHolds(claims.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":   "Pesanan Buku - SIMPUS",
+		"Holds":   holds,
+		"Success": r.URL.Query().Get("success"),
+		"Error":   r.URL.Query().Get("error"),
+		"User":    claims,
+	}
+
+	h.views.Render(w, r, "member/holds/index.html", data)
+}
+
+func (h *Handler) MemberStore(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Redirect(w, r, "/member/books?error=Form tidak valid", http.StatusSeeOther)
+		return
+	}
+
+	bookID, _ := strconv.Atoi(r.FormValue("book_id"))
+	branchID, _ := strconv.Atoi(r.FormValue("branch_id"))
+	anyEdition := r.FormValue("any_edition") == "1"
+	claims := middleware.GetUserFromContext(r.Context())
+
+	if _, err := h.service.PlaceHold(claims.UserID, bookID, branchID, anyEdition); err != nil {
+		http.Redirect(w, r, "/member/books/"+strconv.Itoa(bookID)+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/member/holds?success="+url.QueryEscape("Pesanan dicatat, Anda akan diberi tahu saat buku siap diambil"), http.StatusSeeOther)
+}
+
+func (h *Handler) MemberCancel(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	claims := middleware.GetUserFromContext(r.Context())
+
+	if err := h.service.CancelHold(id, claims.UserID); err != nil {
+		http.Redirect(w, r, "/member/holds?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/member/holds?success="+url.QueryEscape("Pesanan dibatalkan"), http.StatusSeeOther)
+}
+
+// Ready sets aside a copy from the pickup branch's shelf for a waiting hold.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := h.service.ReadyFromShelf(id); err != nil {
+		redirect(w, r, "error", err.Error())
+		return
+	}
+	redirect(w, r, "success", "Pesanan siap diambil, anggota sudah diberi tahu")
+}
+
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := h.service.CancelHold(id, 0); err != nil {
+		redirect(w, r, "error", err.Error())
+		return
+	}
+	redirect(w, r, "success", "Pesanan dibatalkan")
+}
+
+// redirect returns staff to the transfers page, where holds are managed.
+func redirect(w http.ResponseWriter, r *http.Request, key, message string) {
+	http.Redirect(w, r, "/admin/transfers?"+key+"="+url.QueryEscape(message), http.StatusSeeOther)
+}
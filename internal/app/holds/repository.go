@@ -0,0 +1,168 @@
+package holds
+
+import (
+	"database/sql"
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+	"time"
+)
+
+// Repository stores member holds.
+type Repository interface {
+	FindAll(filter models.HoldFilter) ([]models.Hold, error)
+	FindByID(id int) (*models.Hold, error)
+	// FindOpen returns the member's waiting or ready hold on a book.
+	FindOpen(memberID, bookID int) (*models.Hold, error)
+	// FindReady returns the member's ready hold on a book at a branch.
+	FindReady(memberID, bookID, branchID int) (*models.Hold, error)
+	Create(memberID, bookID, branchID int, anyEdition bool) (int64, error)
+	// SetBook moves an any-edition hold to another edition.
+	SetBook(id, bookID int) error
+	// SetReady marks a waiting hold ready for a copy of bookID, which may
+	// be another edition, and applies the stock changes for bookID in the
+	// same transaction. It reports whether the hold was still waiting.
+	SetReady(id, bookID int, changes ...models.StockChange) (bool, error)
+	// Cancel cancels a hold still in status from and applies the stock
+	// changes for bookID in the same transaction. It reports whether the
+	// hold was still in from.
+	Cancel(id int, from string, bookID int, changes ...models.StockChange) (bool, error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+const selectHold = `SELECT h.id, h.member_id, h.book_id, h.branch_id, h.any_edition, h.status, h.created_at, h.ready_at, h.closed_at,
+			  m.member_code, m.name, b.title, br.code, br.name
+			  FROM holds h
+			  JOIN members m ON h.member_id = m.id
+			  JOIN books b ON h.book_id = b.id
+			  JOIN branches br ON h.branch_id = br.id`
+
+func (r *repository) FindAll(filter models.HoldFilter) ([]models.Hold, error) {
+	query := selectHold + ` WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.MemberID > 0 {
+		query += ` AND h.member_id = ?`
+		args = append(args, filter.MemberID)
+	}
+	if filter.BranchID > 0 {
+		query += ` AND h.branch_id = ?`
+		args = append(args, filter.BranchID)
+	}
+	if filter.Status != "" {
+		query += ` AND h.status = ?`
+		args = append(args, filter.Status)
+	}
+	// Oldest first: holds are served in the order they were placed
+	query += ` ORDER BY h.created_at, h.id`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []models.Hold
+	for rows.Next() {
+		h, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *h)
+	}
+	return holds, rows.Err()
+}
+
+func (r *repository) FindByID(id int) (*models.Hold, error) {
+	return scanHold(r.db.QueryRow(selectHold+` WHERE h.id = ?`, id))
+}
+
+func (r *repository) FindOpen(memberID, bookID int) (*models.Hold, error) {
+	query := selectHold + ` WHERE h.member_id = ? AND h.book_id = ? AND h.status IN (?, ?)`
+	return scanHold(r.db.QueryRow(query, memberID, bookID, models.HoldStatusWaiting, models.HoldStatusReady))
+}
+
+func (r *repository) FindReady(memberID, bookID, branchID int) (*models.Hold, error) {
+	query := selectHold + ` WHERE h.member_id = ? AND h.book_id = ? AND h.branch_id = ? AND h.status = ?`
+	return scanHold(r.db.QueryRow(query, memberID, bookID, branchID, models.HoldStatusReady))
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHold(row scanner) (*models.Hold, error) {
+	h := &models.Hold{}
+	var readyAt, closedAt sql.NullTime
+	var memberCode, memberName, bookTitle, branchCode, branchName string
+
+	err := row.Scan(&h.ID, &h.MemberID, &h.BookID, &h.BranchID, &h.AnyEdition, &h.Status, &h.CreatedAt, &readyAt, &closedAt,
+		&memberCode, &memberName, &bookTitle, &branchCode, &branchName)
+	if err != nil {
+		return nil, err
+	}
+	if readyAt.Valid {
+		h.ReadyAt = &readyAt.Time
+	}
+	if closedAt.Valid {
+		h.ClosedAt = &closedAt.Time
+	}
+	h.Member = &models.Member{ID: h.MemberID, MemberCode: memberCode, Name: memberName}
+	h.Book = &models.Book{ID: h.BookID, Title: bookTitle}
+	h.Branch = &models.Branch{ID: h.BranchID, Code: branchCode, Name: branchName}
+	return h, nil
+}
+
+func (r *repository) Create(memberID, bookID, branchID int, anyEdition bool) (int64, error) {
+	query := `INSERT INTO holds (member_id, book_id, branch_id, any_edition, status) VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, memberID, bookID, branchID, anyEdition, models.HoldStatusWaiting)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *repository) SetBook(id, bookID int) error {
+	_, err := r.db.Exec(`UPDATE holds SET book_id = ? WHERE id = ?`, bookID, id)
+	return err
+}
+
+func (r *repository) SetReady(id, bookID int, changes ...models.StockChange) (bool, error) {
+	query := `UPDATE holds SET book_id = ?, status = ?, ready_at = ? WHERE id = ? AND status = ?`
+	return r.update(bookID, changes, query, bookID, models.HoldStatusReady, time.Now(), id, models.HoldStatusWaiting)
+}
+
+func (r *repository) Cancel(id int, from string, bookID int, changes ...models.StockChange) (bool, error) {
+	query := `UPDATE holds SET status = ?, closed_at = ? WHERE id = ? AND status = ?`
+	return r.update(bookID, changes, query, models.HoldStatusCancelled, time.Now(), id, from)
+}
+
+// update runs a conditional update of one hold and, when it matched, the
+// stock changes for bookID, all in one transaction.
+func (r *repository) update(bookID int, changes []models.StockChange, query string, args ...interface{}) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	if err := books.ApplyStock(tx, bookID, changes...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
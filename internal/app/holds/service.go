@@ -0,0 +1,327 @@
+package holds
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+)
+
+// BookRepository is the part of books.BookRepository needed to set copies
+// aside for holds, and to find the editions an any-edition hold can take.
+type BookRepository interface {
+	FindAll(filter models.BookFilter) ([]models.Book, int, error)
+	FindByID(id int) (*models.Book, error)
+	FindStock(bookID int) ([]models.BranchStock, error)
+	FindStockByBooks(bookIDs []int) (map[int][]models.BranchStock, error)
+}
+
+// BranchRepository is the part of branches.Repository needed to check
+// pickup branches.
+type BranchRepository interface {
+	FindByID(id int) (*models.Branch, error)
+}
+
+// Notifier is the part of notifications.Service used to tell members their
+// book is ready.
+type Notifier interface {
+	CreateNotification(data *models.NotificationCreate) (int64, error)
+}
+
+// maxEditions caps the editions of a work an any-edition hold looks at.
+const maxEditions = 100
+
+type Service struct {
+	repo       Repository
+	bookRepo   BookRepository
+	branchRepo BranchRepository
+	notifier   Notifier
+}
+
+func NewService(repo Repository, bookRepo BookRepository, branchRepo BranchRepository, notifier Notifier) *Service {
+	return &Service{
+		repo:       repo,
+		bookRepo:   bookRepo,
+		branchRepo: branchRepo,
+		notifier:   notifier,
+	}
+}
+
+func (s *Service) GetHolds(filter models.HoldFilter) ([]models.Hold, error) {
+	return s.repo.FindAll(filter)
+}
+
+func (s *Service) GetMemberHolds(memberID int) ([]models.Hold, error) {
+	return s.repo.FindAll(models.HoldFilter{MemberID: memberID})
+}
+
+func (s *Service) GetHold(id int) (*models.Hold, error) {
+	h, err := s.repo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("pesanan tidak ditemukan")
+	}
+	return h, err
+}
+
+// PlaceHold asks for a book to be brought to a pickup branch where no copy
+// is available. Books on the shelf there can be borrowed directly. With
+// anyEdition, a copy of any edition of the book's work will do.
+func (s *Service) PlaceHold(memberID, bookID, branchID int, anyEdition bool) (int64, error) {
+	book, err := s.bookRepo.FindByID(bookID)
+	if err != nil {
+		return 0, errors.New("buku tidak ditemukan")
+	}
+	if anyEdition && book.WorkID == nil {
+		return 0, errors.New("buku ini tidak memiliki edisi lain")
+	}
+	if branchID <= 0 {
+		return 0, errors.New("cabang pengambilan wajib dipilih")
+	}
+	branch, err := s.branchRepo.FindByID(branchID)
+	if err != nil {
+		return 0, errors.New("cabang tidak ditemukan")
+	}
+	if !branch.IsActive {
+		return 0, fmt.Errorf("cabang %s sedang tutup", branch.Name)
+	}
+
+	editions, err := s.editions(book)
+	if err != nil {
+		return 0, err
+	}
+	// A hold on one edition and another on any edition would ask for the
+	// same book twice
+	for _, e := range editions {
+		h, err := s.repo.FindOpen(memberID, e)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if anyEdition || h.AnyEdition || e == bookID {
+			return 0, errors.New("buku ini sudah Anda pesan")
+		}
+	}
+
+	if !anyEdition {
+		editions = editions[:1]
+	}
+	stock, err := s.bookRepo.FindStockByBooks(editions)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range editions {
+		for _, st := range stock[e] {
+			if st.BranchID == branchID && st.Available > 0 {
+				if anyEdition {
+					return 0, errors.New("salah satu edisi tersedia di cabang ini, silakan langsung pinjam")
+				}
+				return 0, errors.New("buku tersedia di cabang ini, silakan langsung pinjam")
+			}
+		}
+	}
+
+	return s.repo.Create(memberID, bookID, branchID, anyEdition)
+}
+
+// editions returns the IDs of the book and then of the other editions of
+// its work.
+func (s *Service) editions(book *models.Book) ([]int, error) {
+	ids := []int{book.ID}
+	if book.WorkID == nil {
+		return ids, nil
+	}
+	books, _, err := s.bookRepo.FindAll(models.BookFilter{WorkID: *book.WorkID, Page: 1, Limit: maxEditions})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range books {
+		if b.ID != book.ID {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids, nil
+}
+
+// candidates returns the books a hold can be served with: its own, then
+// for an any-edition hold the other editions of the work.
+func (s *Service) candidates(h *models.Hold) ([]int, error) {
+	if !h.AnyEdition {
+		return []int{h.BookID}, nil
+	}
+	book, err := s.bookRepo.FindByID(h.BookID)
+	if err != nil {
+		return nil, err
+	}
+	return s.editions(book)
+}
+
+// Sources returns, for each hold, the branches other than its pickup
+// branch that have a copy on the shelf to send, keyed by hold ID. The
+// copies of all editions an any-edition hold can take are counted together.
+func (s *Service) Sources(holds []models.Hold) (map[int][]models.BranchStock, error) {
+	books := map[int][]int{}
+	var ids []int
+	for _, h := range holds {
+		c, err := s.candidates(&h)
+		if err != nil {
+			return nil, err
+		}
+		books[h.ID] = c
+		ids = append(ids, c...)
+	}
+	stock, err := s.bookRepo.FindStockByBooks(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[int][]models.BranchStock{}
+	for _, h := range holds {
+		var list []models.BranchStock
+		at := map[int]int{} // branch ID to its index in list
+		for _, id := range books[h.ID] {
+			for _, st := range stock[id] {
+				if st.BranchID == h.BranchID || st.Available == 0 {
+					continue
+				}
+				if i, ok := at[st.BranchID]; ok {
+					list[i].Stock += st.Stock
+					list[i].Available += st.Available
+					continue
+				}
+				at[st.BranchID] = len(list)
+				list = append(list, st)
+			}
+		}
+		sources[h.ID] = list
+	}
+	return sources, nil
+}
+
+// PickEdition chooses the edition a transfer from a branch brings for a
+// waiting hold and moves the hold to it. A hold on one edition keeps it.
+func (s *Service) PickEdition(id, fromBranchID int) (int, error) {
+	h, err := s.GetHold(id)
+	if err != nil {
+		return 0, err
+	}
+	if !h.AnyEdition {
+		return h.BookID, nil
+	}
+	books, err := s.candidates(h)
+	if err != nil {
+		return 0, err
+	}
+	stock, err := s.bookRepo.FindStockByBooks(books)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range books {
+		for _, st := range stock[b] {
+			if st.BranchID == fromBranchID && st.Available > 0 {
+				if b != h.BookID {
+					if err := s.repo.SetBook(id, b); err != nil {
+						return 0, err
+					}
+				}
+				return b, nil
+			}
+		}
+	}
+	return h.BookID, nil
+}
+
+// CancelHold cancels a waiting or ready hold. memberID is the member asking,
+// or 0 for staff. The copy kept for a ready hold goes back on the shelf.
+func (s *Service) CancelHold(id, memberID int) error {
+	h, err := s.GetHold(id)
+	if err != nil {
+		return err
+	}
+	if memberID > 0 && h.MemberID != memberID {
+		return errors.New("pesanan tidak ditemukan")
+	}
+	if !h.IsOpen() {
+		return errors.New("pesanan sudah selesai atau dibatalkan")
+	}
+
+	var changes []models.StockChange
+	if h.Status == models.HoldStatusReady {
+		changes = append(changes, models.StockChange{BranchID: h.BranchID, Available: 1})
+	}
+	ok, err := s.repo.Cancel(id, h.Status, h.BookID, changes...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("pesanan sudah berubah, muat ulang halaman")
+	}
+	return nil
+}
+
+// ReadyFromShelf sets aside a copy that is already at the pickup branch,
+// for instance one that was just returned there. An any-edition hold takes
+// a copy of whichever edition is on the shelf.
+func (s *Service) ReadyFromShelf(id int) error {
+	h, err := s.GetHold(id)
+	if err != nil {
+		return err
+	}
+	if h.Status != models.HoldStatusWaiting {
+		return errors.New("pesanan tidak lagi menunggu")
+	}
+	editions, err := s.candidates(h)
+	if err != nil {
+		return err
+	}
+
+	// The copy is set aside in the same update that switches the edition
+	// and marks the hold ready, so a failure leaves both as they were
+	keep := models.StockChange{BranchID: h.BranchID, Available: -1}
+	for _, b := range editions {
+		err := s.markReady(id, b, keep)
+		if errors.Is(err, books.ErrInsufficientStock) {
+			continue
+		}
+		return err
+	}
+	return errors.New("buku tidak tersedia di cabang ini")
+}
+
+// markReady marks a waiting hold ready for a copy of bookID, applying
+// changes to its stock, and notifies the member.
+func (s *Service) markReady(id, bookID int, changes ...models.StockChange) error {
+	ok, err := s.repo.SetReady(id, bookID, changes...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("pesanan tidak lagi menunggu")
+	}
+	s.NotifyReady(id)
+	return nil
+}
+
+// NotifyReady tells the member a hold is ready to collect. A failed
+// notification is logged; the hold stays ready.
+func (s *Service) NotifyReady(id int) {
+	h, err := s.GetHold(id)
+	if err != nil {
+		slog.Error("holds: notify member", "hold", id, "error", err)
+		return
+	}
+
+	_, err = s.notifier.CreateNotification(&models.NotificationCreate{
+		MemberID: h.MemberID,
+		Type:     "info",
+		Title:    "Buku pesanan siap diambil",
+		Message:  fmt.Sprintf("Buku \"%s\" sudah tersedia di %s dan disimpan untuk Anda.", h.Book.Title, h.Branch.Name),
+	})
+	if err != nil {
+		slog.Error("holds: notify member", "hold", id, "error", err)
+	}
+}
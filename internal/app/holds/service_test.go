@@ -0,0 +1,212 @@
+package holds
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/app/books"
+	"simpus/internal/app/branches"
+	"simpus/internal/app/notifications"
+	"simpus/internal/models"
+)
+
+func newTestService(t *testing.T) (*Service, books.BookRepository, *notifications.Service) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	bookRepo := books.NewBookRepository(db)
+	notifService := notifications.NewService(notifications.NewRepository(db))
+	return NewService(NewRepository(db), bookRepo, branches.NewRepository(db), notifService), bookRepo, notifService
+}
+
+func TestPlaceHold(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	// Bumi (2) is only stocked at the main library (1), Laskar Pelangi (1)
+	// also at the east branch (2)
+	if _, err := s.PlaceHold(1, 2, 2, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                 string
+		member, book, branch int
+		want                 string
+	}{
+		{"second hold on the same book", 1, 2, 2, "sudah Anda pesan"},
+		{"copy on the shelf", 2, 1, 2, "silakan langsung pinjam"},
+		{"no pickup branch", 2, 2, 0, "cabang pengambilan wajib dipilih"},
+		{"unknown book", 2, 99, 2, "buku tidak ditemukan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.PlaceHold(tt.member, tt.book, tt.branch, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	holds, err := s.GetMemberHolds(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || holds[0].Status != models.HoldStatusWaiting || holds[0].Branch.Code != "TIMUR" {
+		t.Fatalf("holds = %+v", holds)
+	}
+}
+
+func TestReadyFromShelfAndCancel(t *testing.T) {
+	s, bookRepo, notifService := newTestService(t)
+
+	id, err := s.PlaceHold(1, 2, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReadyFromShelf(int(id)); err == nil {
+		t.Fatal("hold made ready without a copy at the pickup branch")
+	}
+
+	// A copy turns up at the east branch
+	if err := bookRepo.AdjustStock(2, models.StockChange{BranchID: 2, Stock: 1, Available: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReadyFromShelf(int(id)); err != nil {
+		t.Fatal(err)
+	}
+	if got := availableAt(t, bookRepo, 2, 2); got != 0 {
+		t.Errorf("available after ready = %d, want 0", got)
+	}
+	notes, _ := notifService.GetMemberNotifications(1, 10)
+	if len(notes) != 1 || !strings.Contains(notes[0].Message, "Bumi") {
+		t.Errorf("notifications = %+v", notes)
+	}
+
+	if err := s.CancelHold(int(id), 2); err == nil {
+		t.Fatal("another member cancelled the hold")
+	}
+	if err := s.CancelHold(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := availableAt(t, bookRepo, 2, 2); got != 1 {
+		t.Errorf("available after cancel = %d, want 1", got)
+	}
+}
+
+func availableAt(t *testing.T, repo books.BookRepository, bookID, branchID int) int {
+	t.Helper()
+	stock, err := repo.FindStock(bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stock {
+		if s.BranchID == branchID {
+			return s.Available
+		}
+	}
+	return 0
+}
+
+func TestAnyEditionHold(t *testing.T) {
+	s, bookRepo, _ := newTestService(t)
+
+	// A second edition of Bumi (2, work 1), on the main library's shelf
+	edition, err := bookRepo.Create(&models.BookCreate{Title: "Bumi", WorkID: 1, Edition: "Edisi ke-2", Stock: 1, BranchID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PlaceHold(1, 1, 2, true); err == nil || !strings.Contains(err.Error(), "tidak memiliki edisi lain") {
+		t.Fatalf("any-edition hold on a book without a work: err = %v", err)
+	}
+	id, err := s.PlaceHold(1, 2, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PlaceHold(1, int(edition), 2, false); err == nil || !strings.Contains(err.Error(), "sudah Anda pesan") {
+		t.Fatalf("hold on an edition already held as any edition: err = %v", err)
+	}
+
+	waiting, err := s.GetHolds(models.HoldFilter{Status: models.HoldStatusWaiting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources, err := s.Sources(waiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := availableAt(t, bookRepo, 2, 1) + 1
+	if got := sources[int(id)]; len(got) != 1 || got[0].BranchID != 1 || got[0].Available != want {
+		t.Errorf("sources = %+v, want %d at branch 1", got, want)
+	}
+
+	// A copy of the second edition turns up at the east branch
+	if err := bookRepo.AdjustStock(int(edition), models.StockChange{BranchID: 2, Stock: 1, Available: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PlaceHold(2, 2, 2, true); err == nil || !strings.Contains(err.Error(), "salah satu edisi tersedia") {
+		t.Fatalf("any-edition hold with an edition on the shelf: err = %v", err)
+	}
+	if err := s.ReadyFromShelf(int(id)); err != nil {
+		t.Fatal(err)
+	}
+	hold, err := s.GetHold(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldStatusReady || hold.BookID != int(edition) {
+		t.Errorf("hold = %+v, want ready on book %d", hold, edition)
+	}
+	if got := availableAt(t, bookRepo, int(edition), 2); got != 0 {
+		t.Errorf("available after ready = %d, want 0", got)
+	}
+}
+
+func TestSetReadyOnlyWhileWaiting(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	id, err := s.PlaceHold(1, 2, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelHold(int(id), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A hold cancelled meanwhile keeps its edition
+	if ok, err := s.repo.SetReady(int(id), 5); err != nil || ok {
+		t.Fatalf("SetReady = %v, %v, want false", ok, err)
+	}
+	hold, err := s.GetHold(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldStatusCancelled || hold.BookID != 2 {
+		t.Errorf("hold = status %s book %d, want cancelled on book 2", hold.Status, hold.BookID)
+	}
+}
+
+func TestSetReadyKeepsHoldWithoutCopy(t *testing.T) {
+	s, bookRepo, _ := newTestService(t)
+
+	// Bumi (2) has no copy at the east branch (2)
+	id, err := s.PlaceHold(1, 2, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := models.StockChange{BranchID: 2, Available: -1}
+	if ok, err := s.repo.SetReady(int(id), 2, keep); !errors.Is(err, books.ErrInsufficientStock) || ok {
+		t.Fatalf("SetReady = %v, %v, want ErrInsufficientStock", ok, err)
+	}
+	hold, err := s.GetHold(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldStatusWaiting || hold.ReadyAt != nil {
+		t.Errorf("hold = status %s ready at %v, want still waiting", hold.Status, hold.ReadyAt)
+	}
+	if got := availableAt(t, bookRepo, 2, 2); got != 0 {
+		t.Errorf("available = %d, want 0", got)
+	}
+}
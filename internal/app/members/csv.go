@@ -0,0 +1,159 @@
+package members
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"simpus/internal/models"
+)
+
+// csvColumns are the columns written by ExportCSV. ImportCSV accepts the same
+// header in any order; member_code, status, is_active and created_at are
+// ignored on import.
+var csvColumns = []string{
+	"member_code", "identity_number", "name", "email", "phone", "member_type",
+	"address", "status", "is_active", "created_at",
+}
+
+// ImportResult summarises an import. Rows are numbered as in the file,
+// counting the header as row 1.
+type ImportResult struct {
+	Created int
+	Errors  []RowError
+}
+
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("baris %d: %v", e.Row, e.Err)
+}
+
+// ImportCSV creates a member for every row of r through CreateMember, so the
+// usual code generation and password policy apply. Rows without a password
+// get a random one; those members sign in after a password reset. Invalid
+// rows are reported and skipped.
+func (s *Service) ImportCSV(r io.Reader) (*ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "email", "member_type"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	result := &ImportResult{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Errors = append(result.Errors, RowError{Row: row, Err: err})
+				continue
+			}
+			return result, err
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		data := &models.MemberCreate{
+			IdentityNumber: field("identity_number"),
+			Name:           field("name"),
+			Email:          field("email"),
+			Password:       field("password"),
+			Phone:          field("phone"),
+			MemberType:     field("member_type"),
+			Address:        field("address"),
+		}
+
+		if err := validateImport(data); err != nil {
+			result.Errors = append(result.Errors, RowError{Row: row, Err: err})
+			continue
+		}
+		if data.Password == "" {
+			if data.Password, err = randomPassword(); err != nil {
+				return result, err
+			}
+		}
+		if _, err := s.CreateMember(data); err != nil {
+			result.Errors = append(result.Errors, RowError{Row: row, Err: err})
+			continue
+		}
+		result.Created++
+	}
+	return result, nil
+}
+
+func validateImport(data *models.MemberCreate) error {
+	if data.Name == "" || data.Email == "" {
+		return errors.New("nama dan email wajib diisi")
+	}
+	switch data.MemberType {
+	case "mahasiswa", "guru", "karyawan":
+		return nil
+	}
+	return fmt.Errorf("tipe anggota %q tidak dikenal", data.MemberType)
+}
+
+// ExportCSV writes every member to w. Password hashes are never exported.
+func (s *Service) ExportCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	const pageSize = 500
+	for page := 1; ; page++ {
+		list, total, err := s.repo.FindAll(page, pageSize, "")
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			if err := writer.Write([]string{
+				m.MemberCode, m.IdentityNumber, m.Name, m.Email, m.Phone, m.MemberType,
+				m.Address, m.Status, strconv.FormatBool(m.IsActive), m.CreatedAt.Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+		}
+		if page*pageSize >= total {
+			break
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 15)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.EncodeToString(b)), nil
+}
@@ -0,0 +1,80 @@
+package members
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	s, repo, _ := newTestService(t)
+
+	input := `name,email,member_type,identity_number,password
+Budi,budi@student.ac.id,mahasiswa,2021001,
+Siti,siti@sekolah.sch.id,guru,,rahasia123
+,tanpa-nama@student.ac.id,mahasiswa,,
+Rina,rina@student.ac.id,dosen,,
+Dewi,dewi@kantor.id,karyawan,,pendek
+`
+	result, err := s.ImportCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 {
+		t.Errorf("created = %d, want 2", result.Created)
+	}
+
+	var rows []int
+	for _, e := range result.Errors {
+		rows = append(rows, e.Row)
+	}
+	if len(rows) != 3 || rows[0] != 4 || rows[1] != 5 || rows[2] != 6 {
+		t.Errorf("error rows = %v, want [4 5 6]", rows)
+	}
+
+	for _, m := range repo.members {
+		if m.Email == "budi@student.ac.id" && (m.MemberCode != "MHS001" || m.IdentityNumber != "2021001" || m.Password == "") {
+			t.Errorf("imported member = %+v", m)
+		}
+	}
+}
+
+func TestImportCSVRequiresColumns(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	if _, err := s.ImportCSV(strings.NewReader("nama,email\nBudi,budi@student.ac.id\n")); err == nil {
+		t.Error("file without name/member_type columns accepted")
+	}
+}
+
+func TestExportCSVRoundTrip(t *testing.T) {
+	s, _, _ := newTestService(t)
+	createMember(t, s, "Budi", "mahasiswa")
+	createMember(t, s, "Ahmad", "guru")
+
+	var buf bytes.Buffer
+	if err := s.ExportCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("exported %d records, want header and 2 members", len(records))
+	}
+	if strings.Contains(buf.String(), "$2a$") {
+		t.Error("export contains password hashes")
+	}
+
+	// The export can be imported into another library
+	other, _, _ := newTestService(t)
+	result, err := other.ImportCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 || len(result.Errors) != 0 {
+		t.Errorf("re-import: created %d, errors %v", result.Created, result.Errors)
+	}
+}
@@ -1,24 +1,23 @@
 package members
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
 	"strconv"
 
 	"simpus/internal/middleware"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
 )
 
 type Handler struct {
-	service   *Service
-	templates *template.Template
+	service *Service
+	views   *renderer.Renderer
 }
 
-func NewHandler(service *Service, templates *template.Template) *Handler {
+func NewHandler(service *Service, views *renderer.Renderer) *Handler {
 	return &Handler{
-		service:   service,
-		templates: templates,
+		service: service,
+		views:   views,
 	}
 }
 
@@ -37,11 +36,14 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 
 	totalPages := (total + 10 - 1) / 10
 
+	pending, _ := h.service.GetPendingApprovals()
+
 	claims := middleware.GetUserFromContext(r.Context())
 
 	data := map[string]interface{}{
 		"Title":      "Manajemen Anggota - SIMPUS",
 		"Members":    members,
+		"Pending":    pending,
 		"Total":      total,
 		"Page":       page,
 		"TotalPages": totalPages,
@@ -50,11 +52,11 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/members/table.html", data)
+		h.views.Partial(w, r, "admin/members/index.html", "members-table", data)
 		return
 	}
 
-	h.render(w, "admin/members/index.html", data)
+	h.views.Render(w, r, "admin/members/index.html", data)
 }
 
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
@@ -66,11 +68,11 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/members/form.html", data)
+		h.views.Partial(w, r, "admin/members/create.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/members/create.html", data)
+	h.views.Render(w, r, "admin/members/create.html", data)
 }
 
 func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
@@ -80,12 +82,13 @@ func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := &models.MemberCreate{
-		Name:       r.FormValue("name"),
-		Email:      r.FormValue("email"),
-		Password:   r.FormValue("password"),
-		Phone:      r.FormValue("phone"),
-		MemberType: r.FormValue("member_type"),
-		Address:    r.FormValue("address"),
+		IdentityNumber: r.FormValue("identity_number"),
+		Name:           r.FormValue("name"),
+		Email:          r.FormValue("email"),
+		Password:       r.FormValue("password"),
+		Phone:          r.FormValue("phone"),
+		MemberType:     r.FormValue("member_type"),
+		Address:        r.FormValue("address"),
 	}
 
 	_, err := h.service.CreateMember(data)
@@ -120,11 +123,11 @@ func (h *Handler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/members/form.html", data)
+		h.views.Partial(w, r, "admin/members/edit.html", "content", data)
 		return
 	}
 
-	h.render(w, "admin/members/edit.html", data)
+	h.views.Render(w, r, "admin/members/edit.html", data)
 }
 
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
@@ -160,44 +163,53 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
 }
 
-func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Approve(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(r.PathValue("id"))
 
-	err := h.service.DeleteMember(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.ApproveMember(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		w.WriteHeader(http.StatusOK)
+		w.Header().Set("HX-Redirect", "/admin/members")
 		return
 	}
 
 	http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
 }
 
-func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (h *Handler) Reject(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := h.service.RejectMember(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/admin/members")
+		return
+	}
+
+	http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
+}
+
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	err := h.service.DeleteMember(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
 	}
+
+	http.Redirect(w, r, "/admin/members", http.StatusSeeOther)
 }
 
 func (h *Handler) Profile(w http.ResponseWriter, r *http.Request) {
@@ -216,7 +228,7 @@ func (h *Handler) Profile(w http.ResponseWriter, r *http.Request) {
 		"Success": r.URL.Query().Get("success"),
 	}
 
-	h.renderMember(w, "member/profile.html", data)
+	h.views.Render(w, r, "member/profile.html", data)
 }
 
 func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
@@ -247,44 +259,3 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/member/profile?success=Profil berhasil diperbarui", http.StatusSeeOther)
 }
-
-func (h *Handler) renderMember(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "member.html"),
-		filepath.Join("templates", "components", "member-sidebar.html"),
-		filepath.Join("templates", "components", "member-header.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "member.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (h *Handler) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(filepath.Join("templates", name))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
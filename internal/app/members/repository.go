@@ -2,19 +2,66 @@ package members
 
 import (
 	"database/sql"
-	"fmt"
 	"simpus/internal/models"
+	"time"
 )
 
-type Repository struct {
+// Repository stores members.
+type Repository interface {
+	FindAll(page, limit int, search string) ([]models.Member, int, error)
+	FindByStatus(status string) ([]models.Member, error)
+	FindByID(id int) (*models.Member, error)
+	FindByEmail(email string) (*models.Member, error)
+	FindByIdentityNumber(identityNumber string) (*models.Member, error)
+	GenerateMemberCode(memberType string) (string, error)
+	Create(m *models.MemberCreate, hashedPassword, memberCode string) (int64, error)
+	Update(id int, m *models.MemberUpdate) error
+	UpdatePassword(id int, hashedPassword string) error
+	RehashPassword(id int, hashedPassword string) error
+	UpdateStatus(id int, status string) error
+	MarkEmailVerified(id int, nextStatus string) error
+	Delete(id int) error
+	Count() (int, error)
+}
+
+type repository struct {
 	db *sql.DB
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+const memberColumns = `id, member_code, identity_number, name, email, password, phone, member_type, address,
+              is_active, status, email_verified_at, created_at, updated_at, session_version`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMember(row rowScanner) (*models.Member, error) {
+	m := &models.Member{}
+	var identity, phone, address sql.NullString
+	var verifiedAt sql.NullTime
+
+	err := row.Scan(
+		&m.ID, &m.MemberCode, &identity, &m.Name, &m.Email, &m.Password,
+		&phone, &m.MemberType, &address, &m.IsActive, &m.Status, &verifiedAt,
+		&m.CreatedAt, &m.UpdatedAt, &m.SessionVersion,
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.IdentityNumber = identity.String
+	m.Phone = phone.String
+	m.Address = address.String
+	if verifiedAt.Valid {
+		m.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return m, nil
 }
 
-func (r *Repository) FindAll(page, limit int, search string) ([]models.Member, int, error) {
+func (r *repository) FindAll(page, limit int, search string) ([]models.Member, int, error) {
 	offset := (page - 1) * limit
 
 	// Count total
@@ -22,9 +69,9 @@ func (r *Repository) FindAll(page, limit int, search string) ([]models.Member, i
 	args := []interface{}{}
 
 	if search != "" {
-		countQuery += ` AND (name LIKE ? OR email LIKE ? OR member_code LIKE ?)`
+		countQuery += ` AND (name LIKE ? OR email LIKE ? OR member_code LIKE ? OR identity_number LIKE ?)`
 		searchPattern := "%" + search + "%"
-		args = append(args, searchPattern, searchPattern, searchPattern)
+		args = append(args, searchPattern, searchPattern, searchPattern, searchPattern)
 	}
 
 	var total int
@@ -34,11 +81,10 @@ func (r *Repository) FindAll(page, limit int, search string) ([]models.Member, i
 	}
 
 	// Get data
-	query := `SELECT id, member_code, name, email, password, phone, member_type, address, is_active, created_at, updated_at 
-              FROM members WHERE 1=1`
+	query := `SELECT ` + memberColumns + ` FROM members WHERE 1=1`
 
 	if search != "" {
-		query += ` AND (name LIKE ? OR email LIKE ? OR member_code LIKE ?)`
+		query += ` AND (name LIKE ? OR email LIKE ? OR member_code LIKE ? OR identity_number LIKE ?)`
 	}
 	query += ` ORDER BY created_at DESC LIMIT ? OFFSET ?`
 	args = append(args, limit, offset)
@@ -51,93 +97,94 @@ func (r *Repository) FindAll(page, limit int, search string) ([]models.Member, i
 
 	var members []models.Member
 	for rows.Next() {
-		var m models.Member
-		var phone, address sql.NullString
-		err := rows.Scan(
-			&m.ID, &m.MemberCode, &m.Name, &m.Email, &m.Password,
-			&phone, &m.MemberType, &address, &m.IsActive, &m.CreatedAt, &m.UpdatedAt,
-		)
+		m, err := scanMember(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-		m.Phone = phone.String
-		m.Address = address.String
-		members = append(members, m)
+		members = append(members, *m)
 	}
 	return members, total, nil
 }
 
-func (r *Repository) FindByID(id int) (*models.Member, error) {
-	m := &models.Member{}
-	var phone, address sql.NullString
-	query := `SELECT id, member_code, name, email, password, phone, member_type, address, is_active, created_at, updated_at 
-              FROM members WHERE id = ?`
+func (r *repository) FindByStatus(status string) ([]models.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM members WHERE status = ? ORDER BY created_at`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&m.ID, &m.MemberCode, &m.Name, &m.Email, &m.Password,
-		&phone, &m.MemberType, &address, &m.IsActive, &m.CreatedAt, &m.UpdatedAt,
-	)
+	rows, err := r.db.Query(query, status)
 	if err != nil {
 		return nil, err
 	}
-	m.Phone = phone.String
-	m.Address = address.String
-	return m, nil
+	defer rows.Close()
+
+	var members []models.Member
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *m)
+	}
+	return members, nil
 }
 
-func (r *Repository) FindByEmail(email string) (*models.Member, error) {
-	m := &models.Member{}
-	var phone, address sql.NullString
-	query := `SELECT id, member_code, name, email, password, phone, member_type, address, is_active, created_at, updated_at 
-              FROM members WHERE email = ?`
+func (r *repository) FindByID(id int) (*models.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM members WHERE id = ?`
+	return scanMember(r.db.QueryRow(query, id))
+}
 
-	err := r.db.QueryRow(query, email).Scan(
-		&m.ID, &m.MemberCode, &m.Name, &m.Email, &m.Password,
-		&phone, &m.MemberType, &address, &m.IsActive, &m.CreatedAt, &m.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	m.Phone = phone.String
-	m.Address = address.String
-	return m, nil
+func (r *repository) FindByEmail(email string) (*models.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM members WHERE email = ?`
+	return scanMember(r.db.QueryRow(query, email))
 }
 
-func (r *Repository) GenerateMemberCode(memberType string) (string, error) {
-	prefix := "MBR"
-	switch memberType {
-	case "mahasiswa":
-		prefix = "MHS"
-	case "guru":
-		prefix = "GRU"
-	case "karyawan":
-		prefix = "KRY"
-	}
+func (r *repository) FindByIdentityNumber(identityNumber string) (*models.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM members WHERE identity_number = ?`
+	return scanMember(r.db.QueryRow(query, identityNumber))
+}
 
-	var count int
-	query := `SELECT COUNT(*) FROM members WHERE member_type = ?`
-	err := r.db.QueryRow(query, memberType).Scan(&count)
-	if err != nil {
+// GenerateMemberCode continues from the highest existing code, so codes
+// stay unique after members are deleted.
+func (r *repository) GenerateMemberCode(memberType string) (string, error) {
+	prefix := models.MemberCodePrefix(memberType)
+
+	var last string
+	query := `SELECT member_code FROM members WHERE member_code LIKE ?
+			  ORDER BY LENGTH(member_code) DESC, member_code DESC LIMIT 1`
+	err := r.db.QueryRow(query, prefix+"%").Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s%03d", prefix, count+1), nil
+	return models.NextMemberCode(memberType, last), nil
 }
 
-func (r *Repository) Create(m *models.MemberCreate, hashedPassword, memberCode string) (int64, error) {
-	query := `INSERT INTO members (member_code, name, email, password, phone, member_type, address) 
-              VALUES (?, ?, ?, ?, ?, ?, ?)`
+func (r *repository) Create(m *models.MemberCreate, hashedPassword, memberCode string) (int64, error) {
+	query := `INSERT INTO members (member_code, identity_number, name, email, password, phone, member_type, address, status, email_verified_at) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	status := m.Status
+	if status == "" {
+		status = models.MemberStatusActive
+	}
+
+	// Accounts created by staff skip email verification
+	var identity, verifiedAt interface{}
+	if m.IdentityNumber != "" {
+		identity = m.IdentityNumber
+	}
+	if status != models.MemberStatusPendingVerification {
+		verifiedAt = time.Now()
+	}
 
-	result, err := r.db.Exec(query, memberCode, m.Name, m.Email, hashedPassword, m.Phone, m.MemberType, m.Address)
+	result, err := r.db.Exec(query, memberCode, identity, m.Name, m.Email, hashedPassword, m.Phone, m.MemberType, m.Address, status, verifiedAt)
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
-func (r *Repository) Update(id int, m *models.MemberUpdate) error {
+func (r *repository) Update(id int, m *models.MemberUpdate) error {
 	if m.Password != "" {
-		query := `UPDATE members SET name = ?, email = ?, phone = ?, member_type = ?, address = ?, is_active = ?, password = ? WHERE id = ?`
+		query := `UPDATE members SET name = ?, email = ?, phone = ?, member_type = ?, address = ?, is_active = ?, password = ?, session_version = session_version + 1 WHERE id = ?`
 		_, err := r.db.Exec(query, m.Name, m.Email, m.Phone, m.MemberType, m.Address, m.IsActive, m.Password, id)
 		return err
 	}
@@ -147,13 +194,51 @@ func (r *Repository) Update(id int, m *models.MemberUpdate) error {
 	return err
 }
 
-func (r *Repository) Delete(id int) error {
+// UpdatePassword stores a new password hash and bumps the session version,
+// which invalidates every token issued before the change.
+func (r *repository) UpdatePassword(id int, hashedPassword string) error {
+	query := `UPDATE members SET password = ?, session_version = session_version + 1 WHERE id = ?`
+	_, err := r.db.Exec(query, hashedPassword, id)
+	return err
+}
+
+// RehashPassword replaces the hash of an unchanged password, keeping
+// existing sessions valid.
+func (r *repository) RehashPassword(id int, hashedPassword string) error {
+	_, err := r.db.Exec(`UPDATE members SET password = ? WHERE id = ?`, hashedPassword, id)
+	return err
+}
+
+func (r *repository) UpdateStatus(id int, status string) error {
+	_, err := r.db.Exec(`UPDATE members SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+// MarkEmailVerified records the verification and moves the member from
+// pending_verification to nextStatus.
+func (r *repository) MarkEmailVerified(id int, nextStatus string) error {
+	query := `UPDATE members SET email_verified_at = ?, status = ? WHERE id = ? AND status = ?`
+	result, err := r.db.Exec(query, time.Now(), nextStatus, id, models.MemberStatusPendingVerification)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *repository) Delete(id int) error {
 	_, err := r.db.Exec(`DELETE FROM members WHERE id = ?`, id)
 	return err
 }
 
-func (r *Repository) Count() (int, error) {
+func (r *repository) Count() (int, error) {
 	var count int
-	err := r.db.QueryRow(`SELECT COUNT(*) FROM members WHERE is_active = TRUE`).Scan(&count)
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM members WHERE is_active = TRUE AND status = 'active'`).Scan(&count)
 	return count, err
 }
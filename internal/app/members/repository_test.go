@@ -0,0 +1,88 @@
+package members
+
+import (
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+)
+
+func TestRepositoryRegistrationFlow(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db)
+
+	before, err := repo.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := repo.Create(&models.MemberCreate{
+		IdentityNumber: "2023010099",
+		Name:           "Rina Wulandari",
+		Email:          "rina@student.ac.id",
+		MemberType:     "mahasiswa",
+		Status:         models.MemberStatusPendingVerification,
+	}, "hash", "MHS003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := repo.FindByIdentityNumber("2023010099")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != int(id) || m.Status != models.MemberStatusPendingVerification || m.EmailVerifiedAt != nil {
+		t.Fatalf("created member = %+v", m)
+	}
+
+	if err := repo.MarkEmailVerified(int(id), models.MemberStatusActive); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkEmailVerified(int(id), models.MemberStatusActive); err == nil {
+		t.Error("second MarkEmailVerified succeeded, want error")
+	}
+
+	after, err := repo.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before+1 {
+		t.Errorf("Count = %d, want %d", after, before+1)
+	}
+}
+
+func TestRepositoryUpdatePasswordBumpsSessionVersion(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db)
+
+	if err := repo.UpdatePassword(1, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RehashPassword(1, "rehashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := repo.FindByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Password != "rehashed" || m.SessionVersion != 1 {
+		t.Errorf("password %q session version %d, want rehashed and 1", m.Password, m.SessionVersion)
+	}
+}
+
+func TestRepositoryFindAllSearch(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db)
+
+	members, total, err := repo.FindAll(1, 10, "budi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(members) != 1 || members[0].MemberCode != "MHS001" {
+		t.Errorf("search budi = %d members, total %d", len(members), total)
+	}
+}
@@ -1,17 +1,31 @@
 package members
 
 import (
-	"simpus/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 
-	"golang.org/x/crypto/bcrypt"
+	"simpus/config"
+	"simpus/internal/credentials"
+	"simpus/internal/mailer"
+	"simpus/internal/models"
 )
 
 type Service struct {
-	repo *Repository
+	repo        Repository
+	credentials *credentials.Manager
+	mailer      mailer.Mailer
+	config      *config.Config
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository, creds *credentials.Manager, mail mailer.Mailer, cfg *config.Config) *Service {
+	return &Service{
+		repo:        repo,
+		credentials: creds,
+		mailer:      mail,
+		config:      cfg,
+	}
 }
 
 func (s *Service) GetMembers(page, limit int, search string) ([]models.Member, int, error) {
@@ -23,6 +37,10 @@ func (s *Service) GetMember(id int) (*models.Member, error) {
 }
 
 func (s *Service) CreateMember(data *models.MemberCreate) (int64, error) {
+	if err := s.credentials.Validate("member", 0, "", data.Password); err != nil {
+		return 0, err
+	}
+
 	// Generate member code
 	memberCode, err := s.repo.GenerateMemberCode(data.MemberType)
 	if err != nil {
@@ -30,23 +48,97 @@ func (s *Service) CreateMember(data *models.MemberCreate) (int64, error) {
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.credentials.Hash(data.Password)
 	if err != nil {
 		return 0, err
 	}
 
-	return s.repo.Create(data, string(hashedPassword), memberCode)
+	id, err := s.repo.Create(data, hashedPassword, memberCode)
+	if err != nil {
+		return 0, err
+	}
+	return id, s.credentials.Record("member", int(id), hashedPassword)
 }
 
 func (s *Service) UpdateMember(id int, data *models.MemberUpdate) error {
-	if data.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
-		data.Password = string(hashedPassword)
+	if data.Password == "" {
+		return s.repo.Update(id, data)
 	}
-	return s.repo.Update(id, data)
+
+	member, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.credentials.Validate("member", id, member.Password, data.Password); err != nil {
+		return err
+	}
+	hashedPassword, err := s.credentials.Hash(data.Password)
+	if err != nil {
+		return err
+	}
+	data.Password = hashedPassword
+
+	if err := s.repo.Update(id, data); err != nil {
+		return err
+	}
+	return s.credentials.Record("member", id, hashedPassword)
+}
+
+// GetPendingApprovals returns verified registrations waiting for a librarian.
+func (s *Service) GetPendingApprovals() ([]models.Member, error) {
+	return s.repo.FindByStatus(models.MemberStatusPendingApproval)
+}
+
+func (s *Service) ApproveMember(ctx context.Context, id int) error {
+	member, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("anggota tidak ditemukan")
+	}
+	if member.Status != models.MemberStatusPendingApproval {
+		return errors.New("anggota tidak sedang menunggu persetujuan")
+	}
+
+	if err := s.repo.UpdateStatus(id, models.MemberStatusActive); err != nil {
+		return err
+	}
+
+	s.notifyRegistration(ctx, member, "Pendaftaran Anggota Disetujui",
+		"Pendaftaran Anda telah disetujui. Anda sekarang dapat meminjam buku.\r\n"+
+			"Login di: "+s.config.App.BaseURL+"/login/member\r\n")
+	return nil
+}
+
+func (s *Service) RejectMember(ctx context.Context, id int) error {
+	member, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("anggota tidak ditemukan")
+	}
+	if member.Status != models.MemberStatusPendingApproval && member.Status != models.MemberStatusPendingVerification {
+		return errors.New("anggota tidak sedang menunggu persetujuan")
+	}
+
+	if err := s.repo.UpdateStatus(id, models.MemberStatusRejected); err != nil {
+		return err
+	}
+
+	s.notifyRegistration(ctx, member, "Pendaftaran Anggota Ditolak",
+		"Mohon maaf, pendaftaran Anda belum dapat kami setujui.\r\n"+
+			"Silakan hubungi pustakawan untuk informasi lebih lanjut.\r\n")
+	return nil
+}
+
+func (s *Service) notifyRegistration(ctx context.Context, member *models.Member, subject, body string) {
+	msg := &mailer.Message{
+		To:      member.Email,
+		Subject: subject + " - " + s.config.App.Name,
+		Body:    fmt.Sprintf("Halo %s,\r\n\r\n%s", member.Name, body),
+	}
+
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			slog.ErrorContext(ctx, "members: send registration email", "error", err)
+		}
+	}()
 }
 
 func (s *Service) DeleteMember(id int) error {
@@ -0,0 +1,354 @@
+package members
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/config"
+	"simpus/internal/credentials"
+	"simpus/internal/mailer"
+	"simpus/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeRepo struct {
+	members map[int]*models.Member
+	nextID  int
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{members: map[int]*models.Member{}}
+}
+
+func (r *fakeRepo) sorted() []models.Member {
+	var list []models.Member
+	for _, m := range r.members {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (r *fakeRepo) FindAll(page, limit int, search string) ([]models.Member, int, error) {
+	var list []models.Member
+	for _, m := range r.sorted() {
+		if search == "" || strings.Contains(m.Name, search) {
+			list = append(list, m)
+		}
+	}
+	return list, len(list), nil
+}
+
+func (r *fakeRepo) FindByStatus(status string) ([]models.Member, error) {
+	var list []models.Member
+	for _, m := range r.sorted() {
+		if m.Status == status {
+			list = append(list, m)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeRepo) FindByID(id int) (*models.Member, error) {
+	m, ok := r.members[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copy := *m
+	return &copy, nil
+}
+
+func (r *fakeRepo) FindByEmail(email string) (*models.Member, error) {
+	for _, m := range r.members {
+		if m.Email == email {
+			return r.FindByID(m.ID)
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeRepo) FindByIdentityNumber(identityNumber string) (*models.Member, error) {
+	for _, m := range r.members {
+		if m.IdentityNumber == identityNumber {
+			return r.FindByID(m.ID)
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *fakeRepo) GenerateMemberCode(memberType string) (string, error) {
+	prefix := models.MemberCodePrefix(memberType)
+	last := ""
+	for _, m := range r.members {
+		if strings.HasPrefix(m.MemberCode, prefix) && m.MemberCode > last {
+			last = m.MemberCode
+		}
+	}
+	return models.NextMemberCode(memberType, last), nil
+}
+
+func (r *fakeRepo) Create(data *models.MemberCreate, hashedPassword, memberCode string) (int64, error) {
+	for _, m := range r.members {
+		if m.MemberCode == memberCode {
+			return 0, fmt.Errorf("duplicate member code %s", memberCode)
+		}
+	}
+	status := data.Status
+	if status == "" {
+		status = models.MemberStatusActive
+	}
+	r.nextID++
+	r.members[r.nextID] = &models.Member{
+		ID:             r.nextID,
+		MemberCode:     memberCode,
+		IdentityNumber: data.IdentityNumber,
+		Name:           data.Name,
+		Email:          data.Email,
+		Password:       hashedPassword,
+		MemberType:     data.MemberType,
+		IsActive:       true,
+		Status:         status,
+	}
+	return int64(r.nextID), nil
+}
+
+func (r *fakeRepo) Update(id int, data *models.MemberUpdate) error {
+	m := r.members[id]
+	m.Name, m.Email, m.MemberType, m.IsActive = data.Name, data.Email, data.MemberType, data.IsActive
+	if data.Password != "" {
+		m.Password = data.Password
+		m.SessionVersion++
+	}
+	return nil
+}
+
+func (r *fakeRepo) UpdatePassword(id int, hashedPassword string) error {
+	r.members[id].Password = hashedPassword
+	r.members[id].SessionVersion++
+	return nil
+}
+
+func (r *fakeRepo) RehashPassword(id int, hashedPassword string) error {
+	r.members[id].Password = hashedPassword
+	return nil
+}
+
+func (r *fakeRepo) UpdateStatus(id int, status string) error {
+	r.members[id].Status = status
+	return nil
+}
+
+func (r *fakeRepo) MarkEmailVerified(id int, nextStatus string) error {
+	return r.UpdateStatus(id, nextStatus)
+}
+
+func (r *fakeRepo) Delete(id int) error {
+	delete(r.members, id)
+	return nil
+}
+
+func (r *fakeRepo) Count() (int, error) {
+	return len(r.members), nil
+}
+
+type fakeHistoryRepo struct {
+	hashes map[int][]string
+}
+
+func (r *fakeHistoryRepo) Create(accountType string, accountID int, passwordHash string) error {
+	r.hashes[accountID] = append([]string{passwordHash}, r.hashes[accountID]...)
+	return nil
+}
+
+func (r *fakeHistoryRepo) Recent(accountType string, accountID int, limit int) ([]string, error) {
+	hashes := r.hashes[accountID]
+	if len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+	return hashes, nil
+}
+
+// fakeMailer delivers to a channel because the service sends asynchronously.
+type fakeMailer struct {
+	sent chan *mailer.Message
+}
+
+func (m *fakeMailer) Send(msg *mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func (m *fakeMailer) wait(t *testing.T) *mailer.Message {
+	t.Helper()
+	select {
+	case msg := <-m.sent:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no email sent")
+		return nil
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *fakeRepo, *fakeMailer) {
+	t.Helper()
+
+	creds, err := credentials.NewManager(config.PasswordConfig{
+		MinLength:   8,
+		HistorySize: 3,
+		Algorithm:   credentials.AlgorithmBcrypt,
+		BcryptCost:  bcrypt.MinCost,
+	}, &fakeHistoryRepo{hashes: map[int][]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newFakeRepo()
+	mail := &fakeMailer{sent: make(chan *mailer.Message, 10)}
+	cfg := &config.Config{App: config.AppConfig{Name: "SIMPUS", BaseURL: "http://simpus.test"}}
+	return NewService(repo, creds, mail, cfg), repo, mail
+}
+
+func createMember(t *testing.T, s *Service, name, memberType string) *models.Member {
+	t.Helper()
+
+	id, err := s.CreateMember(&models.MemberCreate{
+		Name:       name,
+		Email:      strings.ToLower(name) + "@kampus.ac.id",
+		Password:   "rahasia123",
+		MemberType: memberType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.GetMember(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCreateMemberCodes(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	want := []struct{ name, memberType, code string }{
+		{"Budi", "mahasiswa", "MHS001"},
+		{"Siti", "mahasiswa", "MHS002"},
+		{"Ahmad", "guru", "GRU001"},
+		{"Dewi", "karyawan", "KRY001"},
+		{"Rina", "mahasiswa", "MHS003"},
+	}
+	for _, w := range want {
+		if m := createMember(t, s, w.name, w.memberType); m.MemberCode != w.code {
+			t.Errorf("%s: code %s, want %s", w.name, m.MemberCode, w.code)
+		}
+	}
+}
+
+func TestCreateMemberCodeNotReusedAfterDelete(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	first := createMember(t, s, "Budi", "mahasiswa")
+	createMember(t, s, "Siti", "mahasiswa")
+	if err := s.DeleteMember(first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := createMember(t, s, "Rina", "mahasiswa"); m.MemberCode != "MHS003" {
+		t.Errorf("code after delete = %s, want MHS003", m.MemberCode)
+	}
+}
+
+func TestCreateMemberPasswordPolicy(t *testing.T) {
+	s, repo, _ := newTestService(t)
+
+	_, err := s.CreateMember(&models.MemberCreate{Name: "Budi", Email: "budi@kampus.ac.id", Password: "pendek", MemberType: "mahasiswa"})
+	if err == nil {
+		t.Fatal("short password accepted")
+	}
+	if len(repo.members) != 0 {
+		t.Error("member created despite invalid password")
+	}
+
+	m := createMember(t, s, "Siti", "mahasiswa")
+	if m.Password == "rahasia123" || bcrypt.CompareHashAndPassword([]byte(m.Password), []byte("rahasia123")) != nil {
+		t.Error("password not stored as bcrypt hash")
+	}
+}
+
+func TestUpdateMemberPassword(t *testing.T) {
+	s, repo, _ := newTestService(t)
+	m := createMember(t, s, "Budi", "mahasiswa")
+
+	update := &models.MemberUpdate{Name: m.Name, Email: m.Email, MemberType: m.MemberType, IsActive: true}
+
+	update.Password = "rahasia123"
+	if err := s.UpdateMember(m.ID, update); err != credentials.ErrPasswordReused {
+		t.Errorf("reused password: err = %v, want ErrPasswordReused", err)
+	}
+
+	update.Password = "passwordbaru1"
+	if err := s.UpdateMember(m.ID, update); err != nil {
+		t.Fatal(err)
+	}
+	if repo.members[m.ID].SessionVersion != 1 {
+		t.Error("password change did not revoke sessions")
+	}
+
+	update.Password = ""
+	if err := s.UpdateMember(m.ID, update); err != nil {
+		t.Fatal(err)
+	}
+	if repo.members[m.ID].SessionVersion != 1 {
+		t.Error("profile update without password revoked sessions")
+	}
+}
+
+func TestApproveAndRejectMember(t *testing.T) {
+	s, repo, mail := newTestService(t)
+	active := createMember(t, s, "Budi", "mahasiswa")
+	pending := createMember(t, s, "Siti", "mahasiswa")
+	repo.members[pending.ID].Status = models.MemberStatusPendingApproval
+	unverified := createMember(t, s, "Rina", "mahasiswa")
+	repo.members[unverified.ID].Status = models.MemberStatusPendingVerification
+
+	if err := s.ApproveMember(context.Background(), active.ID); err == nil {
+		t.Error("approved an already active member")
+	}
+	if err := s.ApproveMember(context.Background(), unverified.ID); err == nil {
+		t.Error("approved a member with an unverified email")
+	}
+
+	queue, err := s.GetPendingApprovals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].ID != pending.ID {
+		t.Fatalf("approval queue = %+v", queue)
+	}
+
+	if err := s.ApproveMember(context.Background(), pending.ID); err != nil {
+		t.Fatal(err)
+	}
+	if repo.members[pending.ID].Status != models.MemberStatusActive {
+		t.Errorf("status after approval = %s", repo.members[pending.ID].Status)
+	}
+	if msg := mail.wait(t); msg.To != pending.Email || !strings.Contains(msg.Subject, "Disetujui") {
+		t.Errorf("approval email = %+v", msg)
+	}
+
+	if err := s.RejectMember(context.Background(), unverified.ID); err != nil {
+		t.Fatal(err)
+	}
+	if repo.members[unverified.ID].Status != models.MemberStatusRejected {
+		t.Errorf("status after rejection = %s", repo.members[unverified.ID].Status)
+	}
+	if msg := mail.wait(t); msg.To != unverified.Email || !strings.Contains(msg.Subject, "Ditolak") {
+		t.Errorf("rejection email = %+v", msg)
+	}
+}
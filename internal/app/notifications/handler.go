@@ -1,21 +1,20 @@
 package notifications
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
 	"simpus/internal/middleware"
+	"simpus/internal/renderer"
 )
 
 type Handler struct {
-	service   *Service
-	templates *template.Template
+	service *Service
+	views   *renderer.Renderer
 }
 
-func NewHandler(service *Service, templates *template.Template) *Handler {
+func NewHandler(service *Service, views *renderer.Renderer) *Handler {
 	return &Handler{
-		service:   service,
-		templates: templates,
+		service: service,
+		views:   views,
 	}
 }
 
@@ -33,28 +32,5 @@ func (h *Handler) MemberIndex(w http.ResponseWriter, r *http.Request) {
 		"User":          claims,
 	}
 
-	h.renderMember(w, "member/notifications/index.html", data)
-}
-
-func (h *Handler) renderMember(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "member.html"),
-		filepath.Join("templates", "components", "member-sidebar.html"),
-		filepath.Join("templates", "components", "member-header.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.ExecuteTemplate(w, "member.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	h.views.Render(w, r, "member/notifications/index.html", data)
 }
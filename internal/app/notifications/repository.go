@@ -5,15 +5,25 @@ import (
 	"simpus/internal/models"
 )
 
-type Repository struct {
+// Repository stores member notifications.
+type Repository interface {
+	FindByMember(memberID int, limit int) ([]models.Notification, error)
+	Create(n *models.NotificationCreate) (int64, error)
+	MarkAsRead(id int) error
+	MarkAllAsRead(memberID int) error
+	CountUnread(memberID int) (int, error)
+	Delete(id int) error
+}
+
+type repository struct {
 	db *sql.DB
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
 }
 
-func (r *Repository) FindByMember(memberID int, limit int) ([]models.Notification, error) {
+func (r *repository) FindByMember(memberID int, limit int) ([]models.Notification, error) {
 	query := `SELECT id, borrowing_id, member_id, type, title, message, is_read, created_at
 			  FROM notifications WHERE member_id = ? ORDER BY created_at DESC LIMIT ?`
 
@@ -41,7 +51,7 @@ func (r *Repository) FindByMember(memberID int, limit int) ([]models.Notificatio
 	return notifications, nil
 }
 
-func (r *Repository) Create(n *models.NotificationCreate) (int64, error) {
+func (r *repository) Create(n *models.NotificationCreate) (int64, error) {
 	query := `INSERT INTO notifications (borrowing_id, member_id, type, title, message) VALUES (?, ?, ?, ?, ?)`
 
 	var borrowingID interface{}
@@ -56,23 +66,23 @@ func (r *Repository) Create(n *models.NotificationCreate) (int64, error) {
 	return result.LastInsertId()
 }
 
-func (r *Repository) MarkAsRead(id int) error {
+func (r *repository) MarkAsRead(id int) error {
 	_, err := r.db.Exec(`UPDATE notifications SET is_read = TRUE WHERE id = ?`, id)
 	return err
 }
 
-func (r *Repository) MarkAllAsRead(memberID int) error {
+func (r *repository) MarkAllAsRead(memberID int) error {
 	_, err := r.db.Exec(`UPDATE notifications SET is_read = TRUE WHERE member_id = ?`, memberID)
 	return err
 }
 
-func (r *Repository) CountUnread(memberID int) (int, error) {
+func (r *repository) CountUnread(memberID int) (int, error) {
 	var count int
 	err := r.db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE member_id = ? AND is_read = FALSE`, memberID).Scan(&count)
 	return count, err
 }
 
-func (r *Repository) Delete(id int) error {
+func (r *repository) Delete(id int) error {
 	_, err := r.db.Exec(`DELETE FROM notifications WHERE id = ?`, id)
 	return err
 }
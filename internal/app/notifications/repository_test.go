@@ -0,0 +1,42 @@
+package notifications
+
+import (
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/models"
+)
+
+func TestRepositoryUnreadCount(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	repo := NewRepository(db)
+
+	for _, title := range []string{"Pengingat", "Terlambat"} {
+		_, err := repo.Create(&models.NotificationCreate{MemberID: 1, Type: "info", Title: title, Message: "Pesan"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unread, err := repo.CountUnread(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread != 2 {
+		t.Errorf("CountUnread = %d, want 2", unread)
+	}
+
+	if err := repo.MarkAllAsRead(1); err != nil {
+		t.Fatal(err)
+	}
+	notifications, err := repo.FindByMember(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range notifications {
+		if !n.IsRead {
+			t.Errorf("notification %q still unread", n.Title)
+		}
+	}
+}
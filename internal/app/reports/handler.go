@@ -1,38 +1,44 @@
 package reports
 
 import (
-	"html/template"
 	"net/http"
-	"path/filepath"
 	"time"
 
 	"simpus/internal/app/borrowings"
+	"simpus/internal/app/branches"
 	"simpus/internal/middleware"
 	"simpus/internal/models"
+	"simpus/internal/renderer"
 )
 
 type Handler struct {
 	borrowService *borrowings.Service
-	templates     *template.Template
+	branchService *branches.Service
+	views         *renderer.Renderer
 }
 
 func NewHandler(
 	borrowService *borrowings.Service,
-	templates *template.Template,
+	branchService *branches.Service,
+	views *renderer.Renderer,
 ) *Handler {
 	return &Handler{
 		borrowService: borrowService,
-		templates:     templates,
+		branchService: branchService,
+		views:         views,
 	}
 }
 
 func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 	fromDate := r.URL.Query().Get("from")
 	toDate := r.URL.Query().Get("to")
+	claims := middleware.GetUserFromContext(r.Context())
+	branchID := branches.Selected(r, claims.BranchID)
 
 	filter := models.BorrowingFilter{
-		Page:  1,
-		Limit: 1000,
+		BranchID: branchID,
+		Page:     1,
+		Limit:    1000,
 	}
 
 	if fromDate != "" {
@@ -45,8 +51,7 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 	}
 
 	borrowings, total, _ := h.borrowService.GetBorrowings(filter)
-
-	claims := middleware.GetUserFromContext(r.Context())
+	branchList, _ := h.branchService.GetBranches()
 
 	// Calculate stats
 	var totalFine float64
@@ -71,54 +76,15 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 		"OverdueCount":  overdueCount,
 		"FromDate":      fromDate,
 		"ToDate":        toDate,
+		"BranchID":      branchID,
+		"Branches":      branchList,
 		"User":          claims,
 	}
 
 	if r.Header.Get("HX-Request") == "true" {
-		h.renderPartial(w, "admin/reports/table.html", data)
-		return
-	}
-
-	h.render(w, "admin/reports/index.html", data)
-}
-
-func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(
-		filepath.Join("templates", "layouts", "admin.html"),
-		filepath.Join("templates", "components", "sidebar.html"),
-		filepath.Join("templates", "components", "navbar.html"),
-		filepath.Join("templates", name),
-	)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.views.Partial(w, r, "admin/reports/index.html", "report-table", data)
 		return
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "admin.html", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
-
-func (h *Handler) renderPartial(w http.ResponseWriter, name string, data interface{}) {
-	tmpl, err := h.templates.Clone()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	tmpl, err = tmpl.ParseFiles(filepath.Join("templates", name))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := tmpl.Execute(w, data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	h.views.Render(w, r, "admin/reports/index.html", data)
 }
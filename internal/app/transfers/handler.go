@@ -0,0 +1,148 @@
+package transfers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"simpus/internal/app/books"
+	"simpus/internal/app/branches"
+	"simpus/internal/app/holds"
+	"simpus/internal/middleware"
+	"simpus/internal/models"
+	"simpus/internal/renderer"
+)
+
+type Handler struct {
+	service       *Service
+	holdService   *holds.Service
+	bookService   *books.Service
+	branchService *branches.Service
+	views         *renderer.Renderer
+}
+
+func NewHandler(
+	service *Service,
+	holdService *holds.Service,
+	bookService *books.Service,
+	branchService *branches.Service,
+	views *renderer.Renderer,
+) *Handler {
+	return &Handler{
+		service:       service,
+		holdService:   holdService,
+		bookService:   bookService,
+		branchService: branchService,
+		views:         views,
+	}
+}
+
+// Index lists the holds and transfers touching the selected branch. Only
+// transfers still on their way are shown unless ?show=all.
+func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r.Context())
+	branchID := branches.Selected(r, claims.BranchID)
+	showAll := r.URL.Query().Get("show") == "all"
+
+	transfers, err := h.service.GetTransfers(models.TransferFilter{BranchID: branchID, Open: !showAll})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	waiting, err := h.holdService.GetHolds(models.HoldFilter{BranchID: branchID, Status: models.HoldStatusWaiting})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ready, err := h.holdService.GetHolds(models.HoldFilter{BranchID: branchID, Status: models.HoldStatusReady})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Where each waiting hold could be sent from
+	sources, err := h.holdService.Sources(waiting)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bookList, _, _ := h.bookService.GetBooks(models.BookFilter{Page: 1, Limit: 100, Available: true})
+	branchList, _ := h.branchService.GetActiveBranches()
+
+	data := map[string]interface{}{
+		"Title":     "Transfer Antar Cabang - SIMPUS",
+		"Transfers": transfers,
+		"Waiting":   waiting,
+		"Ready":     ready,
+		"Sources":   sources,
+		"Books":     bookList,
+		"Branches":  branchList,
+		"BranchID":  branchID,
+		"ShowAll":   showAll,
+		"Success":   r.URL.Query().Get("success"),
+		"Error":     r.URL.Query().Get("error"),
+		"User":      claims,
+	}
+
+	h.views.Render(w, r, "admin/transfers/index.html", data)
+}
+
+func (h *Handler) Store(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form tidak valid", http.StatusBadRequest)
+		return
+	}
+
+	bookID, _ := strconv.Atoi(r.FormValue("book_id"))
+	fromID, _ := strconv.Atoi(r.FormValue("from_branch_id"))
+	toID, _ := strconv.Atoi(r.FormValue("to_branch_id"))
+	holdID, _ := strconv.Atoi(r.FormValue("hold_id"))
+
+	data := &models.TransferCreate{
+		BookID:       bookID,
+		FromBranchID: fromID,
+		ToBranchID:   toID,
+		HoldID:       holdID,
+		Notes:        r.FormValue("notes"),
+	}
+
+	claims := middleware.GetUserFromContext(r.Context())
+	if _, err := h.service.RequestTransfer(data, claims.UserID); err != nil {
+		redirect(w, r, "error", err.Error())
+		return
+	}
+	redirect(w, r, "success", "Permintaan transfer dibuat")
+}
+
+func (h *Handler) Dispatch(w http.ResponseWriter, r *http.Request) {
+	h.step(w, r, h.service.Dispatch, "Buku disiapkan untuk dikirim")
+}
+
+func (h *Handler) Send(w http.ResponseWriter, r *http.Request) {
+	h.step(w, r, h.service.Send, "Buku dalam perjalanan")
+}
+
+func (h *Handler) Receive(w http.ResponseWriter, r *http.Request) {
+	h.step(w, r, h.service.Receive, "Buku diterima di cabang tujuan")
+}
+
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	h.step(w, r, h.service.Cancel, "Transfer dibatalkan")
+}
+
+// step runs one staff action on the transfer in the URL.
+func (h *Handler) step(w http.ResponseWriter, r *http.Request, action func(id, userID int) error, success string) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	claims := middleware.GetUserFromContext(r.Context())
+
+	if err := action(id, claims.UserID); err != nil {
+		redirect(w, r, "error", err.Error())
+		return
+	}
+	redirect(w, r, "success", success)
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, key, message string) {
+	http.Redirect(w, r, "/admin/transfers?"+key+"="+url.QueryEscape(message), http.StatusSeeOther)
+}
@@ -0,0 +1,272 @@
+package transfers
+
+import (
+	"database/sql"
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+	"time"
+)
+
+// Repository stores transfers of copies between branches.
+type Repository interface {
+	FindAll(filter models.TransferFilter) ([]models.Transfer, error)
+	FindByID(id int) (*models.Transfer, error)
+	// FindOpenByHold returns the transfer still bringing a copy for a hold.
+	FindOpenByHold(holdID int) (*models.Transfer, error)
+	// Create records a transfer and applies the stock changes for its book
+	// in the same transaction.
+	Create(t *models.TransferCreate, userID int, changes ...models.StockChange) (int64, error)
+	// Advance moves a transfer from one status to another, recording who
+	// did it and when, and applies the stock changes for its book in the
+	// same transaction. It reports whether the transfer was still in from.
+	Advance(id int, from, to string, userID int, changes ...models.StockChange) (bool, error)
+	// Receive marks an in-transit transfer received and moves its copy
+	// from the source's stock to the destination's in one transaction. A
+	// copy brought for a hold still waiting at the destination stays off
+	// the shelf and the hold becomes ready for it. Receive reports whether
+	// the transfer was still in transit and whether the hold became ready.
+	Receive(id, userID int) (received, held bool, err error)
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// stepColumns are the user and time columns recorded for each status.
+var stepColumns = map[string][2]string{
+	models.TransferStatusDispatched: {"dispatched_by", "dispatched_at"},
+	models.TransferStatusInTransit:  {"sent_by", "sent_at"},
+	models.TransferStatusReceived:   {"received_by", "received_at"},
+	models.TransferStatusCancelled:  {"cancelled_by", "cancelled_at"},
+}
+
+const selectTransfer = `SELECT t.id, t.book_id, t.from_branch_id, t.to_branch_id, t.hold_id, t.status, t.notes,
+			  t.requested_by, t.requested_at, t.dispatched_by, t.dispatched_at, t.sent_by, t.sent_at,
+			  t.received_by, t.received_at, t.cancelled_by, t.cancelled_at,
+			  b.title, fb.code, fb.name, tb.code, tb.name, m.id, m.member_code, m.name
+			  FROM transfers t
+			  JOIN books b ON t.book_id = b.id
+			  JOIN branches fb ON t.from_branch_id = fb.id
+			  JOIN branches tb ON t.to_branch_id = tb.id
+			  LEFT JOIN holds h ON t.hold_id = h.id
+			  LEFT JOIN members m ON h.member_id = m.id`
+
+func (r *repository) FindAll(filter models.TransferFilter) ([]models.Transfer, error) {
+	query := selectTransfer + ` WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.BranchID > 0 {
+		query += ` AND (t.from_branch_id = ? OR t.to_branch_id = ?)`
+		args = append(args, filter.BranchID, filter.BranchID)
+	}
+	if filter.Status != "" {
+		query += ` AND t.status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.Open {
+		query += ` AND t.status IN (?, ?, ?)`
+		args = append(args, models.TransferStatusRequested, models.TransferStatusDispatched, models.TransferStatusInTransit)
+	}
+	query += ` ORDER BY t.requested_at DESC, t.id DESC LIMIT 100`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.Transfer
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *t)
+	}
+	return transfers, rows.Err()
+}
+
+func (r *repository) FindByID(id int) (*models.Transfer, error) {
+	return scanTransfer(r.db.QueryRow(selectTransfer+` WHERE t.id = ?`, id))
+}
+
+func (r *repository) FindOpenByHold(holdID int) (*models.Transfer, error) {
+	query := selectTransfer + ` WHERE t.hold_id = ? AND t.status IN (?, ?, ?)`
+	return scanTransfer(r.db.QueryRow(query, holdID,
+		models.TransferStatusRequested, models.TransferStatusDispatched, models.TransferStatusInTransit))
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransfer(row scanner) (*models.Transfer, error) {
+	t := &models.Transfer{}
+	var holdID, memberID sql.NullInt64
+	var notes, memberCode, memberName sql.NullString
+	var requestedBy, dispatchedBy, sentBy, receivedBy, cancelledBy sql.NullInt64
+	var dispatchedAt, sentAt, receivedAt, cancelledAt sql.NullTime
+	var bookTitle, fromCode, fromName, toCode, toName string
+
+	err := row.Scan(&t.ID, &t.BookID, &t.FromBranchID, &t.ToBranchID, &holdID, &t.Status, &notes,
+		&requestedBy, &t.RequestedAt, &dispatchedBy, &dispatchedAt, &sentBy, &sentAt,
+		&receivedBy, &receivedAt, &cancelledBy, &cancelledAt,
+		&bookTitle, &fromCode, &fromName, &toCode, &toName, &memberID, &memberCode, &memberName)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Notes = notes.String
+	t.HoldID = intPtr(holdID)
+	t.RequestedBy = intPtr(requestedBy)
+	t.DispatchedBy, t.DispatchedAt = intPtr(dispatchedBy), timePtr(dispatchedAt)
+	t.SentBy, t.SentAt = intPtr(sentBy), timePtr(sentAt)
+	t.ReceivedBy, t.ReceivedAt = intPtr(receivedBy), timePtr(receivedAt)
+	t.CancelledBy, t.CancelledAt = intPtr(cancelledBy), timePtr(cancelledAt)
+
+	t.Book = &models.Book{ID: t.BookID, Title: bookTitle}
+	t.FromBranch = &models.Branch{ID: t.FromBranchID, Code: fromCode, Name: fromName}
+	t.ToBranch = &models.Branch{ID: t.ToBranchID, Code: toCode, Name: toName}
+	if memberID.Valid {
+		t.Member = &models.Member{ID: int(memberID.Int64), MemberCode: memberCode.String, Name: memberName.String}
+	}
+	return t, nil
+}
+
+func intPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func timePtr(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
+
+func (r *repository) Create(t *models.TransferCreate, userID int, changes ...models.StockChange) (int64, error) {
+	query := `INSERT INTO transfers (book_id, from_branch_id, to_branch_id, hold_id, status, notes, requested_by, requested_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	var holdID interface{}
+	if t.HoldID > 0 {
+		holdID = t.HoldID
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := books.ApplyStock(tx, t.BookID, changes...); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(query, t.BookID, t.FromBranchID, t.ToBranchID, holdID,
+		models.TransferStatusRequested, t.Notes, nullableUser(userID), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *repository) Advance(id int, from, to string, userID int, changes ...models.StockChange) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	ok, err := advance(tx, id, from, to, userID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if len(changes) > 0 {
+		var bookID int
+		if err := tx.QueryRow(`SELECT book_id FROM transfers WHERE id = ?`, id).Scan(&bookID); err != nil {
+			return false, err
+		}
+		if err := books.ApplyStock(tx, bookID, changes...); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+func (r *repository) Receive(id, userID int) (received, held bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	ok, err := advance(tx, id, models.TransferStatusInTransit, models.TransferStatusReceived, userID)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	var bookID, fromBranchID, toBranchID int
+	var holdID sql.NullInt64
+	err = tx.QueryRow(`SELECT book_id, from_branch_id, to_branch_id, hold_id FROM transfers WHERE id = ?`, id).
+		Scan(&bookID, &fromBranchID, &toBranchID, &holdID)
+	if err != nil {
+		return false, false, err
+	}
+
+	if holdID.Valid {
+		// The hold takes the edition the transfer brought
+		query := `UPDATE holds SET book_id = ?, status = ?, ready_at = ? WHERE id = ? AND status = ? AND branch_id = ?`
+		result, err := tx.Exec(query, bookID, models.HoldStatusReady, time.Now(), holdID.Int64, models.HoldStatusWaiting, toBranchID)
+		if err != nil {
+			return false, false, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return false, false, err
+		}
+		held = n > 0
+	}
+
+	arrival := models.StockChange{BranchID: toBranchID, Stock: 1, Available: 1}
+	if held {
+		arrival.Available = 0
+	}
+	if err := books.ApplyStock(tx, bookID, models.StockChange{BranchID: fromBranchID, Stock: -1}, arrival); err != nil {
+		return false, false, err
+	}
+	return true, held, tx.Commit()
+}
+
+// advance moves a transfer between statuses inside tx.
+func advance(tx *sql.Tx, id int, from, to string, userID int) (bool, error) {
+	columns := stepColumns[to]
+	query := `UPDATE transfers SET status = ?, ` + columns[0] + ` = ?, ` + columns[1] + ` = ?
+			  WHERE id = ? AND status = ?`
+	result, err := tx.Exec(query, to, nullableUser(userID), time.Now(), id, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// nullableUser stores 0, used by the command line, as no user.
+func nullableUser(userID int) interface{} {
+	if userID > 0 {
+		return userID
+	}
+	return nil
+}
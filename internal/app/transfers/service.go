@@ -0,0 +1,184 @@
+package transfers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"simpus/internal/app/books"
+	"simpus/internal/models"
+)
+
+// BookRepository is the part of books.BookRepository needed to check the
+// book of a transfer.
+type BookRepository interface {
+	FindByID(id int) (*models.Book, error)
+}
+
+// BranchRepository is the part of branches.Repository needed to check
+// both ends of a transfer.
+type BranchRepository interface {
+	FindByID(id int) (*models.Branch, error)
+}
+
+// HoldService is the part of holds.Service used when a transfer brings a
+// copy for a hold.
+type HoldService interface {
+	GetHold(id int) (*models.Hold, error)
+	PickEdition(id, fromBranchID int) (int, error)
+	NotifyReady(id int)
+}
+
+type Service struct {
+	repo       Repository
+	bookRepo   BookRepository
+	branchRepo BranchRepository
+	holds      HoldService
+}
+
+func NewService(repo Repository, bookRepo BookRepository, branchRepo BranchRepository, holds HoldService) *Service {
+	return &Service{
+		repo:       repo,
+		bookRepo:   bookRepo,
+		branchRepo: branchRepo,
+		holds:      holds,
+	}
+}
+
+func (s *Service) GetTransfers(filter models.TransferFilter) ([]models.Transfer, error) {
+	return s.repo.FindAll(filter)
+}
+
+func (s *Service) GetTransfer(id int) (*models.Transfer, error) {
+	t, err := s.repo.FindByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("transfer tidak ditemukan")
+	}
+	return t, err
+}
+
+// RequestTransfer asks the source branch to send a copy. The copy is taken
+// off the source's shelf at once so it cannot be lent meanwhile. For a hold,
+// the book and destination come from the hold; an any-edition hold gets
+// an edition the source has on its shelf.
+func (s *Service) RequestTransfer(data *models.TransferCreate, userID int) (int64, error) {
+	if data.HoldID > 0 {
+		hold, err := s.holds.GetHold(data.HoldID)
+		if err != nil {
+			return 0, err
+		}
+		if hold.Status != models.HoldStatusWaiting {
+			return 0, errors.New("pesanan tidak lagi menunggu")
+		}
+		if _, err := s.repo.FindOpenByHold(hold.ID); err == nil {
+			return 0, errors.New("pesanan ini sudah dalam proses transfer")
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+		if data.BookID, err = s.holds.PickEdition(hold.ID, data.FromBranchID); err != nil {
+			return 0, err
+		}
+		data.ToBranchID = hold.BranchID
+	}
+
+	if _, err := s.bookRepo.FindByID(data.BookID); err != nil {
+		return 0, errors.New("buku tidak ditemukan")
+	}
+	if data.FromBranchID <= 0 || data.ToBranchID <= 0 {
+		return 0, errors.New("cabang asal dan tujuan wajib dipilih")
+	}
+	if data.FromBranchID == data.ToBranchID {
+		return 0, errors.New("cabang asal dan tujuan harus berbeda")
+	}
+	for _, id := range []int{data.FromBranchID, data.ToBranchID} {
+		if err := s.checkBranch(id); err != nil {
+			return 0, err
+		}
+	}
+
+	reserve := models.StockChange{BranchID: data.FromBranchID, Available: -1}
+	id, err := s.repo.Create(data, userID, reserve)
+	if errors.Is(err, books.ErrInsufficientStock) {
+		return 0, errors.New("buku tidak tersedia di cabang asal")
+	}
+	return id, err
+}
+
+// Dispatch records that the source branch has pulled the copy and packed
+// it for shipping.
+func (s *Service) Dispatch(id, userID int) error {
+	return s.advance(id, models.TransferStatusRequested, models.TransferStatusDispatched, userID,
+		"hanya transfer yang baru diminta dapat disiapkan")
+}
+
+// Send records that the copy has left the source branch.
+func (s *Service) Send(id, userID int) error {
+	return s.advance(id, models.TransferStatusDispatched, models.TransferStatusInTransit, userID,
+		"hanya transfer yang sudah disiapkan dapat dikirim")
+}
+
+// Receive books the copy into the destination's stock. If it was sent for
+// a waiting hold it stays off the shelf and the member is told to collect
+// it; otherwise it becomes available.
+func (s *Service) Receive(id, userID int) error {
+	t, err := s.GetTransfer(id)
+	if err != nil {
+		return err
+	}
+	received, held, err := s.repo.Receive(id, userID)
+	if err != nil {
+		return err
+	}
+	if !received {
+		return errors.New("hanya transfer yang sedang dalam perjalanan dapat diterima")
+	}
+
+	if held {
+		s.holds.NotifyReady(*t.HoldID)
+	} else if t.HoldID != nil {
+		// The hold was cancelled meanwhile; the copy went on the shelf
+		slog.Warn("transfers: hold not ready", "transfer", id, "hold", *t.HoldID)
+	}
+	return nil
+}
+
+// Cancel stops a transfer that has not left the source branch yet and puts
+// the copy back on its shelf.
+func (s *Service) Cancel(id, userID int) error {
+	t, err := s.GetTransfer(id)
+	if err != nil {
+		return err
+	}
+	if t.Status != models.TransferStatusRequested && t.Status != models.TransferStatusDispatched {
+		return errors.New("transfer yang sudah dikirim tidak dapat dibatalkan")
+	}
+	return s.advance(id, t.Status, models.TransferStatusCancelled, userID,
+		"transfer sudah berubah, muat ulang halaman",
+		models.StockChange{BranchID: t.FromBranchID, Available: 1})
+}
+
+func (s *Service) advance(id int, from, to string, userID int, wrongStatus string, changes ...models.StockChange) error {
+	ok, err := s.repo.Advance(id, from, to, userID, changes...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if _, err := s.GetTransfer(id); err != nil {
+			return err
+		}
+		return errors.New(wrongStatus)
+	}
+	return nil
+}
+
+func (s *Service) checkBranch(branchID int) error {
+	branch, err := s.branchRepo.FindByID(branchID)
+	if err != nil {
+		return errors.New("cabang tidak ditemukan")
+	}
+	if !branch.IsActive {
+		return fmt.Errorf("cabang %s sedang tutup", branch.Name)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package transfers
+
+import (
+	"strings"
+	"testing"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/app/books"
+	"simpus/internal/app/branches"
+	"simpus/internal/app/holds"
+	"simpus/internal/app/notifications"
+	"simpus/internal/models"
+)
+
+type fixture struct {
+	service *Service
+	holds   *holds.Service
+	books   books.BookRepository
+	notif   *notifications.Service
+}
+
+func newFixture(t *testing.T) *fixture {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	bookRepo := books.NewBookRepository(db)
+	branchRepo := branches.NewRepository(db)
+	notifService := notifications.NewService(notifications.NewRepository(db))
+	holdService := holds.NewService(holds.NewRepository(db), bookRepo, branchRepo, notifService)
+
+	return &fixture{
+		service: NewService(NewRepository(db), bookRepo, branchRepo, holdService),
+		holds:   holdService,
+		books:   bookRepo,
+		notif:   notifService,
+	}
+}
+
+// stock returns the stock and available copies of a book at a branch.
+func (f *fixture) stock(t *testing.T, bookID, branchID int) (int, int) {
+	t.Helper()
+	list, err := f.books.FindStock(bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range list {
+		if s.BranchID == branchID {
+			return s.Stock, s.Available
+		}
+	}
+	return 0, 0
+}
+
+func TestTransferSatisfiesHold(t *testing.T) {
+	f := newFixture(t)
+
+	// Budi wants Bumi (2) at the east branch (2); the main library (1) has 3
+	holdID, err := f.holds.PlaceHold(1, 2, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := f.service.RequestTransfer(&models.TransferCreate{HoldID: int(holdID), FromBranchID: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.RequestTransfer(&models.TransferCreate{HoldID: int(holdID), FromBranchID: 1}, 1); err == nil {
+		t.Fatal("second transfer for the same hold")
+	}
+
+	// Requested and in-transit copies are off the shelf
+	if stock, available := f.stock(t, 2, 1); stock != 3 || available != 2 {
+		t.Errorf("source after request = %d/%d, want 3/2", stock, available)
+	}
+	if err := f.service.Receive(int(id), 1); err == nil {
+		t.Fatal("received before it was sent")
+	}
+	if err := f.service.Dispatch(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Send(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Cancel(int(id), 1); err == nil {
+		t.Fatal("cancelled a transfer in transit")
+	}
+	if book, _ := f.books.FindByID(2); book.Available != 2 {
+		t.Errorf("total available in transit = %d, want 2", book.Available)
+	}
+
+	if err := f.service.Receive(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := f.service.GetTransfer(int(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Status != models.TransferStatusReceived || tr.ToBranchID != 2 || tr.BookID != 2 ||
+		tr.DispatchedAt == nil || tr.SentAt == nil || tr.ReceivedAt == nil || tr.Member == nil {
+		t.Fatalf("transfer = %+v", tr)
+	}
+
+	// The copy moved and is kept for the hold
+	if stock, available := f.stock(t, 2, 1); stock != 2 || available != 2 {
+		t.Errorf("source after receive = %d/%d, want 2/2", stock, available)
+	}
+	if stock, available := f.stock(t, 2, 2); stock != 1 || available != 0 {
+		t.Errorf("destination after receive = %d/%d, want 1/0", stock, available)
+	}
+	hold, _ := f.holds.GetHold(int(holdID))
+	if hold.Status != models.HoldStatusReady {
+		t.Errorf("hold status = %q, want ready", hold.Status)
+	}
+	notes, _ := f.notif.GetMemberNotifications(1, 10)
+	if len(notes) != 1 || !strings.Contains(notes[0].Message, "Perpustakaan Cabang Timur") {
+		t.Errorf("notifications = %+v", notes)
+	}
+}
+
+func TestTransferWithoutHoldIsShelved(t *testing.T) {
+	f := newFixture(t)
+
+	id, err := f.service.RequestTransfer(&models.TransferCreate{BookID: 1, FromBranchID: 2, ToBranchID: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []func(id, userID int) error{f.service.Dispatch, f.service.Send, f.service.Receive} {
+		if err := step(int(id), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stock, available := f.stock(t, 1, 1); stock != 4 || available != 4 {
+		t.Errorf("destination = %d/%d, want 4/4", stock, available)
+	}
+	if stock, available := f.stock(t, 1, 2); stock != 1 || available != 1 {
+		t.Errorf("source = %d/%d, want 1/1", stock, available)
+	}
+}
+
+func TestCancelTransferRestoresShelf(t *testing.T) {
+	f := newFixture(t)
+
+	id, err := f.service.RequestTransfer(&models.TransferCreate{BookID: 1, FromBranchID: 2, ToBranchID: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Dispatch(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.Cancel(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+	if stock, available := f.stock(t, 1, 2); stock != 2 || available != 2 {
+		t.Errorf("source = %d/%d, want 2/2", stock, available)
+	}
+
+	open, err := f.service.GetTransfers(models.TransferFilter{Open: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 0 {
+		t.Errorf("open transfers = %d, want 0", len(open))
+	}
+}
+
+func TestRequestTransferValidation(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		name string
+		data models.TransferCreate
+		want string
+	}{
+		{"same branch", models.TransferCreate{BookID: 1, FromBranchID: 1, ToBranchID: 1}, "harus berbeda"},
+		{"no copy at source", models.TransferCreate{BookID: 2, FromBranchID: 2, ToBranchID: 1}, "tidak tersedia di cabang asal"},
+		{"missing destination", models.TransferCreate{BookID: 1, FromBranchID: 1}, "wajib dipilih"},
+		{"unknown book", models.TransferCreate{BookID: 99, FromBranchID: 1, ToBranchID: 2}, "buku tidak ditemukan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.RequestTransfer(&tt.data, 1)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiveForCancelledHoldShelvesCopy(t *testing.T) {
+	f := newFixture(t)
+
+	holdID, err := f.holds.PlaceHold(1, 2, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := f.service.RequestTransfer(&models.TransferCreate{HoldID: int(holdID), FromBranchID: 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []func(id, userID int) error{f.service.Dispatch, f.service.Send} {
+		if err := step(int(id), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.holds.CancelHold(int(holdID), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.service.Receive(int(id), 1); err != nil {
+		t.Fatal(err)
+	}
+	if stock, available := f.stock(t, 2, 2); stock != 1 || available != 1 {
+		t.Errorf("destination = %d/%d, want 1/1", stock, available)
+	}
+	hold, _ := f.holds.GetHold(int(holdID))
+	if hold.Status != models.HoldStatusCancelled {
+		t.Errorf("hold status = %q, want cancelled", hold.Status)
+	}
+	notes, _ := f.notif.GetMemberNotifications(1, 10)
+	if len(notes) != 0 {
+		t.Errorf("notifications = %+v, want none", notes)
+	}
+	if err := f.service.Receive(int(id), 1); err == nil {
+		t.Error("received twice")
+	}
+}
@@ -0,0 +1,563 @@
+// Package backup writes the library's tables and uploaded files to a
+// portable archive and restores them. Archives are gzip-compressed tar
+// files: manifest.json first, then one JSON document per table under
+// tables/ and the uploaded files under uploads/. Table documents do not
+// depend on the driver, so an archive can be restored into either database.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the archive layout version. Restore rejects archives
+// written with another layout.
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// Tables lists the backed-up tables in insert order: every table comes after
+// the tables it references.
+var Tables = []string{
+	"branches",
+	"users",
+	"categories",
+	"authors",
+	"series",
+	"works",
+	"books",
+	"book_contributors",
+	"book_subjects",
+	"branch_stock",
+	"members",
+	"borrowings",
+	"holds",
+	"transfers",
+	"notifications",
+	"password_resets",
+	"external_identities",
+	"password_history",
+}
+
+// ErrSchemaMismatch is returned when an archive was written by a database at
+// another migration version.
+var ErrSchemaMismatch = errors.New("backup: schema version does not match the database")
+
+// Manifest describes an archive. Checksums holds the SHA-256 of every other
+// file in the archive, keyed by its name.
+type Manifest struct {
+	Format        int               `json:"format"`
+	SchemaVersion int               `json:"schema_version"`
+	Driver        string            `json:"driver"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Tables        map[string]int    `json:"tables"` // row count per table
+	Uploads       int               `json:"uploads"`
+	Checksums     map[string]string `json:"checksums"`
+}
+
+// Files is where uploaded files are kept, addressed by slash separated
+// keys. storage.Storage satisfies it, so backups follow the configured
+// storage backend.
+type Files interface {
+	List(ctx context.Context, prefix string) ([]string, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// Options select what Write includes and what Restore expects.
+type Options struct {
+	SchemaVersion int    // last applied migration of the database
+	Driver        string // recorded in the manifest for information only
+	Uploads       Files  // uploaded files to include or restore; nil to skip
+}
+
+// Column kinds that need conversion between the JSON document and the
+// database.
+const (
+	kindDate     = "date"     // stored as 2006-01-02
+	kindDateTime = "datetime" // stored as RFC 3339
+)
+
+type tableData struct {
+	Columns []string `json:"columns"`
+	Kinds   []string `json:"kinds"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// Write dumps every table in Tables and the files in opts.Uploads to w.
+func Write(ctx context.Context, db *sql.DB, w io.Writer, opts Options) (*Manifest, error) {
+	manifest := &Manifest{
+		Format:        FormatVersion,
+		SchemaVersion: opts.SchemaVersion,
+		Driver:        opts.Driver,
+		CreatedAt:     time.Now(),
+		Tables:        map[string]int{},
+		Checksums:     map[string]string{},
+	}
+
+	// All tables are read in one transaction so a loan written halfway
+	// through cannot appear without its book. InnoDB takes the repeatable
+	// read snapshot at the first query; SQLite holds its read lock, or its
+	// WAL snapshot, from the first query to the rollback.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Tables are small enough to hold in memory; the manifest goes first so
+	// Restore can reject an archive before reading the rest.
+	bodies := map[string][]byte{}
+	for _, table := range Tables {
+		data, err := dumpTable(ctx, tx, table)
+		if err != nil {
+			return nil, fmt.Errorf("dump %s: %w", table, err)
+		}
+		body, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", table, err)
+		}
+		name := "tables/" + table + ".json"
+		bodies[name] = body
+		manifest.Tables[table] = len(data.Rows)
+		manifest.Checksums[name] = checksum(body)
+	}
+	if err := tx.Rollback(); err != nil {
+		return nil, err
+	}
+
+	// Uploads are copied to a spool directory first: their checksums go in
+	// the manifest, and a remote store is then read only once
+	spool, err := os.MkdirTemp("", "simpus-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(spool)
+
+	uploads, err := listUploads(ctx, opts.Uploads)
+	if err != nil {
+		return nil, fmt.Errorf("list uploads: %w", err)
+	}
+	for _, key := range uploads {
+		sum, err := spoolUpload(ctx, opts.Uploads, key, filepath.Join(spool, filepath.FromSlash(key)))
+		if err != nil {
+			return nil, fmt.Errorf("copy upload %s: %w", key, err)
+		}
+		manifest.Checksums["uploads/"+key] = sum
+	}
+	manifest.Uploads = len(uploads)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, int64(len(body)), bytes.NewReader(body)); err != nil {
+		return nil, err
+	}
+	for _, table := range Tables {
+		name := "tables/" + table + ".json"
+		if err := writeEntry(tw, name, int64(len(bodies[name])), bytes.NewReader(bodies[name])); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range uploads {
+		if err := writeUpload(tw, spool, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+func writeUpload(tw *tar.Writer, dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return writeEntry(tw, "uploads/"+rel, info.Size(), f)
+}
+
+// listUploads returns the keys of the uploaded files, none when files is
+// nil.
+func listUploads(ctx context.Context, files Files) ([]string, error) {
+	if files == nil {
+		return nil, nil
+	}
+	keys, err := files.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if !filepath.IsLocal(filepath.FromSlash(key)) {
+			return nil, fmt.Errorf("unsafe upload key %q", key)
+		}
+	}
+	return keys, nil
+}
+
+// spoolUpload copies the file at key to dst and returns its checksum.
+func spoolUpload(ctx context.Context, files Files, key, dst string) (string, error) {
+	src, err := files.Open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return "", err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), f.Close()
+}
+
+// retiredColumns are left behind on SQLite by migrations that removed them,
+// as SQLite cannot drop a column taking part in a foreign key. They are
+// left out of archives, which then restore on either driver.
+var retiredColumns = map[string][]string{
+	"books": {"author_id"},
+}
+
+func dumpTable(ctx context.Context, tx *sql.Tx, table string) (*tableData, error) {
+	// The first column is the primary key, or the leading part of it for
+	// tables such as branch_stock
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM "+table+" ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	data := &tableData{Rows: [][]any{}}
+	var keep []int
+	for i, ct := range types {
+		if slices.Contains(retiredColumns[table], ct.Name()) {
+			continue
+		}
+		keep = append(keep, i)
+		data.Columns = append(data.Columns, ct.Name())
+		data.Kinds = append(data.Kinds, columnKind(ct.DatabaseTypeName()))
+	}
+
+	for rows.Next() {
+		values := make([]any, len(types))
+		ptrs := make([]any, len(types))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]any, len(keep))
+		for i, col := range keep {
+			row[i] = encodeValue(values[col], data.Kinds[i])
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return data, rows.Err()
+}
+
+func columnKind(typeName string) string {
+	switch strings.ToUpper(typeName) {
+	case "DATE":
+		return kindDate
+	case "DATETIME", "TIMESTAMP":
+		return kindDateTime
+	}
+	return ""
+}
+
+func encodeValue(v any, kind string) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		if kind == kindDate {
+			return v.Format("2006-01-02")
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// ReadManifest returns the manifest of the archive in r without reading the
+// rest of it.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer gz.Close()
+	return readManifest(tar.NewReader(gz))
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	if hdr.Name != manifestName {
+		return nil, fmt.Errorf("archive does not start with %s", manifestName)
+	}
+	manifest := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	if manifest.Format != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format %d", manifest.Format)
+	}
+	return manifest, nil
+}
+
+// Restore replaces the contents of every table in Tables with the archive
+// read from r. Checksums and the schema version are verified before the
+// database is touched, and the tables are replaced in a single transaction.
+// Uploaded files are written to opts.Uploads after the transaction commits;
+// existing files with other names are kept.
+func Restore(ctx context.Context, db *sql.DB, r io.Reader, opts Options) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion != opts.SchemaVersion {
+		return nil, fmt.Errorf("%w: archive has version %d, database has %d",
+			ErrSchemaMismatch, manifest.SchemaVersion, opts.SchemaVersion)
+	}
+
+	// Uploads are staged and verified before the database is touched, and
+	// stored once the tables are restored
+	var staging string
+	if opts.Uploads != nil && manifest.Uploads > 0 {
+		if staging, err = os.MkdirTemp("", "simpus-restore-"); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(staging)
+	}
+
+	tables := map[string]*tableData{}
+	var uploads []string
+	seen := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		want, ok := manifest.Checksums[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not listed in the manifest", hdr.Name)
+		}
+		seen[hdr.Name] = true
+
+		switch {
+		case strings.HasPrefix(hdr.Name, "tables/"):
+			body, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+			}
+			if checksum(body) != want {
+				return nil, fmt.Errorf("checksum mismatch for %s", hdr.Name)
+			}
+			data := &tableData{}
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			if err := dec.Decode(data); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", hdr.Name, err)
+			}
+			if len(data.Kinds) != len(data.Columns) {
+				return nil, fmt.Errorf("decode %s: column kinds do not match columns", hdr.Name)
+			}
+			tables[strings.TrimSuffix(path.Base(hdr.Name), ".json")] = data
+
+		case strings.HasPrefix(hdr.Name, "uploads/"):
+			rel := strings.TrimPrefix(hdr.Name, "uploads/")
+			if !filepath.IsLocal(filepath.FromSlash(rel)) {
+				return nil, fmt.Errorf("unsafe upload path %q", hdr.Name)
+			}
+			if staging == "" {
+				continue
+			}
+			if err := stageUpload(tr, filepath.Join(staging, filepath.FromSlash(rel)), want); err != nil {
+				return nil, fmt.Errorf("restore %s: %w", hdr.Name, err)
+			}
+			uploads = append(uploads, rel)
+		}
+	}
+
+	for name := range manifest.Checksums {
+		if !seen[name] {
+			return nil, fmt.Errorf("archive is missing %s", name)
+		}
+	}
+	for _, table := range Tables {
+		if tables[table] == nil {
+			return nil, fmt.Errorf("archive has no %s table", table)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i := len(Tables) - 1; i >= 0; i-- {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+Tables[i]); err != nil {
+			return nil, fmt.Errorf("clear %s: %w", Tables[i], err)
+		}
+	}
+	for _, table := range Tables {
+		if err := restoreTable(ctx, tx, table, tables[table]); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, key := range uploads {
+		data, err := os.ReadFile(filepath.Join(staging, filepath.FromSlash(key)))
+		if err != nil {
+			return manifest, err
+		}
+		if err := opts.Uploads.Put(ctx, key, data, mime.TypeByExtension(path.Ext(key))); err != nil {
+			return manifest, fmt.Errorf("store upload %s: %w", key, err)
+		}
+	}
+	return manifest, nil
+}
+
+func stageUpload(r io.Reader, dst, want string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != want {
+		return errors.New("checksum mismatch")
+	}
+	return f.Close()
+}
+
+func restoreTable(ctx context.Context, tx *sql.Tx, table string, data *tableData) error {
+	if len(data.Rows) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(data.Columns)), ", ")
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(data.Columns, ", "), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for n, row := range data.Rows {
+		if len(row) != len(data.Columns) {
+			return fmt.Errorf("row %d has %d values, want %d", n+1, len(row), len(data.Columns))
+		}
+		args := make([]any, len(row))
+		for i, v := range row {
+			if args[i], err = decodeValue(v, data.Kinds[i]); err != nil {
+				return fmt.Errorf("row %d, %s: %w", n+1, data.Columns[i], err)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("row %d: %w", n+1, err)
+		}
+	}
+	return nil
+}
+
+func decodeValue(v any, kind string) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case string:
+		if kind == kindDateTime {
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	}
+	return v, nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,302 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"simpus/database/sqlitetest"
+	"simpus/internal/storage"
+	"simpus/internal/storage/storagetest"
+)
+
+func count(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func write(t *testing.T, db *sql.DB, opts Options) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := Write(context.Background(), db, &buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteRestoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	uploads := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(uploads, "covers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(uploads, "covers", "laskar.jpg"), []byte("sampul"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Now().AddDate(0, 0, -3).Format("2006-01-02")
+	if _, err := db.Exec(`INSERT INTO borrowings (member_id, book_id, user_id, borrow_date, due_date, status, fine)
+		VALUES (1, 1, 1, ?, ?, 'dipinjam', 1500.5)`, due, due); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{}
+	for _, table := range Tables {
+		want[table] = count(t, db, table)
+	}
+
+	opts := Options{SchemaVersion: 5, Driver: "sqlite", Uploads: storage.NewLocal(uploads)}
+	archive := write(t, db, opts)
+
+	manifest, err := ReadManifest(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.SchemaVersion != 5 || manifest.Uploads != 1 || manifest.Tables["books"] != want["books"] {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// Changes made after the backup are undone by the restore
+	if _, err := db.Exec(`DELETE FROM books WHERE id = 2`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO categories (name) VALUES ('Baru')`); err != nil {
+		t.Fatal(err)
+	}
+	restored := filepath.Join(t.TempDir(), "uploads")
+	opts.Uploads = storage.NewLocal(restored)
+
+	if _, err := Restore(ctx, db, bytes.NewReader(archive), opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range Tables {
+		if got := count(t, db, table); got != want[table] {
+			t.Errorf("%s: %d rows after restore, want %d", table, got, want[table])
+		}
+	}
+
+	// Dates must still compare like before, or overdue checks break
+	var overdue int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM borrowings WHERE due_date < date('now', 'localtime') AND fine = 1500.5`).Scan(&overdue); err != nil {
+		t.Fatal(err)
+	}
+	if overdue != 1 {
+		t.Errorf("overdue borrowings after restore = %d, want 1", overdue)
+	}
+
+	var verified sql.NullTime
+	if err := db.QueryRow(`SELECT email_verified_at FROM members WHERE id = 1`).Scan(&verified); err != nil {
+		t.Fatal(err)
+	}
+	if !verified.Valid {
+		t.Error("email_verified_at lost in restore")
+	}
+
+	cover, err := os.ReadFile(filepath.Join(restored, "covers", "laskar.jpg"))
+	if err != nil || string(cover) != "sampul" {
+		t.Errorf("restored cover = %q, %v", cover, err)
+	}
+}
+
+// newS3 returns a client for a new in-memory bucket.
+func newS3(t *testing.T) *storage.S3 {
+	t.Helper()
+	srv := httptest.NewServer(storagetest.NewFakeS3("simpus", "auto", "AKID", "secret"))
+	t.Cleanup(srv.Close)
+
+	s, err := storage.NewS3(storage.S3Options{Endpoint: srv.URL, Region: "auto", Bucket: "simpus", AccessKey: "AKID", SecretKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestWriteRestoreUploadsInS3(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	bucket := newS3(t)
+	for key, data := range map[string]string{"covers/laskar.jpg": "sampul", "covers/bumi.png": "png"} {
+		if err := bucket.Put(ctx, key, []byte(data), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := write(t, db, Options{SchemaVersion: 5, Uploads: bucket})
+
+	manifest, err := ReadManifest(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Uploads != 2 || manifest.Checksums["uploads/covers/laskar.jpg"] != checksum([]byte("sampul")) {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	restored := newS3(t)
+	if _, err := Restore(ctx, db, bytes.NewReader(archive), Options{SchemaVersion: 5, Uploads: restored}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := restored.Open(ctx, "covers/laskar.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "sampul" {
+		t.Errorf("restored cover = %q", data)
+	}
+	if keys, _ := restored.List(ctx, ""); len(keys) != 2 {
+		t.Errorf("restored bucket holds %v", keys)
+	}
+}
+
+func TestRestoreRejectsOtherSchemaVersion(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	archive := write(t, db, Options{SchemaVersion: 4})
+
+	_, err := Restore(context.Background(), db, bytes.NewReader(archive), Options{SchemaVersion: 5})
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("err = %v, want ErrSchemaMismatch", err)
+	}
+}
+
+// tamper rewrites the archive, passing every entry through edit.
+func tamper(t *testing.T, archive []byte, edit func(name string, body []byte) []byte) []byte {
+	t.Helper()
+
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gzr)
+
+	var out bytes.Buffer
+	gzw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gzw)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = edit(hdr.Name, body)
+		hdr.Size = int64(len(body))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gzw.Close()
+	return out.Bytes()
+}
+
+func TestRestoreVerifiesChecksums(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+	archive := write(t, db, Options{SchemaVersion: 5})
+	members := count(t, db, "members")
+
+	broken := tamper(t, archive, func(name string, body []byte) []byte {
+		if name == "tables/members.json" {
+			return bytes.Replace(body, []byte("Budi"), []byte("Budo"), 1)
+		}
+		return body
+	})
+	if _, err := db.Exec(`DELETE FROM notifications`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(ctx, db, bytes.NewReader(broken), Options{SchemaVersion: 5}); err == nil {
+		t.Fatal("tampered archive restored")
+	}
+	if got := count(t, db, "members"); got != members {
+		t.Errorf("members = %d after rejected restore, want %d", got, members)
+	}
+
+	if _, err := Restore(ctx, db, bytes.NewReader([]byte("bukan arsip")), Options{}); err == nil {
+		t.Error("garbage accepted as archive")
+	}
+}
+
+func TestRestoreIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	// A borrowing pointing at a missing book makes the restore fail late,
+	// after the earlier tables were already replaced
+	other := sqlitetest.Open(t)
+	sqlitetest.Seed(t, other)
+	conn, err := other.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO borrowings (member_id, book_id, borrow_date, due_date) VALUES (1, 999, '2024-01-01', '2024-01-08')`); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	broken := write(t, other, Options{})
+
+	books := count(t, db, "books")
+	if _, err := db.Exec(`DELETE FROM books WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(ctx, db, bytes.NewReader(broken), Options{}); err == nil {
+		t.Fatal("restore with dangling foreign key succeeded")
+	}
+	if got := count(t, db, "books"); got != books-1 {
+		t.Errorf("books = %d after failed restore, want %d", got, books-1)
+	}
+}
+
+// Archives made on SQLite restore on MySQL, which has no books.author_id.
+func TestDumpLeavesOutRetiredColumns(t *testing.T) {
+	db := sqlitetest.Open(t)
+	sqlitetest.Seed(t, db)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	data, err := dumpTable(context.Background(), tx, "books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(data.Columns, "author_id") {
+		t.Errorf("columns = %v, want author_id left out", data.Columns)
+	}
+	if len(data.Rows) != 5 || len(data.Rows[0]) != len(data.Columns) {
+		t.Errorf("dumped %d rows of %d values for %d columns", len(data.Rows), len(data.Rows[0]), len(data.Columns))
+	}
+}
@@ -0,0 +1,100 @@
+// Package barcode encodes Code 128 barcodes for printed labels.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code 128 symbol values that switch code sets, start and stop
+const (
+	codeC  = 99
+	codeB  = 100
+	startB = 104
+	startC = 105
+	stop   = 106
+)
+
+// QuietZone is the blank space, in modules, needed on each side of a
+// barcode for scanners to find it.
+const QuietZone = 10
+
+// patterns are the widths of the three bars and three spaces of each
+// symbol value, in modules; the stop symbol has a final bar.
+var patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// Code128 returns the widths, in modules, of the alternating bars and
+// spaces of s encoded in Code 128, starting with a bar: the start symbol,
+// the data, the check symbol and the stop symbol. Runs of four or more
+// digits are packed two to a symbol in code set C, the rest is written in
+// code set B, which covers printable ASCII.
+func Code128(s string) ([]int, error) {
+	if s == "" {
+		return nil, errors.New("barcode: nothing to encode")
+	}
+	for _, c := range []byte(s) {
+		if c < 32 || c > 126 {
+			return nil, fmt.Errorf("barcode: %q cannot be encoded in Code 128 B", c)
+		}
+	}
+
+	var values []int
+	setC := digitRun(s, 0) >= 4
+	if setC {
+		values = append(values, startC)
+	} else {
+		values = append(values, startB)
+	}
+	for i := 0; i < len(s); {
+		run := digitRun(s, i)
+		switch {
+		case setC && run >= 2:
+			values = append(values, int(s[i]-'0')*10+int(s[i+1]-'0'))
+			i += 2
+		case setC:
+			values = append(values, codeB)
+			setC = false
+		case run >= 4 && run%2 == 0:
+			values = append(values, codeC)
+			setC = true
+		default:
+			values = append(values, int(s[i])-32)
+			i++
+		}
+	}
+
+	check := values[0]
+	for i, v := range values[1:] {
+		check += (i + 1) * v
+	}
+	values = append(values, check%103, stop)
+
+	var widths []int
+	for _, v := range values {
+		for _, w := range patterns[v] {
+			widths = append(widths, int(w-'0'))
+		}
+	}
+	return widths, nil
+}
+
+// digitRun counts the digits of s from i on.
+func digitRun(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] >= '0' && s[i+n] <= '9' {
+		n++
+	}
+	return n
+}
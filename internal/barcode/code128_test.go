@@ -0,0 +1,88 @@
+package barcode
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	seen := map[string]int{}
+	for v, p := range patterns {
+		sum := 0
+		for _, w := range p {
+			sum += int(w - '0')
+		}
+		if want := map[bool]int{true: 13, false: 11}[v == stop]; sum != want {
+			t.Errorf("pattern %d spans %d modules, want %d", v, sum, want)
+		}
+		if prev, ok := seen[p]; ok {
+			t.Errorf("patterns %d and %d are both %s", prev, v, p)
+		}
+		seen[p] = v
+	}
+}
+
+// decode reads the symbol values back from the widths.
+func decode(t *testing.T, widths []int) []int {
+	t.Helper()
+	values := map[string]int{}
+	for v, p := range patterns {
+		values[p] = v
+	}
+	var b strings.Builder
+	for _, w := range widths {
+		b.WriteByte(byte('0' + w))
+	}
+	s := b.String()
+	var out []int
+	for len(s) > 7 {
+		out = append(out, values[s[:6]])
+		s = s[6:]
+	}
+	if s != patterns[stop] {
+		t.Fatalf("barcode ends with %s, not the stop symbol", s)
+	}
+	return append(out, stop)
+}
+
+func TestCode128(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int // without the check symbol
+	}{
+		{"A", []int{startB, 33}},
+		{"B000042", []int{startB, 34, codeC, 0, 0, 42}},
+		{"12345", []int{startC, 12, 34, codeB, 21}},
+		{"B12345", []int{startB, 34, 17, codeC, 23, 45}},
+		{"899.2213", []int{startB, 24, 25, 25, 14, codeC, 22, 13}},
+	}
+	for _, tt := range tests {
+		widths, err := Code128(tt.in)
+		if err != nil {
+			t.Fatalf("Code128(%q): %v", tt.in, err)
+		}
+		got := decode(t, widths)
+		check := tt.want[0]
+		for i, v := range tt.want[1:] {
+			check += (i + 1) * v
+		}
+		want := append(slices.Clone(tt.want), check%103, stop)
+		if !slices.Equal(got, want) {
+			t.Errorf("Code128(%q) = %v, want %v", tt.in, got, want)
+		}
+	}
+
+	// The check symbol of "A" is (104 + 33) mod 103 = 34
+	widths, _ := Code128("A")
+	want := []int{2, 1, 1, 2, 1, 4, 1, 1, 1, 3, 2, 3, 1, 3, 1, 1, 2, 3, 2, 3, 3, 1, 1, 1, 2}
+	if !slices.Equal(widths, want) {
+		t.Errorf("Code128(A) = %v, want %v", widths, want)
+	}
+
+	for _, s := range []string{"", "Buku\n", "Pelangi ✓"} {
+		if _, err := Code128(s); err == nil {
+			t.Errorf("Code128(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package credentials
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedList is a set of known-compromised passwords loaded from a local
+// file. Each line is either a plain password or an upper- or lower-case
+// SHA-1 hash, optionally followed by ":count" as in the Have I Been Pwned
+// downloads.
+//
+// The path may also be a directory of HIBP range files as written by the
+// PwnedPasswordsDownloader: each file is named after the first 5 hex digits
+// of the hashes it holds and each line is the remaining 35 digits, a colon
+// and a count.
+type BreachedList struct {
+	hashes map[string]struct{}
+}
+
+const rangePrefixLength = 5
+
+func LoadBreachedList(path string) (*BreachedList, error) {
+	list := &BreachedList{hashes: make(map[string]struct{})}
+	if path == "" {
+		return list, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return list, list.load(path, "")
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || len(name) != rangePrefixLength || !isHex(name) {
+			continue
+		}
+		if err := list.load(filepath.Join(path, entry.Name()), strings.ToUpper(name)); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// load adds the lines of a file. prefix is set for range files, whose lines
+// must all be hash suffixes.
+func (l *BreachedList) load(path, prefix string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		candidate, _, _ := strings.Cut(line, ":")
+		if prefix != "" {
+			if len(candidate) != sha1.Size*2-rangePrefixLength || !isHex(candidate) {
+				return fmt.Errorf("%s:%d: not a SHA-1 suffix", path, n)
+			}
+			l.hashes[prefix+strings.ToUpper(candidate)] = struct{}{}
+			continue
+		}
+
+		if len(candidate) == sha1.Size*2 && isHex(candidate) {
+			l.hashes[strings.ToUpper(candidate)] = struct{}{}
+			continue
+		}
+		l.hashes[sha1Hex(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+func (l *BreachedList) Contains(password string) bool {
+	_, ok := l.hashes[sha1Hex(password)]
+	return ok
+}
+
+func (l *BreachedList) Len() int {
+	return len(l.hashes)
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SHA-1 of "password"
+const passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadBreachedListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	writeFile(t, path, strings.Join([]string{
+		"# komentar",
+		"",
+		passwordSHA1 + ":9545824",
+		strings.ToLower(sha1Hex("qwerty123")),
+		"  123456789  ",
+		"bukan:hash",
+		"ZZZZ61E4C9B93F3F0682250B6CF8331B7EE68FD8",
+	}, "\n"))
+
+	list, err := LoadBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"password", "qwerty123", "123456789", "bukan:hash", "ZZZZ61E4C9B93F3F0682250B6CF8331B7EE68FD8"} {
+		if !list.Contains(password) {
+			t.Errorf("%q not in the list", password)
+		}
+	}
+	for _, password := range []string{"rahasia123", "# komentar", "", "bukan", passwordSHA1} {
+		if list.Contains(password) {
+			t.Errorf("%q in the list", password)
+		}
+	}
+	if list.Len() != 5 {
+		t.Errorf("Len = %d, want 5", list.Len())
+	}
+}
+
+func TestLoadBreachedListRangeFiles(t *testing.T) {
+	dir := t.TempDir()
+	qwerty := sha1Hex("qwerty123")
+	writeFile(t, filepath.Join(dir, passwordSHA1[:5]+".txt"), passwordSHA1[5:]+":9545824\r\n"+strings.Repeat("0", 35)+":1\r\n")
+	writeFile(t, filepath.Join(dir, strings.ToLower(qwerty[:5])), strings.ToLower(qwerty[5:])+":12\n")
+	// Other files are not range files and are skipped
+	writeFile(t, filepath.Join(dir, "README.md"), "password\n")
+
+	list, err := LoadBreachedList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !list.Contains("password") || !list.Contains("qwerty123") || list.Contains("rahasia123") {
+		t.Error("range files not loaded by prefix and suffix")
+	}
+	if list.Len() != 3 {
+		t.Errorf("Len = %d, want 3", list.Len())
+	}
+}
+
+func TestLoadBreachedListRejectsBadRangeLines(t *testing.T) {
+	for name, line := range map[string]string{
+		"full hash": passwordSHA1 + ":1",
+		"short":     passwordSHA1[5:30] + ":1",
+		"not hex":   "Z" + passwordSHA1[6:] + ":1",
+		"plain":     "password",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "5BAA6.txt"), passwordSHA1[5:]+":1\n"+line+"\n")
+
+			_, err := LoadBreachedList(dir)
+			if err == nil || !strings.Contains(err.Error(), "5BAA6.txt:2") {
+				t.Errorf("err = %v, want the bad line reported", err)
+			}
+		})
+	}
+}
+
+func TestLoadBreachedListWithoutFile(t *testing.T) {
+	list, err := LoadBreachedList("")
+	if err != nil || list.Len() != 0 {
+		t.Errorf("no file: Len = %d, err = %v", list.Len(), err)
+	}
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "hilang.txt")); err == nil {
+		t.Error("missing file accepted")
+	}
+}
@@ -0,0 +1,92 @@
+// Package credentials owns password handling for staff and members:
+// the password policy, hashing with bcrypt or argon2id, reuse history and
+// transparent rehashing when the hashing configuration changes.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+
+	"simpus/config"
+)
+
+var (
+	ErrPasswordBreached = errors.New("password ini pernah bocor di internet, silakan pilih password lain")
+	ErrPasswordReused   = errors.New("password tidak boleh sama dengan password yang pernah digunakan")
+)
+
+type Manager struct {
+	hasher   *Hasher
+	breached *BreachedList
+	history  HistoryRepository
+	cfg      config.PasswordConfig
+}
+
+func NewManager(cfg config.PasswordConfig, history HistoryRepository) (*Manager, error) {
+	hasher, err := NewHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	breached, err := LoadBreachedList(cfg.BreachedListFile)
+	if err != nil {
+		return nil, fmt.Errorf("load breached password list: %w", err)
+	}
+
+	return &Manager{
+		hasher:   hasher,
+		breached: breached,
+		history:  history,
+		cfg:      cfg,
+	}, nil
+}
+
+// Validate checks password against the policy. accountID is 0 for accounts
+// that do not exist yet, which skips the reuse check. currentHash is the
+// stored hash of an existing account; it is checked besides the history
+// because accounts created before the history was kept have no row there.
+func (m *Manager) Validate(accountType string, accountID int, currentHash, password string) error {
+	if len([]rune(password)) < m.cfg.MinLength {
+		return fmt.Errorf("password minimal %d karakter", m.cfg.MinLength)
+	}
+	if m.breached.Contains(password) {
+		return ErrPasswordBreached
+	}
+
+	if accountID == 0 || m.cfg.HistorySize <= 0 {
+		return nil
+	}
+
+	previous, err := m.history.Recent(accountType, accountID, m.cfg.HistorySize)
+	if err != nil {
+		return err
+	}
+	if currentHash != "" {
+		previous = append(previous, currentHash)
+	}
+	for _, hash := range previous {
+		if ok, _ := m.hasher.Verify(hash, password); ok {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Hash(password string) (string, error) {
+	return m.hasher.Hash(password)
+}
+
+// Verify checks a login attempt. When needsRehash is true the caller should
+// store a fresh hash of password; this is how existing accounts migrate to a
+// new cost or to argon2id.
+func (m *Manager) Verify(hash, password string) (ok bool, needsRehash bool) {
+	return m.hasher.Verify(hash, password)
+}
+
+// Record remembers a newly set password hash for the reuse check.
+func (m *Manager) Record(accountType string, accountID int, hash string) error {
+	if m.cfg.HistorySize <= 0 {
+		return nil
+	}
+	return m.history.Create(accountType, accountID, hash)
+}
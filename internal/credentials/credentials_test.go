@@ -0,0 +1,152 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"simpus/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeHistory struct {
+	hashes map[string][]string
+}
+
+func historyKey(accountType string, accountID int) string {
+	return fmt.Sprintf("%s/%d", accountType, accountID)
+}
+
+func (h *fakeHistory) Create(accountType string, accountID int, passwordHash string) error {
+	key := historyKey(accountType, accountID)
+	h.hashes[key] = append(h.hashes[key], passwordHash)
+	return nil
+}
+
+func (h *fakeHistory) Recent(accountType string, accountID int, limit int) ([]string, error) {
+	hashes := slices.Clone(h.hashes[historyKey(accountType, accountID)])
+	slices.Reverse(hashes)
+	return hashes[:min(limit, len(hashes))], nil
+}
+
+func newTestManager(t *testing.T, historySize int) (*Manager, *fakeHistory) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("password123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	history := &fakeHistory{hashes: map[string][]string{}}
+	m, err := NewManager(config.PasswordConfig{
+		MinLength:        8,
+		BreachedListFile: path,
+		HistorySize:      historySize,
+		Algorithm:        AlgorithmBcrypt,
+		BcryptCost:       bcrypt.MinCost,
+	}, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, history
+}
+
+// change sets a new password for member 1 the way the services do.
+func change(t *testing.T, m *Manager, password string) string {
+	t.Helper()
+
+	hash, err := m.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Record("member", 1, hash); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestManagerValidatePolicy(t *testing.T) {
+	m, _ := newTestManager(t, 3)
+
+	if err := m.Validate("member", 0, "", "pendek"); err == nil {
+		t.Error("short password accepted")
+	}
+	// Length counts characters, not bytes
+	if err := m.Validate("member", 0, "", "kata-sandi"); err != nil {
+		t.Errorf("10 characters: %v", err)
+	}
+	if err := m.Validate("member", 0, "", "pāsswörd"); err != nil {
+		t.Errorf("8 characters in 10 bytes: %v", err)
+	}
+	if err := m.Validate("member", 0, "", "pāsswö"); err == nil {
+		t.Error("6 characters in 8 bytes accepted")
+	}
+	if err := m.Validate("member", 0, "", "password123"); !errors.Is(err, ErrPasswordBreached) {
+		t.Errorf("breached password: err = %v, want ErrPasswordBreached", err)
+	}
+}
+
+func TestManagerValidateRejectsRecentPasswords(t *testing.T) {
+	m, _ := newTestManager(t, 3)
+
+	var current string
+	for _, password := range []string{"pertama1", "kedua222", "ketiga33", "keempat4"} {
+		current = change(t, m, password)
+	}
+
+	// The last 3 passwords are refused, older ones may come back
+	for _, password := range []string{"kedua222", "ketiga33", "keempat4"} {
+		if err := m.Validate("member", 1, current, password); !errors.Is(err, ErrPasswordReused) {
+			t.Errorf("%s: err = %v, want ErrPasswordReused", password, err)
+		}
+	}
+	if err := m.Validate("member", 1, current, "pertama1"); err != nil {
+		t.Errorf("password older than the history: %v", err)
+	}
+
+	// History is per account
+	if err := m.Validate("member", 2, "", "keempat4"); err != nil {
+		t.Errorf("other account: %v", err)
+	}
+	if err := m.Validate("admin", 1, "", "keempat4"); err != nil {
+		t.Errorf("staff account with the same id: %v", err)
+	}
+	// New accounts have no history yet
+	if err := m.Validate("member", 0, "", "keempat4"); err != nil {
+		t.Errorf("new account: %v", err)
+	}
+}
+
+func TestManagerValidateChecksCurrentHash(t *testing.T) {
+	m, history := newTestManager(t, 3)
+
+	// An account from before the history was kept
+	current, err := m.Hash("lamasekali")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Validate("member", 1, current, "lamasekali"); !errors.Is(err, ErrPasswordReused) {
+		t.Errorf("current password: err = %v, want ErrPasswordReused", err)
+	}
+	if err := m.Validate("member", 1, current, "barusekali"); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+	if len(history.hashes) != 0 {
+		t.Errorf("Validate recorded %v", history.hashes)
+	}
+}
+
+func TestManagerWithoutHistory(t *testing.T) {
+	m, history := newTestManager(t, 0)
+
+	current := change(t, m, "rahasia123")
+	if err := m.Validate("member", 1, current, "rahasia123"); err != nil {
+		t.Errorf("reuse with history disabled: %v", err)
+	}
+	if len(history.hashes) != 0 {
+		t.Errorf("Record stored %v with history disabled", history.hashes)
+	}
+}
@@ -0,0 +1,148 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"simpus/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errUnknownHash = errors.New("unknown password hash format")
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// hashes of every supported algorithm, so existing bcrypt hashes keep working
+// after switching to argon2id.
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon      argon2Params
+}
+
+type argon2Params struct {
+	memory     uint32
+	iterations uint32
+	threads    uint8
+}
+
+func NewHasher(cfg config.PasswordConfig) (*Hasher, error) {
+	h := &Hasher{
+		algorithm:  cfg.Algorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon: argon2Params{
+			memory:     cfg.Argon2Memory,
+			iterations: cfg.Argon2Iterations,
+			threads:    cfg.Argon2Threads,
+		},
+	}
+
+	switch h.algorithm {
+	case AlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case AlgorithmArgon2id:
+		if h.argon.memory < 8*uint32(h.argon.threads) || h.argon.iterations < 1 || h.argon.threads < 1 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", h.algorithm)
+	}
+
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmArgon2id {
+		return h.hashArgon2id(password)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+	return string(hashed), err
+}
+
+// Verify reports whether password matches hash. needsRehash is true when the
+// hash was produced with another algorithm or different parameters than the
+// current configuration.
+func (h *Hasher) Verify(hash, password string) (ok bool, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+		return true, h.algorithm != AlgorithmArgon2id || params != h.argon
+
+	case strings.HasPrefix(hash, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, err != nil || h.algorithm != AlgorithmBcrypt || cost != h.bcryptCost
+	}
+
+	return false, false
+}
+
+func (h *Hasher) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon.iterations, h.argon.memory, h.argon.threads, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.argon.memory, h.argon.iterations, h.argon.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// decodeArgon2id parses the PHC string format
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func decodeArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.threads); err != nil {
+		return params, nil, nil, errUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errUnknownHash
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,147 @@
+package credentials
+
+import (
+	"strings"
+	"testing"
+
+	"simpus/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func bcryptConfig(cost int) config.PasswordConfig {
+	return config.PasswordConfig{Algorithm: AlgorithmBcrypt, BcryptCost: cost}
+}
+
+func argon2Config(memory, iterations uint32, threads uint8) config.PasswordConfig {
+	return config.PasswordConfig{Algorithm: AlgorithmArgon2id, Argon2Memory: memory, Argon2Iterations: iterations, Argon2Threads: threads}
+}
+
+func newTestHasher(t *testing.T, cfg config.PasswordConfig) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func mustHash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestNewHasherRejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []config.PasswordConfig{
+		bcryptConfig(bcrypt.MinCost - 1),
+		bcryptConfig(bcrypt.MaxCost + 1),
+		argon2Config(64, 0, 1),
+		argon2Config(64, 1, 0),
+		argon2Config(8, 1, 2),
+		{Algorithm: "md5"},
+	} {
+		if _, err := NewHasher(cfg); err == nil {
+			t.Errorf("NewHasher(%+v) accepted", cfg)
+		}
+	}
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	for _, cfg := range []config.PasswordConfig{bcryptConfig(bcrypt.MinCost), argon2Config(64, 1, 1)} {
+		t.Run(cfg.Algorithm, func(t *testing.T) {
+			h := newTestHasher(t, cfg)
+			hash := mustHash(t, h, "rahasia123")
+
+			if ok, needsRehash := h.Verify(hash, "rahasia123"); !ok || needsRehash {
+				t.Errorf("Verify = %v, %v, want true, false", ok, needsRehash)
+			}
+			if ok, _ := h.Verify(hash, "rahasia124"); ok {
+				t.Error("wrong password accepted")
+			}
+			if other := mustHash(t, h, "rahasia123"); other == hash {
+				t.Error("two hashes of a password are equal, want a fresh salt")
+			}
+		})
+	}
+}
+
+func TestHasherArgon2idFormat(t *testing.T) {
+	hash := mustHash(t, newTestHasher(t, argon2Config(64, 2, 1)), "rahasia123")
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=2,p=1$") {
+		t.Fatalf("hash = %s", hash)
+	}
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != (argon2Params{memory: 64, iterations: 2, threads: 1}) || len(salt) != argon2SaltLength || len(key) != argon2KeyLength {
+		t.Errorf("params %+v, salt %d bytes, key %d bytes", params, len(salt), len(key))
+	}
+}
+
+func TestHasherRejectsMalformedHashes(t *testing.T) {
+	h := newTestHasher(t, argon2Config(64, 1, 1))
+	good := mustHash(t, h, "rahasia123")
+	parts := strings.Split(good, "$")
+
+	malformed := map[string]string{
+		"empty":            "",
+		"plain text":       "rahasia123",
+		"unknown scheme":   "$1$abc$def",
+		"missing key":      strings.Join(parts[:5], "$"),
+		"extra field":      good + "$x",
+		"other version":    strings.Replace(good, "v=19", "v=16", 1),
+		"bad params":       strings.Replace(good, "m=64,t=1,p=1", "m=64;t=1", 1),
+		"salt not base64":  strings.Replace(good, parts[4], "!!!", 1),
+		"key not base64":   strings.Replace(good, parts[5], "!!!", 1),
+		"truncated bcrypt": mustHash(t, newTestHasher(t, bcryptConfig(bcrypt.MinCost)), "rahasia123")[:30],
+	}
+	for name, hash := range malformed {
+		if ok, needsRehash := h.Verify(hash, "rahasia123"); ok || needsRehash {
+			t.Errorf("%s: Verify(%q) = %v, %v", name, hash, ok, needsRehash)
+		}
+	}
+	for _, name := range []string{"missing key", "extra field", "other version", "bad params", "salt not base64", "key not base64"} {
+		if _, _, _, err := decodeArgon2id(malformed[name]); err == nil {
+			t.Errorf("%s: decodeArgon2id accepted %q", name, malformed[name])
+		}
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	bcryptLow := newTestHasher(t, bcryptConfig(bcrypt.MinCost))
+	bcryptHigh := newTestHasher(t, bcryptConfig(bcrypt.MinCost+1))
+	argonSmall := newTestHasher(t, argon2Config(64, 1, 1))
+
+	tests := []struct {
+		name   string
+		hash   string
+		hasher *Hasher
+		want   bool
+	}{
+		{"same bcrypt cost", mustHash(t, bcryptLow, "rahasia123"), bcryptLow, false},
+		{"bcrypt cost raised", mustHash(t, bcryptLow, "rahasia123"), bcryptHigh, true},
+		{"bcrypt cost lowered", mustHash(t, bcryptHigh, "rahasia123"), bcryptLow, true},
+		{"bcrypt to argon2id", mustHash(t, bcryptLow, "rahasia123"), argonSmall, true},
+		{"argon2id to bcrypt", mustHash(t, argonSmall, "rahasia123"), bcryptLow, true},
+		{"same argon2id parameters", mustHash(t, argonSmall, "rahasia123"), argonSmall, false},
+		{"argon2id memory", mustHash(t, argonSmall, "rahasia123"), newTestHasher(t, argon2Config(128, 1, 1)), true},
+		{"argon2id iterations", mustHash(t, argonSmall, "rahasia123"), newTestHasher(t, argon2Config(64, 2, 1)), true},
+		{"argon2id threads", mustHash(t, argonSmall, "rahasia123"), newTestHasher(t, argon2Config(64, 1, 2)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash := tt.hasher.Verify(tt.hash, "rahasia123")
+			if !ok || needsRehash != tt.want {
+				t.Errorf("Verify = %v, %v, want true, %v", ok, needsRehash, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"database/sql"
+)
+
+// HistoryRepository stores previous password hashes.
+type HistoryRepository interface {
+	Create(accountType string, accountID int, passwordHash string) error
+	Recent(accountType string, accountID int, limit int) ([]string, error)
+}
+
+type historyRepository struct {
+	db *sql.DB
+}
+
+func NewHistoryRepository(db *sql.DB) HistoryRepository {
+	return &historyRepository{db: db}
+}
+
+func (r *historyRepository) Create(accountType string, accountID int, passwordHash string) error {
+	query := `INSERT INTO password_history (account_type, account_id, password_hash) VALUES (?, ?, ?)`
+	_, err := r.db.Exec(query, accountType, accountID, passwordHash)
+	return err
+}
+
+// Recent returns the newest limit password hashes of an account.
+func (r *historyRepository) Recent(accountType string, accountID int, limit int) ([]string, error) {
+	query := `SELECT password_hash FROM password_history 
+			  WHERE account_type = ? AND account_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`
+
+	rows, err := r.db.Query(query, accountType, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
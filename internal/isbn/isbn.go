@@ -0,0 +1,108 @@
+// Package isbn checks International Standard Book Numbers.
+package isbn
+
+import "strings"
+
+// Normalize strips hyphens and spaces and upper-cases the ISBN-10 check
+// character X. It does not validate.
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if r != '-' && r != ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Valid reports whether s is an ISBN-10 or ISBN-13 with a correct check
+// digit. Hyphens and spaces are ignored.
+func Valid(s string) bool {
+	n := Normalize(s)
+	switch len(n) {
+	case 10:
+		return valid10(n)
+	case 13:
+		return valid13(n)
+	}
+	return false
+}
+
+func valid10(n string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var d int
+		switch c := n[i]; {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
+
+func valid13(n string) bool {
+	sum := 0
+	for i := 0; i < 13; i++ {
+		c := n[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// To13 converts a valid ISBN-10 to its ISBN-13 form and returns a valid
+// ISBN-13 normalized. It returns "" for anything else.
+func To13(s string) string {
+	n := Normalize(s)
+	switch {
+	case len(n) == 13 && valid13(n):
+		return n
+	case len(n) == 10 && valid10(n):
+		body := "978" + n[:9]
+		sum := 0
+		for i := 0; i < 12; i++ {
+			d := int(body[i] - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return body + string(rune('0'+(10-sum%10)%10))
+	}
+	return ""
+}
+
+// To10 converts a valid ISBN-13 in the 978 prefix to its ISBN-10 form and
+// returns a valid ISBN-10 normalized. It returns "" for anything else,
+// including ISBN-13s starting with 979, which have no ISBN-10.
+func To10(s string) string {
+	n := Normalize(s)
+	switch {
+	case len(n) == 10 && valid10(n):
+		return n
+	case len(n) == 13 && valid13(n) && strings.HasPrefix(n, "978"):
+		body := n[3:12]
+		sum := 0
+		for i := 0; i < 9; i++ {
+			sum += (10 - i) * int(body[i]-'0')
+		}
+		switch check := (11 - sum%11) % 11; check {
+		case 10:
+			return body + "X"
+		default:
+			return body + string(rune('0'+check))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package isbn
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want bool
+	}{
+		{"978-0-13-235088-4", true},
+		{"9780132350884", true},
+		{"0-13-235088-2", true},
+		{"0-8044-2957-X", true},
+		{"0-8044-2957-x", true},
+		{"978-0-13-235088-5", false},
+		{"0-13-235088-3", false},
+		{"978-602-03-1234", false},
+		{"97806020312345X", false},
+		{"X-8044-2957-0", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.isbn); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestTo13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+	}{
+		{"0-13-235088-2", "9780132350884"},
+		{"0-8044-2957-X", "9780804429573"},
+		{"978-0-13-235088-4", "9780132350884"},
+		{"0-13-235088-3", ""},
+	}
+	for _, tt := range tests {
+		if got := To13(tt.isbn); got != tt.want {
+			t.Errorf("To13(%q) = %q, want %q", tt.isbn, got, tt.want)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+	}{
+		{"978-0-13-235088-4", "0132350882"},
+		{"9780804429573", "080442957X"},
+		{"0-8044-2957-x", "080442957X"},
+		{"979-10-90636-07-1", ""},
+		{"978-0-13-235088-5", ""},
+	}
+	for _, tt := range tests {
+		if got := To10(tt.isbn); got != tt.want {
+			t.Errorf("To10(%q) = %q, want %q", tt.isbn, got, tt.want)
+		}
+		if got := To10(tt.isbn); got != "" && To13(got) != To13(tt.isbn) {
+			t.Errorf("To13(To10(%q)) = %q", tt.isbn, To13(got))
+		}
+	}
+}
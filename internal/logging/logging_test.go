@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"simpus/config"
+)
+
+func TestRequestIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, config.LogConfig{Level: "info", Format: "json"}).With("component", "test")
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-42")
+	logger.InfoContext(ctx, "hello")
+	logger.Debug("hidden below info")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("want exactly one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-42" || record["component"] != "test" || record["msg"] != "hello" {
+		t.Errorf("record = %v", record)
+	}
+}
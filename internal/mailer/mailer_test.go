@@ -0,0 +1,63 @@
+package mailer_test
+
+import (
+	"mime"
+	"testing"
+
+	"simpus/internal/mailer"
+	"simpus/internal/mailer/mailertest"
+)
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := mailertest.NewServer(t, "simpus", "rahasia")
+	m := mailer.NewSMTPMailer(server.Config())
+
+	err := m.Send(&mailer.Message{
+		To:      "budi@student.ac.id",
+		Subject: "Reset Password — SIMPUS",
+		Body:    "Halo Budi,\r\n\r\nBaris kedua.\r\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := server.Next(t)
+	if msg.From != "no-reply@simpus.test" || len(msg.To) != 1 || msg.To[0] != "budi@student.ac.id" {
+		t.Errorf("envelope from %q to %v", msg.From, msg.To)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Reset Password — SIMPUS" {
+		t.Errorf("subject = %q, %v", subject, err)
+	}
+	if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("content type = %q", got)
+	}
+	if msg.Header.Get("Date") == "" {
+		t.Error("missing Date header")
+	}
+	// The dot reader turns CRLF line endings into LF
+	if msg.Body != "Halo Budi,\n\nBaris kedua.\n" {
+		t.Errorf("body = %q", msg.Body)
+	}
+}
+
+func TestSMTPMailerSendFailures(t *testing.T) {
+	server := mailertest.NewServer(t, "simpus", "rahasia")
+	msg := &mailer.Message{To: "budi@student.ac.id", Subject: "Tes", Body: "Tes"}
+
+	cfg := server.Config()
+	cfg.Password = "salah"
+	if err := mailer.NewSMTPMailer(cfg).Send(msg); err == nil {
+		t.Error("wrong SMTP password accepted")
+	}
+
+	cfg = server.Config()
+	cfg.From = "bukan alamat"
+	if err := mailer.NewSMTPMailer(cfg).Send(msg); err == nil {
+		t.Error("invalid sender accepted")
+	}
+
+	if server.Pending() != 0 {
+		t.Errorf("%d messages delivered, want none", server.Pending())
+	}
+}
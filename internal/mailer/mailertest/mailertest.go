@@ -0,0 +1,160 @@
+// Package mailertest provides an SMTP listener that accepts every message
+// and hands it to the test, so mail is delivered through the real SMTP
+// client without a MailHog instance.
+package mailertest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/config"
+)
+
+// Message is an email received by the server.
+type Message struct {
+	From   string
+	To     []string
+	Header mail.Header
+	Body   string
+}
+
+// Server is an SMTP server on a local port. When a username is configured,
+// clients must authenticate with AUTH PLAIN.
+type Server struct {
+	listener net.Listener
+	username string
+	password string
+	messages chan *Message
+}
+
+// NewServer starts a server that is stopped when t ends. Username and
+// password may be empty to accept unauthenticated mail.
+func NewServer(t testing.TB, username, password string) *Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("smtp listen: %v", err)
+	}
+	s := &Server{
+		listener: listener,
+		username: username,
+		password: password,
+		messages: make(chan *Message, 16),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// Config returns the SMTP settings that deliver to the server.
+func (s *Server) Config() config.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return config.SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: s.username,
+		Password: s.password,
+		From:     "SIMPUS <no-reply@simpus.test>",
+	}
+}
+
+// Next waits for the next message, failing t when none arrives in time.
+func (s *Server) Next(t testing.TB) *Message {
+	t.Helper()
+
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+		return nil
+	}
+}
+
+// Pending returns the number of received messages not yet taken by Next.
+func (s *Server) Pending() int {
+	return len(s.messages)
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	msg := &Message{}
+	authenticated := s.username == ""
+	tp.PrintfLine("220 mailertest ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-mailertest")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			mechanism, credentials, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			parts := strings.Split(string(decoded), "\x00")
+			if strings.ToUpper(mechanism) == "PLAIN" && len(parts) == 3 && parts[1] == s.username && parts[2] == s.password {
+				authenticated = true
+				tp.PrintfLine("235 2.7.0 Authentication successful")
+			} else {
+				tp.PrintfLine("535 5.7.8 Authentication credentials invalid")
+			}
+		case "MAIL":
+			if !authenticated {
+				tp.PrintfLine("530 5.7.0 Authentication required")
+				continue
+			}
+			msg = &Message{From: address(arg)}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			parsed, err := mail.ReadMessage(bufio.NewReader(tp.DotReader()))
+			if err != nil {
+				tp.PrintfLine("554 malformed message")
+				continue
+			}
+			body, _ := io.ReadAll(parsed.Body)
+			msg.Header, msg.Body = parsed.Header, string(body)
+			s.messages <- msg
+			tp.PrintfLine("250 OK")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// address extracts the address of a "FROM:<a@b>" or "TO:<a@b>" argument.
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
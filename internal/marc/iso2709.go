@@ -0,0 +1,190 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The ISO 2709 delimiters.
+const (
+	subfieldDelimiter = 0x1f
+	fieldTerminator   = 0x1e
+	recordTerminator  = 0x1d
+)
+
+const (
+	leaderLen    = 24
+	entryLen     = 12
+	maxRecordLen = 99999
+	maxFieldLen  = 9999
+)
+
+// Reader reads records in the ISO 2709 exchange format.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a reader of the records in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more. Line
+// breaks between records, which some tools add, are skipped.
+func (r *Reader) Read() (*Record, error) {
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c != '\n' && c != '\r' && c != ' ' && c != '\t' {
+			r.r.UnreadByte()
+			break
+		}
+	}
+
+	data, err := r.r.ReadBytes(recordTerminator)
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: record not terminated", ErrInvalid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (*Record, error) {
+	if len(data) < leaderLen+1 {
+		return nil, fmt.Errorf("%w: record too short", ErrInvalid)
+	}
+	leader := string(data[:leaderLen])
+	base, err := strconv.Atoi(leader[12:17])
+	if err != nil || base <= leaderLen || base > len(data) {
+		return nil, fmt.Errorf("%w: base address %q", ErrInvalid, leader[12:17])
+	}
+
+	directory := data[leaderLen : base-1]
+	if len(directory)%entryLen != 0 {
+		return nil, fmt.Errorf("%w: directory of %d bytes", ErrInvalid, len(directory))
+	}
+	fields := data[base:]
+
+	rec := &Record{Leader: leader}
+	for i := 0; i < len(directory); i += entryLen {
+		entry := directory[i : i+entryLen]
+		tag := string(entry[:3])
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || start+length > len(fields) || length < 1 {
+			return nil, fmt.Errorf("%w: directory entry %q", ErrInvalid, entry)
+		}
+		// The length counts the field terminator
+		value := fields[start : start+length-1]
+
+		f := Field{Tag: tag}
+		if f.IsControl() {
+			f.Value = text(value)
+			rec.Fields = append(rec.Fields, f)
+			continue
+		}
+		if len(value) < 2 {
+			return nil, fmt.Errorf("%w: field %s has no indicators", ErrInvalid, tag)
+		}
+		f.Ind1, f.Ind2 = value[0], value[1]
+		for _, sf := range bytes.Split(value[2:], []byte{subfieldDelimiter}) {
+			if len(sf) == 0 {
+				continue
+			}
+			f.Subfields = append(f.Subfields, Subfield{Code: sf[0], Value: text(sf[1:])})
+		}
+		rec.Fields = append(rec.Fields, f)
+	}
+	return rec, nil
+}
+
+// text decodes a value of a record assumed to be UTF-8. Records in MARC-8
+// keep their ASCII, the rest is replaced.
+func text(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return strings.ToValidUTF8(string(b), "�")
+}
+
+// Writer writes records in the ISO 2709 exchange format.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a writer of records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes the record, filling in the lengths and addresses of the
+// leader. Records are always written as UTF-8.
+func (w *Writer) Write(rec *Record) error {
+	var directory, fields bytes.Buffer
+	for _, f := range rec.Fields {
+		if len(f.Tag) != 3 {
+			return fmt.Errorf("%w: tag %q", ErrInvalid, f.Tag)
+		}
+		start := fields.Len()
+		if f.IsControl() {
+			fields.WriteString(f.Value)
+		} else {
+			fields.WriteByte(indicator(f.Ind1))
+			fields.WriteByte(indicator(f.Ind2))
+			for _, sf := range f.Subfields {
+				fields.WriteByte(subfieldDelimiter)
+				fields.WriteByte(sf.Code)
+				fields.WriteString(sf.Value)
+			}
+		}
+		fields.WriteByte(fieldTerminator)
+
+		length := fields.Len() - start
+		if length > maxFieldLen {
+			return fmt.Errorf("%w: field %s is %d bytes long", ErrInvalid, f.Tag, length)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", f.Tag, length, start)
+	}
+	directory.WriteByte(fieldTerminator)
+	fields.WriteByte(recordTerminator)
+
+	base := leaderLen + directory.Len()
+	total := base + fields.Len()
+	if total > maxRecordLen {
+		return fmt.Errorf("%w: record is %d bytes long", ErrInvalid, total)
+	}
+
+	leader := []byte(rec.Leader)
+	if len(leader) != leaderLen {
+		leader = []byte(DefaultLeader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	leader[9] = 'a'
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:24], "4500")
+
+	for _, b := range [][]byte{leader, directory.Bytes(), fields.Bytes()} {
+		if _, err := w.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indicator returns the indicator to write, a blank when it is unset.
+func indicator(c byte) byte {
+	if c == 0 {
+		return ' '
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+// Package marc reads and writes MARC 21 bibliographic records, both in the
+// ISO 2709 exchange format (.mrc) and as MARCXML. It deals with the record
+// structure only; what the fields mean is up to the caller.
+package marc
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is wrapped by the errors for malformed records.
+var ErrInvalid = errors.New("marc: invalid record")
+
+// DefaultLeader describes a new record: a monograph of language material,
+// encoded in UTF-8 and described with ISBD punctuation. Its lengths and
+// base address are filled in when the record is written.
+const DefaultLeader = "00000nam a2200000 i 4500"
+
+// Record is a MARC record: the leader and the fields in the order they
+// appear.
+type Record struct {
+	Leader string
+	Fields []Field
+}
+
+// Field is a control field (tags 001 to 009), which holds a value, or a data
+// field with two indicators and subfields.
+type Field struct {
+	Tag       string
+	Value     string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+// Subfield is one coded value of a data field.
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// NewRecord returns an empty record with the default leader.
+func NewRecord() *Record {
+	return &Record{Leader: DefaultLeader}
+}
+
+// IsControl reports whether the field is a control field.
+func (f Field) IsControl() bool {
+	return strings.HasPrefix(f.Tag, "00")
+}
+
+// Subfield returns the first subfield with the code, or "".
+func (f Field) Subfield(code byte) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+	return ""
+}
+
+// All returns the fields with the tag.
+func (r *Record) All(tag string) []Field {
+	var fields []Field
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Control returns the value of the first control field with the tag, or "".
+func (r *Record) Control(tag string) string {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Subfield returns the first subfield with the code in the first field
+// with the tag that has one, or "".
+func (r *Record) Subfield(tag string, code byte) string {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			if v := f.Subfield(code); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// AddControl appends a control field.
+func (r *Record) AddControl(tag, value string) {
+	r.Fields = append(r.Fields, Field{Tag: tag, Value: value})
+}
+
+// AddData appends a data field. Empty subfields are left out, and so is
+// the field when all of them are.
+func (r *Record) AddData(tag string, ind1, ind2 byte, subfields ...Subfield) {
+	f := Field{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for _, sf := range subfields {
+		if sf.Value != "" {
+			f.Subfields = append(f.Subfields, sf)
+		}
+	}
+	if len(f.Subfields) > 0 {
+		r.Fields = append(r.Fields, f)
+	}
+}
@@ -0,0 +1,155 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sample is a record as another system writes it: a leader with its own
+// lengths, blank indicators and a title in Indonesian.
+const sample = "00096nam a2200049 i 4500001000300000245004300003\x1e42\x1e10\x1faBumi manusia /\x1fcPramoedya Ananta Toer.\x1e\x1d"
+
+func sampleRecords() []*Record {
+	r1 := NewRecord()
+	r1.AddControl("001", "1")
+	r1.AddControl("008", "050101s2005    io            000 1 ind d")
+	r1.AddData("020", ' ', ' ', Subfield{'a', "9789793062792"})
+	r1.AddData("100", '1', ' ', Subfield{'a', "Hirata, Andrea,"}, Subfield{'e', "pengarang."})
+	r1.AddData("245", '1', '0', Subfield{'a', "Laskar pelangi :"}, Subfield{'b', "sebuah novel /"}, Subfield{'c', "Andrea Hirata."})
+	r1.AddData("520", ' ', ' ', Subfield{'a', "Kisah sepuluh anak Belitung — \"Laskar Pelangi\" & gurunya <Bu Mus>."})
+	r1.AddData("650", ' ', '4', Subfield{'a', "Fiksi"})
+
+	r2 := NewRecord()
+	r2.AddControl("001", "2")
+	r2.AddData("245", '0', '0', Subfield{'a', "Clean code"})
+	return []*Record{r1, r2}
+}
+
+func TestAddData(t *testing.T) {
+	r := NewRecord()
+	r.AddData("020", ' ', ' ', Subfield{'a', ""})
+	r.AddData("264", ' ', '1', Subfield{'b', ""}, Subfield{'c', "2005"})
+	if len(r.Fields) != 1 || len(r.Fields[0].Subfields) != 1 || r.Subfield("264", 'c') != "2005" {
+		t.Errorf("fields = %+v, want only 264 $c", r.Fields)
+	}
+}
+
+func TestRead(t *testing.T) {
+	rec, err := NewReader(strings.NewReader(sample + "\n")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Control("001") != "42" || rec.Subfield("245", 'a') != "Bumi manusia /" ||
+		rec.Subfield("245", 'c') != "Pramoedya Ananta Toer." {
+		t.Errorf("record = %+v", rec)
+	}
+	if f := rec.All("245"); len(f) != 1 || f[0].Ind1 != '1' || f[0].Ind2 != '0' {
+		t.Errorf("245 = %+v", f)
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != sample {
+		t.Errorf("Write = %q, want %q", buf.String(), sample)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"truncated":    sample[:60],
+		"base address": strings.Replace(sample, "00049", "0004x", 1),
+		"directory":    strings.Replace(sample, "245004300003", "245009900003", 1),
+	} {
+		if _, err := NewReader(strings.NewReader(data)).Read(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Read = %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestWriteTooLong(t *testing.T) {
+	r := NewRecord()
+	r.AddData("520", ' ', ' ', Subfield{'a', strings.Repeat("x", 10000)})
+	if err := NewWriter(io.Discard).Write(r); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Write of a 10000 byte field = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := sampleRecords()
+
+	var iso, xml bytes.Buffer
+	w, xw := NewWriter(&iso), NewXMLWriter(&xml)
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+		if err := xw.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := xw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, read := range map[string]func() (*Record, error){
+		"ISO 2709": NewReader(&iso).Read,
+		"MARCXML":  NewXMLReader(&xml).Read,
+	} {
+		for i, want := range records {
+			got, err := read()
+			if err != nil {
+				t.Fatalf("%s: record %d: %v", name, i, err)
+			}
+			// The writer fills in the leader
+			got.Leader = want.Leader
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: record %d = %+v, want %+v", name, i, got, want)
+			}
+		}
+		if _, err := read(); err != io.EOF {
+			t.Errorf("%s: Read after the last record = %v, want io.EOF", name, err)
+		}
+	}
+}
+
+// A lone record with a namespace prefix, as from an SRU or OAI-PMH response.
+func TestReadXML(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<marc:record xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:leader>01142cam  2200301 a 4500</marc:leader>
+  <marc:controlfield tag="001">92005291</marc:controlfield>
+  <marc:datafield tag="020" ind1=" " ind2=" ">
+    <marc:subfield code="a">0152038655 :</marc:subfield>
+    <marc:subfield code="c">$15.95</marc:subfield>
+  </marc:datafield>
+  <marc:datafield tag="650" ind1="" ind2="1">
+    <marc:subfield code="a">Arithmetic</marc:subfield>
+  </marc:datafield>
+</marc:record>`
+
+	r := NewXMLReader(strings.NewReader(doc))
+	rec, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Leader != "01142cam  2200301 a 4500" || rec.Control("001") != "92005291" ||
+		rec.Subfield("020", 'a') != "0152038655 :" || rec.Subfield("650", 'a') != "Arithmetic" {
+		t.Errorf("record = %+v", rec)
+	}
+	if f := rec.All("650")[0]; f.Ind1 != ' ' || f.Ind2 != '1' {
+		t.Errorf("650 indicators = %q %q", f.Ind1, f.Ind2)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("second Read = %v, want io.EOF", err)
+	}
+
+	if _, err := NewXMLReader(strings.NewReader("<collection><record><leader>")).Read(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Read of truncated XML = %v, want ErrInvalid", err)
+	}
+}
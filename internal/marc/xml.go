@@ -0,0 +1,156 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Namespace is the XML namespace of MARCXML.
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// XMLReader reads MARCXML records, from a collection or a lone record.
+// Elements are matched by name whatever their namespace prefix.
+type XMLReader struct {
+	d *xml.Decoder
+}
+
+// NewXMLReader returns a reader of the MARCXML records in r.
+func NewXMLReader(r io.Reader) *XMLReader {
+	return &XMLReader{d: xml.NewDecoder(r)}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (r *XMLReader) Read() (*Record, error) {
+	for {
+		tok, err := r.d.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var xr xmlRecord
+		if err := r.d.DecodeElement(&xr, &start); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return fromXML(&xr), nil
+	}
+}
+
+// fromXML puts the control fields before the data fields, their order in
+// any valid record.
+func fromXML(xr *xmlRecord) *Record {
+	rec := &Record{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		rec.Fields = append(rec.Fields, Field{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range xr.DataFields {
+		f := Field{Tag: df.Tag, Ind1: xmlIndicator(df.Ind1), Ind2: xmlIndicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			if sf.Code == "" {
+				continue
+			}
+			f.Subfields = append(f.Subfields, Subfield{Code: sf.Code[0], Value: sf.Value})
+		}
+		rec.Fields = append(rec.Fields, f)
+	}
+	return rec
+}
+
+func xmlIndicator(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
+
+// XMLWriter writes records as a MARCXML collection. Close ends the
+// collection.
+type XMLWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// NewXMLWriter returns a writer of a collection to w.
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{w: w}
+}
+
+func (w *XMLWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := fmt.Fprintf(w.w, "%s<collection xmlns=%q>\n", xml.Header, Namespace)
+	return err
+}
+
+// Write writes the record into the collection.
+func (w *XMLWriter) Write(rec *Record) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	xr := xmlRecord{Leader: rec.Leader}
+	if len(xr.Leader) != leaderLen {
+		xr.Leader = DefaultLeader
+	}
+	for _, f := range rec.Fields {
+		if f.IsControl() {
+			xr.ControlFields = append(xr.ControlFields, xmlControlField{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+		df := xmlDataField{Tag: f.Tag, Ind1: string(indicator(f.Ind1)), Ind2: string(indicator(f.Ind2))}
+		for _, sf := range f.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: string(sf.Code), Value: sf.Value})
+		}
+		xr.DataFields = append(xr.DataFields, df)
+	}
+
+	enc := xml.NewEncoder(w.w)
+	enc.Indent("  ", "  ")
+	if err := enc.Encode(xr); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n")
+	return err
+}
+
+// Close ends the collection, which is empty when no record was written.
+func (w *XMLWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "</collection>\n")
+	return err
+}
@@ -0,0 +1,92 @@
+// Package metrics defines the Prometheus metrics exposed on /metrics.
+// Services update the domain counters directly; the HTTP middleware records
+// request latency.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simpus"
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	LoansCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_created_total",
+		Help:      "Borrowings created.",
+	})
+
+	Returns = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "returns_total",
+		Help:      "Borrowed books returned.",
+	})
+
+	FinesCharged = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fines_charged_rupiah_total",
+		Help:      "Late fines charged on return, in rupiah.",
+	})
+
+	NotificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "Member notifications created, by type.",
+	}, []string{"type"})
+
+	LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Failed logins, by account type (admin or member).",
+	}, []string{"account"})
+)
+
+// ObserveHTTP records one served request. route is the chi route pattern,
+// not the raw path, to keep label cardinality bounded.
+func ObserveHTTP(method, route string, status int, elapsed time.Duration) {
+	HTTPRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// Handler returns the /metrics handler. It also reports the connection pool
+// stats of db and the current number of overdue borrowings.
+func Handler(db *sql.DB, overdueCount func() (int, error)) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, namespace),
+		HTTPRequestDuration,
+		LoansCreated,
+		Returns,
+		FinesCharged,
+		NotificationsSent,
+		LoginFailures,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "overdue_borrowings",
+			Help:      "Borrowings past their due date that are not returned yet.",
+		}, func() float64 {
+			count, err := overdueCount()
+			if err != nil {
+				return 0
+			}
+			return float64(count)
+		}),
+	)
+
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
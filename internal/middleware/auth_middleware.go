@@ -49,6 +49,21 @@ func (m *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
 	})
 }
 
+// RequireRole limits a route to staff accounts with role, e.g. "admin" for
+// tasks that staff members must not perform. Use after RequireAdmin.
+func (m *AuthMiddleware) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := GetUserFromContext(r.Context())
+			if claims == nil || claims.Role != role {
+				http.Error(w, "Anda tidak memiliki akses ke halaman ini", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (m *AuthMiddleware) RequireMember(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		claims := GetUserFromContext(r.Context())
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"simpus/internal/metrics"
+)
+
+func TestRequestLoggerRecordsRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(chimw.RequestID)
+	r.Use(RequestLogger)
+	r.Get("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for _, path := range []string{"/books/1", "/books/2"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Header().Get("X-Request-ID") == "" {
+			t.Error("response has no X-Request-ID")
+		}
+	}
+
+	// Both requests share one series labelled with the pattern, not the path
+	series := metrics.HTTPRequestDuration.WithLabelValues("GET", "/books/{id}", "418")
+	if count := testutil.CollectAndCount(metrics.HTTPRequestDuration); count != 1 {
+		t.Errorf("series = %d, want 1", count)
+	}
+	if n := histogramCount(t, series); n != 2 {
+		t.Errorf("observations = %d, want 2", n)
+	}
+}
+
+func histogramCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := o.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
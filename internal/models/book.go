@@ -1,15 +1,23 @@
 package models
 
-import "time"
+import (
+	"regexp"
+	"strings"
+	"time"
+)
 
 type Book struct {
 	ID          int       `json:"id"`
 	ISBN        string    `json:"isbn"`
 	Title       string    `json:"title"`
 	CategoryID  *int      `json:"category_id"`
-	AuthorID    *int      `json:"author_id"`
+	WorkID      *int      `json:"work_id"`
+	Edition     string    `json:"edition"` // "Edisi ke-2", "" for the only or first one
 	Publisher   string    `json:"publisher"`
 	PublishYear int       `json:"publish_year"`
+	Language    string    `json:"language"` // ISO 639-1 code
+	DDC         string    `json:"ddc"`      // Dewey Decimal class number, "899.2213"
+	Cutter      string    `json:"cutter"`   // author mark, "HIR"
 	Stock       int       `json:"stock"`
 	Available   int       `json:"available"`
 	CoverImage  string    `json:"cover_image"`
@@ -18,39 +26,313 @@ type Book struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 
 	// Relations
-	Category *Category `json:"category,omitempty"`
-	Author   *Author   `json:"author,omitempty"`
+	Category     *Category     `json:"category,omitempty"`
+	Work         *Work         `json:"work,omitempty"`
+	Contributors []Contributor `json:"contributors,omitempty"` // in credit order
+	Subjects     []string      `json:"subjects,omitempty"`
+	Branches     []BranchStock `json:"branches,omitempty"`
 }
 
 type BookCreate struct {
 	ISBN        string `json:"isbn"`
 	Title       string `json:"title"`
 	CategoryID  int    `json:"category_id"`
-	AuthorID    int    `json:"author_id"`
+	WorkID      int    `json:"work_id"`  // 0 for a book that is a work of its own
+	NewWork     bool   `json:"new_work"` // start a work of the book's title instead
+	Edition     string `json:"edition"`
 	Publisher   string `json:"publisher"`
 	PublishYear int    `json:"publish_year"`
+	Language    string `json:"language"`
+	DDC         string `json:"ddc"`
+	Cutter      string `json:"cutter"` // made from the main entry when empty
 	Stock       int    `json:"stock"`
+	BranchID    int    `json:"branch_id"` // branch that receives the initial stock
 	CoverImage  string `json:"cover_image"`
 	Description string `json:"description"`
+
+	Contributors []Contributor `json:"contributors"` // in credit order
+	Subjects     []string      `json:"subjects"`
+}
+
+// BookImport is a book read from an import file. A category or author
+// named without an ID is created.
+type BookImport struct {
+	BookCreate
+	Category string
+	Authors  []string // names of the authors, in order, matching Contributors
+}
+
+// BookMetadata is what a metadata provider knows of an edition, looked up
+// by its ISBN.
+type BookMetadata struct {
+	ISBN        string   `json:"isbn"` // ISBN-13
+	Title       string   `json:"title"`
+	Authors     []string `json:"authors"`
+	Publisher   string   `json:"publisher"`
+	PublishYear int      `json:"publish_year"`
+	Language    string   `json:"language"` // ISO 639-1 code, "" when unknown
+	Description string   `json:"description"`
+	Subjects    []string `json:"subjects"`
+	CoverURL    string   `json:"cover_url"`
 }
 
 type BookUpdate struct {
 	ISBN        string `json:"isbn"`
 	Title       string `json:"title"`
 	CategoryID  int    `json:"category_id"`
-	AuthorID    int    `json:"author_id"`
+	WorkID      int    `json:"work_id"`  // 0 for a book that is a work of its own
+	NewWork     bool   `json:"new_work"` // start a work of the book's title instead
+	Edition     string `json:"edition"`
 	Publisher   string `json:"publisher"`
 	PublishYear int    `json:"publish_year"`
-	Stock       int    `json:"stock"`
+	Language    string `json:"language"`
+	DDC         string `json:"ddc"`
+	Cutter      string `json:"cutter"` // made from the main entry when empty
 	CoverImage  string `json:"cover_image"`
 	Description string `json:"description"`
+
+	Contributors []Contributor `json:"contributors"` // in credit order
+	Subjects     []string      `json:"subjects"`
+}
+
+// Roles of a book contributor
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+)
+
+// ContributorRole is a role offered in forms.
+type ContributorRole struct {
+	Code string
+	Name string
+}
+
+// ContributorRoles are the roles a contributor can have, authors first.
+var ContributorRoles = []ContributorRole{
+	{RoleAuthor, "Penulis"},
+	{RoleEditor, "Editor"},
+	{RoleTranslator, "Penerjemah"},
+	{RoleIllustrator, "Ilustrator"},
+}
+
+// ValidRole reports whether role is one of ContributorRoles.
+func ValidRole(role string) bool {
+	for _, r := range ContributorRoles {
+		if r.Code == role {
+			return true
+		}
+	}
+	return false
 }
 
+// Contributor is an author credited with a role in a book.
+type Contributor struct {
+	AuthorID int    `json:"author_id"`
+	Name     string `json:"name,omitempty"`
+	Role     string `json:"role"`
+}
+
+// RoleName returns the name of the contributor's role.
+func (c Contributor) RoleName() string {
+	for _, r := range ContributorRoles {
+		if r.Code == c.Role {
+			return r.Name
+		}
+	}
+	return c.Role
+}
+
+// Orders of the catalog. With a search query and no order, books are
+// listed by relevance.
+const (
+	BookSortNewest  = "newest"  // most recently added
+	BookSortTitle   = "title"   // A to Z
+	BookSortPopular = "popular" // most borrowed
+	BookSortYear    = "year"    // most recently published
+	BookSortShelf   = "shelf"   // by call number, as on the shelves
+	BookSortVolume  = "volume"  // by volume of the series, then newest edition
+)
+
 type BookFilter struct {
-	Search     string
-	CategoryID int
-	AuthorID   int
-	Available  bool
-	Page       int
-	Limit      int
+	Search      string // full-text query, resolved to IDs by the search index
+	IDs         []int  // when not nil, only these books, in this order
+	CategoryID  int    // includes the subcategories
+	CategoryIDs []int  // CategoryID and its subcategories, resolved by the service
+	AuthorID    int
+	Subject     string
+	WorkID      int    // editions of a work
+	SeriesID    int    // editions of the works of a series
+	Class       string // leading digits of the DDC class: "8", "89" or "899"
+	Publisher   string
+	Language    string
+	YearFrom    int // publish year range, 0 leaves that end open
+	YearTo      int
+	Available   bool
+	BranchID    int // with Available, only books on the shelf at this branch
+	Sort        string
+	Page        int
+	Limit       int
+}
+
+// FacetValue is one choice of a catalog facet with the number of books it
+// would show, given the other selections.
+type FacetValue struct {
+	Value    string
+	Label    string
+	Count    int
+	Selected bool
+	Depth    int // level in the category or class tree, 0 for other facets
+}
+
+// BookFacets are the choices to narrow a catalog listing down by.
+type BookFacets struct {
+	Categories []FacetValue
+	Authors    []FacetValue
+	Subjects   []FacetValue
+	Classes    []FacetValue
+	Series     []FacetValue
+	Publishers []FacetValue
+	Languages  []FacetValue
+	Decades    []FacetValue // Value is the first year of the decade
+	Available  int          // books that can be borrowed now
+}
+
+// Language is a book language offered in forms.
+type Language struct {
+	Code string
+	Name string
+}
+
+// Languages are the common languages of the collection, Indonesian first.
+// Books may carry other ISO 639-1 codes.
+var Languages = []Language{
+	{"id", "Indonesia"},
+	{"en", "Inggris"},
+	{"ar", "Arab"},
+	{"ms", "Melayu"},
+	{"jv", "Jawa"},
+	{"su", "Sunda"},
+	{"nl", "Belanda"},
+	{"de", "Jerman"},
+	{"fr", "Prancis"},
+	{"ja", "Jepang"},
+	{"zh", "Mandarin"},
+}
+
+// LanguageName returns the name of a language code, or the code itself
+// when it is not one of Languages.
+func LanguageName(code string) string {
+	for _, l := range Languages {
+		if l.Code == code {
+			return l.Name
+		}
+	}
+	return code
+}
+
+// CoverSizes are the thumbnails made of every uploaded cover, by name, with
+// their width in pixels.
+var CoverSizes = map[string]int{
+	"small":  120,
+	"medium": 300,
+	"large":  600,
+}
+
+// hashedCover matches the covers named after their content, which have
+// thumbnails.
+var hashedCover = regexp.MustCompile(`^covers/[0-9a-f]{32}\.(jpg|png|gif)$`)
+
+// CoverThumbnail returns the storage key of a cover's thumbnail of the
+// size. Covers saved before thumbnails were made are their own thumbnail.
+func CoverThumbnail(cover, size string) string {
+	if _, ok := CoverSizes[size]; !ok || !hashedCover.MatchString(cover) {
+		return cover
+	}
+	return cover[:len("covers/")+32] + "-" + size + ".jpg"
+}
+
+// CoverURL returns where the thumbnail of a cover is served, or "" for no
+// cover.
+func CoverURL(cover, size string) string {
+	if cover == "" {
+		return ""
+	}
+	return "/media/" + CoverThumbnail(cover, size)
+}
+
+// CoverURL returns where the book's cover is served in the size.
+func (b Book) CoverURL(size string) string {
+	return CoverURL(b.CoverImage, size)
+}
+
+// CoverURL returns where the cover of the new book is served in the size.
+func (b BookCreate) CoverURL(size string) string {
+	return CoverURL(b.CoverImage, size)
+}
+
+// AvailableAt returns the copies on the shelf at a branch. Branches must
+// have been loaded.
+func (b Book) AvailableAt(branchID int) int {
+	for _, s := range b.Branches {
+		if s.BranchID == branchID {
+			return s.Available
+		}
+	}
+	return 0
+}
+
+// Authors returns the contributors credited as authors, in order.
+func (b Book) Authors() []Contributor {
+	return b.ContributorsAs(RoleAuthor)
+}
+
+// ContributorsAs returns the contributors with the role, in order.
+func (b Book) ContributorsAs(role string) []Contributor {
+	var list []Contributor
+	for _, c := range b.Contributors {
+		if c.Role == role {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Others returns the contributors other than the authors, in order.
+func (b Book) Others() []Contributor {
+	var list []Contributor
+	for _, c := range b.Contributors {
+		if c.Role != RoleAuthor {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// AuthorNames returns the names of the authors joined by commas, or "" when
+// the book credits none.
+func (b Book) AuthorNames() string {
+	names := make([]string, 0, len(b.Contributors))
+	for _, c := range b.Authors() {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// LanguageName returns the name of the book's language.
+func (b Book) LanguageName() string {
+	return LanguageName(b.Language)
+}
+
+// HoldBranches returns the branches with no copy on the shelf, where members
+// can place a hold. Branches must have been loaded.
+func (b Book) HoldBranches() []BranchStock {
+	var list []BranchStock
+	for _, s := range b.Branches {
+		if s.Available == 0 {
+			list = append(list, s)
+		}
+	}
+	return list
 }
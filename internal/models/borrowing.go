@@ -3,33 +3,39 @@ package models
 import "time"
 
 type Borrowing struct {
-	ID         int        `json:"id"`
-	MemberID   int        `json:"member_id"`
-	BookID     int        `json:"book_id"`
-	UserID     *int       `json:"user_id"`
-	BorrowDate time.Time  `json:"borrow_date"`
-	DueDate    time.Time  `json:"due_date"`
-	ReturnDate *time.Time `json:"return_date"`
-	Status     string     `json:"status"`
-	Fine       float64    `json:"fine"`
-	Notes      string     `json:"notes"`
-	CreatedAt  time.Time  `json:"created_at"`
+	ID             int        `json:"id"`
+	MemberID       int        `json:"member_id"`
+	BookID         int        `json:"book_id"`
+	UserID         *int       `json:"user_id"`
+	BranchID       int        `json:"branch_id"`
+	BorrowDate     time.Time  `json:"borrow_date"`
+	DueDate        time.Time  `json:"due_date"`
+	ReturnDate     *time.Time `json:"return_date"`
+	ReturnBranchID *int       `json:"return_branch_id"` // may differ from BranchID
+	Status         string     `json:"status"`
+	Fine           float64    `json:"fine"`
+	Notes          string     `json:"notes"`
+	CreatedAt      time.Time  `json:"created_at"`
 
 	// Relations
-	Member *Member `json:"member,omitempty"`
-	Book   *Book   `json:"book,omitempty"`
-	User   *User   `json:"user,omitempty"`
+	Member       *Member `json:"member,omitempty"`
+	Book         *Book   `json:"book,omitempty"`
+	User         *User   `json:"user,omitempty"`
+	Branch       *Branch `json:"branch,omitempty"`
+	ReturnBranch *Branch `json:"return_branch,omitempty"`
 }
 
 type BorrowingCreate struct {
 	MemberID   int    `json:"member_id"`
 	BookID     int    `json:"book_id"`
+	BranchID   int    `json:"branch_id"`
 	BorrowDays int    `json:"borrow_days"`
 	Notes      string `json:"notes"`
 }
 
 type BorrowingReturn struct {
 	ReturnDate time.Time `json:"return_date"`
+	BranchID   int       `json:"branch_id"`
 	Fine       float64   `json:"fine"`
 	Notes      string    `json:"notes"`
 }
@@ -37,12 +43,10 @@ type BorrowingReturn struct {
 type BorrowingFilter struct {
 	MemberID int
 	BookID   int
+	BranchID int // checkout branch
 	Status   string
 	FromDate time.Time
 	ToDate   time.Time
 	Page     int
 	Limit    int
 }
-
-// Fine calculation: Rp 1000 per day
-const FinePerDay = 1000.0
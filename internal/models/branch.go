@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type Branch struct {
+	ID        int       `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	Copies    int       `json:"copies,omitempty"`
+}
+
+type BranchCreate struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	IsActive bool   `json:"is_active"`
+}
+
+// BranchStock is the number of copies of a book held at one branch.
+// Available excludes copies that are currently on loan.
+type BranchStock struct {
+	BranchID   int    `json:"branch_id"`
+	BranchCode string `json:"branch_code"`
+	BranchName string `json:"branch_name"`
+	Stock      int    `json:"stock"`
+	Available  int    `json:"available"`
+}
+
+// StockChange moves the counters of a book at one branch by the given
+// deltas.
+type StockChange struct {
+	BranchID  int
+	Stock     int
+	Available int
+}
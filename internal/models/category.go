@@ -1,16 +1,44 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type Category struct {
 	ID          int       `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	ParentID    *int      `json:"parent_id"`
 	CreatedAt   time.Time `json:"created_at"`
-	BookCount   int       `json:"book_count,omitempty"`
+	// BookCount includes the books of every descendant, DirectBookCount
+	// only those filed under the category itself.
+	BookCount       int `json:"book_count,omitempty"`
+	DirectBookCount int `json:"direct_book_count,omitempty"`
+
+	// Set when listed as a tree
+	Depth int        `json:"depth,omitempty"`
+	Path  []Category `json:"path,omitempty"` // ancestors, from the root
 }
 
 type CategoryCreate struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	ParentID    int    `json:"parent_id"` // 0 for a top-level category
+}
+
+// TreeName returns the name indented by the category's depth, for lists
+// and selects showing the whole tree.
+func (c Category) TreeName() string {
+	return strings.Repeat("— ", c.Depth) + c.Name
+}
+
+// FullName returns the names of the ancestors and the category joined by
+// arrows: "Sains › Fisika › Mekanika".
+func (c Category) FullName() string {
+	names := make([]string, 0, len(c.Path)+1)
+	for _, p := range c.Path {
+		names = append(names, p.Name)
+	}
+	return strings.Join(append(names, c.Name), " › ")
 }
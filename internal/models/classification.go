@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DDCClass is a main class of the Dewey Decimal Classification.
+type DDCClass struct {
+	Code string // first digit of the class numbers
+	Name string
+}
+
+// DDCClasses are the ten main classes, by their first digit.
+var DDCClasses = []DDCClass{
+	{"0", "Karya Umum"},
+	{"1", "Filsafat dan Psikologi"},
+	{"2", "Agama"},
+	{"3", "Ilmu Sosial"},
+	{"4", "Bahasa"},
+	{"5", "Ilmu Murni"},
+	{"6", "Ilmu Terapan"},
+	{"7", "Kesenian dan Olahraga"},
+	{"8", "Kesusastraan"},
+	{"9", "Sejarah dan Geografi"},
+}
+
+// ClassName names the class of the leading digits of a class number:
+// "8" is "800 Kesusastraan", "89" is "890".
+func ClassName(digits string) string {
+	number := (digits + "00")[:3]
+	if len(digits) == 1 {
+		for _, c := range DDCClasses {
+			if c.Code == digits {
+				return number + " " + c.Name
+			}
+		}
+	}
+	return number
+}
+
+// AuthorMark makes the author mark of a book from its main entry: the first
+// three letters of the surname, taken as the last word of the name, of the
+// first author or else the first contributor, or of the title's first word
+// when no one is credited. "Andrea Hirata" gives "HIR".
+func AuthorMark(contributors []Contributor, title string) string {
+	entry := title
+	if i := slices.IndexFunc(contributors, func(c Contributor) bool { return c.Name != "" }); i >= 0 {
+		entry = contributors[i].Name
+	}
+	if i := slices.IndexFunc(contributors, func(c Contributor) bool { return c.Role == RoleAuthor && c.Name != "" }); i >= 0 {
+		entry = contributors[i].Name
+	}
+	words := strings.Fields(entry)
+	if len(words) == 0 {
+		return ""
+	}
+	word := words[0]
+	if entry != title {
+		word = words[len(words)-1]
+	}
+	var mark []rune
+	for _, r := range word {
+		if unicode.IsLetter(r) && len(mark) < 3 {
+			mark = append(mark, unicode.ToUpper(r))
+		}
+	}
+	return string(mark)
+}
+
+// TitleMark returns the first letter of a title in lower case, the last
+// part of a call number.
+func TitleMark(title string) string {
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return string(unicode.ToLower(r))
+		}
+	}
+	return ""
+}
+
+// AuthorMark returns the book's author mark, made from its main entry when
+// none was recorded.
+func (b Book) AuthorMark() string {
+	if b.Cutter != "" {
+		return b.Cutter
+	}
+	return AuthorMark(b.Contributors, b.Title)
+}
+
+// CallNumberLines returns the lines of the book's spine label: the class
+// number, the author mark and the title mark. A book without a class
+// number has only the last two.
+func (b Book) CallNumberLines() []string {
+	var lines []string
+	for _, part := range []string{b.DDC, b.AuthorMark(), TitleMark(b.Title)} {
+		if part != "" {
+			lines = append(lines, part)
+		}
+	}
+	return lines
+}
+
+// CallNumber returns the call number on one line: "899.2213 HIR l".
+func (b Book) CallNumber() string {
+	return strings.Join(b.CallNumberLines(), " ")
+}
+
+// Barcode returns the code printed on the book's barcode label, made from
+// its ID: "B000042".
+func (b Book) Barcode() string {
+	return fmt.Sprintf("B%06d", b.ID)
+}
+
+var barcodePattern = regexp.MustCompile(`^[Bb]([0-9]{6,})$`)
+
+// ParseBarcode returns the ID of the book a scanned barcode label belongs
+// to.
+func ParseBarcode(s string) (int, bool) {
+	m := barcodePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(m[1])
+	return id, err == nil && id > 0
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ExternalIdentity links a local account to a user of an external identity
+// provider such as campus SSO (OIDC) or the LDAP directory.
+type ExternalIdentity struct {
+	ID          int        `json:"id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	AccountType string     `json:"account_type"` // "admin" or "member"
+	AccountID   int        `json:"account_id"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
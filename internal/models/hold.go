@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Hold statuses. A waiting hold becomes ready once a copy is set aside at
+// the pickup branch, and fulfilled when the member borrows it.
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusReady     = "ready"
+	HoldStatusFulfilled = "fulfilled"
+	HoldStatusCancelled = "cancelled"
+)
+
+// Hold is a member's request to pick up a book at a branch. A ready hold
+// keeps its copy out of the branch's available count. An any-edition hold
+// moves to the edition whose copy is set aside for it.
+type Hold struct {
+	ID         int        `json:"id"`
+	MemberID   int        `json:"member_id"`
+	BookID     int        `json:"book_id"`
+	BranchID   int        `json:"branch_id"`   // pickup branch
+	AnyEdition bool       `json:"any_edition"` // any edition of the book's work will do
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReadyAt    *time.Time `json:"ready_at"`
+	ClosedAt   *time.Time `json:"closed_at"`
+
+	// Relations
+	Member *Member `json:"member,omitempty"`
+	Book   *Book   `json:"book,omitempty"`
+	Branch *Branch `json:"branch,omitempty"`
+}
+
+// IsOpen reports whether the hold still waits for or keeps a copy.
+func (h Hold) IsOpen() bool {
+	return h.Status == HoldStatusWaiting || h.Status == HoldStatusReady
+}
+
+type HoldFilter struct {
+	MemberID int
+	BranchID int // pickup branch
+	Status   string
+}
@@ -1,28 +1,74 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Member registration status
+const (
+	MemberStatusPendingVerification = "pending_verification"
+	MemberStatusPendingApproval     = "pending_approval"
+	MemberStatusActive              = "active"
+	MemberStatusRejected            = "rejected"
+)
 
 type Member struct {
-	ID         int       `json:"id"`
-	MemberCode string    `json:"member_code"`
-	Name       string    `json:"name"`
-	Email      string    `json:"email"`
-	Password   string    `json:"-"`
-	Phone      string    `json:"phone"`
-	MemberType string    `json:"member_type"`
-	Address    string    `json:"address"`
-	IsActive   bool      `json:"is_active"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID              int        `json:"id"`
+	MemberCode      string     `json:"member_code"`
+	IdentityNumber  string     `json:"identity_number"` // NIM (mahasiswa) or NIP (guru/karyawan)
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Phone           string     `json:"phone"`
+	MemberType      string     `json:"member_type"`
+	Address         string     `json:"address"`
+	IsActive        bool       `json:"is_active"`
+	Status          string     `json:"status"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	SessionVersion int `json:"-"`
+}
+
+// CanBorrow reports whether the member may borrow books.
+func (m *Member) CanBorrow() bool {
+	return m.IsActive && m.Status == MemberStatusActive
+}
+
+// MemberCodePrefix returns the member code prefix for a member type.
+func MemberCodePrefix(memberType string) string {
+	switch memberType {
+	case "mahasiswa":
+		return "MHS"
+	case "guru":
+		return "GRU"
+	case "karyawan":
+		return "KRY"
+	}
+	return "MBR"
+}
+
+// NextMemberCode returns the code after last, the highest existing code for
+// memberType ("" when there is none): MHS007 is followed by MHS008.
+func NextMemberCode(memberType, last string) string {
+	prefix := MemberCodePrefix(memberType)
+	seq, _ := strconv.Atoi(strings.TrimPrefix(last, prefix))
+	return fmt.Sprintf("%s%03d", prefix, seq+1)
 }
 
 type MemberCreate struct {
-	Name       string `json:"name"`
-	Email      string `json:"email"`
-	Password   string `json:"password"`
-	Phone      string `json:"phone"`
-	MemberType string `json:"member_type"`
-	Address    string `json:"address"`
+	IdentityNumber string `json:"identity_number"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Password       string `json:"password"`
+	Phone          string `json:"phone"`
+	MemberType     string `json:"member_type"`
+	Address        string `json:"address"`
+	Status         string `json:"-"` // defaults to active
 }
 
 type MemberUpdate struct {
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type PasswordReset struct {
+	ID          int        `json:"id"`
+	AccountType string     `json:"account_type"` // "admin" or "member"
+	AccountID   int        `json:"account_id"`
+	TokenHash   string     `json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Transfer statuses, in workflow order. The copy is taken off the source
+// branch's available count when the transfer is requested and joins the
+// destination's stock when it is received.
+const (
+	TransferStatusRequested  = "requested"
+	TransferStatusDispatched = "dispatched"
+	TransferStatusInTransit  = "in_transit"
+	TransferStatusReceived   = "received"
+	TransferStatusCancelled  = "cancelled"
+)
+
+// Transfer moves one copy of a book between branches, optionally to
+// satisfy a hold at the destination.
+type Transfer struct {
+	ID           int        `json:"id"`
+	BookID       int        `json:"book_id"`
+	FromBranchID int        `json:"from_branch_id"`
+	ToBranchID   int        `json:"to_branch_id"`
+	HoldID       *int       `json:"hold_id"`
+	Status       string     `json:"status"`
+	Notes        string     `json:"notes"`
+	RequestedBy  *int       `json:"requested_by"`
+	RequestedAt  time.Time  `json:"requested_at"`
+	DispatchedBy *int       `json:"dispatched_by"`
+	DispatchedAt *time.Time `json:"dispatched_at"`
+	SentBy       *int       `json:"sent_by"`
+	SentAt       *time.Time `json:"sent_at"` // start of in_transit
+	ReceivedBy   *int       `json:"received_by"`
+	ReceivedAt   *time.Time `json:"received_at"`
+	CancelledBy  *int       `json:"cancelled_by"`
+	CancelledAt  *time.Time `json:"cancelled_at"`
+
+	// Relations
+	Book       *Book   `json:"book,omitempty"`
+	FromBranch *Branch `json:"from_branch,omitempty"`
+	ToBranch   *Branch `json:"to_branch,omitempty"`
+	Member     *Member `json:"member,omitempty"` // holder, for hold transfers
+}
+
+// IsOpen reports whether the copy is still on its way.
+func (t Transfer) IsOpen() bool {
+	switch t.Status {
+	case TransferStatusRequested, TransferStatusDispatched, TransferStatusInTransit:
+		return true
+	}
+	return false
+}
+
+type TransferCreate struct {
+	BookID       int    `json:"book_id"`
+	FromBranchID int    `json:"from_branch_id"`
+	ToBranchID   int    `json:"to_branch_id"`
+	HoldID       int    `json:"hold_id"`
+	Notes        string `json:"notes"`
+}
+
+type TransferFilter struct {
+	BranchID int // source or destination
+	Status   string
+	Open     bool // only transfers still on their way
+}
@@ -9,9 +9,12 @@ type User struct {
 	Password  string    `json:"-"`
 	Name      string    `json:"name"`
 	Role      string    `json:"role"`
+	BranchID  *int      `json:"branch_id"` // nil for staff working across all branches
 	IsActive  bool      `json:"is_active"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	SessionVersion int `json:"-"`
 }
 
 type UserLogin struct {
@@ -25,4 +28,5 @@ type UserCreate struct {
 	Password string `json:"password"`
 	Name     string `json:"name"`
 	Role     string `json:"role"`
+	BranchID *int   `json:"branch_id"`
 }
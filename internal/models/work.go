@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Series is a numbered run of works, such as the volumes of a novel series.
+type Series struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	WorkCount   int       `json:"work_count,omitempty"`
+}
+
+type SeriesCreate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Work groups the editions of one title: each edition is a book of its
+// own, with its own ISBN and stock. A work may be a volume of a series.
+type Work struct {
+	ID           int       `json:"id"`
+	Title        string    `json:"title"`
+	SeriesID     *int      `json:"series_id"`
+	Volume       int       `json:"volume"` // 0 outside a series
+	CreatedAt    time.Time `json:"created_at"`
+	EditionCount int       `json:"edition_count,omitempty"`
+
+	// Relations
+	Series *Series `json:"series,omitempty"`
+}
+
+type WorkCreate struct {
+	Title    string `json:"title"`
+	SeriesID int    `json:"series_id"` // 0 for a work outside a series
+	Volume   int    `json:"volume"`
+}
+
+// SeriesLabel names the work's place in its series, "Serial Bumi #2", or
+// returns "" outside a series.
+func (w Work) SeriesLabel() string {
+	if w.Series == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s #%d", w.Series.Name, w.Volume)
+}
+
+// BookRelations are the books related to a book through its work.
+type BookRelations struct {
+	Editions []Book // the work's other editions, most recently published first
+	Previous *Book  // an edition of the previous volume of the series
+	Next     *Book  // an edition of the next volume
+}
@@ -0,0 +1,90 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	doc := New(A4Width, A4Height)
+	p := doc.AddPage()
+	p.Text(10, 20, HelveticaBold, 12, "Bumi (2014) \\ Tere Liye")
+	p.Rect(10, 10, 0.5, 20)
+	doc.AddPage().Text(10, 20, Helvetica, 9, "Rp75.000 – “Kisah” ✓")
+
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+	if n != int64(len(out)) {
+		t.Errorf("WriteTo = %d, wrote %d bytes", n, len(out))
+	}
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF:\n%s", out)
+	}
+
+	// Every entry of the cross-reference table points at its object
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n0 9\n")) {
+		t.Fatalf("startxref %d does not point at a table of 8 objects", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(out[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("object %d is not at offset %d", i+1, off)
+		}
+	}
+
+	for _, want := range []string{
+		"/Count 2 /MediaBox [0 0 595.28 841.89]",
+		"BT /F2 12 Tf 10 20 Td (Bumi \\(2014\\) \\\\ Tere Liye) Tj ET",
+		"10 10 0.5 20 re f",
+		"(Rp75.000 \x96 \x93Kisah\x94 ?) Tj",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("document lacks %q", want)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	if len(helveticaWidths) != 95 || len(helveticaBoldWidths) != 95 {
+		t.Fatalf("width tables have %d and %d entries, want 95", len(helveticaWidths), len(helveticaBoldWidths))
+	}
+	tests := []struct {
+		font Font
+		s    string
+		want float64
+	}{
+		{Helvetica, "AB", 13.34},
+		{HelveticaBold, "HIR l", 22.78},
+		{Helvetica, "é", 5.56},
+	}
+	for _, tt := range tests {
+		if got := Width(tt.font, 10, tt.s); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("Width(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+
+	if got := Fit(Helvetica, 10, 40, "Laskar Pelangi"); got != "Laskar…" {
+		t.Errorf("Fit = %q", got)
+	}
+	if got := Fit(Helvetica, 10, 100, "Laskar Pelangi"); got != "Laskar Pelangi" {
+		t.Errorf("Fit shortened a title that fits: %q", got)
+	}
+	if strings.Contains(Fit(Helvetica, 10, 1, "Bumi"), "B") {
+		t.Error("Fit kept text wider than the width")
+	}
+}
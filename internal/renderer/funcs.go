@@ -0,0 +1,101 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// funcs are available to every template
+var funcs = template.FuncMap{
+	"add": func(a, b int) int {
+		return a + b
+	},
+	"subtract": func(a, b int) int {
+		return a - b
+	},
+	"upper": func(s string) string {
+		return strings.ToUpper(s)
+	},
+	"join": func(list []string, sep string) string {
+		return strings.Join(list, sep)
+	},
+	"contains": func(s, substr string) bool {
+		return strings.Contains(s, substr)
+	},
+	"slice": func(s string, start, end int) string {
+		if start >= len(s) {
+			return ""
+		}
+		if end > len(s) {
+			end = len(s)
+		}
+		return s[start:end]
+	},
+	"seq": func(start, end int) []int {
+		var s []int
+		for i := start; i <= end; i++ {
+			s = append(s, i)
+		}
+		return s
+	},
+	"deref": func(i *int) int {
+		if i == nil {
+			return 0
+		}
+		return *i
+	},
+	"atoi": func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	},
+	// dict builds a map from key/value pairs, to pass several values to a
+	// nested template.
+	"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+		if len(pairs)%2 != 0 {
+			return nil, errors.New("dict: odd number of arguments")
+		}
+		m := make(map[string]interface{}, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			key, ok := pairs[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+			}
+			m[key] = pairs[i+1]
+		}
+		return m, nil
+	},
+	// withQuery links to path with q, setting each key/value pair that
+	// follows and removing keys paired with "". Changing anything but the
+	// page goes back to the first page.
+	"withQuery": func(path string, q url.Values, pairs ...string) string {
+		out := url.Values{}
+		for k, v := range q {
+			out[k] = v
+		}
+		out.Del("page")
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if pairs[i+1] == "" {
+				out.Del(pairs[i])
+			} else {
+				out.Set(pairs[i], pairs[i+1])
+			}
+		}
+		if len(out) == 0 {
+			return path
+		}
+		return path + "?" + out.Encode()
+	},
+	"filesize": func(n int64) string {
+		switch {
+		case n >= 1<<20:
+			return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+		case n >= 1<<10:
+			return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+		}
+		return fmt.Sprintf("%d B", n)
+	},
+}
@@ -0,0 +1,225 @@
+// Package renderer parses the HTML templates once at startup and renders
+// pages and HTMX fragments from them.
+//
+// A page is any template under admin/, member/ or auth/ that defines a
+// "content" block, plus the standalone home.html. Pages are wrapped in the
+// layout of their top-level directory. Other templates in a page's directory
+// (such as admin/borrowings/table.html) are parsed along with it.
+package renderer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type layout struct {
+	name  string   // template that renders the whole page
+	files []string // layout and shared components
+}
+
+var layouts = map[string]layout{
+	"admin": {
+		name:  "admin.html",
+		files: []string{"layouts/admin.html", "components/sidebar.html", "components/navbar.html", "components/branch-filter.html"},
+	},
+	"member": {
+		name:  "member.html",
+		files: []string{"layouts/member.html", "components/member-sidebar.html", "components/member-header.html"},
+	},
+	"auth": {
+		name:  "auth.html",
+		files: []string{"layouts/auth.html"},
+	},
+}
+
+// standalone pages carry their own <html> document
+var standalone = []string{"home.html"}
+
+type page struct {
+	tmpl *template.Template
+	root string // template executed for a full render
+}
+
+type Renderer struct {
+	files  fs.FS
+	reload bool
+	pages  map[string]*page
+}
+
+// New parses every page in files and returns the first error found. With
+// reload set, pages are parsed again on each render so edits on disk show
+// up without a restart; pass os.DirFS("templates") as files in that case.
+func New(files fs.FS, reload bool) (*Renderer, error) {
+	r := &Renderer{
+		files:  files,
+		reload: reload,
+		pages:  make(map[string]*page),
+	}
+
+	names, err := r.pageNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		p, err := r.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		r.pages[name] = p
+	}
+
+	return r, nil
+}
+
+// Pages lists the names of the parsed pages.
+func (r *Renderer) Pages() []string {
+	names := make([]string, 0, len(r.pages))
+	for name := range r.pages {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Render writes the page name (e.g. "admin/books/index.html") inside its
+// layout.
+func (r *Renderer) Render(w http.ResponseWriter, req *http.Request, name string, data interface{}) {
+	p, err := r.lookup(name)
+	if err != nil {
+		r.fail(w, req, name, err)
+		return
+	}
+	r.execute(w, req, name, p.tmpl, p.root, data)
+}
+
+// Partial writes a single block of a page without its layout, for HTMX
+// requests that swap part of the page.
+func (r *Renderer) Partial(w http.ResponseWriter, req *http.Request, name, block string, data interface{}) {
+	p, err := r.lookup(name)
+	if err != nil {
+		r.fail(w, req, name, err)
+		return
+	}
+	r.execute(w, req, name, p.tmpl, block, data)
+}
+
+func (r *Renderer) execute(w http.ResponseWriter, req *http.Request, name string, tmpl *template.Template, root string, data interface{}) {
+	// Buffer the output so a failing template does not leave half a page
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, root, data); err != nil {
+		r.fail(w, req, name, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+func (r *Renderer) fail(w http.ResponseWriter, req *http.Request, name string, err error) {
+	slog.ErrorContext(req.Context(), "renderer: render template", "template", name, "error", err)
+	http.Error(w, "Terjadi kesalahan saat menampilkan halaman", http.StatusInternalServerError)
+}
+
+func (r *Renderer) lookup(name string) (*page, error) {
+	if r.reload {
+		return r.parse(name)
+	}
+	p, ok := r.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	return p, nil
+}
+
+// pageNames walks files and returns every page template.
+func (r *Renderer) pageNames() ([]string, error) {
+	names := append([]string(nil), standalone...)
+
+	for dir := range layouts {
+		err := fs.WalkDir(r.files, dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || path.Ext(name) != ".html" {
+				return err
+			}
+			isPage, err := r.definesContent(name)
+			if isPage {
+				names = append(names, name)
+			}
+			return err
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return names, nil
+}
+
+// parse builds the template set for a page.
+func (r *Renderer) parse(name string) (*page, error) {
+	dir := strings.SplitN(name, "/", 2)[0]
+	l, ok := layouts[dir]
+	if !ok {
+		// Standalone page, executed by its file name
+		tmpl, err := template.New(path.Base(name)).Funcs(funcs).ParseFS(r.files, name)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return &page{tmpl: tmpl, root: path.Base(name)}, nil
+	}
+
+	files := append([]string(nil), l.files...)
+	siblings, err := r.partials(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, siblings...)
+	files = append(files, name)
+
+	tmpl, err := template.New(l.name).Funcs(funcs).ParseFS(r.files, files...)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	if tmpl.Lookup("content") == nil {
+		return nil, fmt.Errorf("parse %s: no content block", name)
+	}
+
+	return &page{tmpl: tmpl, root: l.name}, nil
+}
+
+// partials returns the templates in dir that are not pages themselves.
+func (r *Renderer) partials(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(r.files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		if e.IsDir() || path.Ext(name) != ".html" {
+			continue
+		}
+		isPage, err := r.definesContent(name)
+		if err != nil {
+			return nil, err
+		}
+		if !isPage {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+func (r *Renderer) definesContent(name string) (bool, error) {
+	tmpl, err := template.New(path.Base(name)).Funcs(funcs).ParseFS(r.files, name)
+	if err != nil {
+		return false, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return tmpl.Lookup("content") != nil, nil
+}
@@ -0,0 +1,95 @@
+package renderer
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"simpus"
+)
+
+func TestEmbeddedTemplatesParse(t *testing.T) {
+	r, err := New(simpus.Templates(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := r.Pages()
+	for _, name := range []string{
+		"home.html",
+		"admin/books/index.html",
+		"admin/borrowings/index.html",
+		"auth/login.html",
+		"member/dashboard.html",
+	} {
+		if !slices.Contains(pages, name) {
+			t.Errorf("page %s not parsed", name)
+		}
+	}
+	if slices.Contains(pages, "admin/borrowings/table.html") {
+		t.Error("partial admin/borrowings/table.html parsed as a page")
+	}
+}
+
+func testFiles(content string) fstest.MapFS {
+	return fstest.MapFS{
+		"home.html":           {Data: []byte(`<h1>Beranda</h1>`)},
+		"layouts/auth.html":   {Data: []byte(`{{define "auth.html"}}<main>{{template "content" .}}</main>{{end}}`)},
+		"auth/login.html":     {Data: []byte(content)},
+		"auth/login-box.html": {Data: []byte(`{{define "login-box"}}[{{.}}]{{end}}`)},
+	}
+}
+
+func TestRenderAndPartial(t *testing.T) {
+	r, err := New(testFiles(`{{define "content"}}Masuk {{template "login-box" .}}{{end}}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/login", nil)
+	w := httptest.NewRecorder()
+	r.Render(w, req, "auth/login.html", "admin")
+	if got := w.Body.String(); got != "<main>Masuk [admin]</main>" {
+		t.Errorf("Render = %q", got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	w = httptest.NewRecorder()
+	r.Partial(w, req, "auth/login.html", "login-box", "admin")
+	if got := w.Body.String(); got != "[admin]" {
+		t.Errorf("Partial = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	r.Render(w, req, "auth/missing.html", nil)
+	if w.Code != 500 {
+		t.Errorf("unknown page: status %d, want 500", w.Code)
+	}
+}
+
+func TestNewFailsOnTemplateError(t *testing.T) {
+	if _, err := New(testFiles(`{{define "content"}}{{.Name}{{end}}`), false); err == nil {
+		t.Fatal("broken template accepted")
+	}
+}
+
+func TestReload(t *testing.T) {
+	files := testFiles(`{{define "content"}}lama{{end}}`)
+	r, err := New(files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files["auth/login.html"].Data = []byte(`{{define "content"}}baru{{end}}`)
+
+	req := httptest.NewRequest("GET", "/login", nil)
+	w := httptest.NewRecorder()
+	r.Render(w, req, "auth/login.html", nil)
+	if got := w.Body.String(); got != "<main>baru</main>" {
+		t.Errorf("Render after edit = %q", got)
+	}
+}
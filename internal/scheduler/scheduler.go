@@ -0,0 +1,130 @@
+// Package scheduler runs periodic background jobs, such as the daily check
+// for overdue borrowings.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs []Job
+
+	mu        sync.Mutex
+	heartbeat map[string]time.Time // last time each job loop was seen
+	started   bool
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{heartbeat: make(map[string]time.Time)}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job once and then at its interval until ctx is done or
+// Stop is called.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.started = true
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop cancels the jobs and waits for running ones to return, or until ctx
+// is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler: %w", ctx.Err())
+	}
+}
+
+// Alive reports an error when the scheduler is not running or a job has
+// been stuck for more than two of its intervals.
+func (s *Scheduler) Alive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return errors.New("scheduler not started")
+	}
+	for _, job := range s.jobs {
+		last, ok := s.heartbeat[job.Name]
+		if !ok {
+			return fmt.Errorf("job %s stopped", job.Name)
+		}
+		if time.Since(last) > 2*job.Interval {
+			return fmt.Errorf("job %s stuck since %s", job.Name, last.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+	defer s.beat(job.Name, false)
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.beat(job.Name, true)
+		if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "scheduler: job failed", "job", job.Name, "error", err)
+		}
+		s.beat(job.Name, true)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) beat(name string, alive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if alive {
+		s.heartbeat[name] = time.Now()
+	} else {
+		delete(s.heartbeat, name)
+	}
+}
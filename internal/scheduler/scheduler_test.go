@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsAndStops(t *testing.T) {
+	var runs atomic.Int32
+	s := New()
+	s.Add(Job{Name: "tick", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	if err := s.Alive(); err == nil {
+		t.Error("alive before Start")
+	}
+
+	s.Start(context.Background())
+	time.Sleep(35 * time.Millisecond)
+	if err := s.Alive(); err != nil {
+		t.Errorf("Alive: %v", err)
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() < 2 {
+		t.Errorf("job ran %d times, want at least 2", runs.Load())
+	}
+	if err := s.Alive(); err == nil {
+		t.Error("alive after Stop")
+	}
+
+	after := runs.Load()
+	time.Sleep(25 * time.Millisecond)
+	if runs.Load() != after {
+		t.Error("job kept running after Stop")
+	}
+}
+
+func TestSchedulerReportsStuckJob(t *testing.T) {
+	release := make(chan struct{})
+	s := New()
+	s.Add(Job{Name: "stuck", Interval: 5 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	s.Start(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Alive(); err == nil {
+		t.Error("stuck job reported alive")
+	}
+
+	// Stop gives up when the job does not return in time
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); err == nil {
+		t.Error("Stop returned before the job finished")
+	}
+	close(release)
+}
@@ -0,0 +1,126 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords are left out of queries: they appear in most titles and only
+// add noise to the ranking. They are still indexed, so a query made only of
+// stopwords finds something.
+var stopwords = map[string]bool{
+	"ada": true, "adalah": true, "agar": true, "akan": true, "antara": true,
+	"atau": true, "bagi": true, "bahwa": true, "dalam": true, "dan": true,
+	"dapat": true, "dari": true, "dengan": true, "di": true, "hingga": true,
+	"ini": true, "itu": true, "juga": true, "kami": true, "karena": true,
+	"ke": true, "kepada": true, "kita": true, "lebih": true, "maka": true,
+	"masih": true, "mereka": true, "namun": true, "oleh": true, "pada": true,
+	"para": true, "saat": true, "secara": true, "seperti": true, "serta": true,
+	"sebagai": true, "sebuah": true, "setiap": true, "sudah": true, "suatu": true,
+	"telah": true, "tentang": true, "tersebut": true, "tidak": true, "untuk": true,
+	"yaitu": true, "yakni": true, "yang": true,
+
+	"a": true, "an": true, "and": true, "for": true, "in": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// tokenize splits text into lower-case words of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// queryTerms returns the words of a query worth searching for: stopwords
+// are dropped unless nothing else is left.
+func queryTerms(query string) []string {
+	words := tokenize(query)
+	var terms []string
+	for _, w := range words {
+		if !stopwords[w] {
+			terms = append(terms, w)
+		}
+	}
+	if len(terms) == 0 {
+		return words
+	}
+	return terms
+}
+
+// normalizeISBN keeps the digits and a trailing check character X, so
+// "978-979-3062-79-2" and "9789793062792" compare equal.
+func normalizeISBN(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if unicode.IsDigit(r) || r == 'X' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// looksLikeISBN reports whether a query is a whole or partial ISBN rather
+// than words: only digits, hyphens and spaces, with at least six digits.
+func looksLikeISBN(query string) bool {
+	digits := 0
+	for _, r := range strings.TrimSpace(query) {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case r == '-' || r == ' ' || r == 'x' || r == 'X':
+		default:
+			return false
+		}
+	}
+	return digits >= 6
+}
+
+// editDistance is the Damerau-Levenshtein distance between a and b
+// (insertions, deletions, substitutions and swaps of adjacent letters),
+// giving up with limit+1 once it is certain to exceed limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// maxEdits is how many typos a query word may contain: none for short
+// words, where a single edit already makes another common word.
+func maxEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
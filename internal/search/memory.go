@@ -0,0 +1,342 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Field weights: a word in the title counts three times as much as the
+// same word in the description.
+const (
+	weightTitle       = 3.0
+	weightAuthor      = 2.0
+	weightSubject     = 2.0
+	weightCategory    = 1.5
+	weightPublisher   = 1.0
+	weightDescription = 1.0
+)
+
+// How much a looser match is worth compared to the word itself.
+const (
+	weightStem   = 0.9 // shares a root: "menulis" for "tulisan"
+	weightPrefix = 0.7 // the word being typed: "pela" for "pelangi"
+	weightTypo   = 0.6 // divided by the number of edits
+	boostISBN    = 10.0
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type memory struct {
+	mu       sync.RWMutex
+	docs     map[int]*entry
+	postings map[string]map[int]float64 // term -> document -> weighted frequency
+	totalLen float64
+	phrases  map[string]*phrase // keyed by lower-case text
+}
+
+type entry struct {
+	isbn    string
+	terms   map[string]float64
+	length  float64
+	phrases []string
+}
+
+// phrase is a title, name, category or subject offered by Suggest.
+type phrase struct {
+	text  string
+	words []string
+	count int // documents carrying it
+}
+
+// NewMemory returns an empty index kept in memory. It is rebuilt from the
+// database at startup and suits catalogs of up to some hundred thousand
+// books.
+func NewMemory() Index {
+	return newMemory()
+}
+
+func newMemory() *memory {
+	return &memory{
+		docs:     make(map[int]*entry),
+		postings: make(map[string]map[int]float64),
+		phrases:  make(map[string]*phrase),
+	}
+}
+
+func (m *memory) Put(doc Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(doc.ID)
+	m.add(doc)
+	return nil
+}
+
+func (m *memory) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(id)
+	return nil
+}
+
+func (m *memory) Replace(docs []Document) error {
+	fresh := newMemory()
+	for _, doc := range docs {
+		fresh.add(doc)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs, m.postings, m.totalLen, m.phrases = fresh.docs, fresh.postings, fresh.totalLen, fresh.phrases
+	return nil
+}
+
+func (m *memory) add(doc Document) {
+	e := &entry{isbn: normalizeISBN(doc.ISBN), terms: make(map[string]float64)}
+	index := func(text string, weight float64) {
+		for _, w := range tokenize(text) {
+			e.length += weight
+			for _, s := range stems(w) {
+				e.terms[s] += weight
+			}
+		}
+	}
+	index(doc.Title, weightTitle)
+	for _, a := range doc.Authors {
+		index(a, weightAuthor)
+	}
+	index(doc.Category, weightCategory)
+	index(doc.Publisher, weightPublisher)
+	index(doc.Description, weightDescription)
+	for _, s := range doc.Subjects {
+		index(s, weightSubject)
+	}
+
+	for term, tf := range e.terms {
+		if m.postings[term] == nil {
+			m.postings[term] = make(map[int]float64)
+		}
+		m.postings[term][doc.ID] = tf
+	}
+	m.totalLen += e.length
+
+	phrases := append([]string{doc.Title, doc.Category}, doc.Authors...)
+	for _, text := range append(phrases, doc.Subjects...) {
+		text = strings.Join(strings.Fields(text), " ")
+		key := strings.ToLower(text)
+		if key == "" || slices.Contains(e.phrases, key) {
+			continue
+		}
+		p := m.phrases[key]
+		if p == nil {
+			p = &phrase{text: text, words: tokenize(text)}
+			m.phrases[key] = p
+		}
+		p.count++
+		e.phrases = append(e.phrases, key)
+	}
+
+	m.docs[doc.ID] = e
+}
+
+func (m *memory) remove(id int) {
+	e, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	for term := range e.terms {
+		delete(m.postings[term], id)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	m.totalLen -= e.length
+	for _, key := range e.phrases {
+		if p := m.phrases[key]; p != nil {
+			if p.count--; p.count == 0 {
+				delete(m.phrases, key)
+			}
+		}
+	}
+	delete(m.docs, id)
+}
+
+// Search scores documents with BM25 over the weighted fields. A document
+// must match most of the query words (all of them for queries of up to two
+// words); those matching more words rank first, then by score.
+func (m *memory) Search(query string, limit int) ([]Hit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type match struct {
+		score   float64
+		matched int
+	}
+	matches := make(map[int]*match)
+	get := func(id int) *match {
+		if matches[id] == nil {
+			matches[id] = &match{}
+		}
+		return matches[id]
+	}
+
+	terms := queryTerms(query)
+	for i, t := range terms {
+		best := make(map[int]float64)
+		for term, weight := range m.expand(t, i == len(terms)-1) {
+			for id := range m.postings[term] {
+				best[id] = max(best[id], weight*m.bm25(term, id))
+			}
+		}
+		for id, score := range best {
+			mt := get(id)
+			mt.score += score
+			mt.matched++
+		}
+	}
+
+	if looksLikeISBN(query) {
+		isbn := normalizeISBN(query)
+		for id, e := range m.docs {
+			if strings.Contains(e.isbn, isbn) {
+				mt := get(id)
+				mt.score += boostISBN
+				mt.matched = len(terms)
+			}
+		}
+	}
+
+	need := len(terms) - len(terms)/3
+	hits := make([]Hit, 0, len(matches))
+	for id, mt := range matches {
+		if mt.matched >= need {
+			hits = append(hits, Hit{ID: id, Score: mt.score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		mi, mj := matches[hits[i].ID], matches[hits[j].ID]
+		if mi.matched != mj.matched {
+			return mi.matched > mj.matched
+		}
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// expand returns the indexed terms that stand for a query word, with how
+// much each is worth. The word and its roots come first; the last word is
+// also completed as a prefix since the user may still be typing. Words
+// found nowhere are matched to indexed terms with typos corrected.
+func (m *memory) expand(word string, last bool) map[string]float64 {
+	forms := make(map[string]float64)
+	for i, s := range stems(word) {
+		if _, ok := m.postings[s]; !ok {
+			continue
+		}
+		if i == 0 {
+			forms[s] = 1
+		} else {
+			forms[s] = max(forms[s], weightStem)
+		}
+	}
+
+	if len(word) >= 2 && (last || len(forms) == 0) {
+		for term := range m.postings {
+			if term != word && strings.HasPrefix(term, word) {
+				forms[term] = max(forms[term], weightPrefix)
+			}
+		}
+	}
+
+	if edits := maxEdits(word); len(forms) == 0 && edits > 0 {
+		for term := range m.postings {
+			if d := editDistance(word, term, edits); d <= edits {
+				forms[term] = max(forms[term], weightTypo/float64(d))
+			}
+		}
+	}
+	return forms
+}
+
+func (m *memory) bm25(term string, id int) float64 {
+	docs := m.postings[term]
+	n, df := float64(len(m.docs)), float64(len(docs))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	tf := docs[id]
+	avg := m.totalLen / n
+	norm := 1 - b + b*m.docs[id].length/avg
+	return idf * tf * (k1 + 1) / (tf + k1*norm)
+}
+
+// Suggest matches every typed word against the start of a word in the
+// phrase. Phrases that start with the first typed word come first.
+func (m *memory) Suggest(prefix string, limit int) ([]string, error) {
+	typed := tokenize(prefix)
+	if len(typed) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type suggestion struct {
+		*phrase
+		leading bool
+	}
+	var found []suggestion
+	for _, p := range m.phrases {
+		if completes(p.words, typed) {
+			found = append(found, suggestion{p, strings.HasPrefix(p.words[0], typed[0])})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].leading != found[j].leading {
+			return found[i].leading
+		}
+		if found[i].count != found[j].count {
+			return found[i].count > found[j].count
+		}
+		return found[i].text < found[j].text
+	})
+
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	texts := make([]string, len(found))
+	for i, s := range found {
+		texts[i] = s.text
+	}
+	return texts, nil
+}
+
+// completes reports whether each typed word starts a different word of
+// the phrase.
+func completes(words, typed []string) bool {
+	used := make([]bool, len(words))
+	for _, t := range typed {
+		ok := false
+		for i, w := range words {
+			if !used[i] && strings.HasPrefix(w, t) {
+				used[i], ok = true, true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func newTestIndex(t *testing.T) Index {
+	idx := NewMemory()
+	err := idx.Replace([]Document{
+		{ID: 1, ISBN: "978-979-3062-79-2", Title: "Laskar Pelangi", Authors: []string{"Andrea Hirata"}, Publisher: "Bentang Pustaka",
+			Category: "Fiksi", Description: "Kisah sepuluh anak Belitung yang belajar di sekolah Muhammadiyah."},
+		{ID: 2, ISBN: "978-602-03-3295-6", Title: "Bumi", Authors: []string{"Tere Liye"}, Publisher: "Gramedia", Category: "Fiksi",
+			Description: "Petualangan Raib di dunia paralel."},
+		{ID: 3, ISBN: "978-979-97312-3-4", Title: "Bumi Manusia", Authors: []string{"Pramoedya Ananta Toer"}, Publisher: "Lentera Dipantara",
+			Category: "Fiksi", Description: "Minke menulis tentang kehidupan di masa kolonial."},
+		{ID: 4, ISBN: "978-0-13-235088-4", Title: "Clean Code", Authors: []string{"Robert C. Martin"}, Publisher: "Prentice Hall",
+			Category: "Teknologi", Description: "Panduan menulis kode yang bersih.", Subjects: []string{"Pemrograman"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func ids(hits []Hit) []int {
+	list := make([]int, len(hits))
+	for i, h := range hits {
+		list[i] = h.ID
+	}
+	return list
+}
+
+func TestSearch(t *testing.T) {
+	idx := newTestIndex(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"title", "laskar pelangi", []int{1}},
+		{"title before description", "bumi", []int{2, 3}},
+		{"all words first", "bumi manusia", []int{3}},
+		{"author", "hirata", []int{1}},
+		{"publisher", "gramedia", []int{2}},
+		{"category", "teknologi", []int{4}},
+		{"subject", "pemrograman", []int{4}},
+		{"description", "belitung", []int{1}},
+		{"stemmed", "tulisan", []int{3, 4}},
+		{"stopwords ignored", "kisah tentang belitung", []int{1}},
+		{"typo", "plangi", []int{1}},
+		{"swapped letters", "laksar", []int{1}},
+		{"prefix of last word", "bumi man", []int{3}},
+		{"isbn", "9789793062792", []int{1}},
+		{"partial isbn", "0-13-235088", []int{4}},
+		{"no match", "kriptografi", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := idx.Search(tt.query, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(hits); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	idx := newTestIndex(t)
+
+	hits, err := idx.Search("fiksi", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Errorf("got %d hits, want 2", len(hits))
+	}
+}
+
+func TestPutAndDelete(t *testing.T) {
+	idx := newTestIndex(t)
+
+	if err := idx.Put(Document{ID: 2, Title: "Bulan", Authors: []string{"Tere Liye"}}); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := idx.Search("bumi", 0); !slices.Equal(ids(hits), []int{3}) {
+		t.Errorf("after replacing book 2, bumi = %v", ids(hits))
+	}
+	if hits, _ := idx.Search("bulan", 0); !slices.Equal(ids(hits), []int{2}) {
+		t.Errorf("after replacing book 2, bulan = %v", ids(hits))
+	}
+
+	if err := idx.Delete(3); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := idx.Search("bumi", 0); len(hits) != 0 {
+		t.Errorf("after deleting book 3, bumi = %v", ids(hits))
+	}
+	if got, _ := idx.Suggest("bumi", 5); len(got) != 0 {
+		t.Errorf("after deleting book 3, suggestions = %v", got)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	idx := newTestIndex(t)
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"bu", []string{"Bumi", "Bumi Manusia"}},
+		{"fik", []string{"Fiksi"}},
+		{"man", []string{"Bumi Manusia"}},
+		{"tere", []string{"Tere Liye"}},
+		{"pelangi las", []string{"Laskar Pelangi"}},
+		{"pem", []string{"Pemrograman"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := idx.Suggest(tt.prefix, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Suggest(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package search is the full-text index of the catalog. Books are fed in as
+// Documents; queries return book IDs ranked by relevance, which the book
+// repository then loads and filters.
+//
+// Index is the only thing the rest of the application sees, so the
+// in-memory implementation here can be swapped for a database or external
+// engine without touching callers.
+package search
+
+// Document is the searchable text of one book.
+type Document struct {
+	ID          int
+	ISBN        string
+	Title       string
+	Authors     []string // every contributor, authors first
+	Description string
+	Publisher   string
+	Category    string
+	Subjects    []string
+}
+
+// Hit is a matching book and its relevance score. Scores only compare hits
+// of the same query.
+type Hit struct {
+	ID    int
+	Score float64
+}
+
+// Index keeps documents searchable. Implementations must be safe for
+// concurrent use.
+type Index interface {
+	// Put adds a document or replaces the one with the same ID.
+	Put(doc Document) error
+	Delete(id int) error
+	// Replace swaps the whole index for docs at once, so searches never
+	// see it half rebuilt.
+	Replace(docs []Document) error
+	// Search returns up to limit hits, best first. A limit of 0 or less
+	// returns every hit.
+	Search(query string, limit int) ([]Hit, error)
+	// Suggest completes what the user has typed so far into titles, author
+	// names, categories and subjects, most common first.
+	Suggest(prefix string, limit int) ([]string, error)
+}
@@ -0,0 +1,159 @@
+package search
+
+import "strings"
+
+// The stemmer strips Indonesian affixes in the order of the Tala algorithm:
+// particles (-lah, -kah), possessives (-ku, -nya), a first-order prefix
+// (meng-, di-, ter-, ...), derivational suffixes (-kan, -an, -i) and a
+// second-order prefix (ber-, per-, ...).
+//
+// Some prefixes hide the first letter of the root: "menulis" comes from
+// "tulis" but "menanti" from "nanti". Rather than guess with a dictionary,
+// stems returns every plausible root. Documents and queries are stemmed the
+// same way, so a word matches when the two share any candidate.
+
+var (
+	particles   = []string{"lah", "kah", "tah", "pun"}
+	possessives = []string{"nya", "ku", "mu"}
+	suffixes    = []string{"kan", "an", "i"}
+)
+
+// stems returns word followed by its candidate roots, without duplicates.
+// Words with digits and words too short to carry affixes are returned
+// as is.
+func stems(word string) []string {
+	out := []string{word}
+	if len(word) < 5 || strings.ContainsAny(word, "0123456789") {
+		return out
+	}
+	add := func(s string) {
+		if vowels(s) < 2 {
+			return
+		}
+		for _, o := range out {
+			if o == s {
+				return
+			}
+		}
+		out = append(out, s)
+	}
+
+	base := trimSuffix(trimSuffix(word, particles), possessives)
+	for _, root := range append([]string{base}, firstOrderPrefix(base)...) {
+		for _, s := range append([]string{root}, derivations(root)...) {
+			add(s)
+			for _, r := range secondOrderPrefix(s) {
+				add(r)
+				for _, d := range derivations(r) {
+					add(d)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// trimSuffix removes the first of suffixes that word ends in, if enough of
+// the word is left.
+func trimSuffix(word string, suffixes []string) string {
+	for _, s := range suffixes {
+		if rest, ok := strings.CutSuffix(word, s); ok && vowels(rest) >= 2 {
+			return rest
+		}
+	}
+	return word
+}
+
+// derivations returns word without each derivational suffix it may carry:
+// "baikan" is "baik" with -an, "bacakan" is "baca" with -kan.
+func derivations(word string) []string {
+	var roots []string
+	for _, s := range suffixes {
+		if rest, ok := strings.CutSuffix(word, s); ok && vowels(rest) >= 2 {
+			roots = append(roots, rest)
+		}
+	}
+	return roots
+}
+
+// firstOrderPrefix returns the possible roots of a word with a me-, pe-,
+// di-, ter- or ke- prefix.
+func firstOrderPrefix(word string) []string {
+	// Longest prefixes first: "meng" before "men" before "me"
+	for _, p := range []string{"meng", "peng", "meny", "peny", "men", "pen", "mem", "pem"} {
+		rest, ok := strings.CutPrefix(word, p)
+		if !ok || rest == "" {
+			continue
+		}
+		if !startsWithVowel(rest) {
+			if strings.HasSuffix(p, "ny") {
+				continue
+			}
+			return []string{rest}
+		}
+		switch p[len(p)-2:] {
+		case "ng": // mengirim -> kirim, mengambil -> ambil
+			return []string{"k" + rest, rest}
+		case "ny": // menyapu -> sapu, menyanyi -> nyanyi
+			return []string{"s" + rest, "ny" + rest}
+		case "en": // menulis -> tulis, menanti -> nanti
+			return []string{"t" + rest, "n" + rest}
+		default: // memukul -> pukul, memakan -> makan
+			return []string{"p" + rest, "m" + rest}
+		}
+	}
+	if rest, ok := strings.CutPrefix(word, "me"); ok && rest != "" && !startsWithVowel(rest) {
+		// melihat -> lihat, merawat -> rawat
+		return []string{rest}
+	}
+	if rest, ok := strings.CutPrefix(word, "pe"); ok && rest != "" && !startsWithVowel(rest) &&
+		rest[0] != 'r' && rest[0] != 'l' {
+		// pekerja -> kerja; per- and pel- are second-order prefixes
+		return []string{rest}
+	}
+	for _, p := range []string{"di", "ter", "ke"} {
+		if rest, ok := strings.CutPrefix(word, p); ok {
+			return []string{rest}
+		}
+	}
+	return nil
+}
+
+// secondOrderPrefix returns the possible roots of a word with a be- or pe-
+// prefix left after the first-order prefix is gone.
+func secondOrderPrefix(word string) []string {
+	switch {
+	case strings.HasPrefix(word, "bel") || strings.HasPrefix(word, "pel"):
+		// belajar -> ajar, pelari -> lari
+		return []string{word[3:], word[2:]}
+	case strings.HasPrefix(word, "ber") || strings.HasPrefix(word, "per"):
+		return []string{word[3:]}
+	case strings.HasPrefix(word, "be") || strings.HasPrefix(word, "pe"):
+		return []string{word[2:]}
+	}
+	return nil
+}
+
+func startsWithVowel(s string) bool {
+	return s != "" && isVowel(s[0])
+}
+
+// vowels counts vowels, a rough count of syllables used to avoid cutting a
+// word down to a fragment.
+func vowels(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if isVowel(s[i]) {
+			n++
+		}
+	}
+	return n
+}
+
+func isVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u':
+		return true
+	}
+	return false
+}
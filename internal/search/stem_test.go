@@ -0,0 +1,66 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStems(t *testing.T) {
+	tests := []struct {
+		word string
+		root string
+	}{
+		{"menulis", "tulis"},
+		{"menanti", "nanti"},
+		{"mengirim", "kirim"},
+		{"mengambil", "ambil"},
+		{"menyapu", "sapu"},
+		{"membaca", "baca"},
+		{"memukul", "pukul"},
+		{"melihat", "lihat"},
+		{"dibacakan", "baca"},
+		{"tertulis", "tulis"},
+		{"bacaan", "baca"},
+		{"belajar", "ajar"},
+		{"pelajaran", "ajar"},
+		{"bermain", "main"},
+		{"perbaikan", "baik"},
+		{"pendidikan", "didik"},
+		{"kebersihan", "bersih"},
+		{"bukunya", "buku"},
+		{"bacalah", "baca"},
+	}
+	for _, tt := range tests {
+		if got := stems(tt.word); !slices.Contains(got, tt.root) {
+			t.Errorf("stems(%q) = %v, want it to include %q", tt.word, got, tt.root)
+		}
+	}
+}
+
+func TestStemsKeepShortRoots(t *testing.T) {
+	// Cutting these would leave a single syllable
+	for _, word := range []string{"makan", "merah", "kecil", "teras", "dinding", "buku", "2024"} {
+		if got := stems(word); len(got) != 1 || got[0] != word {
+			t.Errorf("stems(%q) = %v, want the word alone", word, got)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"pelangi", "pelangi", 0},
+		{"pelagi", "pelangi", 1},
+		{"plenagi", "pelangi", 2},
+		{"laksar", "laskar", 1},
+		{"buku", "bumi", 2},
+		{"a", "pelangi", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, 2); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
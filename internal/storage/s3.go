@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3Options configure an S3 client; see config.S3Config.
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	Client    *http.Client // http.DefaultClient when nil
+}
+
+// S3 keeps files as objects of a bucket in an S3 compatible store. Requests
+// are signed with AWS Signature Version 4.
+type S3 struct {
+	endpoint *url.URL
+	opts     S3Options
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(opts S3Options) (*S3, error) {
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("storage: invalid S3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("storage: no S3 bucket")
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3{endpoint: u, opts: opts, client: client, now: time.Now}, nil
+}
+
+// objectURL addresses key in the bucket, either as a path on the endpoint
+// or on the bucket's own host name. An empty key addresses the bucket.
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.opts.PathStyle {
+		u.Path = base + "/" + s.opts.Bucket + "/" + key
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = ""
+	return &u
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	if !ValidKey(key) {
+		return nil, fmt.Errorf("storage: invalid key %q", key)
+	}
+	return s.send(ctx, method, s.objectURL(key), body, contentType)
+}
+
+func (s *S3) send(ctx context.Context, method string, u *url.URL, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sign(req, body, s.now(), s.opts.Region, s.opts.AccessKey, s.opts.SecretKey)
+	return s.client.Do(req)
+}
+
+// s3Error is the body of a failed S3 request.
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func responseError(method, key string, resp *http.Response) error {
+	var e s3Error
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &e) != nil || e.Code == "" {
+		return fmt.Errorf("storage: S3 %s %s: %s", method, key, resp.Status)
+	}
+	return fmt.Errorf("storage: S3 %s %s: %s: %s", method, key, e.Code, e.Message)
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(http.MethodPut, key, resp)
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotExist
+	}
+	defer resp.Body.Close()
+	return nil, responseError(http.MethodGet, key, resp)
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, "")
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("storage: S3 HEAD %s: %s", key, resp.Status)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return responseError(http.MethodDelete, key, resp)
+	}
+	return nil
+}
+
+// listResult is the body of a ListObjectsV2 response.
+type listResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2, which returns keys in byte order.
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	query := url.Values{"list-type": {"2"}}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	for {
+		u := s.objectURL("")
+		u.RawQuery = canonicalQuery(query)
+		resp, err := s.send(ctx, http.MethodGet, u, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return nil, responseError(http.MethodGet, "?prefix="+prefix, resp)
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("storage: S3 list %s: %w", prefix, err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+const (
+	amzDate   = "20060102T150405Z"
+	algorithm = "AWS4-HMAC-SHA256"
+)
+
+// sign adds the Signature Version 4 headers to req. Host, the date and the
+// payload hash are signed, and Content-Type when set.
+func sign(req *http.Request, body []byte, t time.Time, region, accessKey, secretKey string) {
+	t = t.UTC()
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", t.Format(amzDate))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signed = append(signed, "content-type")
+		slices.Sort(signed)
+	}
+	scope := t.Format("20060102") + "/" + region + "/s3/aws4_request"
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, accessKey, scope, strings.Join(signed, ";"), signature(req, signed, t, region, secretKey)))
+}
+
+// signature computes the signature of req over the signed headers, which
+// must be lower case and sorted. The payload hash is taken from the
+// X-Amz-Content-Sha256 header.
+func signature(req *http.Request, signed []string, t time.Time, region, secretKey string) string {
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(uriEncode(req.URL.Path, false) + "\n")
+	canonical.WriteString(canonicalQuery(req.URL.Query()) + "\n")
+	for _, h := range signed {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		canonical.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical.WriteString("\n" + strings.Join(signed, ";") + "\n")
+	canonical.WriteString(req.Header.Get("X-Amz-Content-Sha256"))
+
+	date := t.Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical.String()))
+	toSign := algorithm + "\n" + t.Format(amzDate) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	var parts []string
+	for _, k := range keys {
+		values := slices.Clone(q[k])
+		slices.Sort(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, and
+// the slash unless encodeSlash is set, as Signature Version 4 wants.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
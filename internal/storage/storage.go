@@ -0,0 +1,66 @@
+// Package storage keeps uploaded files, such as book covers, behind one
+// interface with a local disk and an S3 compatible implementation. Files
+// are addressed by slash separated keys like "covers/4f2a.jpg".
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrNotExist is returned for a key that holds no file.
+var ErrNotExist = errors.New("storage: file does not exist")
+
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open returns ErrNotExist for a missing key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete succeeds for a key that is already missing.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// ValidKey reports whether key is a clean relative path, which every
+// implementation can store without escaping its root.
+func ValidKey(key string) bool {
+	return key != "" && key == path.Clean(key) && !path.IsAbs(key) &&
+		key != "." && key != ".." && !strings.HasPrefix(key, "../") && !strings.Contains(key, `\`)
+}
+
+// Handler serves the files of s, the request path being the key. Mount it
+// with http.StripPrefix.
+func Handler(s Storage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if !ValidKey(key) {
+			http.NotFound(w, r)
+			return
+		}
+		f, err := s.Open(r.Context(), key)
+		if errors.Is(err, ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "storage: open", "key", key, "error", err)
+			http.Error(w, "File tidak dapat dibaca", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		io.Copy(w, f)
+	})
+}
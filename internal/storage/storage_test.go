@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"simpus/internal/storage/storagetest"
+)
+
+// testStorage runs the behaviour every implementation shares.
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "covers/a.jpg", []byte("jpeg data"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Exists(ctx, "covers/a.jpg"); err != nil || !ok {
+		t.Errorf("Exists = %v, %v after Put", ok, err)
+	}
+	f, err := s.Open(ctx, "covers/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "jpeg data" {
+		t.Errorf("Open read %q", data)
+	}
+
+	// Replacing keeps the last version
+	if err := s.Put(ctx, "covers/a.jpg", []byte("newer"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	f, err = s.Open(ctx, "covers/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(f)
+	f.Close()
+	if string(data) != "newer" {
+		t.Errorf("Open read %q after replacing", data)
+	}
+
+	for _, key := range []string{"covers/b c.jpg", "labels/1.pdf"} {
+		if err := s.Put(ctx, key, []byte("x"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys, err := s.List(ctx, "covers/"); err != nil || !slices.Equal(keys, []string{"covers/a.jpg", "covers/b c.jpg"}) {
+		t.Errorf("List(covers/) = %v, %v", keys, err)
+	}
+	if keys, err := s.List(ctx, ""); err != nil || !slices.Equal(keys, []string{"covers/a.jpg", "covers/b c.jpg", "labels/1.pdf"}) {
+		t.Errorf("List() = %v, %v", keys, err)
+	}
+	if keys, err := s.List(ctx, "isbn/"); err != nil || len(keys) != 0 {
+		t.Errorf("List(isbn/) = %v, %v, want none", keys, err)
+	}
+	for _, key := range []string{"covers/b c.jpg", "labels/1.pdf"} {
+		if err := s.Delete(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Delete(ctx, "covers/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Exists(ctx, "covers/a.jpg"); err != nil || ok {
+		t.Errorf("Exists = %v, %v after Delete", ok, err)
+	}
+	if _, err := s.Open(ctx, "covers/a.jpg"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Open after Delete = %v, want ErrNotExist", err)
+	}
+	if err := s.Delete(ctx, "covers/a.jpg"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+
+	for _, key := range []string{"", "../x.jpg", "/etc/passwd", "covers/../../x", `covers\x`} {
+		if err := s.Put(ctx, key, []byte("x"), ""); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	testStorage(t, NewLocal(t.TempDir()))
+}
+
+func newFakeS3(t *testing.T) (*S3, *storagetest.FakeS3) {
+	fake := storagetest.NewFakeS3("simpus", "ap-southeast-3", "AKIDEXAMPLE", "secret")
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3(S3Options{
+		Endpoint: srv.URL, Region: "ap-southeast-3", Bucket: "simpus",
+		AccessKey: "AKIDEXAMPLE", SecretKey: "secret", PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, fake
+}
+
+func TestS3(t *testing.T) {
+	s, fake := newFakeS3(t)
+	testStorage(t, s)
+
+	if err := s.Put(context.Background(), "covers/b.png", []byte("png"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := fake.Keys(); !slices.Equal(keys, []string{"covers/b.png"}) {
+		t.Errorf("bucket holds %v", keys)
+	}
+}
+
+func TestS3ListPages(t *testing.T) {
+	s, fake := newFakeS3(t)
+	fake.MaxKeys = 2
+
+	var want []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		key := "covers/" + name + ".jpg"
+		if err := s.Put(context.Background(), key, []byte(name), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, key)
+	}
+	if keys, err := s.List(context.Background(), "covers/"); err != nil || !slices.Equal(keys, want) {
+		t.Errorf("List = %v, %v, want %v", keys, err, want)
+	}
+}
+
+func TestLocalListSkipsUnfinishedFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir)
+	if keys, err := NewLocal(dir+"/missing").List(context.Background(), ""); err != nil || len(keys) != 0 {
+		t.Errorf("List of a missing directory = %v, %v", keys, err)
+	}
+	if err := s.Put(context.Background(), "covers/a.jpg", []byte("x"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "covers", tempPrefix+"123"), []byte("half"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := s.List(context.Background(), ""); err != nil || !slices.Equal(keys, []string{"covers/a.jpg"}) {
+		t.Errorf("List = %v, %v", keys, err)
+	}
+}
+
+func TestS3WrongCredentials(t *testing.T) {
+	s, _ := newFakeS3(t)
+	s.opts.SecretKey = "wrong"
+
+	err := s.Put(context.Background(), "covers/a.jpg", []byte("x"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Put with a wrong secret = %v", err)
+	}
+	if _, err := s.Exists(context.Background(), "covers/a.jpg"); err == nil {
+		t.Error("Exists with a wrong secret succeeded")
+	}
+}
+
+func TestS3VirtualHostURL(t *testing.T) {
+	s, err := NewS3(S3Options{Endpoint: "https://s3.ap-southeast-3.amazonaws.com", Region: "ap-southeast-3", Bucket: "simpus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.objectURL("covers/a b.jpg").String(); got != "https://simpus.s3.ap-southeast-3.amazonaws.com/covers/a%20b.jpg" {
+		t.Errorf("objectURL = %s", got)
+	}
+}
+
+// TestSignature checks the GET Object example of the Signature Version 4
+// documentation.
+func TestSignature(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("X-Amz-Content-Sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	req.Header.Set("X-Amz-Date", "20130524T000000Z")
+	at := time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+
+	got := signature(req, []string{"host", "range", "x-amz-content-sha256", "x-amz-date"}, at,
+		"us-east-1", "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY")
+	if want := "f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41"; got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := NewLocal(t.TempDir())
+	if err := s.Put(context.Background(), "covers/a.png", []byte("\x89PNG"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	h := http.StripPrefix("/media/", Handler(s))
+
+	for path, want := range map[string]int{
+		"/media/covers/a.png":       http.StatusOK,
+		"/media/covers/missing.png": http.StatusNotFound,
+		"/media/covers/../a.png":    http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+		}
+		if want == http.StatusOK && rec.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("GET %s served as %s", path, rec.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package storagetest provides an in-memory S3 server for tests of the
+// storage backends and of the code storing files through them.
+package storagetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	amzDate   = "20060102T150405Z"
+	algorithm = "AWS4-HMAC-SHA256"
+)
+
+// FakeS3 is an in-memory stand-in for an S3 server. It serves one bucket,
+// addressed path style, and checks the signature of every request against
+// its credentials. The signature is computed here independently of the
+// storage package, so a signing mistake in the client fails its tests.
+type FakeS3 struct {
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// MaxKeys is the page size of object listings, 1000 when zero.
+	MaxKeys int
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func NewFakeS3(bucket, region, accessKey, secretKey string) *FakeS3 {
+	return &FakeS3{Bucket: bucket, Region: region, AccessKey: accessKey, SecretKey: secretKey, objects: map[string]fakeObject{}}
+}
+
+// Keys lists the stored objects.
+func (f *FakeS3) Keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (f *FakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if code, msg := f.authorize(r); code != "" {
+		f.fail(w, http.StatusForbidden, code, msg)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.Bucket {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			f.fail(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != r.Header.Get("X-Amz-Content-Sha256") {
+			f.fail(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.")
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet, http.MethodHead:
+		if key == "" && r.URL.Query().Get("list-type") == "2" {
+			f.list(w, r.URL.Query())
+			return
+		}
+		obj, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed")
+	}
+}
+
+// list answers a ListObjectsV2 request. The continuation token is the
+// last key of the previous page.
+func (f *FakeS3) list(w http.ResponseWriter, q url.Values) {
+	maxKeys := f.MaxKeys
+	if maxKeys == 0 {
+		maxKeys = 1000
+	}
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, q.Get("prefix")) && k > q.Get("continuation-token") {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	type content struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Name                  string    `xml:"Name"`
+		Prefix                string    `xml:"Prefix"`
+		KeyCount              int       `xml:"KeyCount"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+		Contents              []content `xml:"Contents"`
+	}{Name: f.Bucket, Prefix: q.Get("prefix")}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		result.Contents = append(result.Contents, content{Key: k, Size: len(f.objects[k].data)})
+	}
+	result.KeyCount = len(keys)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// authorize checks the Signature Version 4 Authorization header, returning
+// the S3 error code when it does not hold.
+func (f *FakeS3) authorize(r *http.Request) (code, message string) {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), algorithm+" ")
+	if !ok {
+		return "AccessDenied", "Access Denied"
+	}
+	params := map[string]string{}
+	for _, p := range strings.Split(auth, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		params[k] = v
+	}
+	scope := strings.Split(params["Credential"], "/")
+	if len(scope) != 5 || scope[0] != f.AccessKey {
+		return "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records."
+	}
+	if scope[2] != f.Region {
+		return "AuthorizationHeaderMalformed", "the region '" + scope[2] + "' is wrong; expecting '" + f.Region + "'"
+	}
+	t, err := time.Parse(amzDate, r.Header.Get("X-Amz-Date"))
+	if err != nil || t.Format("20060102") != scope[1] {
+		return "AccessDenied", "invalid X-Amz-Date"
+	}
+	if f.signature(r, strings.Split(params["SignedHeaders"], ";"), t) != params["Signature"] {
+		return "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided."
+	}
+	return "", ""
+}
+
+func (f *FakeS3) fail(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
+
+// signature computes the Signature Version 4 of r over the signed headers,
+// taking the payload hash from the X-Amz-Content-Sha256 header.
+func (f *FakeS3) signature(r *http.Request, signed []string, t time.Time) string {
+	headers := make([]string, len(signed))
+	for i, h := range signed {
+		value := r.Header.Get(h)
+		if h == "host" {
+			value = r.Host
+		}
+		headers[i] = h + ":" + strings.TrimSpace(value) + "\n"
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		escape(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		strings.Join(headers, ""),
+		strings.Join(signed, ";"),
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	date := t.Format("20060102")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{algorithm, t.Format(amzDate), date + "/" + f.Region + "/s3/aws4_request", hex.EncodeToString(hash[:])}, "\n")
+
+	key := []byte("AWS4" + f.SecretKey)
+	for _, part := range []string{date, f.Region, "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return hex.EncodeToString(key)
+}
+
+func canonicalQuery(q url.Values) string {
+	var parts []string
+	for k, values := range q {
+		for _, v := range values {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, "&")
+}
+
+// escape percent-encodes all but the unreserved characters, and the slash
+// unless escapeSlash is set.
+func escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~", c) >= 0 || c == '/' && !escapeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
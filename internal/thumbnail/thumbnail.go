@@ -0,0 +1,57 @@
+// Package thumbnail scales images down for display. Each pixel of a
+// thumbnail averages the source pixels it covers, which keeps text and fine
+// lines on book covers readable.
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+// Resize scales src down to width, keeping its aspect ratio, onto a white
+// background. An image no wider than width keeps its size.
+func Resize(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, b.Min, draw.Over)
+	if b.Dx() <= width || width <= 0 {
+		return flat
+	}
+
+	sw, sh := b.Dx(), b.Dy()
+	dw := width
+	dh := max(1, (sh*dw+sw/2)/sw)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, bl, n int
+			for sy := y0; sy < y1; sy++ {
+				row := flat.Pix[sy*flat.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+3]
+					r += int(p[0])
+					g += int(p[1])
+					bl += int(p[2])
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), 0xff})
+		}
+	}
+	return dst
+}
+
+// JPEG returns src resized to width and encoded as a JPEG.
+func JPEG(src image.Image, width, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, Resize(src, width), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
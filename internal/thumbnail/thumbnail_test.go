@@ -0,0 +1,60 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	// Left half black, right half transparent
+	src := image.NewNRGBA(image.Rect(10, 10, 410, 610))
+	for y := 10; y < 610; y++ {
+		for x := 10; x < 210; x++ {
+			src.Set(x, y, color.Black)
+		}
+	}
+
+	got := Resize(src, 100)
+	if b := got.Bounds(); b.Dx() != 100 || b.Dy() != 150 {
+		t.Fatalf("resized to %v, want 100x150", b)
+	}
+	if c := got.RGBAAt(10, 75); c != (color.RGBA{0, 0, 0, 0xff}) {
+		t.Errorf("left pixel = %v, want black", c)
+	}
+	if c := got.RGBAAt(90, 75); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("right pixel = %v, want white", c)
+	}
+
+	// Never enlarged
+	if b := Resize(src, 1000).Bounds(); b.Dx() != 400 || b.Dy() != 600 {
+		t.Errorf("small image resized to %v", b)
+	}
+
+	// Averages the pixels it covers
+	stripes := image.NewGray(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		stripes.SetGray(0, y, color.Gray{200})
+		stripes.SetGray(2, y, color.Gray{100})
+	}
+	if c := Resize(stripes, 2).RGBAAt(0, 0); c.R != 100 {
+		t.Errorf("averaged pixel = %v, want 100", c)
+	}
+}
+
+func TestJPEG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 300, 450))
+	data, err := JPEG(src, 120, 85)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 120 || cfg.Height != 180 {
+		t.Errorf("thumbnail is %dx%d, want 120x180", cfg.Width, cfg.Height)
+	}
+}
@@ -0,0 +1,378 @@
+// Package xlsx reads and writes the cell text of simple Office Open XML
+// workbooks. It knows nothing of styles, formulas or dates: reading returns
+// what the cells hold and writing produces a single sheet of text cells.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoSheet is returned for a workbook without worksheets.
+var ErrNoSheet = errors.New("xlsx: workbook has no worksheet")
+
+// maxPartSize bounds each part read from the archive, so a small upload
+// cannot expand into gigabytes of XML.
+const maxPartSize = 64 << 20
+
+// Read returns the rows of the first worksheet. Cells are returned as text;
+// rows are padded so that a cell keeps its column index, but trailing empty
+// cells are dropped.
+func Read(r io.ReaderAt, size int64) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if f := files["xl/sharedStrings.xml"]; f != nil {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+	return readSheet(sheet, shared)
+}
+
+// firstSheet finds the part of the first sheet listed in the workbook.
+func firstSheet(files map[string]*zip.File) (*zip.File, error) {
+	var wb struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if f := files["xl/workbook.xml"]; f != nil {
+		if err := decodePart(f, &wb); err != nil {
+			return nil, err
+		}
+	}
+	if f := files["xl/_rels/workbook.xml.rels"]; f != nil {
+		if err := decodePart(f, &rels); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(wb.Sheets) > 0 {
+		for _, rel := range rels.Rels {
+			if rel.ID != wb.Sheets[0].ID {
+				continue
+			}
+			name := strings.TrimPrefix(rel.Target, "/")
+			if !strings.HasPrefix(rel.Target, "/") {
+				name = path.Join("xl", rel.Target)
+			}
+			if f := files[name]; f != nil {
+				return f, nil
+			}
+		}
+	}
+	if f := files["xl/worksheets/sheet1.xml"]; f != nil {
+		return f, nil
+	}
+	return nil, ErrNoSheet
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := decodePart(f, &sst); err != nil {
+		return nil, err
+	}
+	shared := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		text := si.T
+		for _, r := range si.Runs {
+			text += r.T
+		}
+		shared[i] = text
+	}
+	return shared, nil
+}
+
+type cell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"is"`
+}
+
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+	var ws struct {
+		Rows []struct {
+			Ref   int    `xml:"r,attr"`
+			Cells []cell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodePart(f, &ws); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, row := range ws.Rows {
+		// Rows left out of the file are empty rows
+		for row.Ref > len(rows)+1 {
+			rows = append(rows, nil)
+		}
+		var values []string
+		for _, c := range row.Cells {
+			col := len(values)
+			if c.Ref != "" {
+				if col = column(c.Ref); col < 0 {
+					return nil, fmt.Errorf("xlsx: bad cell reference %q", c.Ref)
+				}
+			}
+			text, err := c.text(shared)
+			if err != nil {
+				return nil, err
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			values[col] = text
+		}
+		for len(values) > 0 && values[len(values)-1] == "" {
+			values = values[:len(values)-1]
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func (c cell) text(shared []string) (string, error) {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(c.Value))
+		if err != nil || i < 0 || i >= len(shared) {
+			return "", fmt.Errorf("xlsx: cell %s refers to a missing shared string", c.Ref)
+		}
+		return shared[i], nil
+	case "inlineStr":
+		text := c.Inline.T
+		for _, r := range c.Inline.Runs {
+			text += r.T
+		}
+		return text, nil
+	case "b":
+		if c.Value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		return number(c.Value), nil
+	}
+	return c.Value, nil
+}
+
+// number prints a numeric cell the way a spreadsheet shows it by default:
+// whole numbers such as ISBNs and years without exponent or decimals.
+func number(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	if f == float64(int64(f)) && f < 1e18 && f > -1e18 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// column returns the zero-based column of a cell reference such as "AB12".
+func column(ref string) int {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return -1
+	}
+	return col - 1
+}
+
+func decodePart(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("xlsx: %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// Write writes rows as a workbook with one sheet of text cells. The first
+// row is frozen and bold, as it is expected to hold the column names.
+func Write(w io.Writer, sheet string, rows [][]string) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+	parts := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, escape(sheetName(sheet)))},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+	for _, p := range parts {
+		pw, err := create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(pw, p.data); err != nil {
+			return err
+		}
+	}
+
+	pw, err := create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if err := writeSheet(pw, rows); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeSheet(w io.Writer, rows [][]string) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	buf.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	buf.WriteString(`<sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&buf, `<row r="%d">`, i+1)
+		for j, v := range row {
+			style := ""
+			if i == 0 {
+				style = ` s="1"`
+			}
+			fmt.Fprintf(&buf, `<c r="%s%d" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`,
+				columnName(j), i+1, style, escape(v))
+		}
+		buf.WriteString(`</row>`)
+		// Flush every so often so large exports stream
+		if buf.Len() > 1<<16 {
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+	}
+	buf.WriteString(`</sheetData></worksheet>`)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// columnName returns the letters of a zero-based column: 0 is A, 26 is AA.
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// escape escapes text for XML, dropping the control characters XML 1.0
+// cannot carry.
+func escape(s string) string {
+	var buf bytes.Buffer
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// sheetName trims a name to what spreadsheets accept: at most 31 characters
+// and none of []:*?/\.
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+const contentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const workbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// styles holds the default cell format and a bold one for the header.
+const styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
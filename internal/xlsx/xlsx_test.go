@@ -0,0 +1,99 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	rows := [][]string{
+		{"isbn", "judul", "catatan"},
+		{"978-0-13-235088-4", "Clean Code", "<kode> & \"bersih\""},
+		{"", "Laskar Pelangi"},
+		{"9786020332956", "  Bumi  ", "baris\nkedua"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Katalog", rows); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("Read = %q, want %q", got, rows)
+	}
+}
+
+// A workbook as a spreadsheet program saves it: shared and rich-text
+// strings, numbers, booleans, skipped cells and rows, and a sheet part that
+// is not named sheet1.xml.
+func TestReadSpreadsheet(t *testing.T) {
+	parts := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Buku" sheetId="3" r:id="rId7"/><sheet name="Lain" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId7" Target="/xl/worksheets/buku.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>judul</t></si><si><t>tahun</t></si><si><r><t>Bumi </t></r><r><rPr><b/></rPr><t>Manusia</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>salah</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/buku.xml": `<?xml version="1.0"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3" t="b"><v>1</v></c><c r="C3"><v>1980</v></c><c r="D3"><v>9.780978973123E+12</v></c><c r="E3"/></row>
+<row r="4"><c r="C4"><v>2.5</v></c></row>
+</sheetData></worksheet>`,
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"judul", "", "tahun"},
+		nil,
+		{"Bumi Manusia", "TRUE", "1980", "9780978973123"},
+		{"", "", "2.5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %q, want %q", got, want)
+	}
+}
+
+func TestReadNotWorkbook(t *testing.T) {
+	data := []byte("isbn,judul\n")
+	if _, err := Read(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Read of a CSV file succeeded")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for col, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(col); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", col, got, want)
+		}
+		if got := column(want + "12"); got != col {
+			t.Errorf("column(%q) = %d, want %d", want+"12", got, col)
+		}
+	}
+}